@@ -16,17 +16,30 @@ limitations under the License.
 package main
 
 import (
+	"SLALite/assessment/notifier"
+	"SLALite/assessment/notifier/sse"
+	"SLALite/auth"
 	"SLALite/generator"
+	"SLALite/health"
+	"SLALite/metrics"
 	"SLALite/model"
+	"SLALite/repositories/authorization"
 	"SLALite/utils"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/spf13/viper"
 )
@@ -41,6 +54,18 @@ const (
 	enableSslPropertyName   = "enableSsl"
 	sslCertPathPropertyName = "sslCertPath"
 	sslKeyPathPropertyName  = "sslKeyPath"
+
+	// totalCountHeader contains the total number of items in paged lists
+	totalCountHeader = "X-Total-Count"
+
+	// tenantHeader selects the tenant of a request (see App.tenant)
+	tenantHeader = "X-Tenant-ID"
+
+	// readinessTimeout is the maximum time to wait for the checks of the readiness probe
+	readinessTimeout = 5 * time.Second
+
+	// shutdownTimeout is the maximum time to wait for the requests in progress on shutdown
+	shutdownTimeout = 10 * time.Second
 )
 
 // App is a main application "object", to be built by main and testmain
@@ -52,14 +77,22 @@ type App struct {
 	SslEnabled  bool
 	SslCertPath string
 	SslKeyPath  string
+	Notifier    notifier.StateNotifier
+	Events      *sse.Broker
+	Checks      health.Checks
 	externalIDs bool
 	validator   model.Validator
+
+	// Authenticator authenticates the callers of the API; nil if authentication is disabled
+	Authenticator auth.Authenticator
 }
 
 // ApiError is the struct sent to client on errors
 type ApiError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+	// Parameters contains the error of each invalid template parameter, if any
+	Parameters map[string]string `json:"parameters,omitempty"`
 }
 
 func (e *ApiError) Error() string {
@@ -80,16 +113,31 @@ type endpoint struct {
 }
 
 var api = map[string]endpoint{
-	"providers":  endpoint{"GET", "/providers", "Providers"},
-	"agreements": endpoint{"GET", "/agreements", "Agreements"},
-	"templates":  endpoint{"GET", "/templates", "Templates"},
+	"providers":     endpoint{"GET", "/providers", "Providers"},
+	"agreements":    endpoint{"GET", "/agreements", "Agreements"},
+	"templates":     endpoint{"GET", "/templates", "Templates"},
+	"violations":    endpoint{"GET", "/violations", "Violations"},
+	"subscriptions": endpoint{"GET", "/subscriptions", "Subscriptions"},
+	"events":        endpoint{"GET", "/events", "Stream of assessment events"},
+	"metrics":       endpoint{"GET", "/metrics", "Prometheus metrics"},
+	"healthz":       endpoint{"GET", "/healthz", "Liveness probe"},
+	"readyz":        endpoint{"GET", "/readyz", "Readiness probe"},
 }
 
-func NewApp(config *viper.Viper, repository model.IRepository, validator model.Validator) (App, error) {
+// NewApp creates the REST API. stateNotifier, if not nil, is notified of the changes
+// of state of agreements made through the API. events, if not nil, is streamed on /events.
+// checks are the components checked by the readiness probe.
+func NewApp(config *viper.Viper, repository model.IRepository, validator model.Validator,
+	stateNotifier notifier.StateNotifier, events *sse.Broker, checks health.Checks) (App, error) {
 
 	setDefaults(config)
 	logConfig(config)
 
+	authenticator, err := auth.New(config)
+	if err != nil {
+		return App{}, err
+	}
+
 	a := App{
 		Port:        config.GetString(portPropertyName),
 		SslEnabled:  config.GetBool(enableSslPropertyName),
@@ -97,6 +145,11 @@ func NewApp(config *viper.Viper, repository model.IRepository, validator model.V
 		SslKeyPath:  config.GetString(sslKeyPathPropertyName),
 		externalIDs: config.GetBool(utils.ExternalIDsPropertyName),
 		validator:   validator,
+		Notifier:    stateNotifier,
+		Events:      events,
+		Checks:      checks,
+
+		Authenticator: authenticator,
 	}
 
 	a.initialize(repository)
@@ -136,43 +189,102 @@ func (a *App) initialize(repository model.IRepository) {
 	a.Router = mux.NewRouter().StrictSlash(true)
 
 	a.Router.HandleFunc("/", a.Index).Methods("GET")
+	a.Router.Methods("GET").Path("/metrics").Handler(metrics.Handler())
+	a.Router.Methods("GET").Path("/healthz").HandlerFunc(a.Healthz)
+	a.Router.Methods("GET").Path("/readyz").HandlerFunc(a.Readyz)
+
+	a.Router.Methods("GET").Path("/providers").Handler(a.protected(a.GetAllProviders))
+
+	a.Router.Methods("GET").Path("/providers/{id}").Handler(a.protected(a.GetProvider))
+	a.Router.Methods("POST").Path("/providers").Handler(a.admin(a.CreateProvider))
+	a.Router.Methods("DELETE").Path("/providers/{id}").Handler(a.admin(a.DeleteProvider))
+
+	a.Router.Methods("GET").Path("/agreements").Handler(a.protected(a.GetAgreements))
+	a.Router.Methods("GET").Path("/agreements/{id}").Handler(a.protected(a.GetAgreement))
+	a.Router.Methods("POST").Path("/agreements").Handler(a.protected(a.CreateAgreement))
+	a.Router.Methods("PUT").Path("/agreements/{id}/start").Handler(a.protected(a.StartAgreement))
+	a.Router.Methods("PUT").Path("/agreements/{id}/stop").Handler(a.protected(a.StopAgreement))
+	a.Router.Methods("PUT").Path("/agreements/{id}/terminate").Handler(a.admin(a.TerminateAgreement))
+	a.Router.Methods("PUT").Path("/agreements/{id}").Handler(a.protected(a.UpdateAgreement))
+	a.Router.Methods("DELETE").Path("/agreements/{id}").Handler(a.admin(a.DeleteAgreement))
+	a.Router.Methods("GET").Path("/agreements/{id}/details").Handler(a.protected(a.GetAgreementDetails))
+	a.Router.Methods("GET").Path("/agreements/{id}/violations").Handler(a.protected(a.GetAgreementViolations))
+	a.Router.Methods("GET").Path("/agreements/{id}/warnings").Handler(a.protected(a.GetAgreementWarnings))
+	a.Router.Methods("GET").Path("/agreements/{id}/penalties").Handler(a.protected(a.GetAgreementPenalties))
+
+	a.Router.Methods("GET").Path("/violations").Handler(a.protected(a.GetViolations))
+	a.Router.Methods("GET").Path("/violations/{id}").Handler(a.protected(a.GetViolation))
+
+	a.Router.Methods("GET").Path("/subscriptions").Handler(a.protected(a.GetSubscriptions))
+	a.Router.Methods("GET").Path("/subscriptions/{id}").Handler(a.protected(a.GetSubscription))
+	a.Router.Methods("POST").Path("/subscriptions").Handler(a.protected(a.CreateSubscription))
+	a.Router.Methods("PUT").Path("/subscriptions/{id}").Handler(a.protected(a.UpdateSubscription))
+	a.Router.Methods("DELETE").Path("/subscriptions/{id}").Handler(a.protected(a.DeleteSubscription))
+
+	if a.Events != nil {
+		a.Router.Methods("GET").Path("/events").Handler(a.protected(a.GetEvents))
+	}
 
-	a.Router.Methods("GET").Path("/providers").Handler(logger(a.GetAllProviders))
-
-	a.Router.Methods("GET").Path("/providers/{id}").Handler(logger(a.GetProvider))
-	a.Router.Methods("POST").Path("/providers").Handler(logger(a.CreateProvider))
-	a.Router.Methods("DELETE").Path("/providers/{id}").Handler(logger(a.DeleteProvider))
-
-	a.Router.Methods("GET").Path("/agreements").Handler(logger(a.GetAgreements))
-	a.Router.Methods("GET").Path("/agreements/{id}").Handler(logger(a.GetAgreement))
-	a.Router.Methods("POST").Path("/agreements").Handler(logger(a.CreateAgreement))
-	a.Router.Methods("PUT").Path("/agreements/{id}/start").Handler(logger(a.StartAgreement))
-	a.Router.Methods("PUT").Path("/agreements/{id}/stop").Handler(logger(a.StopAgreement))
-	a.Router.Methods("PUT").Path("/agreements/{id}/terminate").Handler(logger(a.TerminateAgreement))
-	a.Router.Methods("PUT").Path("/agreements/{id}").Handler(logger(a.UpdateAgreement))
-	a.Router.Methods("DELETE").Path("/agreements/{id}").Handler(logger(a.DeleteAgreement))
-	a.Router.Methods("GET").Path("/agreements/{id}/details").Handler(logger(a.GetAgreementDetails))
-
-	a.Router.Methods("GET").Path("/templates").Handler(logger(a.GetTemplates))
-	a.Router.Methods("GET").Path("/templates/{id}").Handler(logger(a.GetTemplate))
-	a.Router.Methods("POST").Path("/templates").Handler(logger(a.CreateTemplate))
+	a.Router.Methods("GET").Path("/templates").Handler(a.protected(a.GetTemplates))
+	a.Router.Methods("GET").Path("/templates/{id}").Handler(a.protected(a.GetTemplate))
+	a.Router.Methods("GET").Path("/templates/{id}/versions").Handler(a.protected(a.GetTemplateVersions))
+	a.Router.Methods("POST").Path("/templates/{id}/preview").Handler(a.protected(a.PreviewAgreement))
+	a.Router.Methods("POST").Path("/templates").Handler(a.admin(a.CreateTemplate))
+	a.Router.Methods("PUT").Path("/templates/{id}/start").Handler(a.admin(a.StartTemplate))
+	a.Router.Methods("PUT").Path("/templates/{id}/stop").Handler(a.admin(a.StopTemplate))
+	a.Router.Methods("PUT").Path("/templates/{id}").Handler(a.admin(a.UpdateTemplate))
+	a.Router.Methods("DELETE").Path("/templates/{id}").Handler(a.admin(a.DeleteTemplate))
 
-	a.Router.Methods("POST").Path("/create-agreement").Handler(logger(a.CreateAgreementFromTemplate))
+	a.Router.Methods("POST").Path("/create-agreement").Handler(a.protected(a.CreateAgreementFromTemplate))
 
 	a.Router.Methods("POST").Path("/mf2c/create-agreement").
-		Handler(logger(a.Mf2cCreateAgreementFromTemplate))
+		Handler(a.protected(a.Mf2cCreateAgreementFromTemplate))
+
+}
 
+// Run starts the REST API on the configured port and serves it until ctx is done (see Serve)
+func (a *App) Run(ctx context.Context) error {
+	l, err := net.Listen("tcp", ":"+a.Port)
+	if err != nil {
+		return err
+	}
+	return a.Serve(ctx, l)
 }
 
-// Run starts the REST API
-func (a *App) Run() {
-	addr := ":" + a.Port
+// Serve serves the REST API on l until ctx is done or an error occurs.
+//
+// When ctx is done, the server stops accepting connections, the event streams are
+// closed and the requests in progress are waited up to shutdownTimeout. Serve returns
+// nil after a graceful shutdown.
+func (a *App) Serve(ctx context.Context, l net.Listener) error {
+	streams, closeStreams := context.WithCancel(context.Background())
+	defer closeStreams()
+
+	server := &http.Server{
+		Handler:     a.Router,
+		BaseContext: func(net.Listener) context.Context { return streams },
+	}
+	server.RegisterOnShutdown(closeStreams)
+
+	errs := make(chan error, 1)
+	go func() {
+		if a.SslEnabled {
+			errs <- server.ServeTLS(l, a.SslCertPath, a.SslKeyPath)
+		} else {
+			errs <- server.Serve(l)
+		}
+	}()
 
-	if a.SslEnabled {
-		log.Fatal(http.ListenAndServeTLS(addr, a.SslCertPath, a.SslKeyPath, a.Router))
-	} else {
-		log.Fatal(http.ListenAndServe(addr, a.Router))
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
 	}
+
+	log.Info("Shutting down REST API")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
 }
 
 // Index is the API index
@@ -194,30 +306,187 @@ func (a *App) Index(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(api)
 }
 
-func logger(f func(w http.ResponseWriter, r *http.Request)) http.Handler {
-	return loggerDecorator(http.HandlerFunc(f))
+// protected returns the handler of an operation that needs authentication
+func (a *App) protected(f func(w http.ResponseWriter, r *http.Request)) http.Handler {
+	return loggerDecorator(a.authenticate(a.tenant(http.HandlerFunc(f))))
+}
+
+// authenticate rejects with 401 the requests without valid credentials, and passes the
+// caller to inner in the request context (see auth.FromContext). All the requests are
+// passed if authentication is disabled.
+func (a *App) authenticate(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Authenticator == nil {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		p, err := a.Authenticator.Authenticate(r)
+		if err == auth.ErrNoCredentials {
+			respondWithError(w, http.StatusUnauthorized, "Authentication required")
+			return
+		} else if err != nil {
+			log.Debugf("Authentication failed: %v", err)
+			respondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		inner.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), p)))
+	})
+}
+
+// admin returns the handler of an operation that only admins are allowed to perform
+func (a *App) admin(f func(w http.ResponseWriter, r *http.Request)) http.Handler {
+	return loggerDecorator(a.authenticate(a.authorize(a.tenant(http.HandlerFunc(f)), auth.ADMIN)))
+}
+
+// authorize rejects with 403 the requests of authenticated callers that have none of
+// the roles. All the requests are passed if authentication is disabled.
+func (a *App) authorize(inner http.Handler, roles ...auth.Role) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p, ok := auth.FromContext(r.Context()); ok {
+			allowed := false
+			for _, role := range roles {
+				allowed = allowed || p.HasRole(role)
+			}
+			if !allowed {
+				respondWithError(w, http.StatusForbidden, auth.ErrForbidden.Error())
+				return
+			}
+		}
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// tenantKey and repositoryKey are the keys of the tenant of a request and its repository
+// in the request context
+type tenantKey struct{}
+type repositoryKey struct{}
+
+// tenant resolves the tenant of the requests and passes it to inner in the request
+// context, along with the repository of the tenant (see model.ForTenant).
+//
+// The tenant is the one of the caller. Admins without a tenant, and every request if
+// authentication is disabled, may select it with the tenantHeader header. The requests
+// for other tenants are rejected with 403; for invalid tenants, with 400.
+func (a *App) tenant(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get(tenantHeader)
+		if p, ok := auth.FromContext(r.Context()); ok && (p.Tenant != "" || !p.HasRole(auth.ADMIN)) {
+			if tenant != "" && tenant != p.Tenant {
+				respondWithError(w, http.StatusForbidden, fmt.Sprintf("Tenant '%s' not allowed", tenant))
+				return
+			}
+			tenant = p.Tenant
+		}
+		repo, err := model.ForTenant(a.Repository, tenant)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ctx := context.WithValue(r.Context(), tenantKey{}, tenant)
+		ctx = context.WithValue(ctx, repositoryKey{}, repo)
+		inner.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// repository returns the repository of the tenant of r, restricted to the caller of r
+// (see authorization.New) if authentication is enabled
+func (a *App) repository(r *http.Request) model.IRepository {
+	repo, ok := r.Context().Value(repositoryKey{}).(model.IRepository)
+	if !ok {
+		repo = a.Repository
+	}
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return authorization.New(repo, p)
+	}
+	return repo
 }
 
 func loggerDecorator(inner http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
 
-		inner.ServeHTTP(w, r)
+		inner.ServeHTTP(sw, r)
 
+		elapsed := time.Since(start)
 		log.Printf(
 			"%s\t%s\t\t%s",
 			r.Method,
 			r.RequestURI,
-			time.Since(start),
+			elapsed,
 		)
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(sw.status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, route).Observe(elapsed.Seconds())
 	})
 }
 
-func (a *App) getAll(w http.ResponseWriter, r *http.Request, f func() (interface{}, error)) {
-	list, err := f()
+// statusWriter is a ResponseWriter that keeps the status code of the response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush implements http.Flusher, needed by the event streams
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Healthz is the liveness probe
+// swagger:operation GET /healthz healthz
+//
+// Returns 200 while the SLALite is running.
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: The SLALite is running
+func (a *App) Healthz(w http.ResponseWriter, r *http.Request) {
+	respondSuccessJSON(w, health.Status{Status: health.UP})
+}
+
+// Readyz is the readiness probe
+// swagger:operation GET /readyz readyz
+//
+// Checks the components the SLALite depends on (e.g., the repository), returning
+// the status of each component.
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: All the components are available
+//   '503':
+//     description: Some component is not available
+func (a *App) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := a.Checks.Run(readinessTimeout)
+	code := http.StatusOK
+	if report.Status != health.UP {
+		code = http.StatusServiceUnavailable
+	}
+	respondWithJSON(w, code, report)
+}
+
+// Gets a page of a list, setting the total number of items in the X-Total-Count header
+func (a *App) getPage(w http.ResponseWriter, r *http.Request, f func() (interface{}, int, error)) {
+	list, total, err := f()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		manageError(err, w)
 	} else {
+		w.Header().Set(totalCountHeader, strconv.Itoa(total))
 		respondSuccessJSON(w, list)
 	}
 }
@@ -286,21 +555,44 @@ func (a *App) update(w http.ResponseWriter, r *http.Request, upd func(string) er
 // GetAllProviders return all providers in db
 // swagger:operation GET /providers getAllProviders
 //
-// Returns all registered providers
+// Returns the registered providers. The total number of providers
+// is returned in the X-Total-Count header.
 //
 // ---
 // produces:
 // - application/json
+// parameters:
+// - name: sort
+//   in: query
+//   description: Comma-separated list of fields (id, name) to sort by; '-' before a field sorts in descending order
+//   required: false
+//   type: string
+// - name: offset
+//   in: query
+//   description: Number of providers to skip
+//   required: false
+//   type: integer
+// - name: limit
+//   in: query
+//   description: Maximum number of providers to return
+//   required: false
+//   type: integer
 // responses:
 //   '200':
-//     description: The complete list of registered providers
+//     description: The page of registered providers
 //     schema:
-//       type: object
-//       additionalProperties:
-//         "$ref": "#/definitions/Providers"
+//       "$ref": "#/definitions/Providers"
+//   '400' :
+//     description: Invalid query parameters
 func (a *App) GetAllProviders(w http.ResponseWriter, r *http.Request) {
-	a.getAll(w, r, func() (interface{}, error) {
-		return a.Repository.GetAllProviders()
+	q, err := parseProviderQuery(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a.getPage(w, r, func() (interface{}, int, error) {
+		return a.repository(r).GetProviders(q)
 	})
 }
 
@@ -327,7 +619,7 @@ func (a *App) GetAllProviders(w http.ResponseWriter, r *http.Request) {
 //     description: Provider not found
 func (a *App) GetProvider(w http.ResponseWriter, r *http.Request) {
 	a.get(w, r, func(id string) (interface{}, error) {
-		return a.Repository.GetProvider(id)
+		return a.repository(r).GetProvider(id)
 	})
 }
 
@@ -362,7 +654,7 @@ func (a *App) CreateProvider(w http.ResponseWriter, r *http.Request) {
 			return json.NewDecoder(r.Body).Decode(&provider)
 		},
 		func() (model.Identity, error) {
-			return a.Repository.CreateProvider(&provider)
+			return a.repository(r).CreateProvider(&provider)
 		})
 }
 
@@ -387,34 +679,96 @@ func (a *App) CreateProvider(w http.ResponseWriter, r *http.Request) {
 //     description: Provider not found
 func (a *App) DeleteProvider(w http.ResponseWriter, r *http.Request) {
 	a.update(w, r, func(id string) error {
-		return a.Repository.DeleteProvider(&model.Provider{Id: id})
+		return a.repository(r).DeleteProvider(&model.Provider{Id: id})
 	})
 }
 
 // GetAgreements return all agreements in db
 // swagger:operation GET /agreements getAllAgreements
 //
-// Returns all registered agreements
+// Returns the registered agreements that match the query parameters.
+// The total number of matching agreements is returned in the X-Total-Count header.
 //
 // ---
 // produces:
 // - application/json
+// parameters:
+// - name: provider
+//   in: query
+//   description: The identifier of the provider of the agreements
+//   required: false
+//   type: string
+// - name: client
+//   in: query
+//   description: The identifier of the client of the agreements
+//   required: false
+//   type: string
+// - name: state
+//   in: query
+//   description: Returns agreements in any of these states (comma-separated or repeated)
+//   required: false
+//   type: string
+// - name: active
+//   in: query
+//   description: If not empty, returns only started agreements. Cannot be used with state
+//   required: false
+//   type: string
+// - name: creation_from
+//   in: query
+//   description: Returns agreements created at or after this datetime (RFC3339)
+//   required: false
+//   type: string
+//   format: date-time
+// - name: creation_to
+//   in: query
+//   description: Returns agreements created before this datetime (RFC3339)
+//   required: false
+//   type: string
+//   format: date-time
+// - name: expiration_from
+//   in: query
+//   description: Returns agreements that expire at or after this datetime (RFC3339)
+//   required: false
+//   type: string
+//   format: date-time
+// - name: expiration_to
+//   in: query
+//   description: Returns agreements that expire before this datetime (RFC3339)
+//   required: false
+//   type: string
+//   format: date-time
+// - name: sort
+//   in: query
+//   description: Comma-separated list of fields (id, name, state, provider, client, creation, expiration)
+//     to sort by; '-' before a field sorts in descending order
+//   required: false
+//   type: string
+// - name: offset
+//   in: query
+//   description: Number of agreements to skip
+//   required: false
+//   type: integer
+// - name: limit
+//   in: query
+//   description: Maximum number of agreements to return
+//   required: false
+//   type: integer
 // responses:
 //   '200':
-//     description: The complete list of registered agreements
+//     description: The page of agreements that match the query
 //     schema:
-//       type: object
-//       additionalProperties:
-//         "$ref": "#/definitions/Agreements"
+//       "$ref": "#/definitions/Agreements"
+//   '400' :
+//     description: Invalid query parameters
 func (a *App) GetAgreements(w http.ResponseWriter, r *http.Request) {
-	v := r.URL.Query()
-	active := v.Get("active")
+	q, err := parseAgreementQuery(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	a.getAll(w, r, func() (interface{}, error) {
-		if active != "" {
-			return a.Repository.GetAgreementsByState(model.STARTED)
-		}
-		return a.Repository.GetAllAgreements()
+	a.getPage(w, r, func() (interface{}, int, error) {
+		return a.repository(r).GetAgreements(q)
 	})
 }
 
@@ -441,7 +795,7 @@ func (a *App) GetAgreements(w http.ResponseWriter, r *http.Request) {
 //     description: Agreement not found
 func (a *App) GetAgreement(w http.ResponseWriter, r *http.Request) {
 	a.get(w, r, func(id string) (interface{}, error) {
-		return a.Repository.GetAgreement(id)
+		return a.repository(r).GetAgreement(id)
 	})
 }
 
@@ -468,8 +822,11 @@ func (a *App) GetAgreement(w http.ResponseWriter, r *http.Request) {
 //     description: Agreement not found
 func (a *App) GetAgreementDetails(w http.ResponseWriter, r *http.Request) {
 	a.get(w, r, func(id string) (interface{}, error) {
-		agreement, error := a.Repository.GetAgreement(id)
-		return agreement.Details, error
+		agreement, err := a.repository(r).GetAgreement(id)
+		if err != nil {
+			return nil, err
+		}
+		return agreement.Details, nil
 	})
 }
 
@@ -504,7 +861,7 @@ func (a *App) CreateAgreement(w http.ResponseWriter, r *http.Request) {
 			return json.NewDecoder(r.Body).Decode(&agreement)
 		},
 		func() (model.Identity, error) {
-			return a.Repository.CreateAgreement(&agreement)
+			return a.repository(r).CreateAgreement(&agreement)
 		})
 }
 
@@ -529,7 +886,7 @@ func (a *App) CreateAgreement(w http.ResponseWriter, r *http.Request) {
 //     description: Agreement not found
 func (a *App) DeleteAgreement(w http.ResponseWriter, r *http.Request) {
 	a.update(w, r, func(id string) error {
-		return a.Repository.DeleteAgreement(&model.Agreement{Id: id})
+		return a.repository(r).DeleteAgreement(&model.Agreement{Id: id})
 	})
 }
 
@@ -570,14 +927,14 @@ func (a *App) UpdateAgreement(w http.ResponseWriter, r *http.Request) {
 		},
 		func(id string) (model.Identity, error) {
 			newState := agreement.State
-			return a.Repository.UpdateAgreementState(id, newState)
+			return a.updateAgreementState(a.repository(r), id, newState)
 		})
 }
 
 // StartAgreement starts monitoring an agreement
 func (a *App) StartAgreement(w http.ResponseWriter, r *http.Request) {
 	a.update(w, r, func(id string) error {
-		_, err := a.Repository.UpdateAgreementState(id, model.STARTED)
+		_, err := a.updateAgreementState(a.repository(r), id, model.STARTED)
 		return err
 	})
 }
@@ -585,7 +942,7 @@ func (a *App) StartAgreement(w http.ResponseWriter, r *http.Request) {
 // StopAgreement stop monitoring an agreement
 func (a *App) StopAgreement(w http.ResponseWriter, r *http.Request) {
 	a.update(w, r, func(id string) error {
-		_, err := a.Repository.UpdateAgreementState(id, model.STOPPED)
+		_, err := a.updateAgreementState(a.repository(r), id, model.STOPPED)
 		return err
 	})
 }
@@ -593,30 +950,78 @@ func (a *App) StopAgreement(w http.ResponseWriter, r *http.Request) {
 // TerminateAgreement terminates an agreement
 func (a *App) TerminateAgreement(w http.ResponseWriter, r *http.Request) {
 	a.update(w, r, func(id string) error {
-		_, err := a.Repository.UpdateAgreementState(id, model.TERMINATED)
+		_, err := a.updateAgreementState(a.repository(r), id, model.TERMINATED)
 		return err
 	})
 }
 
+// updateAgreementState changes the state of an agreement, notifying the change to a.Notifier
+func (a *App) updateAgreementState(repo model.IRepository, id string, newState model.State) (*model.Agreement, error) {
+	var previous model.State
+	if a.Notifier != nil {
+		if current, err := repo.GetAgreement(id); err == nil {
+			previous = current.State
+		}
+	}
+	agreement, err := repo.UpdateAgreementState(id, newState)
+	if err == nil && previous != "" && previous != agreement.State {
+		a.Notifier.NotifyStateChange(agreement, previous)
+	}
+	return agreement, err
+}
+
 // GetTemplates return all templates in db
 // swagger:operation GET /templates getAllTemplates
 //
-// Returns all registered templates
+// Returns the registered templates that match the query parameters.
+// The total number of matching templates is returned in the X-Total-Count header.
 //
 // ---
 // produces:
 // - application/json
+// parameters:
+// - name: provider
+//   in: query
+//   description: The identifier of the provider of the templates
+//   required: false
+//   type: string
+// - name: state
+//   in: query
+//   description: Returns templates in any of these states (comma-separated or repeated)
+//   required: false
+//   type: string
+// - name: sort
+//   in: query
+//   description: Comma-separated list of fields (id, name, state, provider, creation)
+//     to sort by; '-' before a field sorts in descending order
+//   required: false
+//   type: string
+// - name: offset
+//   in: query
+//   description: Number of templates to skip
+//   required: false
+//   type: integer
+// - name: limit
+//   in: query
+//   description: Maximum number of templates to return
+//   required: false
+//   type: integer
 // responses:
 //   '200':
-//     description: The complete list of registered templates
+//     description: The page of templates that match the query
 //     schema:
-//       type: object
-//       additionalProperties:
-//         "$ref": "#/definitions/Templates"
+//       "$ref": "#/definitions/Templates"
+//   '400' :
+//     description: Invalid query parameters
 func (a *App) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	q, err := parseTemplateQuery(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	a.getAll(w, r, func() (interface{}, error) {
-		return a.Repository.GetAllTemplates()
+	a.getPage(w, r, func() (interface{}, int, error) {
+		return a.repository(r).GetTemplates(q)
 	})
 }
 
@@ -643,7 +1048,37 @@ func (a *App) GetTemplates(w http.ResponseWriter, r *http.Request) {
 //     description: Template not found
 func (a *App) GetTemplate(w http.ResponseWriter, r *http.Request) {
 	a.get(w, r, func(id string) (interface{}, error) {
-		return a.Repository.GetTemplate(id)
+		return a.repository(r).GetTemplate(id)
+	})
+}
+
+// GetTemplateVersions gets the revisions of a template by REST ID
+// swagger:operation GET /templates/{id}/versions getTemplateVersions
+//
+// Returns the revisions of a template given its ID, sorted by version.
+// The last one is the current template.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the template
+//   required: true
+//   type: string
+// responses:
+//   '200':
+//     description: The revisions of the template with the ID
+//     schema:
+//       "$ref": "#/definitions/Templates"
+//   '404' :
+//     description: Template not found
+//   '501' :
+//     description: Template versions are not supported by the repository
+func (a *App) GetTemplateVersions(w http.ResponseWriter, r *http.Request) {
+	a.get(w, r, func(id string) (interface{}, error) {
+		return a.repository(r).GetTemplateVersions(id)
 	})
 }
 
@@ -678,18 +1113,15 @@ func (a *App) CreateTemplate(w http.ResponseWriter, r *http.Request) {
 			return json.NewDecoder(r.Body).Decode(&template)
 		},
 		func() (model.Identity, error) {
-			return a.Repository.CreateTemplate(&template)
+			return a.repository(r).CreateTemplate(&template)
 		})
 }
 
-// CreateAgreementFromTemplate generates an agreement from a template and parameters
-//
-// swagger:operation POST /create-agreement createAgreementFromTemplate
+// UpdateTemplate updates a template. The Id in the body is ignored; only the id path is
+// taken into account.
+// swagger:operation PUT /templates/{id} updateTemplate
 //
-// Creates an agreement from a template; templateId is the templateID to base the
-// agreement from; agreementID is an output field, containing the ID of the created
-// and stored agreement; parameters must contain a property for each placeholder to
-// be substituted in the template.
+// Replaces the template whose ID is passed as parameter with the information in the request body
 //
 // ---
 // produces:
@@ -697,69 +1129,92 @@ func (a *App) CreateTemplate(w http.ResponseWriter, r *http.Request) {
 // consumes:
 // - application/json
 // parameters:
-// - name: createAgreement
+// - name: id
+//   in: path
+//   description: The identifier of the template
+//   required: true
+//   type: string
+// - name: template
 //   in: body
-//   description: Parameters to create an agreement from a template
+//   description: The new information of the template
 //   required: true
 //   schema:
-//     "$ref": "#/definitions/CreateAgreement"
+//     "$ref": "#/definitions/Template"
 // responses:
 //   '200':
-//     description: The response contains the ID of the created agreement
+//     description: The updated template
 //     schema:
-//       "$ref": "#/definitions/CreateAgreement"
+//       "$ref": "#/definitions/Template"
 //   '400' :
-//     description: Not all template placeholders were substituted
+//     description: Invalid template
 //   '404' :
-//     description: Not found the TemplateID to create the agreement from
-func (a *App) CreateAgreementFromTemplate(w http.ResponseWriter, r *http.Request) {
-
-	var in model.CreateAgreement
-	var t *model.Template
-	var ag *model.Agreement
+//     description: Template not found
+func (a *App) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	var template model.Template
 
-	a.create(w, r,
+	a.updateEntity(w, r,
 		func() error {
-			return json.NewDecoder(r.Body).Decode(&in)
+			return json.NewDecoder(r.Body).Decode(&template)
 		},
-		func() (model.Identity, error) {
-			var err error
-
-			t, err = a.Repository.GetTemplate(in.TemplateID)
-			if err != nil {
-				return nil, err
-			}
-
-			genmodel := generator.Model{
-				Template:  *t,
-				Variables: in.Parameters,
-			}
+		func(id string) (model.Identity, error) {
+			template.Id = id
+			return a.repository(r).UpdateTemplate(&template)
+		})
+}
 
-			ag, err = generator.Do(&genmodel, a.validator, a.externalIDs)
-			if err != nil {
-				return nil, err
-			}
+// DeleteTemplate deletes a template by id
+// swagger:operation DELETE /templates/{id} deleteTemplate
+//
+// Deletes a template given its ID. The agreements generated from it are not modified.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the template
+//   required: true
+//   type: string
+// responses:
+//   '204':
+//     description: The template has been successfully deleted
+//   '404' :
+//     description: Template not found
+func (a *App) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	a.update(w, r, func(id string) error {
+		return a.repository(r).DeleteTemplate(&model.Template{Id: id})
+	})
+}
 
-			ag, err = a.Repository.CreateAgreement(ag)
-			if err != nil {
-				return nil, err
-			}
+// StartTemplate allows generating agreements from a template
+func (a *App) StartTemplate(w http.ResponseWriter, r *http.Request) {
+	a.update(w, r, func(id string) error {
+		return updateTemplateState(a.repository(r), id, model.STARTED)
+	})
+}
 
-			out := in
-			out.AgreementID = ag.Id
+// StopTemplate stops generating agreements from a template
+func (a *App) StopTemplate(w http.ResponseWriter, r *http.Request) {
+	a.update(w, r, func(id string) error {
+		return updateTemplateState(a.repository(r), id, model.STOPPED)
+	})
+}
 
-			return &out, nil
-		})
+// updateTemplateState changes the state of a template, keeping its version
+func updateTemplateState(repo model.IRepository, id string, newState model.State) error {
+	_, err := repo.UpdateTemplateState(id, newState)
+	return err
 }
 
-// Mf2cCreateAgreementFromTemplate generates an specific mF2C agreement from a template and parameters
-//
-// swagger:operation POST /mf2c/create-agreement mf2cCreateAgreementFromTemplate
+// PreviewAgreement generates an agreement from a template without storing it
+// swagger:operation POST /templates/{id}/preview previewAgreement
 //
-// Creates an agreement from a template; templateId is the templateID to base the
-// agreement from; agreementID is an output field, containing the ID of the created
-// and stored agreement; parameters must contain a property for each placeholder to
-// be substituted in the template.
+// Generates an agreement from a template and parameters as /create-agreement does
+// (regardless of the template state), and returns it along with the generation errors
+// found (invalid parameters, non-replaced placeholders, placeholders that cannot be
+// executed or validation errors).
+// Nothing is stored.
 //
 // ---
 // produces:
@@ -767,36 +1222,712 @@ func (a *App) CreateAgreementFromTemplate(w http.ResponseWriter, r *http.Request
 // consumes:
 // - application/json
 // parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the template
+//   required: true
+//   type: string
 // - name: createAgreement
 //   in: body
-//   description: Parameters to create an agreement from a template
+//   description: Parameters to create an agreement from the template (template_id is ignored)
 //   required: true
 //   schema:
 //     "$ref": "#/definitions/CreateAgreement"
 // responses:
 //   '200':
-//     description: The response contains the ID of the created agreement
+//     description: The generated agreement (if any) and the errors found
 //     schema:
-//       "$ref": "#/definitions/CreateAgreement"
+//       "$ref": "#/definitions/AgreementPreview"
 //   '400' :
-//     description: Not all template placeholders were substituted
+//     description: Error decoding input
 //   '404' :
-//     description: Not found the TemplateID to create the agreement from
-func (a *App) Mf2cCreateAgreementFromTemplate(w http.ResponseWriter, r *http.Request) {
-	/*
-	 * No additional requirements yet
-	 */
-	a.CreateAgreementFromTemplate(w, r)
-}
-
-func manageError(err error, w http.ResponseWriter) {
-	switch err {
-	case model.ErrAlreadyExist:
-		respondWithError(w, http.StatusConflict, "Object already exist")
-	case model.ErrNotFound:
+//     description: Template not found
+func (a *App) PreviewAgreement(w http.ResponseWriter, r *http.Request) {
+	var in model.CreateAgreement
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	t, err := a.repository(r).GetTemplate(mux.Vars(r)["id"])
+	if err != nil {
+		manageError(err, w)
+		return
+	}
+
+	genmodel := generator.Model{
+		Template:  *t,
+		Variables: in.Parameters,
+	}
+	ag, err := generator.Do(&genmodel, a.validator, a.externalIDs)
+	if err != nil && !generator.IsErrParameters(err) && !generator.IsErrUnreplaced(err) &&
+		!generator.IsErrValidation(err) && !generator.IsErrExecution(err) && !generator.IsErrDecode(err) {
+		manageError(err, w)
+		return
+	}
+	respondSuccessJSON(w, model.AgreementPreview{
+		Agreement:  ag,
+		Errors:     generator.Errors(err),
+		Parameters: generator.ParameterErrors(err),
+	})
+}
+
+// GetViolations return the violations that match the query parameters
+// swagger:operation GET /violations getViolations
+//
+// Returns the violations that match the query parameters, sorted by datetime.
+// The total number of matching violations is returned in the X-Total-Count header.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: agreement
+//   in: query
+//   description: The identifier of the agreement the violations belong to
+//   required: false
+//   type: string
+// - name: guarantee
+//   in: query
+//   description: The name of the violated guarantee term
+//   required: false
+//   type: string
+// - name: from
+//   in: query
+//   description: Returns violations raised at or after this datetime (RFC3339)
+//   required: false
+//   type: string
+//   format: date-time
+// - name: to
+//   in: query
+//   description: Returns violations raised before this datetime (RFC3339)
+//   required: false
+//   type: string
+//   format: date-time
+// - name: offset
+//   in: query
+//   description: Number of violations to skip
+//   required: false
+//   type: integer
+// - name: limit
+//   in: query
+//   description: Maximum number of violations to return
+//   required: false
+//   type: integer
+// responses:
+//   '200':
+//     description: The list of violations that match the query
+//     schema:
+//       "$ref": "#/definitions/Violations"
+//   '400' :
+//     description: Invalid query parameters
+func (a *App) GetViolations(w http.ResponseWriter, r *http.Request) {
+	q, err := parseViolationQuery(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a.getPage(w, r, func() (interface{}, int, error) {
+		return a.repository(r).GetViolations(q)
+	})
+}
+
+// GetAgreementViolations return the violations of an agreement that match the query parameters
+// swagger:operation GET /agreements/{id}/violations getAgreementViolations
+//
+// Returns the violations of an agreement that match the query parameters, sorted by datetime.
+// The query parameters are the same as in /violations, except agreement.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the agreement
+//   required: true
+//   type: string
+// responses:
+//   '200':
+//     description: The list of violations of the agreement that match the query
+//     schema:
+//       "$ref": "#/definitions/Violations"
+//   '400' :
+//     description: Invalid query parameters
+//   '404' :
+//     description: Agreement not found
+func (a *App) GetAgreementViolations(w http.ResponseWriter, r *http.Request) {
+	q, err := parseViolationQuery(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q.AgreementId = mux.Vars(r)["id"]
+	a.getPage(w, r, func() (interface{}, int, error) {
+		if _, err := a.repository(r).GetAgreement(q.AgreementId); err != nil {
+			return nil, 0, err
+		}
+		return a.repository(r).GetViolations(q)
+	})
+}
+
+// GetAgreementWarnings return the warnings of an agreement that match the query parameters
+// swagger:operation GET /agreements/{id}/warnings getAgreementWarnings
+//
+// Returns the warnings raised by the guarantee terms of an agreement, sorted by datetime.
+// The total number of matching warnings is returned in the X-Total-Count header.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the agreement
+//   required: true
+//   type: string
+// - name: guarantee
+//   in: query
+//   description: The name of the guarantee term that raised the warning
+//   required: false
+//   type: string
+// - name: from
+//   in: query
+//   description: Returns warnings raised at or after this datetime (RFC3339)
+//   required: false
+//   type: string
+//   format: date-time
+// - name: to
+//   in: query
+//   description: Returns warnings raised before this datetime (RFC3339)
+//   required: false
+//   type: string
+//   format: date-time
+// - name: offset
+//   in: query
+//   description: Number of warnings to skip
+//   required: false
+//   type: integer
+// - name: limit
+//   in: query
+//   description: Maximum number of warnings to return
+//   required: false
+//   type: integer
+// responses:
+//   '200':
+//     description: The list of warnings of the agreement that match the query
+//     schema:
+//       "$ref": "#/definitions/Warnings"
+//   '400' :
+//     description: Invalid query parameters
+//   '404' :
+//     description: Agreement not found
+//   '501' :
+//     description: Warnings are not supported by the repository
+func (a *App) GetAgreementWarnings(w http.ResponseWriter, r *http.Request) {
+	q, err := parseWarningQuery(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q.AgreementId = mux.Vars(r)["id"]
+	a.getPage(w, r, func() (interface{}, int, error) {
+		if _, err := a.repository(r).GetAgreement(q.AgreementId); err != nil {
+			return nil, 0, err
+		}
+		return a.repository(r).GetWarnings(q)
+	})
+}
+
+// GetAgreementPenalties return the penalties of an agreement that match the query parameters
+// swagger:operation GET /agreements/{id}/penalties getAgreementPenalties
+//
+// Returns the penalties caused by the violations of an agreement, sorted by datetime.
+// The total number of matching penalties is returned in the X-Total-Count header.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the agreement
+//   required: true
+//   type: string
+// - name: guarantee
+//   in: query
+//   description: The name of the violated guarantee term
+//   required: false
+//   type: string
+// - name: from
+//   in: query
+//   description: Returns penalties raised at or after this datetime (RFC3339)
+//   required: false
+//   type: string
+//   format: date-time
+// - name: to
+//   in: query
+//   description: Returns penalties raised before this datetime (RFC3339)
+//   required: false
+//   type: string
+//   format: date-time
+// - name: offset
+//   in: query
+//   description: Number of penalties to skip
+//   required: false
+//   type: integer
+// - name: limit
+//   in: query
+//   description: Maximum number of penalties to return
+//   required: false
+//   type: integer
+// responses:
+//   '200':
+//     description: The list of penalties of the agreement that match the query
+//     schema:
+//       "$ref": "#/definitions/Penalties"
+//   '400' :
+//     description: Invalid query parameters
+//   '404' :
+//     description: Agreement not found
+//   '501' :
+//     description: Penalties are not supported by the repository
+func (a *App) GetAgreementPenalties(w http.ResponseWriter, r *http.Request) {
+	q, err := parsePenaltyQuery(r.URL.Query())
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	q.AgreementId = mux.Vars(r)["id"]
+	a.getPage(w, r, func() (interface{}, int, error) {
+		if _, err := a.repository(r).GetAgreement(q.AgreementId); err != nil {
+			return nil, 0, err
+		}
+		return a.repository(r).GetPenalties(q)
+	})
+}
+
+// GetViolation gets a violation by REST ID
+// swagger:operation GET /violations/{id} getViolation
+//
+// Returns a violation given its ID
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the violation
+//   required: true
+//   type: string
+// responses:
+//   '200':
+//     description: The violation with the ID
+//     schema:
+//       "$ref": "#/definitions/Violation"
+//   '404' :
+//     description: Violation not found
+func (a *App) GetViolation(w http.ResponseWriter, r *http.Request) {
+	a.get(w, r, func(id string) (interface{}, error) {
+		return a.repository(r).GetViolation(id)
+	})
+}
+
+// GetSubscriptions return all subscriptions in db
+// swagger:operation GET /subscriptions getAllSubscriptions
+//
+// Returns the registered subscriptions.
+//
+// ---
+// produces:
+// - application/json
+// responses:
+//   '200':
+//     description: The list of registered subscriptions
+//     schema:
+//       "$ref": "#/definitions/Subscriptions"
+//   '501' :
+//     description: Subscriptions are not supported by the repository
+func (a *App) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := a.repository(r).GetAllSubscriptions()
+	if err != nil {
+		manageError(err, w)
+	} else {
+		respondSuccessJSON(w, subscriptions)
+	}
+}
+
+// GetSubscription gets a subscription by REST ID
+// swagger:operation GET /subscriptions/{id} getSubscription
+//
+// Returns a subscription given its ID
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the subscription
+//   required: true
+//   type: string
+// responses:
+//   '200':
+//     description: The subscription with the ID
+//     schema:
+//       "$ref": "#/definitions/Subscription"
+//   '404' :
+//     description: Subscription not found
+//   '501' :
+//     description: Subscriptions are not supported by the repository
+func (a *App) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	a.get(w, r, func(id string) (interface{}, error) {
+		return a.repository(r).GetSubscription(id)
+	})
+}
+
+// CreateSubscription creates a subscription passed by REST params
+// swagger:operation POST /subscriptions createSubscription
+//
+// Creates a subscription with the information passed in the request body.
+// An id is assigned to the subscription if not set, and a secret to sign its
+// events if not set. A provider or client only creates subscriptions filtered
+// by its party (provider_id or client_id).
+//
+// ---
+// produces:
+// - application/json
+// consumes:
+// - application/json
+// parameters:
+// - name: subscription
+//   in: body
+//   description: The subscription to create
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/Subscription"
+// responses:
+//   '201':
+//     description: The new subscription that has been created
+//     schema:
+//       "$ref": "#/definitions/Subscription"
+//   '400' :
+//     description: Invalid subscription
+//   '501' :
+//     description: Subscriptions are not supported by the repository
+func (a *App) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+
+	var subscription model.Subscription
+
+	a.create(w, r,
+		func() error {
+			return json.NewDecoder(r.Body).Decode(&subscription)
+		},
+		func() (model.Identity, error) {
+			if subscription.Id == "" && !a.externalIDs {
+				subscription.Id = uuid.New().String()
+			}
+			if subscription.Secret == "" {
+				secret, err := newSecret()
+				if err != nil {
+					return nil, err
+				}
+				subscription.Secret = secret
+			}
+			return a.repository(r).CreateSubscription(&subscription)
+		})
+}
+
+// newSecret returns a random secret to sign the events of a subscription
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// UpdateSubscription updates a subscription.
+// The Id in the body is ignored; only the id path is taken into account.
+// swagger:operation PUT /subscriptions/{id} updateSubscription
+//
+// Replaces the subscription whose ID is passed as parameter. The secret is kept
+// if not set.
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the subscription
+//   required: true
+//   type: string
+// - name: subscription
+//   in: body
+//   description: The new information of the subscription
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/Subscription"
+// responses:
+//   '200':
+//     description: The updated subscription
+//     schema:
+//       "$ref": "#/definitions/Subscription"
+//   '400' :
+//     description: Invalid subscription
+//   '404' :
+//     description: Subscription not found
+//   '501' :
+//     description: Subscriptions are not supported by the repository
+func (a *App) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	var subscription model.Subscription
+
+	a.updateEntity(w, r,
+		func() error {
+			return json.NewDecoder(r.Body).Decode(&subscription)
+		},
+		func(id string) (model.Identity, error) {
+			subscription.Id = id
+			if subscription.Secret == "" {
+				current, err := a.repository(r).GetSubscription(id)
+				if err != nil {
+					return nil, err
+				}
+				subscription.Secret = current.Secret
+			}
+			return a.repository(r).UpdateSubscription(&subscription)
+		})
+}
+
+// DeleteSubscription deletes a subscription by id
+// swagger:operation DELETE /subscriptions/{id} deleteSubscription
+//
+// Deletes a subscription given its ID
+//
+// ---
+// produces:
+// - application/json
+// parameters:
+// - name: id
+//   in: path
+//   description: The identifier of the subscription
+//   required: true
+//   type: string
+// responses:
+//   '204':
+//     description: The subscription has been successfully deleted
+//   '404' :
+//     description: Subscription not found
+//   '501' :
+//     description: Subscriptions are not supported by the repository
+func (a *App) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	a.update(w, r, func(id string) error {
+		return a.repository(r).DeleteSubscription(&model.Subscription{Id: id})
+	})
+}
+
+// GetEvents streams the assessment events
+// swagger:operation GET /events getEvents
+//
+// Streams the events of the assessment as Server-Sent Events: violations, warnings,
+// changes of state of agreements and the start and end of each assessment.
+// The data of each event is a JSON object with the event type, the agreement
+// and the datetime, among other fields. Only the events of the agreements of the
+// tenant of the request, and visible to the caller, are sent.
+//
+// ---
+// produces:
+// - text/event-stream
+// parameters:
+// - name: agreement
+//   in: query
+//   description: Comma separated list of agreements whose events are sent (all if not set)
+//   required: false
+//   type: string
+// - name: Last-Event-ID
+//   in: header
+//   description: The id of the last received event, to resume the stream
+//   required: false
+//   type: integer
+// responses:
+//   '200':
+//     description: The stream of events
+//   '400' :
+//     description: Invalid Last-Event-ID
+func (a *App) GetEvents(w http.ResponseWriter, r *http.Request) {
+	tenant, _ := r.Context().Value(tenantKey{}).(string)
+	q := sse.Query{Tenant: tenant}
+	if p, ok := auth.FromContext(r.Context()); ok && !p.HasRole(auth.ADMIN) {
+		q.Restricted = true
+		if p.HasRole(auth.PROVIDER) {
+			q.ProviderId = p.PartyId
+		}
+		if p.HasRole(auth.CLIENT) {
+			q.ClientId = p.PartyId
+		}
+	}
+	for _, value := range r.URL.Query()["agreement"] {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				q.AgreementIds = append(q.AgreementIds, item)
+			}
+		}
+	}
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest,
+				fmt.Sprintf("Invalid value '%s' of header 'Last-Event-ID': must be a non-negative integer", value))
+			return
+		}
+		q.LastEventId = &id
+	}
+
+	err := a.Events.Stream(r.Context(), w, q)
+	if err == sse.ErrStreamingUnsupported {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+	} else if err != nil {
+		log.Debugf("Event stream closed: %v", err)
+	}
+}
+
+// CreateAgreementFromTemplate generates an agreement from a template and parameters
+//
+// swagger:operation POST /create-agreement createAgreementFromTemplate
+//
+// Creates an agreement from a template; templateId is the templateID to base the
+// agreement from; agreementID is an output field, containing the ID of the created
+// and stored agreement; parameters must contain a property for each placeholder to
+// be substituted in the template, satisfying the parameters declared in the template.
+//
+// ---
+// produces:
+// - application/json
+// consumes:
+// - application/json
+// parameters:
+// - name: createAgreement
+//   in: body
+//   description: Parameters to create an agreement from a template
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/CreateAgreement"
+// responses:
+//   '200':
+//     description: The response contains the ID of the created agreement
+//     schema:
+//       "$ref": "#/definitions/CreateAgreement"
+//   '400' :
+//     description: Not all template placeholders were substituted, or invalid template parameters
+//   '404' :
+//     description: Not found the TemplateID to create the agreement from
+//   '409' :
+//     description: The template is not started
+func (a *App) CreateAgreementFromTemplate(w http.ResponseWriter, r *http.Request) {
+
+	var in model.CreateAgreement
+	var t *model.Template
+	var ag *model.Agreement
+
+	a.create(w, r,
+		func() error {
+			return json.NewDecoder(r.Body).Decode(&in)
+		},
+		func() (model.Identity, error) {
+			var err error
+
+			t, err = a.repository(r).GetTemplate(in.TemplateID)
+			if err != nil {
+				return nil, err
+			}
+			if !t.IsStarted() {
+				return nil, model.ErrTemplateNotStarted
+			}
+
+			genmodel := generator.Model{
+				Template:  *t,
+				Variables: in.Parameters,
+			}
+
+			ag, err = generator.Do(&genmodel, a.validator, a.externalIDs)
+			if err != nil {
+				return nil, err
+			}
+
+			ag, err = a.repository(r).CreateAgreement(ag)
+			if err != nil {
+				return nil, err
+			}
+
+			out := in
+			out.AgreementID = ag.Id
+
+			return &out, nil
+		})
+}
+
+// Mf2cCreateAgreementFromTemplate generates an specific mF2C agreement from a template and parameters
+//
+// swagger:operation POST /mf2c/create-agreement mf2cCreateAgreementFromTemplate
+//
+// Creates an agreement from a template; templateId is the templateID to base the
+// agreement from; agreementID is an output field, containing the ID of the created
+// and stored agreement; parameters must contain a property for each placeholder to
+// be substituted in the template, satisfying the parameters declared in the template.
+//
+// ---
+// produces:
+// - application/json
+// consumes:
+// - application/json
+// parameters:
+// - name: createAgreement
+//   in: body
+//   description: Parameters to create an agreement from a template
+//   required: true
+//   schema:
+//     "$ref": "#/definitions/CreateAgreement"
+// responses:
+//   '200':
+//     description: The response contains the ID of the created agreement
+//     schema:
+//       "$ref": "#/definitions/CreateAgreement"
+//   '400' :
+//     description: Not all template placeholders were substituted, or invalid template parameters
+//   '404' :
+//     description: Not found the TemplateID to create the agreement from
+//   '409' :
+//     description: The template is not started
+func (a *App) Mf2cCreateAgreementFromTemplate(w http.ResponseWriter, r *http.Request) {
+	/*
+	 * No additional requirements yet
+	 */
+	a.CreateAgreementFromTemplate(w, r)
+}
+
+func manageError(err error, w http.ResponseWriter) {
+	switch err {
+	case model.ErrAlreadyExist:
+		respondWithError(w, http.StatusConflict, "Object already exist")
+	case model.ErrNotFound:
 		respondWithError(w, http.StatusNotFound, "Can't find object")
+	case auth.ErrForbidden:
+		respondWithError(w, http.StatusForbidden, err.Error())
+	case model.ErrTemplateNotStarted:
+		respondWithError(w, http.StatusConflict, err.Error())
+	case model.ErrNotSupported:
+		respondWithError(w, http.StatusNotImplemented, err.Error())
 	default:
-		if model.IsErrValidation(err) || generator.IsErrUnreplaced(err) {
+		if params := generator.ParameterErrors(err); params != nil {
+			code := http.StatusBadRequest
+			respondWithJSON(w, code, ApiError{Code: strconv.Itoa(code), Message: err.Error(), Parameters: params})
+		} else if model.IsErrValidation(err) || generator.IsErrUnreplaced(err) ||
+			generator.IsErrExecution(err) || generator.IsErrDecode(err) {
 			respondWithError(w, http.StatusBadRequest, err.Error())
 		} else {
 			respondWithError(w, http.StatusInternalServerError, err.Error())
@@ -804,8 +1935,171 @@ func manageError(err error, w http.ResponseWriter) {
 	}
 }
 
+func parseProviderQuery(v url.Values) (model.ProviderQuery, error) {
+	var q model.ProviderQuery
+	var err error
+
+	if q.Sort, err = model.ParseSort(v.Get("sort"), model.ProviderSortFields); err != nil {
+		return q, err
+	}
+	q.Page, err = parsePage(v)
+	return q, err
+}
+
+func parseAgreementQuery(v url.Values) (model.AgreementQuery, error) {
+	var err error
+
+	q := model.AgreementQuery{
+		ProviderId: v.Get("provider"),
+		ClientId:   v.Get("client"),
+	}
+	if q.States, err = parseStates(v); err != nil {
+		return q, err
+	}
+	if v.Get("active") != "" {
+		if len(q.States) > 0 {
+			return q, fmt.Errorf("Cannot filter by both active and state")
+		}
+		q.States = []model.State{model.STARTED}
+	}
+	if q.CreationFrom, err = parseTime(v, "creation_from"); err != nil {
+		return q, err
+	}
+	if q.CreationTo, err = parseTime(v, "creation_to"); err != nil {
+		return q, err
+	}
+	if q.ExpirationFrom, err = parseTime(v, "expiration_from"); err != nil {
+		return q, err
+	}
+	if q.ExpirationTo, err = parseTime(v, "expiration_to"); err != nil {
+		return q, err
+	}
+	if q.Sort, err = model.ParseSort(v.Get("sort"), model.AgreementSortFields); err != nil {
+		return q, err
+	}
+	q.Page, err = parsePage(v)
+	return q, err
+}
+
+func parseTemplateQuery(v url.Values) (model.TemplateQuery, error) {
+	var err error
+
+	q := model.TemplateQuery{
+		ProviderId: v.Get("provider"),
+	}
+	if q.States, err = parseStates(v); err != nil {
+		return q, err
+	}
+	if q.Sort, err = model.ParseSort(v.Get("sort"), model.TemplateSortFields); err != nil {
+		return q, err
+	}
+	q.Page, err = parsePage(v)
+	return q, err
+}
+
+// parseStates reads the state parameter, that may be repeated or contain a comma-separated list
+func parseStates(v url.Values) ([]model.State, error) {
+	var result []model.State
+
+	for _, value := range v["state"] {
+		for _, item := range strings.Split(value, ",") {
+			state := model.State(strings.TrimSpace(item))
+			if state.Normalize() != state {
+				return nil, fmt.Errorf("Invalid value '%s' of parameter 'state': valid states are %v",
+					item, model.States)
+			}
+			result = append(result, state)
+		}
+	}
+	return result, nil
+}
+
+func parseViolationQuery(v url.Values) (model.ViolationQuery, error) {
+	var err error
+
+	q := model.ViolationQuery{
+		AgreementId: v.Get("agreement"),
+		Guarantee:   v.Get("guarantee"),
+	}
+	if q.From, err = parseTime(v, "from"); err != nil {
+		return q, err
+	}
+	if q.To, err = parseTime(v, "to"); err != nil {
+		return q, err
+	}
+	q.Page, err = parsePage(v)
+	return q, err
+}
+
+func parseWarningQuery(v url.Values) (model.WarningQuery, error) {
+	var err error
+
+	q := model.WarningQuery{
+		Guarantee: v.Get("guarantee"),
+	}
+	if q.From, err = parseTime(v, "from"); err != nil {
+		return q, err
+	}
+	if q.To, err = parseTime(v, "to"); err != nil {
+		return q, err
+	}
+	q.Page, err = parsePage(v)
+	return q, err
+}
+
+func parsePenaltyQuery(v url.Values) (model.PenaltyQuery, error) {
+	var err error
+
+	q := model.PenaltyQuery{
+		Guarantee: v.Get("guarantee"),
+	}
+	if q.From, err = parseTime(v, "from"); err != nil {
+		return q, err
+	}
+	if q.To, err = parseTime(v, "to"); err != nil {
+		return q, err
+	}
+	q.Page, err = parsePage(v)
+	return q, err
+}
+
+func parsePage(v url.Values) (model.Page, error) {
+	var page model.Page
+	var err error
+
+	if page.Offset, err = parseNonNegativeInt(v, "offset"); err != nil {
+		return page, err
+	}
+	page.Limit, err = parseNonNegativeInt(v, "limit")
+	return page, err
+}
+
+func parseTime(v url.Values, name string) (time.Time, error) {
+	value := v.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("Invalid value '%s' of parameter '%s': must be a RFC3339 datetime", value, name)
+	}
+	return t, nil
+}
+
+func parseNonNegativeInt(v url.Values, name string) (int, error) {
+	value := v.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("Invalid value '%s' of parameter '%s': must be a non-negative integer", value, name)
+	}
+	return i, nil
+}
+
 func respondWithError(w http.ResponseWriter, code int, message string) {
-	respondWithJSON(w, code, ApiError{strconv.Itoa(code), message})
+	respondWithJSON(w, code, ApiError{Code: strconv.Itoa(code), Message: message})
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
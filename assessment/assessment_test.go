@@ -20,6 +20,7 @@ import (
 	assessment_model "SLALite/assessment/model"
 	"SLALite/assessment/monitor/simpleadapter"
 	"SLALite/model"
+	"SLALite/repositories/memrepository"
 	"SLALite/utils"
 	"fmt"
 	"os"
@@ -101,6 +102,57 @@ func TestAssessActiveAgreements(t *testing.T) {
 	}, T: t})
 }
 
+type warningRecorder struct {
+	violations int
+	warnings   int
+}
+
+func (n *warningRecorder) NotifyViolations(agreement *model.Agreement, result *assessment_model.Result) {
+	n.violations += len(result.GetViolations())
+}
+
+func (n *warningRecorder) NotifyWarnings(agreement *model.Agreement, result *assessment_model.Result) {
+	n.warnings += len(result.GetWarnings())
+}
+
+func TestAssessActiveAgreementsWithWarnings(t *testing.T) {
+	a := createAgreement("aw01", p1, c2, "Agreement aw01", "m >= 10")
+	a.Details.Guarantees[0].Warning = "m >= 20 && n < 50"
+	a.State = model.STARTED
+	repo.CreateAgreement(&a)
+	defer repo.DeleteAgreement(&a)
+
+	var m1 = assessment_model.GuaranteeData{
+		{
+			"m": model.MetricValue{Key: "m", Value: 5, DateTime: t_(0)},
+			"n": model.MetricValue{Key: "n", Value: 25, DateTime: t_(0)},
+		},
+		{
+			"m": model.MetricValue{Key: "m", Value: 15, DateTime: t_(1)},
+			"n": model.MetricValue{Key: "n", Value: 40, DateTime: t_(1)},
+		},
+		{
+			"m": model.MetricValue{Key: "m", Value: 30, DateTime: t_(2)},
+			"n": model.MetricValue{Key: "n", Value: 75, DateTime: t_(2)},
+		},
+		{
+			"m": model.MetricValue{Key: "m", Value: 30, DateTime: t_(3)},
+			"n": model.MetricValue{Key: "n", Value: 30, DateTime: t_(3)},
+		},
+	}
+	not := &warningRecorder{}
+	AssessActiveAgreements(repo, simpleadapter.New(m1), not)
+
+	/* other agreements in repo may be STARTED; violations are not checked */
+	if not.warnings != 2 {
+		t.Errorf("Unexpected number of warnings. Expected: 2; Actual: %d", not.warnings)
+	}
+	stored, _, err := repo.GetWarnings(model.WarningQuery{AgreementId: a.Id})
+	if err != nil || len(stored) != 2 {
+		t.Errorf("Unexpected stored warnings. Expected: 2; Actual: %v (%v)", stored, err)
+	}
+}
+
 func TestAssessAgreement(t *testing.T) {
 	a2 := createAgreement("a02", p1, c2, "Agreement 02", "m >= 0")
 	values := assessment_model.GuaranteeData{
@@ -187,6 +239,61 @@ func checkTimes(t *testing.T, a *model.Agreement, expectedFirst time.Time, expec
 	}
 }
 
+type stateRecorder struct {
+	warningRecorder
+	changes     map[string]model.State
+	expirations map[string]bool
+}
+
+func (n *stateRecorder) NotifyStateChange(agreement *model.Agreement, previous model.State) {
+	n.changes[agreement.Id] = previous
+}
+
+func (n *stateRecorder) NotifyExpiration(agreement *model.Agreement) {
+	n.expirations[agreement.Id] = true
+}
+
+func TestAssessActiveAgreementsWithExpiration(t *testing.T) {
+	a := createAgreement("ae01", p1, c2, "Agreement ae01", "m >= 0")
+	a.State = model.STARTED
+	expiration := time.Now().Add(-time.Minute)
+	a.Details.Expiration = &expiration
+	repo.CreateAgreement(&a)
+	defer repo.DeleteAgreement(&a)
+
+	not := &stateRecorder{changes: map[string]model.State{}, expirations: map[string]bool{}}
+	AssessActiveAgreements(repo, simpleadapter.New(nil), not)
+
+	/* other agreements in repo may be STARTED; only ae01 is checked */
+	if previous, ok := not.changes["ae01"]; !ok || previous != model.STARTED {
+		t.Errorf("Expected state change from started. Actual: %v", not.changes)
+	}
+	if !not.expirations["ae01"] {
+		t.Errorf("Expected expiration of ae01. Actual: %v", not.expirations)
+	}
+	if stored, _ := repo.GetAgreement("ae01"); stored.State != model.TERMINATED {
+		t.Errorf("Agreement in unexpected state. Expected: terminated. Actual: %v", stored.State)
+	}
+}
+
+func TestAssessTenants(t *testing.T) {
+	memRepo, _ := memrepository.New(nil)
+	tenantRepo, _ := model.ForTenant(memRepo, "tenant01")
+	for _, r := range []model.IRepository{memRepo, tenantRepo} {
+		a := createAgreement("at01", p1, c2, "Agreement at01", "m >= 0")
+		a.State = model.STARTED
+		r.CreateAgreement(&a)
+	}
+
+	not := &cycleRecorder{stateRecorder: stateRecorder{
+		changes: map[string]model.State{}, expirations: map[string]bool{}}}
+	AssessTenants(memRepo, simpleadapter.New(nil), not)
+	if not.starts != 1 || not.ends != 1 || not.agreements != 2 {
+		t.Errorf("Unexpected cycle notifications: %d starts, %d ends, %d agreements",
+			not.starts, not.ends, not.agreements)
+	}
+}
+
 func TestAssessExpiredAgreement(t *testing.T) {
 	a2 := createAgreement("a02", p1, c2, "Agreement 02", "m >= 0")
 	ma := simpleadapter.New(nil)
@@ -239,6 +346,84 @@ func TestEvaluateAgreement(t *testing.T) {
 	}
 }
 
+func TestEvaluateAgreementWithSchedule(t *testing.T) {
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(0)}},
+	}
+	ma := simpleadapter.New(values)
+	now := time.Now()
+	a := createAgreementFull("a-schedule", p1, c2, "schedule",
+		map[string]string{"always": "m > 0", "hourly": "m > 0", "daily": "m > 0"}, nil)
+	a.State = model.STARTED
+	for i := range a.Details.Guarantees {
+		gt := &a.Details.Guarantees[i]
+		switch gt.Name {
+		case "hourly":
+			gt.Schedule = "1h"
+		case "daily":
+			gt.Schedule = "@daily"
+		}
+		a.Assessment.SetGuarantee(gt.Name, model.AssessmentGuarantee{LastExecution: now.Add(-30 * time.Minute)})
+	}
+
+	result, err := EvaluateAgreement(&a, ma, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := result.LastExecution["always"]; !ok {
+		t.Errorf("Guarantee without schedule not evaluated")
+	}
+	if _, ok := result.LastExecution["hourly"]; ok {
+		t.Errorf("Guarantee evaluated before its schedule")
+	}
+	if len(result.Violated) != 1 {
+		t.Errorf("Unexpected violated guarantees. Expected: 1; Actual: %v", result.Violated)
+	}
+
+	result, _ = EvaluateAgreement(&a, ma, now.Add(31*time.Minute))
+	if _, ok := result.LastExecution["hourly"]; !ok {
+		t.Errorf("Guarantee not evaluated after its schedule")
+	}
+}
+
+func TestEvaluateAgreementWithWarning(t *testing.T) {
+	values := assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: 5, DateTime: t_(0)}},
+		{"m": model.MetricValue{Key: "m", Value: -1, DateTime: t_(1)}},
+		{"m": model.MetricValue{Key: "m", Value: 20, DateTime: t_(2)}},
+	}
+	ma := simpleadapter.New(values)
+	a := createAgreement("a-warning", p1, c2, "warning", "m >= 0")
+	a.Details.Guarantees[0].Warning = "m >= 10"
+
+	result, err := EvaluateAgreement(&a, ma, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := len(result.GetViolations()); n != 1 {
+		t.Errorf("Unexpected number of violations. Expected: 1; Actual: %d", n)
+	}
+	warnings := result.GetWarnings()
+	if len(warnings) != 1 {
+		t.Fatalf("Unexpected number of warnings. Expected: 1; Actual: %d", len(warnings))
+	}
+	w := warnings[0]
+	if w.AgreementId != a.Id || w.Guarantee != "TestGuarantee" || w.Expression != "m >= 10" ||
+		!w.Datetime.Equal(t_(0)) || len(w.Values) != 1 || w.Values[0].Value != 5 {
+		t.Errorf("Unexpected warning: %v", w)
+	}
+
+	/* a wrong warning expression does not prevent the evaluation of the constraint */
+	a.Details.Guarantees[0].Warning = "m >="
+	result, err = EvaluateAgreement(&a, ma, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.GetViolations()) != 1 || len(result.Warned) != 0 {
+		t.Errorf("Unexpected result with wrong warning: %v", result)
+	}
+}
+
 func TestEvaluateAgreementWithWrongValues(t *testing.T) {
 	values := assessment_model.GuaranteeData{
 		{"n": model.MetricValue{Key: "n", Value: 1, DateTime: t_(0)}},
@@ -21,6 +21,7 @@ import (
 	amodel "SLALite/assessment/model"
 	"SLALite/assessment/monitor"
 	"SLALite/assessment/notifier"
+	"SLALite/metrics"
 	"SLALite/model"
 	"time"
 
@@ -33,19 +34,101 @@ func init() {
 }
 
 //AssessActiveAgreements will get the active agreements from the provided repository and assess them, notifying about violations with the provided notifier.
+//
+// The violations are stored in the repository, along with the penalties they cause,
+// and so are the warnings.
+// If the notifier is also a notifier.WarningNotifier, it is notified about warnings too;
+// the same applies to notifier.StateNotifier and notifier.CycleNotifier.
 func AssessActiveAgreements(repo model.IRepository, ma monitor.MonitoringAdapter, not notifier.ViolationNotifier) {
+	start := startCycle(not)
+	n := assessActiveAgreements(repo, ma, not)
+	endCycle(not, start, n)
+}
+
+// AssessTenants assesses the active agreements of each tenant of the repository as
+// AssessActiveAgreements does, in a single assessment cycle.
+func AssessTenants(repo model.IRepository, ma monitor.MonitoringAdapter, not notifier.ViolationNotifier) {
+	start := startCycle(not)
+	n := 0
+	defer func() {
+		endCycle(not, start, n)
+	}()
+
+	tenants, err := model.GetTenants(repo)
+	if err != nil {
+		log.Errorf("Error getting tenants: %s", err.Error())
+		return
+	}
+	for _, tenant := range tenants {
+		tenantRepo, err := model.ForTenant(repo, tenant)
+		if err != nil {
+			log.Errorf("Error getting repository of tenant '%s': %s", tenant, err.Error())
+			continue
+		}
+		n += assessActiveAgreements(tenantRepo, ma, not)
+	}
+}
+
+// startCycle notifies the start of an assessment cycle, returning its start time
+func startCycle(not notifier.ViolationNotifier) time.Time {
+	start := time.Now()
+	if cn, ok := not.(notifier.CycleNotifier); ok {
+		cn.NotifyCycleStart(start)
+	}
+	return start
+}
+
+// endCycle notifies the end of an assessment cycle, where n agreements were evaluated,
+// and updates the assessment metrics
+func endCycle(not notifier.ViolationNotifier, start time.Time, n int) {
+	metrics.AssessmentDuration.Observe(time.Since(start).Seconds())
+	metrics.AssessedAgreements.Set(float64(n))
+	if cn, ok := not.(notifier.CycleNotifier); ok {
+		cn.NotifyCycleEnd(start, n)
+	}
+}
+
+// assessActiveAgreements assesses the active agreements of repo, returning the number
+// of agreements evaluated
+func assessActiveAgreements(repo model.IRepository, ma monitor.MonitoringAdapter, not notifier.ViolationNotifier) int {
 	agreements, err := repo.GetAgreementsByState(model.STARTED, model.STOPPED)
 	if err != nil {
 		log.Errorf("Error getting active agreements: %s", err.Error())
-	} else {
-		log.Printf("AssessActiveAgreements(). %d agreements to evaluate", len(agreements))
-		for _, agreement := range agreements {
-			result := AssessAgreement(&agreement, ma, time.Now())
-			repo.UpdateAgreement(&agreement)
-			if not != nil && len(result.Violated) > 0 {
-				not.NotifyViolations(&agreement, &result)
-			}
-		}
+		return 0
+	}
+	log.Printf("AssessActiveAgreements(). %d agreements to evaluate", len(agreements))
+	for _, agreement := range agreements {
+		previous := agreement.State
+		result := AssessAgreement(&agreement, ma, time.Now())
+		repo.UpdateAgreement(&agreement)
+		storeViolations(repo, &agreement, &result)
+		storeWarnings(repo, &agreement, &result)
+		countViolations(&agreement, &result)
+		notify(not, &agreement, &result, previous)
+	}
+	return len(agreements)
+}
+
+// countViolations updates the violation metrics with the violations of an assessment
+func countViolations(a *model.Agreement, result *amodel.Result) {
+	for _, v := range result.GetViolations() {
+		metrics.Violations.WithLabelValues(a.Tenant, v.AgreementId, v.Guarantee).Inc()
+	}
+}
+
+// notify notifies the result of the assessment of an agreement, whose state before
+// the assessment was previous
+func notify(not notifier.ViolationNotifier, a *model.Agreement, result *amodel.Result, previous model.State) {
+	if not != nil && len(result.Violated) > 0 {
+		not.NotifyViolations(a, result)
+	}
+	if wn, ok := not.(notifier.WarningNotifier); ok && len(result.Warned) > 0 {
+		wn.NotifyWarnings(a, result)
+	}
+	if sn, ok := not.(notifier.StateNotifier); ok && a.State != previous {
+		sn.NotifyStateChange(a, previous)
+		// the assessment only changes the state of expired agreements
+		sn.NotifyExpiration(a)
 	}
 }
 
@@ -56,8 +139,9 @@ func AssessActiveAgreements(repo model.IRepository, ma monitor.MonitoringAdapter
 //
 // The output is:
 // - parameter a is modified
-// - evaluation results are the function return (violated metrics and raised violations).
-//   a guarantee term is filled in the result only if there are violations.
+// - evaluation results are the function return (violated metrics and raised violations,
+//   and metrics that raised warnings).
+//   a guarantee term is filled in the result only if there are violations or warnings.
 //
 // The function results are not persisted. The output must be persisted/handled accordingly.
 // E.g.: agreement and violations must be persisted to DB. Violations must be notified to
@@ -75,6 +159,7 @@ func AssessAgreement(a *model.Agreement, ma monitor.MonitoringAdapter, now time.
 	if a.State == model.STARTED {
 		result, err = EvaluateAgreement(a, ma, now)
 		if err != nil {
+			metrics.AssessmentErrors.Inc()
 			log.Warn("Error evaluating agreement " + a.Id + ": " + err.Error())
 			return result
 		}
@@ -106,8 +191,8 @@ func updateAssessmentGuarantee(a *model.Agreement, gtname string, last amodel.Ex
 	a.Assessment.SetGuarantee(gtname, ag)
 }
 
-// EvaluateAgreement evaluates the guarantee terms of an agreement. The metric values
-// are retrieved from a MonitoringAdapter.
+// EvaluateAgreement evaluates the guarantee terms of an agreement that are due according
+// to their schedule. The metric values are retrieved from a MonitoringAdapter.
 // The MonitoringAdapter must feed the process correctly
 // (e.g. if the constraint of a guarantee term is of the type "A>B && C>D", the
 // MonitoringAdapter must supply pairs of values).
@@ -117,16 +202,18 @@ func EvaluateAgreement(a *model.Agreement, ma monitor.MonitoringAdapter, now tim
 	log.Debugf("EvaluateAgreement(%s)", a.Id)
 	result := amodel.Result{
 		Violated:      map[string]amodel.EvaluationGtResult{},
+		Warned:        map[string]amodel.EvaluationGtWarning{},
 		LastValues:    map[string]amodel.ExpressionData{},
 		LastExecution: map[string]time.Time{},
 	}
 	gts := a.Details.Guarantees
 
 	for _, gt := range gts {
-		/*
-		 * TODO Evaluate if gt has to be evaluated according to schedule
-		 */
-		failed, lastvalues, err := EvaluateGuarantee(a, gt, ma, now)
+		if !isDue(a, gt, now) {
+			log.Debugf("Skipping guarantee %s of agreement %s: not due", gt.Name, a.Id)
+			continue
+		}
+		failed, warned, lastvalues, err := evaluateGuarantee(a, gt, ma, now)
 		if err != nil {
 			log.Warn("Error evaluating expression " + gt.Constraint + ": " + err.Error())
 			return amodel.Result{}, err
@@ -139,12 +226,33 @@ func EvaluateAgreement(a *model.Agreement, ma monitor.MonitoringAdapter, now tim
 			}
 			result.Violated[gt.Name] = gtResult
 		}
+		if len(warned) > 0 {
+			result.Warned[gt.Name] = amodel.EvaluationGtWarning{
+				Metrics:  warned,
+				Warnings: EvaluateGtWarnings(a, gt, warned),
+			}
+		}
 		result.LastValues[gt.Name] = lastvalues
 		result.LastExecution[gt.Name] = now
 	}
 	return result, nil
 }
 
+// isDue returns if a guarantee term has to be evaluated at now according to its schedule.
+// A guarantee term with a wrong schedule is always evaluated.
+func isDue(a *model.Agreement, gt model.Guarantee, now time.Time) bool {
+	var last time.Time
+	if a.Assessment != nil {
+		last = a.Assessment.GetGuarantee(gt.Name).LastExecution
+	}
+	due, err := gt.Schedule.IsDue(last, now)
+	if err != nil {
+		log.Warnf("Error in schedule of guarantee %s of agreement %s: %s", gt.Name, a.Id, err.Error())
+		return true
+	}
+	return due
+}
+
 // EvaluateGuarantee evaluates a guarantee term of an Agreement
 // (see EvaluateAgreement)
 //
@@ -155,48 +263,96 @@ func EvaluateGuarantee(a *model.Agreement,
 	now time.Time) (
 	failed []amodel.ExpressionData, last amodel.ExpressionData, err error) {
 
+	failed, _, last, err = evaluateGuarantee(a, gt, ma, now)
+	return failed, last, err
+}
+
+// evaluateGuarantee evaluates the constraint and the warning expression of a guarantee term.
+//
+// Returns the metrics that failed the GT constraint, and the metrics that fulfilled the
+// constraint but failed the warning expression. An error in the warning expression
+// is logged and does not prevent the evaluation of the constraint.
+func evaluateGuarantee(a *model.Agreement,
+	gt model.Guarantee,
+	ma monitor.MonitoringAdapter,
+	now time.Time) (
+	failed []amodel.ExpressionData, warned []amodel.ExpressionData, last amodel.ExpressionData, err error) {
+
 	log.Debugf("EvaluateGuarantee(%s, %s)", a.Id, gt.Name)
 	failed = make(amodel.GuaranteeData, 0, 1)
+	warned = make(amodel.GuaranteeData, 0)
 
 	expression, err := govaluate.NewEvaluableExpression(gt.Constraint)
 	if err != nil {
 		log.Warnf("Error parsing expression '%s'", gt.Constraint)
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
-	values := ma.GetValues(gt, expression.Vars(), now)
+	vars := expression.Vars()
+
+	var warning *govaluate.EvaluableExpression
+	if gt.Warning != "" {
+		warning, err = govaluate.NewEvaluableExpression(gt.Warning)
+		if err != nil {
+			log.Warnf("Error parsing warning expression '%s' of guarantee %s: %s", gt.Warning, gt.Name, err.Error())
+			warning = nil
+		} else {
+			vars = appendMissing(vars, warning.Vars())
+		}
+	}
+
+	values := ma.GetValues(gt, vars, now)
 	for _, value := range values {
 		aux, err := evaluateExpression(expression, value)
 		if err != nil {
 			log.Warn("Error evaluating expression " + gt.Constraint + ": " + err.Error())
-			return nil, nil, err
+			return nil, nil, nil, err
 		}
 		if aux != nil {
 			failed = append(failed, aux)
+			continue
+		}
+		if warning != nil {
+			aux, err = evaluateExpression(warning, value)
+			if err != nil {
+				log.Warn("Error evaluating warning expression " + gt.Warning + ": " + err.Error())
+				warning = nil
+			} else if aux != nil {
+				warned = append(warned, aux)
+			}
 		}
 	}
 	if len(values) > 0 {
 		last = values[len(values)-1]
 	}
-	return failed, last, nil
+	return failed, warned, last, nil
+}
+
+// appendMissing appends to vars the items of others that are not in vars
+func appendMissing(vars []string, others []string) []string {
+	for _, other := range others {
+		found := false
+		for _, v := range vars {
+			if v == other {
+				found = true
+				break
+			}
+		}
+		if !found {
+			vars = append(vars, other)
+		}
+	}
+	return vars
 }
 
 // EvaluateGtViolations creates violations for the detected violated metrics in EvaluateGuarantee
 func EvaluateGtViolations(a *model.Agreement, gt model.Guarantee, violated amodel.GuaranteeData) []model.Violation {
 	gtv := make([]model.Violation, 0, len(violated))
 	for _, tuple := range violated {
-		// build values map and find newer metric
-		var d *time.Time
-		var values = make([]model.MetricValue, 0, len(tuple))
-		for _, m := range tuple {
-			values = append(values, m)
-			if d == nil || m.DateTime.After(*d) {
-				d = &m.DateTime
-			}
-		}
+		values, d := tupleValues(tuple)
 		v := model.Violation{
 			AgreementId: a.Id,
 			Guarantee:   gt.Name,
-			Datetime:    *d,
+			Datetime:    d,
 			Constraint:  gt.Constraint,
 			Values:      values,
 		}
@@ -205,6 +361,36 @@ func EvaluateGtViolations(a *model.Agreement, gt model.Guarantee, violated amode
 	return gtv
 }
 
+// EvaluateGtWarnings creates warnings for the metrics that failed the warning expression in evaluateGuarantee
+func EvaluateGtWarnings(a *model.Agreement, gt model.Guarantee, warned amodel.GuaranteeData) []model.Warning {
+	gtw := make([]model.Warning, 0, len(warned))
+	for _, tuple := range warned {
+		values, d := tupleValues(tuple)
+		w := model.Warning{
+			AgreementId: a.Id,
+			Guarantee:   gt.Name,
+			Datetime:    d,
+			Expression:  gt.Warning,
+			Values:      values,
+		}
+		gtw = append(gtw, w)
+	}
+	return gtw
+}
+
+// tupleValues builds the values list of a tuple, and finds the time of the newer metric
+func tupleValues(tuple amodel.ExpressionData) ([]model.MetricValue, time.Time) {
+	var d time.Time
+	var values = make([]model.MetricValue, 0, len(tuple))
+	for _, m := range tuple {
+		values = append(values, m)
+		if m.DateTime.After(d) {
+			d = m.DateTime
+		}
+	}
+	return values, d
+}
+
 // evaluateExpression evaluate a GT expression at a single point in time with a tuple of metric values
 // (one value per variable in GT expresssion)
 //
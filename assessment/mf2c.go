@@ -18,6 +18,7 @@ package assessment
 
 import (
 	"SLALite/assessment/monitor"
+	"SLALite/assessment/notifier"
 	"SLALite/mf2c"
 	"SLALite/model"
 	"SLALite/repositories/cimi"
@@ -29,9 +30,12 @@ import (
 This file contains the mF2C asssessment code
 */
 
-// AssessMf2cAgreements is the main process for the mf2c assessment
+// AssessMf2cAgreements is the main process for the mf2c assessment.
+//
+// The notifier receives the same notifications, and the same metrics are updated,
+// as in AssessActiveAgreements.
 func AssessMf2cAgreements(repo model.IRepository, mf2cRepo cimi.IRepository,
-	ma monitor.MonitoringAdapter, policies mf2c.PoliciesConnecter) {
+	ma monitor.MonitoringAdapter, policies mf2c.PoliciesConnecter, not notifier.ViolationNotifier) {
 
 	// Checking if running on the leader
 	leader, err := policies.IsLeader()
@@ -42,7 +46,11 @@ func AssessMf2cAgreements(repo model.IRepository, mf2cRepo cimi.IRepository,
 		log.Printf("Not running on leader. Exiting...")
 		return
 	}
+	start := startCycle(not)
 	agreements, err := repo.GetAllAgreements()
+	defer func() {
+		endCycle(not, start, len(agreements))
+	}()
 	log.Printf("Running assessment. Processing %d agreement(s)", len(agreements))
 	if err != nil {
 		log.Printf("Error getting agreements: %v\n", err)
@@ -56,20 +64,29 @@ func AssessMf2cAgreements(repo model.IRepository, mf2cRepo cimi.IRepository,
 		if a.State == model.STARTED && a.Assessment == nil {
 			a.Assessment = new(model.Assessment)
 		}
+		previous := a.State
 
 		var result = AssessAgreement(&a, ma, now)
 		log.Printf("Result: %v\n", result)
 
-		for _, v := range result.GetViolations() {
-			pv := &v
-			pv, err = mf2cRepo.CreateViolation(pv)
-			if err != nil {
-				log.Printf("Error creating violation: %v", err)
+		for name, gtresult := range result.Violated {
+			for i := range gtresult.Violations {
+				pv, err := mf2cRepo.CreateViolation(&gtresult.Violations[i])
+				if err != nil {
+					log.Printf("Error creating violation: %v", err)
+				} else {
+					gtresult.Violations[i] = *pv
+					createPenalties(repo, &a, *pv)
+				}
 			}
+			result.Violated[name] = gtresult
 		}
+		storeWarnings(repo, &a, &result)
 		_, err = repo.UpdateAgreement(&a)
 		if err != nil {
 			log.Printf("Error updating agreement: %v", err)
 		}
+		countViolations(&a, &result)
+		notify(not, &a, &result, previous)
 	}
 }
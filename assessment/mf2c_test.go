@@ -17,7 +17,9 @@ limitations under the License.
 package assessment
 
 import (
+	amodel "SLALite/assessment/model"
 	"SLALite/assessment/monitor/cimiadapter"
+	"SLALite/assessment/monitor/simpleadapter"
 	"SLALite/mf2c"
 	"SLALite/model"
 	"SLALite/repositories/cimi"
@@ -61,12 +63,12 @@ func (r mf2cTestRepo) GetServiceContainerMetrics(device string, container string
 
 func TestIsNotLeader(t *testing.T) {
 	var policies = mf2c.NewPoliciesMock(false)
-	AssessMf2cAgreements(nil, nil, nil, policies)
+	AssessMf2cAgreements(nil, nil, nil, policies, nil)
 }
 
 func TestErrorGettingIsLeader(t *testing.T) {
 	var policies = failingPolicies{}
-	AssessMf2cAgreements(nil, nil, nil, policies)
+	AssessMf2cAgreements(nil, nil, nil, policies, nil)
 	// AssessMf2cAgreements should return a code or error to check behaviour
 }
 
@@ -100,7 +102,7 @@ func TestStartedAgreement(t *testing.T) {
 	mf2cRepo.CreateAgreement(&a)
 
 	ma := cimiadapter.New(mf2cRepo)
-	AssessMf2cAgreements(mf2cRepo, mf2cRepo, ma, policies)
+	AssessMf2cAgreements(mf2cRepo, mf2cRepo, ma, policies, nil)
 	pa, _ := mf2cRepo.GetAgreement("id")
 	if pa.Assessment == nil {
 		t.Errorf("Unexpected final conditions: Assessment == nil\n")
@@ -140,9 +142,57 @@ func TestStoppedAgreement(t *testing.T) {
 	mf2cRepo.CreateAgreement(&a)
 
 	ma := cimiadapter.New(mf2cRepo)
-	AssessMf2cAgreements(mf2cRepo, mf2cRepo, ma, policies)
+	AssessMf2cAgreements(mf2cRepo, mf2cRepo, ma, policies, nil)
 	pa, _ := mf2cRepo.GetAgreement("id")
 	if pa.Assessment != nil {
 		t.Errorf("Unexpected final conditions: Assessment != nil\n")
 	}
 }
+
+type cycleRecorder struct {
+	stateRecorder
+	starts     int
+	ends       int
+	agreements int
+}
+
+func (n *cycleRecorder) NotifyCycleStart(start time.Time) {
+	n.starts++
+}
+
+func (n *cycleRecorder) NotifyCycleEnd(start time.Time, agreements int) {
+	n.ends++
+	n.agreements = agreements
+}
+
+func TestMf2cNotifications(t *testing.T) {
+	var memRepo, _ = memrepository.New(nil)
+	var mf2cRepo = mf2cTestRepo{&memRepo}
+	var policies = mf2c.NewPoliciesMock(true)
+
+	violated := createAgreement("av01", provider, client, "Agreement av01", "test_value > 10")
+	violated.State = model.STARTED
+	expired := createAgreement("ae01", provider, client, "Agreement ae01", "test_value > 10")
+	expired.State = model.STARTED
+	expiration := time.Now().Add(-time.Minute)
+	expired.Details.Expiration = &expiration
+	mf2cRepo.CreateAgreement(&violated)
+	mf2cRepo.CreateAgreement(&expired)
+
+	ma := simpleadapter.New(amodel.GuaranteeData{
+		{"test_value": model.MetricValue{Key: "test_value", Value: 5, DateTime: time.Now()}},
+	})
+	not := &cycleRecorder{stateRecorder: stateRecorder{
+		changes: map[string]model.State{}, expirations: map[string]bool{}}}
+	AssessMf2cAgreements(mf2cRepo, mf2cRepo, ma, policies, not)
+
+	if not.violations != 1 {
+		t.Errorf("Unexpected notified violations. Expected: 1; Actual: %d", not.violations)
+	}
+	if !not.expirations["ae01"] || not.changes["ae01"] != model.STARTED {
+		t.Errorf("Expected expiration of ae01. Actual: %v, %v", not.expirations, not.changes)
+	}
+	if not.starts != 1 || not.ends != 1 {
+		t.Errorf("Unexpected cycle notifications: %d starts, %d ends", not.starts, not.ends)
+	}
+}
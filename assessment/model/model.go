@@ -37,11 +37,20 @@ type EvaluationGtResult struct {
 	Violations []model.Violation // violations occurred as of violated metrics
 }
 
+// EvaluationGtWarning is the result of the evaluation of the warning expression of a guarantee term
+//
+// It contains the metrics that fulfill the constraint but fail the warning, and the associated warnings.
+type EvaluationGtWarning struct {
+	Metrics  GuaranteeData   // metrics that raised warnings
+	Warnings []model.Warning // warnings raised as of the metrics
+}
+
 // Result is the result of the agreement assessment
 type Result struct {
-	Violated      map[string]EvaluationGtResult // terms that were violated
-	LastValues    map[string]ExpressionData     // last value of variables in the term
-	LastExecution map[string]time.Time          // last execution of a guarantee
+	Violated      map[string]EvaluationGtResult  // terms that were violated
+	Warned        map[string]EvaluationGtWarning // terms that raised warnings
+	LastValues    map[string]ExpressionData      // last value of variables in the term
+	LastExecution map[string]time.Time           // last execution of a guarantee
 }
 
 // GetViolations return the violations contained in a Result
@@ -55,3 +64,15 @@ func (r *Result) GetViolations() []model.Violation {
 	}
 	return result
 }
+
+// GetWarnings return the warnings contained in a Result
+func (r *Result) GetWarnings() []model.Warning {
+	result := make([]model.Warning, 0, len(r.Warned))
+
+	for _, gtresult := range r.Warned {
+		for _, w := range gtresult.Warnings {
+			result = append(result, w)
+		}
+	}
+	return result
+}
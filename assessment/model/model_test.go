@@ -55,3 +55,19 @@ func TestGetViolations(test *testing.T) {
 		test.Errorf("Unexpected number of violations. Expected: %d, Actual: %d", 1, len(violations))
 	}
 }
+
+func TestGetWarnings(test *testing.T) {
+	r := Result{
+		Warned: map[string]EvaluationGtWarning{
+			"gt1": EvaluationGtWarning{
+				Warnings: []model.Warning{model.Warning{}, model.Warning{}},
+			},
+			"gt2": EvaluationGtWarning{
+				Warnings: []model.Warning{model.Warning{}},
+			},
+		},
+	}
+	if warnings := r.GetWarnings(); len(warnings) != 3 {
+		test.Errorf("Unexpected number of warnings. Expected: %d, Actual: %d", 3, len(warnings))
+	}
+}
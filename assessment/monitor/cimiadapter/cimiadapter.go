@@ -22,12 +22,16 @@ package cimiadapter
 import (
 	assessment_model "SLALite/assessment/model"
 	"SLALite/assessment/monitor"
+	"SLALite/metrics"
 	"SLALite/model"
 	"SLALite/repositories/cimi"
 	"time"
 )
 
 const (
+	// Name is the unique identifier of this adapter
+	Name = "cimi"
+
 	// ExecTime is the name of execution time variable on mF2C
 	ExecTime = "execution_time"
 	// Availability is the name of the Availability variable on mF2C
@@ -120,11 +124,13 @@ func (ma *adapter) retrieveExecTime(gt model.Guarantee, from time.Time) []model.
 	reports := make([]cimi.ServiceOperationReport, 0, 5)
 	sis, err := ma.repository.GetServiceInstancesByAgreement(a.Id)
 	if err != nil {
+		metrics.MonitoringErrors.WithLabelValues(Name).Inc()
 		return nil
 	}
 	for _, si := range sis {
 		siReports, err := ma.repository.GetServiceOperationReportsByDate(si.Id, from)
 		if err != nil {
+			metrics.MonitoringErrors.WithLabelValues(Name).Inc()
 			return nil
 		}
 		reports = append(reports, siReports...)
@@ -148,6 +154,7 @@ func (ma *adapter) retrieveExecTime(gt model.Guarantee, from time.Time) []model.
 func (ma *adapter) retrieveAvailability(gt model.Guarantee, from, to time.Time) []model.MetricValue {
 	sis, err := ma.repository.GetServiceInstancesByAgreement(ma.agreement.Id)
 	if err != nil {
+		metrics.MonitoringErrors.WithLabelValues(Name).Inc()
 		return nil
 	}
 
@@ -157,6 +164,7 @@ func (ma *adapter) retrieveAvailability(gt model.Guarantee, from, to time.Time)
 
 			aux, err := ma.repository.GetServiceContainerMetrics("", container, from, to)
 			if err != nil {
+				metrics.MonitoringErrors.WithLabelValues(Name).Inc()
 				return nil
 			}
 			scms = append(scms, aux...)
@@ -38,6 +38,9 @@ import (
 	"time"
 )
 
+// Name is the unique identifier of this adapter
+const Name = "dummy"
+
 type monitoringAdapter struct {
 	agreement *model.Agreement
 	size      int
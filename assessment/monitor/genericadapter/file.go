@@ -0,0 +1,70 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genericadapter
+
+import (
+	"SLALite/assessment/monitor"
+	"SLALite/model"
+	"encoding/json"
+	"os"
+)
+
+// FileRetriever retrieves the metric values from a JSON file, which is read on each
+// retrieval so that it can be updated by an external process. The file contains the
+// metric values of each agreement by agreement id, e.g.:
+//
+//   { "a01": [ { "key": "availability", "value": 0.99, "datetime": "2020-01-16T17:09:45Z" } ] }
+//
+// The key of a value is the Metric of the variable; only the values in the
+// retrieval interval are returned.
+type FileRetriever struct {
+	// Path is the path of the file
+	Path string
+}
+
+// Retrieve returns a Retrieve function.
+func (r FileRetriever) Retrieve() Retrieve {
+
+	return func(agreement model.Agreement,
+		items []monitor.RetrievalItem) (map[model.Variable][]model.MetricValue, error) {
+
+		var all map[string][]model.MetricValue
+		f, err := os.Open(r.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := json.NewDecoder(f).Decode(&all); err != nil {
+			return nil, err
+		}
+
+		result := map[model.Variable][]model.MetricValue{}
+		values := all[agreement.Id]
+		for _, item := range items {
+			result[item.Var] = make([]model.MetricValue, 0)
+			for _, value := range values {
+				if value.Key != item.Var.Metric ||
+					!value.DateTime.After(item.From) || value.DateTime.After(item.To) {
+					continue
+				}
+				value.Key = item.Var.Name
+				result[item.Var] = append(result[item.Var], value)
+			}
+		}
+		return result, nil
+	}
+}
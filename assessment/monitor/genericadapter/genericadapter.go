@@ -33,11 +33,19 @@ import (
 	"SLALite/assessment"
 	amodel "SLALite/assessment/model"
 	"SLALite/assessment/monitor"
+	"SLALite/metrics"
 	"SLALite/model"
+	"math"
 	"math/rand"
+	"sort"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
+// Name is the unique identifier of this adapter
+const Name = "generic"
+
 /*
 Adapter is the type of a customizable adapter.
 
@@ -58,8 +66,10 @@ type Adapter struct {
 // Retrieve is the type of the function that makes the actual request to monitoring.
 //
 // It receives the list of variables to be able to retrieve all of them at once if possible.
+// The values retrieved are returned even if there is an error (e.g., the retrieval of
+// other variables failed).
 type Retrieve func(agreement model.Agreement,
-	items []monitor.RetrievalItem) map[model.Variable][]model.MetricValue
+	items []monitor.RetrievalItem) (map[model.Variable][]model.MetricValue, error)
 
 // Process is the type of the function that performs additional custom processing on
 // retrieved data.
@@ -104,7 +114,11 @@ func (ga *Adapter) GetValues(gt model.Guarantee,
 	a := ga.agreement
 
 	items := assessment.BuildRetrievalItems(a, gt, varnames, now)
-	unprocessed := ga.Retrieve(*a, items)
+	unprocessed, err := ga.Retrieve(*a, items)
+	if err != nil {
+		metrics.MonitoringErrors.WithLabelValues(Name).Inc()
+		log.Warnf("Error retrieving metrics of agreement %s: %v", a.Id, err)
+	}
 
 	/* process each of the series*/
 	valuesmap := map[model.Variable][]model.MetricValue{}
@@ -131,7 +145,7 @@ func lastvalues(a *model.Agreement, gt model.Guarantee) model.LastValues {
 }
 
 // DummyRetriever is a simple struct that generates a RetrieveFunction that works similar
-// to the DummyAdapter, returning random values for each variable.
+// to the DummyAdapter, returning random values for each variable (for testing purposes).
 //
 // Usage:
 //   adapter := Adapter { Retrieve: DummyRetriever{3}.RetrieveFunction() }
@@ -144,7 +158,7 @@ type DummyRetriever struct {
 func (r DummyRetriever) Retrieve() Retrieve {
 
 	return func(agreement model.Agreement,
-		items []monitor.RetrievalItem) map[model.Variable][]model.MetricValue {
+		items []monitor.RetrievalItem) (map[model.Variable][]model.MetricValue, error) {
 
 		result := map[model.Variable][]model.MetricValue{}
 		for _, item := range items {
@@ -163,7 +177,7 @@ func (r DummyRetriever) Retrieve() Retrieve {
 				result[v] = append(result[v], m)
 			}
 		}
-		return result
+		return result, nil
 	}
 }
 
@@ -177,30 +191,148 @@ func Identity(v model.Variable, values []model.MetricValue) []model.MetricValue
 // This expects that all the values are in the appropriate window. For that,
 // the Retrieve function needs to return only the values in the window. If not,
 // this function will return an invalid result.
+//
+// The aggregated value is a float64 with the datetime of the last input value.
+// Non numeric values are ignored, except by COUNT. No value is returned if the
+// aggregation cannot be calculated (e.g., RATE of a single value).
 func Aggregate(v model.Variable, values []model.MetricValue) []model.MetricValue {
 	if len(values) == 0 || v.Aggregation == nil || v.Aggregation.Type == "" {
 		return values
 	}
-	if v.Aggregation.Type == model.AVERAGE {
-		avg := average(values)
-		return []model.MetricValue{
-			model.MetricValue{
-				Key:      v.Name,
-				Value:    avg,
-				DateTime: values[len(values)-1].DateTime,
-			},
-		}
+	var result float64
+	var ok bool
+
+	switch v.Aggregation.Type {
+	case model.AVERAGE:
+		result, ok = average(values)
+	case model.MAX:
+		result, ok = extreme(values, func(a, b float64) bool { return a > b })
+	case model.MIN:
+		result, ok = extreme(values, func(a, b float64) bool { return a < b })
+	case model.SUM:
+		result, ok = sum(values), true
+	case model.COUNT:
+		result, ok = float64(len(values)), true
+	case model.PERCENTILE:
+		result, ok = percentile(values, v.Aggregation.Percentile)
+	case model.RATE:
+		result, ok = rate(values)
+	default:
+		/* fallback */
+		return values
+	}
+	if !ok {
+		return []model.MetricValue{}
+	}
+	return []model.MetricValue{
+		model.MetricValue{
+			Key:      v.Name,
+			Value:    result,
+			DateTime: values[len(values)-1].DateTime,
+		},
 	}
-	/* fallback */
-	return values
 }
 
-func average(values []model.MetricValue) float64 {
-	sum := 0.0
+// average returns the average of the numeric values; ok is false if there are none
+func average(values []model.MetricValue) (float64, bool) {
+	result := 0.0
+	n := 0
 	for _, value := range values {
-		sum += value.Value.(float64)
+		if f, ok := toFloat(value.Value); ok {
+			result += f
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
 	}
-	result := sum / float64(len(values))
+	return result / float64(n), true
+}
 
+func sum(values []model.MetricValue) float64 {
+	result := 0.0
+	for _, value := range values {
+		if f, ok := toFloat(value.Value); ok {
+			result += f
+		}
+	}
 	return result
 }
+
+// extreme returns the value that is "better" than the rest according to the
+// function (e.g., the max value if better is >)
+func extreme(values []model.MetricValue, better func(a, b float64) bool) (float64, bool) {
+	var result float64
+	found := false
+	for _, value := range values {
+		if f, ok := toFloat(value.Value); ok && (!found || better(f, result)) {
+			result = f
+			found = true
+		}
+	}
+	return result, found
+}
+
+// percentile returns the p-th percentile (0 < p <= 100) of the values,
+// using the nearest-rank method
+func percentile(values []model.MetricValue, p float64) (float64, bool) {
+	if p <= 0 || p > 100 {
+		return 0, false
+	}
+	fs := make([]float64, 0, len(values))
+	for _, value := range values {
+		if f, ok := toFloat(value.Value); ok {
+			fs = append(fs, f)
+		}
+	}
+	if len(fs) == 0 {
+		return 0, false
+	}
+	sort.Float64s(fs)
+	rank := int(math.Ceil(p / 100 * float64(len(fs))))
+	return fs[rank-1], true
+}
+
+// rate returns the per-second increase between the first and the last values
+// in time
+func rate(values []model.MetricValue) (float64, bool) {
+	var first, last *model.MetricValue
+	var vfirst, vlast float64
+	for i := range values {
+		value := &values[i]
+		f, ok := toFloat(value.Value)
+		if !ok {
+			continue
+		}
+		if first == nil || value.DateTime.Before(first.DateTime) {
+			first, vfirst = value, f
+		}
+		if last == nil || !value.DateTime.Before(last.DateTime) {
+			last, vlast = value, f
+		}
+	}
+	if first == nil {
+		return 0, false
+	}
+	elapsed := last.DateTime.Sub(first.DateTime).Seconds()
+	if elapsed <= 0 {
+		return 0, false
+	}
+	return (vlast - vfirst) / elapsed, true
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	}
+	return 0, false
+}
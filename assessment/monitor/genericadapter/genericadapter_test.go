@@ -18,8 +18,12 @@ package genericadapter
 
 import (
 	"SLALite/assessment"
+	"SLALite/assessment/monitor"
 	"SLALite/model"
 	"SLALite/utils"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 	"time"
@@ -53,10 +57,14 @@ func TestAverage(t *testing.T) {
 		{0, 1}, {1, 2}, {2, 0.5}, {3, 1.5},
 	})
 
-	var avg float64
-	if avg = average(values); avg != 1.25 {
+	if avg, ok := average(values); !ok || avg != 1.25 {
 		t.Errorf("Unexpected average. Expected: %f; Actual: %f", 1.25, avg)
 	}
+	/* non numeric values are not averaged */
+	mixed := append(values, model.MetricValue{Key: name, Value: "a", DateTime: t0.Add(4 * time.Second)})
+	if avg, ok := average(mixed); !ok || avg != 1.25 {
+		t.Errorf("Unexpected average with non numeric value. Expected: %f; Actual: %f", 1.25, avg)
+	}
 	v := model.Variable{
 		Name:        name,
 		Metric:      name,
@@ -114,6 +122,79 @@ func testAverageWrongInput(t *testing.T, v model.Variable, values []model.Metric
 	}
 }
 
+func TestAggregations(t *testing.T) {
+	name := "m"
+	t0 := time.Now()
+	values := newValues(name, t0, []m{
+		{0, 10}, {1, 40}, {2, 20}, {3, 30}, {4, 50},
+	})
+
+	check := func(ag model.Aggregation, expected float64) {
+		v := model.Variable{Name: name, Metric: name, Aggregation: &ag}
+		output := Aggregate(v, values)
+		if len(output) != 1 {
+			t.Errorf("%v: unexpected values length. Expected: %d; Actual: %d", ag, 1, len(output))
+			return
+		}
+		if output[0].Value != expected {
+			t.Errorf("%v: unexpected value. Expected: %f; Actual: %v", ag, expected, output[0].Value)
+		}
+		if output[0].DateTime != values[len(values)-1].DateTime {
+			t.Errorf("%v: unexpected datetime %v", ag, output[0].DateTime)
+		}
+	}
+	check(model.Aggregation{Type: model.MAX}, 50)
+	check(model.Aggregation{Type: model.MIN}, 10)
+	check(model.Aggregation{Type: model.SUM}, 150)
+	check(model.Aggregation{Type: model.COUNT}, 5)
+	check(model.Aggregation{Type: model.PERCENTILE, Percentile: 50}, 30)
+	check(model.Aggregation{Type: model.PERCENTILE, Percentile: 90}, 50)
+	check(model.Aggregation{Type: model.PERCENTILE, Percentile: 20}, 10)
+	check(model.Aggregation{Type: model.PERCENTILE, Percentile: 100}, 50)
+	check(model.Aggregation{Type: model.RATE, Window: 4}, 10)
+}
+
+func TestAggregationsNonComputable(t *testing.T) {
+	name := "m"
+	t0 := time.Now()
+	single := newValues(name, t0, []m{{0, 10}})
+
+	v := model.Variable{Name: name, Metric: name, Aggregation: &model.Aggregation{Type: model.RATE}}
+	if output := Aggregate(v, single); len(output) != 0 {
+		t.Errorf("Unexpected rate of single value: %v", output)
+	}
+	v.Aggregation = &model.Aggregation{Type: model.PERCENTILE, Percentile: 0}
+	if output := Aggregate(v, single); len(output) != 0 {
+		t.Errorf("Unexpected percentile 0: %v", output)
+	}
+
+	nonNumeric := []model.MetricValue{{Key: name, Value: "a", DateTime: t0}}
+	v.Aggregation = &model.Aggregation{Type: model.MAX}
+	if output := Aggregate(v, nonNumeric); len(output) != 0 {
+		t.Errorf("Unexpected max of non numeric value: %v", output)
+	}
+	v.Aggregation = &model.Aggregation{Type: model.AVERAGE}
+	if output := Aggregate(v, nonNumeric); len(output) != 0 {
+		t.Errorf("Unexpected average of non numeric value: %v", output)
+	}
+	v.Aggregation = &model.Aggregation{Type: model.COUNT}
+	if output := Aggregate(v, nonNumeric); len(output) != 1 || output[0].Value != 1.0 {
+		t.Errorf("Unexpected count of non numeric value: %v", output)
+	}
+}
+
+func TestAggregationIntValues(t *testing.T) {
+	t0 := time.Now()
+	values := []model.MetricValue{
+		{Key: "m", Value: 1, DateTime: t0},
+		{Key: "m", Value: int64(3), DateTime: t0.Add(time.Second)},
+	}
+	v := model.Variable{Name: "m", Metric: "m", Aggregation: &Average}
+	if output := Aggregate(v, values); len(output) != 1 || output[0].Value != 2.0 {
+		t.Errorf("Unexpected average of int values: %v", output)
+	}
+}
+
 func TestGenericAdapter(t *testing.T) {
 	retriever := DummyRetriever{3}
 	retrieve := retriever.Retrieve()
@@ -132,6 +213,61 @@ func TestGenericAdapter(t *testing.T) {
 	 */
 }
 
+func TestFileRetriever(t *testing.T) {
+	t0, _ := time.Parse(time.RFC3339, "2020-01-16T17:00:00Z")
+	v := model.Variable{Name: "availability", Metric: "av"}
+	items := []monitor.RetrievalItem{{Var: v, From: t0, To: t0.Add(2 * time.Minute)}}
+
+	retrieve := FileRetriever{Path: "testdata/metrics.json"}.Retrieve()
+	values, err := retrieve(model.Agreement{Id: "a01"}, items)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(values[v]) != 2 || values[v][0].Value != 0.8 || values[v][0].Key != "availability" {
+		t.Errorf("Unexpected values: %v", values[v])
+	}
+	if values, _ := retrieve(model.Agreement{Id: "a02"}, items); len(values[v]) != 0 {
+		t.Errorf("Unexpected values of other agreement: %v", values[v])
+	}
+
+	retrieve = FileRetriever{Path: "testdata/notexists.json"}.Retrieve()
+	if _, err := retrieve(model.Agreement{Id: "a01"}, items); err == nil {
+		t.Error("Expected error reading non existing file")
+	}
+}
+
+func TestPrometheusRetriever(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" || r.URL.Query().Get("query") != "up" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","error":"bad query"}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"matrix","result":[`+
+			`{"metric":{},"values":[[1579194000,"1"],[1579194015.5,"0"]]}]}}`)
+	}))
+	defer server.Close()
+
+	t0 := time.Unix(1579194000, 0)
+	v := model.Variable{Name: "availability", Metric: "up"}
+	items := []monitor.RetrievalItem{{Var: v, From: t0, To: t0.Add(time.Minute)}}
+
+	retrieve := PrometheusRetriever{URL: server.URL}.Retrieve()
+	values, err := retrieve(model.Agreement{Id: "a01"}, items)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(values[v]) != 2 || values[v][1].Value != 0.0 ||
+		!values[v][1].DateTime.Equal(t0.Add(15500*time.Millisecond)) {
+		t.Errorf("Unexpected values: %v", values[v])
+	}
+
+	items[0].Var.Metric = "down"
+	if _, err := retrieve(model.Agreement{Id: "a01"}, items); err == nil {
+		t.Error("Expected error in failed query")
+	}
+}
+
 func newVar(name string) model.Variable {
 	return model.Variable{
 		Name:   name,
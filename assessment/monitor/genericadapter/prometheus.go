@@ -0,0 +1,128 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package genericadapter
+
+import (
+	"SLALite/assessment/monitor"
+	"SLALite/model"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrometheusRetriever retrieves the metric values from the range query API of a
+// Prometheus server. The Metric of each variable is the PromQL query; the values
+// of all the returned series are used.
+type PrometheusRetriever struct {
+	// URL is the base URL of the Prometheus server (e.g., http://localhost:9090)
+	URL string
+	// Step is the resolution of the queries; 15 seconds if zero
+	Step time.Duration
+	// Client is the http.Client used in the queries; http.DefaultClient if nil
+	Client *http.Client
+}
+
+const defaultPrometheusStep = 15 * time.Second
+
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		Result []struct {
+			Values [][2]interface{} `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// Retrieve returns a Retrieve function.
+func (r PrometheusRetriever) Retrieve() Retrieve {
+
+	return func(agreement model.Agreement,
+		items []monitor.RetrievalItem) (map[model.Variable][]model.MetricValue, error) {
+
+		result := map[model.Variable][]model.MetricValue{}
+		var errs []string
+		for _, item := range items {
+			values, err := r.query(item)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", item.Var.Name, err))
+				continue
+			}
+			result[item.Var] = values
+		}
+		if len(errs) > 0 {
+			return result, fmt.Errorf("Error querying Prometheus: %s", strings.Join(errs, "; "))
+		}
+		return result, nil
+	}
+}
+
+func (r PrometheusRetriever) query(item monitor.RetrievalItem) ([]model.MetricValue, error) {
+	step := r.Step
+	if step == 0 {
+		step = defaultPrometheusStep
+	}
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	params := url.Values{}
+	params.Set("query", item.Var.Metric)
+	params.Set("start", strconv.FormatInt(item.From.Unix(), 10))
+	params.Set("end", strconv.FormatInt(item.To.Unix(), 10))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	resp, err := client.Get(strings.TrimSuffix(r.URL, "/") + "/api/v1/query_range?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("status %d: %v", resp.StatusCode, err)
+	}
+	if body.Status != "success" {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, body.Error)
+	}
+
+	result := make([]model.MetricValue, 0)
+	for _, series := range body.Data.Result {
+		for _, point := range series.Values {
+			ts, ok1 := point[0].(float64)
+			s, ok2 := point[1].(string)
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("unexpected value %v", point)
+			}
+			value, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, err
+			}
+			sec, frac := int64(ts), ts-float64(int64(ts))
+			result = append(result, model.MetricValue{
+				Key:      item.Var.Name,
+				Value:    value,
+				DateTime: time.Unix(sec, int64(frac*1e9)),
+			})
+		}
+	}
+	return result, nil
+}
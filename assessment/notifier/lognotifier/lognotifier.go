@@ -24,6 +24,9 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// Name is the unique identifier of this notifier
+const Name = "log"
+
 // LogNotifier logs violations
 type LogNotifier struct {
 }
@@ -40,3 +43,10 @@ func (n LogNotifier) NotifyViolations(agreement *model.Agreement, result *assess
 		}
 	}
 }
+
+// NotifyWarnings implements WarningNotifier interface
+func (n LogNotifier) NotifyWarnings(agreement *model.Agreement, result *assessment_model.Result) {
+	for _, w := range result.GetWarnings() {
+		log.Warnf("Warning in guarantee %v of agreement %s at %s", w.Guarantee, w.AgreementId, w.Datetime)
+	}
+}
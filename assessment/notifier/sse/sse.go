@@ -0,0 +1,331 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package sse contains a notifier that streams the events of the assessment
+(violations, warnings, changes of state of the agreements and the start and end
+of the assessment cycles) to HTTP clients as Server-Sent Events.
+
+Each event is assigned a sequential id, and the last events are kept in a bounded
+buffer, so that a client can resume a stream sending the id of the last received
+event (i.e., the Last-Event-ID header).
+*/
+package sse
+
+import (
+	assessment_model "SLALite/assessment/model"
+	"SLALite/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// ASSESSMENT_START is the type of the event sent before assessing the active agreements
+	ASSESSMENT_START model.EventType = "assessment_start"
+
+	// ASSESSMENT_END is the type of the event sent after assessing the active agreements
+	ASSESSMENT_END model.EventType = "assessment_end"
+)
+
+// ErrStreamingUnsupported is returned by Stream if the ResponseWriter cannot be flushed
+var ErrStreamingUnsupported = errors.New("Streaming not supported")
+
+// heartbeat is the period of the comments sent to keep idle connections open
+var heartbeat = 15 * time.Second
+
+// Event is an event of the stream, sent as JSON in the data field of the SSE.
+//
+// ProviderId and ClientId are the parties of the agreement of the event.
+// Guarantee, Expression and Values are only set on violations and warnings;
+// State and PreviousState on changes of state and expirations; Agreements
+// (the number of assessed agreements) on the end of an assessment.
+type Event struct {
+	Id            uint64              `json:"-"`
+	Type          model.EventType     `json:"type"`
+	Tenant        string              `json:"tenant,omitempty"`
+	AgreementId   string              `json:"agreement_id,omitempty"`
+	ProviderId    string              `json:"provider_id,omitempty"`
+	ClientId      string              `json:"client_id,omitempty"`
+	Datetime      time.Time           `json:"datetime"`
+	Guarantee     string              `json:"guarantee,omitempty"`
+	Expression    string              `json:"expression,omitempty"`
+	Values        []model.MetricValue `json:"values,omitempty"`
+	State         model.State         `json:"state,omitempty"`
+	PreviousState model.State         `json:"previous_state,omitempty"`
+	Agreements    int                 `json:"agreements,omitempty"`
+}
+
+// Query selects the events sent to a client
+type Query struct {
+	// Tenant restricts the events of agreements to the agreements of this tenant.
+	Tenant string
+
+	// AgreementIds, if not empty, restricts the events of agreements to these agreements.
+	// The events of the assessment cycles are always sent.
+	AgreementIds []string
+
+	// Restricted restricts the events of agreements to the agreements whose provider
+	// is ProviderId or whose client is ClientId (e.g., to the party of a caller that
+	// is not an admin). An empty id matches no agreement.
+	Restricted bool
+	ProviderId string
+	ClientId   string
+
+	// LastEventId, if not nil, is the id of the last event received by the client.
+	// The buffered events after it are sent before the new events.
+	LastEventId *uint64
+}
+
+func (q Query) matches(e Event) bool {
+	if e.AgreementId == "" {
+		return true
+	}
+	if e.Tenant != q.Tenant {
+		return false
+	}
+	if q.Restricted && (q.ProviderId == "" || q.ProviderId != e.ProviderId) &&
+		(q.ClientId == "" || q.ClientId != e.ClientId) {
+		return false
+	}
+	if len(q.AgreementIds) == 0 {
+		return true
+	}
+	for _, id := range q.AgreementIds {
+		if id == e.AgreementId {
+			return true
+		}
+	}
+	return false
+}
+
+// Broker is a notifier that keeps the last events of the assessment and
+// sends them to the connected clients
+type Broker struct {
+	mu      sync.Mutex
+	size    int
+	events  []Event
+	lastId  uint64
+	clients map[chan struct{}]bool
+}
+
+// New creates a Broker that keeps the last size events
+func New(size int) *Broker {
+	if size < 1 {
+		size = 1
+	}
+	return &Broker{
+		size:    size,
+		events:  make([]Event, 0, size),
+		clients: make(map[chan struct{}]bool),
+	}
+}
+
+// NotifyViolations implements ViolationNotifier interface
+func (b *Broker) NotifyViolations(agreement *model.Agreement, result *assessment_model.Result) {
+	for _, v := range result.GetViolations() {
+		b.publish(Event{
+			Type:        model.VIOLATION,
+			Tenant:      agreement.Tenant,
+			AgreementId: v.AgreementId,
+			ProviderId:  agreement.Details.Provider.Id,
+			ClientId:    agreement.Details.Client.Id,
+			Datetime:    v.Datetime,
+			Guarantee:   v.Guarantee,
+			Expression:  v.Constraint,
+			Values:      v.Values,
+		})
+	}
+}
+
+// NotifyWarnings implements WarningNotifier interface
+func (b *Broker) NotifyWarnings(agreement *model.Agreement, result *assessment_model.Result) {
+	for _, w := range result.GetWarnings() {
+		b.publish(Event{
+			Type:        model.WARNING,
+			Tenant:      agreement.Tenant,
+			AgreementId: w.AgreementId,
+			ProviderId:  agreement.Details.Provider.Id,
+			ClientId:    agreement.Details.Client.Id,
+			Datetime:    w.Datetime,
+			Guarantee:   w.Guarantee,
+			Expression:  w.Expression,
+			Values:      w.Values,
+		})
+	}
+}
+
+// NotifyStateChange implements StateNotifier interface
+func (b *Broker) NotifyStateChange(agreement *model.Agreement, previous model.State) {
+	b.publish(Event{
+		Type:          model.STATE_CHANGE,
+		Tenant:        agreement.Tenant,
+		AgreementId:   agreement.Id,
+		ProviderId:    agreement.Details.Provider.Id,
+		ClientId:      agreement.Details.Client.Id,
+		Datetime:      time.Now(),
+		State:         agreement.State,
+		PreviousState: previous,
+	})
+}
+
+// NotifyExpiration implements StateNotifier interface
+func (b *Broker) NotifyExpiration(agreement *model.Agreement) {
+	e := Event{
+		Type:        model.EXPIRATION,
+		Tenant:      agreement.Tenant,
+		AgreementId: agreement.Id,
+		ProviderId:  agreement.Details.Provider.Id,
+		ClientId:    agreement.Details.Client.Id,
+		Datetime:    time.Now(),
+		State:       agreement.State,
+	}
+	if agreement.Details.Expiration != nil {
+		e.Datetime = *agreement.Details.Expiration
+	}
+	b.publish(e)
+}
+
+// NotifyCycleStart implements CycleNotifier interface
+func (b *Broker) NotifyCycleStart(start time.Time) {
+	b.publish(Event{Type: ASSESSMENT_START, Datetime: start})
+}
+
+// NotifyCycleEnd implements CycleNotifier interface
+func (b *Broker) NotifyCycleEnd(start time.Time, agreements int) {
+	b.publish(Event{Type: ASSESSMENT_END, Datetime: time.Now(), Agreements: agreements})
+}
+
+// publish assigns the next id to e, buffers it and wakes up the clients
+func (b *Broker) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastId++
+	e.Id = b.lastId
+	if len(b.events) == b.size {
+		copy(b.events, b.events[1:])
+		b.events = b.events[:b.size-1]
+	}
+	b.events = append(b.events, e)
+
+	for c := range b.clients {
+		select {
+		case c <- struct{}{}:
+		default:
+			/* client already signaled */
+		}
+	}
+}
+
+// since returns the buffered events whose id is greater than id
+func (b *Broker) since(id uint64) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, e := range b.events {
+		if e.Id > id {
+			return append([]Event(nil), b.events[i:]...)
+		}
+	}
+	return nil
+}
+
+func (b *Broker) subscribe() (chan struct{}, uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan struct{}, 1)
+	b.clients[c] = true
+	return c, b.lastId
+}
+
+func (b *Broker) unsubscribe(c chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.clients, c)
+}
+
+/*
+Stream sends the events that match q to w until ctx is done (e.g., the client disconnects).
+
+Without q.LastEventId, only the events published after the call are sent. If the events
+after q.LastEventId are no longer buffered, or q.LastEventId is unknown (e.g., the ids were
+reset by a restart), the stream resumes from the oldest buffered event.
+
+ErrStreamingUnsupported is returned, before writing anything, if w does not support flushing.
+*/
+func (b *Broker) Stream(ctx context.Context, w http.ResponseWriter, q Query) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return ErrStreamingUnsupported
+	}
+
+	c, last := b.subscribe()
+	defer b.unsubscribe(c)
+	if q.LastEventId != nil && *q.LastEventId <= last {
+		last = *q.LastEventId
+	} else if q.LastEventId != nil {
+		/* ids are reset on restart */
+		last = 0
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		for _, e := range b.since(last) {
+			last = e.Id
+			if !q.matches(e) {
+				continue
+			}
+			if err := write(w, e); err != nil {
+				return err
+			}
+		}
+		flusher.Flush()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c:
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func write(w http.ResponseWriter, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Type, data)
+	return err
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sse
+
+import (
+	"SLALite/model"
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// received is an event as read by a client
+type received struct {
+	id    uint64
+	event string
+	data  Event
+}
+
+// readEvents reads n events from a stream
+func readEvents(t *testing.T, scanner *bufio.Scanner, n int) []received {
+	result := make([]received, 0, n)
+	var current received
+	for len(result) < n && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			current.id, _ = strconv.ParseUint(line[4:], 10, 64)
+		case strings.HasPrefix(line, "event: "):
+			current.event = line[7:]
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(line[6:]), &current.data); err != nil {
+				t.Fatalf("Error decoding event data %s: %v", line, err)
+			}
+		case line == "" && current.id != 0:
+			result = append(result, current)
+			current = received{}
+		}
+	}
+	return result
+}
+
+func newServer(b *Broker, q Query) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.Stream(r.Context(), w, q)
+	}))
+}
+
+func TestStream(t *testing.T) {
+	b := New(10)
+	b.NotifyStateChange(&model.Agreement{Id: "a01", State: model.STARTED}, model.STOPPED)
+
+	server := newServer(b, Query{AgreementIds: []string{"a01"}})
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Error connecting to stream: %v", err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Unexpected content type %s", ct)
+	}
+
+	/* events published before the connection are not sent without LastEventId */
+	b.NotifyStateChange(&model.Agreement{Id: "a02", State: model.STARTED}, model.STOPPED)
+	b.NotifyCycleStart(time.Now())
+	b.NotifyStateChange(&model.Agreement{Id: "a01", State: model.STOPPED}, model.STARTED)
+	b.NotifyCycleEnd(time.Now(), 2)
+
+	events := readEvents(t, bufio.NewScanner(res.Body), 3)
+	if len(events) != 3 {
+		t.Fatalf("Unexpected number of events. Expected: 3; Actual: %d", len(events))
+	}
+	expected := []struct {
+		id  uint64
+		typ model.EventType
+	}{{3, ASSESSMENT_START}, {4, model.STATE_CHANGE}, {5, ASSESSMENT_END}}
+	for i, e := range events {
+		if e.id != expected[i].id || e.event != string(expected[i].typ) || e.data.Type != expected[i].typ {
+			t.Errorf("Unexpected event %d: %#v", i, e)
+		}
+	}
+	if events[1].data.AgreementId != "a01" || events[1].data.PreviousState != model.STARTED {
+		t.Errorf("Unexpected state change %#v", events[1].data)
+	}
+	if events[2].data.Agreements != 2 {
+		t.Errorf("Unexpected assessment end %#v", events[2].data)
+	}
+}
+
+func TestStreamRestricted(t *testing.T) {
+	b := New(10)
+	agreement := func(id, provider, client string) *model.Agreement {
+		return &model.Agreement{
+			Id:      id,
+			State:   model.TERMINATED,
+			Details: model.Details{Provider: model.Provider{Id: provider}, Client: model.Client{Id: client}},
+		}
+	}
+	b.NotifyExpiration(agreement("a01", "p01", "c01"))
+	b.NotifyExpiration(agreement("a02", "p02", "c01"))
+	b.NotifyExpiration(agreement("a03", "p02", "c02"))
+	b.NotifyExpiration(agreement("a04", "", ""))
+	b.NotifyCycleEnd(time.Now(), 4)
+
+	check := func(q Query, expected []string) {
+		var last uint64
+		q.LastEventId = &last
+		server := newServer(b, q)
+		defer server.Close()
+		res, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Error connecting to stream: %v", err)
+		}
+		defer res.Body.Close()
+
+		events := readEvents(t, bufio.NewScanner(res.Body), len(expected))
+		for i, e := range events {
+			if e.data.AgreementId != expected[i] {
+				t.Errorf("Unexpected event %d of %#v. Expected: %s; Actual: %#v", i, q, expected[i], e.data)
+			}
+		}
+	}
+	check(Query{Restricted: true, ProviderId: "p01"}, []string{"a01", ""})
+	check(Query{Restricted: true, ClientId: "c01"}, []string{"a01", "a02", ""})
+	check(Query{Restricted: true, ProviderId: "p02", ClientId: "c01"}, []string{"a01", "a02", "a03", ""})
+	check(Query{Restricted: true}, []string{""})
+}
+
+func TestStreamResume(t *testing.T) {
+	b := New(3)
+	for i := 0; i < 5; i++ {
+		b.NotifyExpiration(&model.Agreement{Id: "a0" + strconv.Itoa(i), State: model.TERMINATED})
+	}
+
+	check := func(last uint64, expected []uint64) {
+		server := newServer(b, Query{LastEventId: &last})
+		defer server.Close()
+		res, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Error connecting to stream: %v", err)
+		}
+		defer res.Body.Close()
+
+		events := readEvents(t, bufio.NewScanner(res.Body), len(expected))
+		for i, e := range events {
+			if e.id != expected[i] {
+				t.Errorf("Unexpected event after %d. Expected: %d; Actual: %d", last, expected[i], e.id)
+			}
+		}
+	}
+	check(3, []uint64{4, 5})
+	/* events 2 and 3 are not in the buffer anymore */
+	check(1, []uint64{3, 4, 5})
+	/* unknown id */
+	check(100, []uint64{3, 4, 5})
+}
+
+func TestBufferIsBounded(t *testing.T) {
+	b := New(2)
+	for i := 0; i < 5; i++ {
+		b.NotifyCycleStart(time.Now())
+	}
+	events := b.since(0)
+	if len(events) != 2 || events[0].Id != 4 || events[1].Id != 5 {
+		t.Errorf("Unexpected buffered events %#v", events)
+	}
+}
+
+type noFlusher struct {
+	http.ResponseWriter
+}
+
+func TestStreamNotSupported(t *testing.T) {
+	b := New(2)
+	err := b.Stream(context.Background(), noFlusher{httptest.NewRecorder()}, Query{})
+	if err != ErrStreamingUnsupported {
+		t.Errorf("Expected ErrStreamingUnsupported. Actual: %v", err)
+	}
+}
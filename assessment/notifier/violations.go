@@ -18,8 +18,111 @@ package notifier
 import (
 	assessment_model "SLALite/assessment/model"
 	"SLALite/model"
+	"io"
+	"time"
 )
 
 type ViolationNotifier interface {
 	NotifyViolations(agreement *model.Agreement, result *assessment_model.Result)
 }
+
+// WarningNotifier is implemented by the notifiers that also want to be notified about
+// the warnings raised in an assessment (see model.Guarantee.Warning).
+//
+// NotifyWarnings is called when an assessment raises warnings, i.e., result.Warned is not empty.
+type WarningNotifier interface {
+	NotifyWarnings(agreement *model.Agreement, result *assessment_model.Result)
+}
+
+// StateNotifier is implemented by the notifiers that also want to be notified about the
+// changes of state of the agreements.
+type StateNotifier interface {
+	// NotifyStateChange is called when the state of an agreement changes from previous to agreement.State
+	NotifyStateChange(agreement *model.Agreement, previous model.State)
+
+	// NotifyExpiration is called when an agreement is terminated by the assessment because it has expired
+	NotifyExpiration(agreement *model.Agreement)
+}
+
+// CycleNotifier is implemented by the notifiers that also want to be notified about the
+// start and end of each assessment of the active agreements.
+type CycleNotifier interface {
+	// NotifyCycleStart is called before assessing the active agreements
+	NotifyCycleStart(start time.Time)
+
+	// NotifyCycleEnd is called after assessing the active agreements; agreements is the
+	// number of assessed agreements
+	NotifyCycleEnd(start time.Time, agreements int)
+}
+
+// Notifiers is a ViolationNotifier that forwards the notifications to a list of notifiers.
+//
+// Warnings, changes of state and assessment cycles are only forwarded to the notifiers
+// that implement WarningNotifier, StateNotifier and CycleNotifier respectively.
+type Notifiers []ViolationNotifier
+
+// NotifyViolations implements ViolationNotifier interface
+func (ns Notifiers) NotifyViolations(agreement *model.Agreement, result *assessment_model.Result) {
+	for _, n := range ns {
+		n.NotifyViolations(agreement, result)
+	}
+}
+
+// NotifyWarnings implements WarningNotifier interface
+func (ns Notifiers) NotifyWarnings(agreement *model.Agreement, result *assessment_model.Result) {
+	for _, n := range ns {
+		if wn, ok := n.(WarningNotifier); ok {
+			wn.NotifyWarnings(agreement, result)
+		}
+	}
+}
+
+// NotifyStateChange implements StateNotifier interface
+func (ns Notifiers) NotifyStateChange(agreement *model.Agreement, previous model.State) {
+	for _, n := range ns {
+		if sn, ok := n.(StateNotifier); ok {
+			sn.NotifyStateChange(agreement, previous)
+		}
+	}
+}
+
+// NotifyExpiration implements StateNotifier interface
+func (ns Notifiers) NotifyExpiration(agreement *model.Agreement) {
+	for _, n := range ns {
+		if sn, ok := n.(StateNotifier); ok {
+			sn.NotifyExpiration(agreement)
+		}
+	}
+}
+
+// NotifyCycleStart implements CycleNotifier interface
+func (ns Notifiers) NotifyCycleStart(start time.Time) {
+	for _, n := range ns {
+		if cn, ok := n.(CycleNotifier); ok {
+			cn.NotifyCycleStart(start)
+		}
+	}
+}
+
+// NotifyCycleEnd implements CycleNotifier interface
+func (ns Notifiers) NotifyCycleEnd(start time.Time, agreements int) {
+	for _, n := range ns {
+		if cn, ok := n.(CycleNotifier); ok {
+			cn.NotifyCycleEnd(start, agreements)
+		}
+	}
+}
+
+// Close closes the notifiers that implement io.Closer (e.g., to wait for the notifications
+// in progress), returning the first error.
+func (ns Notifiers) Close() error {
+	var result error
+	for _, n := range ns {
+		if c, ok := n.(io.Closer); ok {
+			if err := c.Close(); err != nil && result == nil {
+				result = err
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"SLALite/utils/fileutil"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// pendingStore saves the failed deliveries in a JSON file, until they are delivered.
+// Deliveries are not saved if path is empty.
+type pendingStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// add appends a delivery to the file, assigning it an id
+func (s *pendingStore) add(d delivery) error {
+	if s.path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries, err := s.read()
+	if err != nil {
+		return err
+	}
+	d.ID = uuid.New().String()
+	return s.write(append(deliveries, d))
+}
+
+// list returns the saved deliveries, which are kept in the file until removed.
+// The deliveries saved without id are assigned one.
+func (s *pendingStore) list() ([]delivery, error) {
+	if s.path == "" {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	changed := false
+	for i := range deliveries {
+		if deliveries[i].ID == "" {
+			deliveries[i].ID = uuid.New().String()
+			changed = true
+		}
+	}
+	if changed {
+		if err := s.write(deliveries); err != nil {
+			return nil, err
+		}
+	}
+	return deliveries, nil
+}
+
+// remove deletes the delivery identified by id from the file
+func (s *pendingStore) remove(id string) error {
+	if s.path == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deliveries, err := s.read()
+	if err != nil {
+		return err
+	}
+	result := make([]delivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		if d.ID != id {
+			result = append(result, d)
+		}
+	}
+	if len(result) == len(deliveries) {
+		return nil
+	}
+	if len(result) == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return s.write(result)
+}
+
+func (s *pendingStore) read() ([]delivery, error) {
+	var result []delivery
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("Error reading pending webhook deliveries %s: %v", s.path, err)
+	}
+	return result, nil
+}
+
+func (s *pendingStore) write(deliveries []delivery) error {
+	data, err := json.Marshal(deliveries)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFile(s.path, data)
+}
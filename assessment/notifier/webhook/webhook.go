@@ -0,0 +1,439 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package webhook contains a ViolationNotifier that POSTs the events of the assessment
+(violations, warnings and changes of state of the agreements) to a list of URLs, and
+to the URLs of the matching subscriptions (see model.Subscription).
+
+Each event is sent as an Event in a JSON body, signed with HMAC-SHA256; the signature
+is sent in the SignatureHeader header as "sha256=<hex digest>". The body is signed with
+the secret of the subscription, or with the configured secret for the configured URLs.
+Nothing is signed if there is no secret.
+
+Deliveries are made in background, retrying with exponential backoff. The deliveries that
+keep failing are saved to a pending file, and retried when a new Notifier is created
+(i.e., after a restart).
+*/
+package webhook
+
+import (
+	assessment_model "SLALite/assessment/model"
+	"SLALite/model"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// Name is the unique identifier of this notifier
+	Name = "webhook"
+
+	// SignatureHeader is the header that contains the signature of the body
+	SignatureHeader = "X-SLA-Signature"
+
+	webhookConfigName = "webhook.yml"
+
+	urlsPropertyName    = "urls"
+	secretPropertyName  = "secret"
+	retriesPropertyName = "retries"
+	backoffPropertyName = "backoff"
+	timeoutPropertyName = "timeout"
+	pendingPropertyName = "pending"
+
+	defaultRetries = 5
+	defaultBackoff = "1s"
+	defaultTimeout = "10s"
+	defaultPending = "webhook-pending.json"
+)
+
+// Event is the payload sent to the webhooks.
+//
+// Guarantee, Expression and Values are only set on violations and warnings;
+// State and PreviousState are only set on changes of state and expirations.
+type Event struct {
+	Type          model.EventType     `json:"type"`
+	Tenant        string              `json:"tenant,omitempty"`
+	AgreementId   string              `json:"agreement_id"`
+	Guarantee     string              `json:"guarantee,omitempty"`
+	Datetime      time.Time           `json:"datetime"`
+	Expression    string              `json:"expression,omitempty"`
+	Values        []model.MetricValue `json:"values,omitempty"`
+	State         model.State         `json:"state,omitempty"`
+	PreviousState model.State         `json:"previous_state,omitempty"`
+}
+
+// Subscriptions is the source of the subscriptions of a Notifier (e.g., a model.IRepository)
+type Subscriptions interface {
+	GetAllSubscriptions() (model.Subscriptions, error)
+}
+
+// target is a webhook where an event is sent, and the secret to sign it
+type target struct {
+	url    string
+	secret []byte
+}
+
+// delivery is a request to be sent to a webhook
+type delivery struct {
+	// ID identifies the delivery in the pending file; empty if it is not saved
+	ID   string          `json:"id,omitempty"`
+	URL  string          `json:"url"`
+	Body json.RawMessage `json:"body"`
+	// Signature is the value of the SignatureHeader; empty if the body is not signed
+	Signature string `json:"signature,omitempty"`
+}
+
+// Notifier sends the events of the assessment to webhooks
+type Notifier struct {
+	urls          []string
+	subscriptions Subscriptions
+	secret        []byte
+	retries       int
+	backoff       time.Duration
+	client        *http.Client
+	pending       *pendingStore
+	wg            sync.WaitGroup
+	stop          chan struct{}
+	once          sync.Once
+}
+
+// NewDefaultConfig gets a default configuration for a Notifier
+func NewDefaultConfig() (*viper.Viper, error) {
+	config := viper.New()
+
+	config.SetEnvPrefix("sla") // Env vars start with 'SLA_'
+	config.AutomaticEnv()
+	config.SetConfigName(webhookConfigName)
+	config.AddConfigPath(model.UnixConfigPath)
+	setDefaults(config)
+
+	confError := config.ReadInConfig()
+	if confError != nil {
+		log.Println("Can't find webhook configuration file: " + confError.Error())
+		log.Println("Using defaults")
+	}
+
+	return config, confError
+}
+
+func setDefaults(config *viper.Viper) {
+	config.SetDefault(secretPropertyName, "")
+	config.SetDefault(retriesPropertyName, defaultRetries)
+	config.SetDefault(backoffPropertyName, defaultBackoff)
+	config.SetDefault(timeoutPropertyName, defaultTimeout)
+	config.SetDefault(pendingPropertyName, defaultPending)
+}
+
+func logConfig(config *viper.Viper, urls []string) {
+	log.Printf("Webhook configuration\n"+
+		"\tURLs: %v\n"+
+		"\tRetries: %d\n"+
+		"\tBackoff: %v\n"+
+		"\tTimeout: %v\n"+
+		"\tPending file: %s\n",
+		urls,
+		config.GetInt(retriesPropertyName),
+		config.GetDuration(backoffPropertyName),
+		config.GetDuration(timeoutPropertyName),
+		config.GetString(pendingPropertyName))
+}
+
+// urls returns the configured URLs. The setting may be a list or a comma separated string.
+func urls(config *viper.Viper) []string {
+	result := make([]string, 0)
+	for _, item := range config.GetStringSlice(urlsPropertyName) {
+		for _, url := range strings.Split(item, ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				result = append(result, url)
+			}
+		}
+	}
+	return result
+}
+
+// New creates a new Notifier, and retries the pending deliveries of previous executions.
+// The events are sent to the configured URLs and, if subscriptions is not nil, to the
+// subscriptions that match each event.
+//
+// Close must be called to wait for the deliveries in progress.
+func New(config *viper.Viper, subscriptions Subscriptions) (*Notifier, error) {
+	if config == nil {
+		config, _ = NewDefaultConfig()
+	} else {
+		setDefaults(config)
+	}
+	return newNotifier(config, urls(config), subscriptions)
+}
+
+// NewSubscriptions creates a new Notifier that only sends the events to the subscriptions
+// that match each event, ignoring the configured URLs. It is used when the webhook
+// notifier is not enabled, so that the subscriptions are always notified.
+func NewSubscriptions(config *viper.Viper, subscriptions Subscriptions) (*Notifier, error) {
+	if config == nil {
+		config, _ = NewDefaultConfig()
+	} else {
+		setDefaults(config)
+	}
+	return newNotifier(config, []string{}, subscriptions)
+}
+
+func newNotifier(config *viper.Viper, urls []string, subscriptions Subscriptions) (*Notifier, error) {
+	logConfig(config, urls)
+
+	n := &Notifier{
+		urls:          urls,
+		subscriptions: subscriptions,
+		secret:        []byte(config.GetString(secretPropertyName)),
+		retries:       config.GetInt(retriesPropertyName),
+		backoff:       config.GetDuration(backoffPropertyName),
+		client:        &http.Client{Timeout: config.GetDuration(timeoutPropertyName)},
+		pending:       &pendingStore{path: config.GetString(pendingPropertyName)},
+		stop:          make(chan struct{}),
+	}
+	if len(n.urls) == 0 && subscriptions == nil {
+		return nil, errors.New("No webhook urls configured")
+	}
+
+	deliveries, err := n.pending.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) > 0 {
+		log.Infof("Retrying %d pending webhook deliveries", len(deliveries))
+	}
+	for _, d := range deliveries {
+		n.dispatch(d)
+	}
+	return n, nil
+}
+
+// NotifyViolations implements ViolationNotifier interface
+func (n *Notifier) NotifyViolations(agreement *model.Agreement, result *assessment_model.Result) {
+	targets := n.targets(agreement, model.VIOLATION)
+	for _, v := range result.GetViolations() {
+		n.send(targets, Event{
+			Type:        model.VIOLATION,
+			Tenant:      agreement.Tenant,
+			AgreementId: v.AgreementId,
+			Guarantee:   v.Guarantee,
+			Datetime:    v.Datetime,
+			Expression:  v.Constraint,
+			Values:      v.Values,
+		})
+	}
+}
+
+// NotifyWarnings implements WarningNotifier interface
+func (n *Notifier) NotifyWarnings(agreement *model.Agreement, result *assessment_model.Result) {
+	targets := n.targets(agreement, model.WARNING)
+	for _, w := range result.GetWarnings() {
+		n.send(targets, Event{
+			Type:        model.WARNING,
+			Tenant:      agreement.Tenant,
+			AgreementId: w.AgreementId,
+			Guarantee:   w.Guarantee,
+			Datetime:    w.Datetime,
+			Expression:  w.Expression,
+			Values:      w.Values,
+		})
+	}
+}
+
+// NotifyStateChange implements StateNotifier interface
+func (n *Notifier) NotifyStateChange(agreement *model.Agreement, previous model.State) {
+	n.send(n.targets(agreement, model.STATE_CHANGE), Event{
+		Type:          model.STATE_CHANGE,
+		Tenant:        agreement.Tenant,
+		AgreementId:   agreement.Id,
+		Datetime:      time.Now(),
+		State:         agreement.State,
+		PreviousState: previous,
+	})
+}
+
+// NotifyExpiration implements StateNotifier interface
+func (n *Notifier) NotifyExpiration(agreement *model.Agreement) {
+	e := Event{
+		Type:        model.EXPIRATION,
+		Tenant:      agreement.Tenant,
+		AgreementId: agreement.Id,
+		Datetime:    time.Now(),
+		State:       agreement.State,
+	}
+	if agreement.Details.Expiration != nil {
+		e.Datetime = *agreement.Details.Expiration
+	}
+	n.send(n.targets(agreement, model.EXPIRATION), e)
+}
+
+// targets returns the webhooks where an event of type t of the agreement must be sent:
+// the configured URLs and the matching subscriptions of the tenant of the agreement.
+func (n *Notifier) targets(agreement *model.Agreement, t model.EventType) []target {
+	result := make([]target, 0, len(n.urls))
+	for _, url := range n.urls {
+		result = append(result, target{url: url, secret: n.secret})
+	}
+	if n.subscriptions == nil {
+		return result
+	}
+	source, err := n.source(agreement.Tenant)
+	if err != nil {
+		log.Errorf("Error getting subscriptions of tenant '%s': %v", agreement.Tenant, err)
+		return result
+	}
+	subscriptions, err := source.GetAllSubscriptions()
+	if err != nil {
+		log.Errorf("Error getting subscriptions: %v", err)
+		return result
+	}
+	for _, s := range subscriptions {
+		if s.Matches(agreement, t) {
+			result = append(result, target{url: s.Url, secret: []byte(s.Secret)})
+		}
+	}
+	return result
+}
+
+// source returns the source of the subscriptions of tenant, which must implement
+// model.TenantRepository for tenants other than model.DefaultTenant
+func (n *Notifier) source(tenant string) (Subscriptions, error) {
+	if tenant == model.DefaultTenant {
+		return n.subscriptions, nil
+	}
+	tr, ok := n.subscriptions.(model.TenantRepository)
+	if !ok {
+		return nil, model.ErrTenantsUnsupported
+	}
+	return tr.ForTenant(tenant)
+}
+
+// send delivers an event to targets in background
+func (n *Notifier) send(targets []target, e Event) {
+	if len(targets) == 0 {
+		return
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Errorf("Error marshalling webhook event: %v", err)
+		return
+	}
+	for _, t := range targets {
+		d := delivery{URL: t.url, Body: body}
+		if len(t.secret) > 0 {
+			d.Signature = Sign(t.secret, body)
+		}
+		n.dispatch(d)
+	}
+}
+
+/*
+Close waits for the deliveries in progress. The deliveries waiting for a retry
+are not retried again, but saved as pending.
+*/
+func (n *Notifier) Close() error {
+	n.once.Do(func() {
+		close(n.stop)
+	})
+	n.wg.Wait()
+	return nil
+}
+
+// dispatch delivers d in background, saving it as pending if all the retries fail.
+// A pending delivery (i.e., with ID) is removed from the pending file once delivered.
+func (n *Notifier) dispatch(d delivery) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		err := n.deliver(d)
+		switch {
+		case err == nil && d.ID != "":
+			if err := n.pending.remove(d.ID); err != nil {
+				log.Errorf("Error removing pending webhook delivery: %v", err)
+			}
+		case err != nil:
+			log.Errorf("Error delivering webhook to %s: %v", d.URL, err)
+			if d.ID != "" {
+				/* it is still in the pending file */
+				return
+			}
+			if err := n.pending.add(d); err != nil {
+				log.Errorf("Error saving pending webhook delivery: %v", err)
+			}
+		}
+	}()
+}
+
+// deliver sends d, retrying with exponential backoff until it succeeds, the retries
+// are exhausted or the notifier is closed.
+func (n *Notifier) deliver(d delivery) error {
+	wait := n.backoff
+	err := n.post(d)
+	for i := 0; err != nil && i < n.retries; i++ {
+		log.Debugf("Error delivering webhook to %s: %v. Retrying in %v", d.URL, err, wait)
+		select {
+		case <-n.stop:
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+		err = n.post(d)
+	}
+	return err
+}
+
+func (n *Notifier) post(d delivery) error {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.Signature != "" {
+		req.Header.Set(SignatureHeader, d.Signature)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the value of the SignatureHeader of a body signed with secret.
+//
+// Receivers can check the authenticity of a body comparing (with hmac.Equal) the
+// header value with the result of Sign.
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,318 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	assessment_model "SLALite/assessment/model"
+	"SLALite/model"
+	"crypto/hmac"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// receiver is a webhook endpoint that fails the first requests
+type receiver struct {
+	mu         sync.Mutex
+	failures   int
+	requests   int
+	events     []Event
+	signatures []string
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.requests++
+	if rc.requests <= rc.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	var e Event
+	json.Unmarshal(body, &e)
+	rc.events = append(rc.events, e)
+	rc.signatures = append(rc.signatures, r.Header.Get(SignatureHeader))
+	if r.Header.Get(SignatureHeader) != Sign([]byte("secret"), body) {
+		rc.signatures[len(rc.signatures)-1] = "invalid"
+	}
+}
+
+func newConfig(t *testing.T, urls ...string) *viper.Viper {
+	config := viper.New()
+	config.Set(urlsPropertyName, urls)
+	config.Set(secretPropertyName, "secret")
+	config.Set(retriesPropertyName, 2)
+	config.Set(backoffPropertyName, "1ms")
+	config.Set(pendingPropertyName, filepath.Join(tempDir(t), "pending.json"))
+	return config
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func newResult() *assessment_model.Result {
+	now := time.Now()
+	return &assessment_model.Result{
+		Violated: map[string]assessment_model.EvaluationGtResult{
+			"gt": assessment_model.EvaluationGtResult{
+				Violations: []model.Violation{
+					model.Violation{
+						Id:          "v01",
+						AgreementId: "a01",
+						Guarantee:   "gt",
+						Datetime:    now,
+						Constraint:  "m < 10",
+						Values:      []model.MetricValue{{Key: "m", Value: 11.0, DateTime: now}},
+					},
+				},
+			},
+		},
+		Warned: map[string]assessment_model.EvaluationGtWarning{
+			"gt": assessment_model.EvaluationGtWarning{
+				Warnings: []model.Warning{
+					model.Warning{AgreementId: "a01", Guarantee: "gt", Datetime: now, Expression: "m < 5"},
+				},
+			},
+		},
+	}
+}
+
+func TestNotify(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	n, err := New(newConfig(t, server.URL), nil)
+	if err != nil {
+		t.Fatalf("Error creating notifier: %v", err)
+	}
+	result := newResult()
+	n.NotifyViolations(&model.Agreement{Id: "a01"}, result)
+	n.NotifyWarnings(&model.Agreement{Id: "a01"}, result)
+	n.wg.Wait()
+	n.Close()
+
+	if len(rc.events) != 2 {
+		t.Fatalf("Unexpected number of events. Expected: 2; Actual: %d", len(rc.events))
+	}
+	for i, e := range rc.events {
+		if e.AgreementId != "a01" || e.Guarantee != "gt" {
+			t.Errorf("Unexpected event %#v", e)
+		}
+		if rc.signatures[i] == "invalid" {
+			t.Errorf("Invalid signature of event %#v", e)
+		}
+	}
+	types := map[model.EventType]bool{rc.events[0].Type: true, rc.events[1].Type: true}
+	if !types[model.VIOLATION] || !types[model.WARNING] {
+		t.Errorf("Unexpected event types %v", types)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	rc := &receiver{failures: 2}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	config := newConfig(t, server.URL)
+	n, _ := New(config, nil)
+	n.NotifyViolations(&model.Agreement{Id: "a01"}, newResult())
+	n.wg.Wait()
+	n.Close()
+
+	if rc.requests != 3 || len(rc.events) != 1 {
+		t.Errorf("Unexpected requests. Expected: 3 (1 event); Actual: %d (%d events)", rc.requests, len(rc.events))
+	}
+	if _, err := os.Stat(config.GetString(pendingPropertyName)); !os.IsNotExist(err) {
+		t.Errorf("Unexpected pending file: %v", err)
+	}
+}
+
+func TestPending(t *testing.T) {
+	rc := &receiver{failures: 3}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	config := newConfig(t, server.URL)
+	n, _ := New(config, nil)
+	n.NotifyViolations(&model.Agreement{Id: "a01"}, newResult())
+	n.wg.Wait()
+	n.Close()
+
+	if rc.requests != 3 || len(rc.events) != 0 {
+		t.Fatalf("Unexpected requests. Expected: 3 (0 events); Actual: %d (%d events)", rc.requests, len(rc.events))
+	}
+	if _, err := os.Stat(config.GetString(pendingPropertyName)); err != nil {
+		t.Fatalf("Expected pending file: %v", err)
+	}
+
+	/* restart */
+	n, err := New(config, nil)
+	if err != nil {
+		t.Fatalf("Error creating notifier: %v", err)
+	}
+	n.wg.Wait()
+	n.Close()
+	if len(rc.events) != 1 || rc.events[0].Type != model.VIOLATION {
+		t.Errorf("Pending delivery not retried: %#v", rc.events)
+	}
+	if _, err := os.Stat(config.GetString(pendingPropertyName)); !os.IsNotExist(err) {
+		t.Errorf("Unexpected pending file: %v", err)
+	}
+}
+
+func TestNoUrls(t *testing.T) {
+	if _, err := New(newConfig(t), nil); err == nil {
+		t.Error("Expected error creating notifier without urls")
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"violation"}`)
+	s := Sign([]byte("secret"), body)
+	if !hmac.Equal([]byte(s), []byte(Sign([]byte("secret"), body))) {
+		t.Error("Signature is not deterministic")
+	}
+	if s == Sign([]byte("other"), body) {
+		t.Error("Signature does not depend on secret")
+	}
+}
+
+func TestCloseSavesPending(t *testing.T) {
+	rc := &receiver{failures: 100}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	config := newConfig(t, server.URL)
+	config.Set(backoffPropertyName, "1h")
+	n, _ := New(config, nil)
+	n.NotifyViolations(&model.Agreement{Id: "a01"}, newResult())
+	n.Close()
+
+	deliveries, err := n.pending.list()
+	if err != nil || len(deliveries) != 1 {
+		t.Errorf("Unexpected pending deliveries: %v (%v)", deliveries, err)
+	}
+}
+
+func TestPendingKeptUntilDelivered(t *testing.T) {
+	rc := &receiver{failures: 6}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	config := newConfig(t, server.URL)
+	n, _ := New(config, nil)
+	n.NotifyViolations(&model.Agreement{Id: "a01"}, newResult())
+	n.wg.Wait()
+	n.Close()
+	saved, err := n.pending.list()
+	if err != nil || len(saved) != 1 {
+		t.Fatalf("Unexpected pending deliveries: %v (%v)", saved, err)
+	}
+
+	/* restart; the retry fails again */
+	n, _ = New(config, nil)
+	n.wg.Wait()
+	n.Close()
+	deliveries, err := n.pending.list()
+	if err != nil || len(deliveries) != 1 || deliveries[0].ID != saved[0].ID {
+		t.Fatalf("Unexpected pending deliveries. Expected: %v; Actual: %v (%v)", saved, deliveries, err)
+	}
+
+	/* restart; the retry succeeds */
+	n, _ = New(config, nil)
+	n.wg.Wait()
+	n.Close()
+	if len(rc.events) != 1 {
+		t.Errorf("Pending delivery not retried: %#v", rc.events)
+	}
+	if _, err := os.Stat(config.GetString(pendingPropertyName)); !os.IsNotExist(err) {
+		t.Errorf("Unexpected pending file: %v", err)
+	}
+}
+
+type subscriptions model.Subscriptions
+
+func (s subscriptions) GetAllSubscriptions() (model.Subscriptions, error) {
+	return model.Subscriptions(s), nil
+}
+
+func TestSubscriptions(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	subs := subscriptions{
+		model.Subscription{Id: "s01", Url: server.URL, AgreementId: "a01", Events: []model.EventType{model.STATE_CHANGE},
+			Secret: "secret"},
+		model.Subscription{Id: "s02", Url: server.URL, AgreementId: "a02", Secret: "secret"},
+		model.Subscription{Id: "s03", Url: server.URL, ProviderId: "p01", Events: []model.EventType{model.EXPIRATION},
+			Secret: "secret"},
+	}
+	config := newConfig(t)
+	config.Set(secretPropertyName, "other")
+	n, err := New(config, subs)
+	if err != nil {
+		t.Fatalf("Error creating notifier: %v", err)
+	}
+	agreement := &model.Agreement{
+		Id:    "a01",
+		State: model.TERMINATED,
+		Details: model.Details{
+			Provider: model.Provider{Id: "p01"},
+		},
+	}
+	n.NotifyViolations(agreement, newResult())
+	n.NotifyStateChange(agreement, model.STARTED)
+	n.NotifyExpiration(agreement)
+	n.wg.Wait()
+	n.Close()
+
+	if len(rc.events) != 2 {
+		t.Fatalf("Unexpected number of events. Expected: 2; Actual: %d", len(rc.events))
+	}
+	types := map[model.EventType]bool{rc.events[0].Type: true, rc.events[1].Type: true}
+	if !types[model.STATE_CHANGE] || !types[model.EXPIRATION] {
+		t.Errorf("Unexpected event types %v", types)
+	}
+	for _, e := range rc.events {
+		if e.Type == model.STATE_CHANGE && (e.State != model.TERMINATED || e.PreviousState != model.STARTED) {
+			t.Errorf("Unexpected state change event %#v", e)
+		}
+	}
+	for _, s := range rc.signatures {
+		if s == "invalid" {
+			t.Errorf("Events not signed with the secret of the subscription: %v", rc.signatures)
+		}
+	}
+}
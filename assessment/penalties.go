@@ -0,0 +1,126 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package assessment
+
+import (
+	amodel "SLALite/assessment/model"
+	"SLALite/model"
+	"strconv"
+
+	"github.com/Knetic/govaluate"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// EvaluatePenalties returns the penalties to apply because of a violation: one for each
+// PenaltyDef of the violated guarantee term.
+//
+// The value of a PenaltyDef may be an expression on the metrics of the violated
+// constraint (e.g. "(t - 100) * 0.5"); in this case, the value of the penalty is the
+// result of the expression evaluated with the values of the violation.
+func EvaluatePenalties(a *model.Agreement, v model.Violation) []model.Penalty {
+	var defs []model.PenaltyDef
+	for _, gt := range a.Details.Guarantees {
+		if gt.Name == v.Guarantee {
+			defs = gt.Penalties
+			break
+		}
+	}
+
+	result := make([]model.Penalty, 0, len(defs))
+	for _, def := range defs {
+		def.Value = evaluatePenaltyValue(def.Value, v)
+		p := model.Penalty{
+			AgreementId: a.Id,
+			Guarantee:   v.Guarantee,
+			ViolationId: v.Id,
+			Datetime:    v.Datetime,
+			Definition:  def,
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// evaluatePenaltyValue returns the result of evaluating value as an expression on the
+// metric values of the violation. The value is returned unchanged if it is not a valid
+// expression, or it does not evaluate to a number.
+func evaluatePenaltyValue(value string, v model.Violation) string {
+	expression, err := govaluate.NewEvaluableExpression(value)
+	if err != nil {
+		return value
+	}
+	params := make(map[string]interface{}, len(v.Values))
+	for _, m := range v.Values {
+		params[m.Key] = m.Value
+	}
+	result, err := expression.Evaluate(params)
+	if err != nil {
+		log.Debugf("Penalty value '%s' is not an expression: %s", value, err.Error())
+		return value
+	}
+	if n, ok := result.(float64); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return value
+}
+
+// storeViolations persists the violations of an assessment result, and the penalties
+// derived from them. The violations in the result are updated with their new ids.
+func storeViolations(repo model.IRepository, a *model.Agreement, result *amodel.Result) {
+	for name, gtresult := range result.Violated {
+		for i := range gtresult.Violations {
+			v := &gtresult.Violations[i]
+			if v.Id == "" {
+				v.Id = uuid.New().String()
+			}
+			if _, err := repo.CreateViolation(v); err != nil {
+				log.Errorf("Error creating violation of agreement %s: %s", a.Id, err.Error())
+				continue
+			}
+			createPenalties(repo, a, *v)
+		}
+		result.Violated[name] = gtresult
+	}
+}
+
+// storeWarnings persists the warnings of an assessment result. The warnings in the
+// result are updated with their new ids.
+func storeWarnings(repo model.IRepository, a *model.Agreement, result *amodel.Result) {
+	for name, gtresult := range result.Warned {
+		for i := range gtresult.Warnings {
+			w := &gtresult.Warnings[i]
+			if w.Id == "" {
+				w.Id = uuid.New().String()
+			}
+			if _, err := repo.CreateWarning(w); err != nil {
+				log.Errorf("Error creating warning of agreement %s: %s", a.Id, err.Error())
+			}
+		}
+		result.Warned[name] = gtresult
+	}
+}
+
+// createPenalties persists the penalties that apply because of a stored violation
+func createPenalties(repo model.IRepository, a *model.Agreement, v model.Violation) {
+	for _, p := range EvaluatePenalties(a, v) {
+		p.Id = uuid.New().String()
+		if _, err := repo.CreatePenalty(&p); err != nil {
+			log.Errorf("Error creating penalty of agreement %s: %s", a.Id, err.Error())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package assessment
+
+import (
+	assessment_model "SLALite/assessment/model"
+	"SLALite/assessment/monitor/simpleadapter"
+	"SLALite/model"
+	"testing"
+)
+
+func TestEvaluatePenalties(t *testing.T) {
+	a := createAgreement("a-penalties", p1, c2, "penalties", "t < 100")
+	a.Details.Guarantees[0].Penalties = []model.PenaltyDef{
+		{Type: "discount", Value: "10", Unit: "%"},
+		{Type: "discount", Value: "(t - 100) * 0.5", Unit: "euro"},
+		{Type: "service", Value: "upgrade", Unit: ""},
+	}
+	v := model.Violation{
+		Id:          "v01",
+		AgreementId: a.Id,
+		Guarantee:   "TestGuarantee",
+		Datetime:    t_(1),
+		Values:      []model.MetricValue{{Key: "t", Value: 120, DateTime: t_(1)}},
+	}
+
+	penalties := EvaluatePenalties(&a, v)
+	if len(penalties) != 3 {
+		t.Fatalf("Unexpected number of penalties. Expected: 3; Actual: %d", len(penalties))
+	}
+	expected := []string{"10", "10", "upgrade"}
+	for i, p := range penalties {
+		if p.AgreementId != a.Id || p.Guarantee != v.Guarantee || p.ViolationId != v.Id || !p.Datetime.Equal(v.Datetime) {
+			t.Errorf("Unexpected penalty: %v", p)
+		}
+		if p.Definition.Value != expected[i] {
+			t.Errorf("Unexpected penalty value. Expected: %s; Actual: %s", expected[i], p.Definition.Value)
+		}
+	}
+
+	v.Guarantee = "other"
+	if penalties := EvaluatePenalties(&a, v); len(penalties) != 0 {
+		t.Errorf("Unexpected penalties of guarantee without penalties: %v", penalties)
+	}
+}
+
+func TestAssessActiveAgreementsStoresPenalties(t *testing.T) {
+	a := createAgreement("ap01", p1, c2, "Agreement ap01", "m >= 10")
+	a.Details.Guarantees[0].Penalties = []model.PenaltyDef{{Type: "discount", Value: "10 - m", Unit: "%"}}
+	a.State = model.STARTED
+	repo.CreateAgreement(&a)
+	defer repo.DeleteAgreement(&a)
+
+	var values = assessment_model.GuaranteeData{
+		{"m": model.MetricValue{Key: "m", Value: 5, DateTime: t_(0)}},
+		{"m": model.MetricValue{Key: "m", Value: 15, DateTime: t_(1)}},
+		{"m": model.MetricValue{Key: "m", Value: 8, DateTime: t_(2)}},
+	}
+	AssessActiveAgreements(repo, simpleadapter.New(values), nil)
+
+	violations, _, err := repo.GetViolations(model.ViolationQuery{AgreementId: a.Id})
+	if err != nil || len(violations) != 2 {
+		t.Fatalf("Unexpected violations. Expected: 2; Actual: %v; err: %v", violations, err)
+	}
+	penalties, _, err := repo.GetPenalties(model.PenaltyQuery{AgreementId: a.Id})
+	if err != nil || len(penalties) != 2 {
+		t.Fatalf("Unexpected penalties. Expected: 2; Actual: %v; err: %v", penalties, err)
+	}
+	for i, expected := range []string{"5", "2"} {
+		p := penalties[i]
+		if p.ViolationId != violations[i].Id || p.Definition.Value != expected {
+			t.Errorf("Unexpected penalty. Expected value %s for violation %s; Actual: %v", expected, violations[i].Id, p)
+		}
+	}
+}
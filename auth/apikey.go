@@ -0,0 +1,59 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIKeys is an Authenticator of static API keys, sent in the APIKeyHeader header.
+// The keys are indexed by the name of their owner, which is the Subject of the Principal.
+type APIKeys map[string]string
+
+func parseAPIKeys(items []string) (APIKeys, error) {
+	result := make(APIKeys, len(items))
+	for i, item := range items {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			/* the value is not logged, as it may be a key */
+			return nil, fmt.Errorf("API key %d must be 'name:key'", i+1)
+		}
+		result[parts[0]] = parts[1]
+	}
+	return result, nil
+}
+
+// Authenticate implements Authenticator interface
+func (ks APIKeys) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+	var result *Principal
+	/* all keys are compared to not leak the matching one by timing */
+	for name, k := range ks {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			result = &Principal{Subject: name}
+		}
+	}
+	if result == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return result, nil
+}
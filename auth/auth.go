@@ -0,0 +1,223 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+/*
+Package auth contains the authentication of the callers of the REST API.
+
+A caller is authenticated with a static API key (sent in the APIKeyHeader header)
+or with a JWT bearer token (sent in the Authorization header), whose signature is
+validated with the keys of a JWKS file or with a public key.
+
+Authentication is disabled if no API keys nor JWT keys are configured.
+
+An authenticated caller receives a Grant: its roles (ADMIN, PROVIDER or CLIENT) and the
+id of the provider or client it acts as, which scope the operations it is allowed to do
+(see RoleMapper). The Grant may also contain the tenant of the caller, which restricts
+the entities it accesses to those of the tenant.
+*/
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// APIKeyHeader is the header that contains the API key of a caller
+	APIKeyHeader = "X-API-Key"
+
+	apiKeysPropertyName       = "apiKeys"
+	jwksPropertyName          = "jwks"
+	jwtPublicKeyPropertyName  = "jwtPublicKey"
+	jwtIssuerPropertyName     = "jwtIssuer"
+	jwtAudiencePropertyName   = "jwtAudience"
+	apiKeyRolesPropertyName   = "apiKeyRoles"
+	rolesClaimPropertyName    = "rolesClaim"
+	partyClaimPropertyName    = "partyClaim"
+	apiKeyTenantsPropertyName = "apiKeyTenants"
+	tenantClaimPropertyName   = "tenantClaim"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator if the request does not
+	// contain the credentials it checks
+	ErrNoCredentials = errors.New("No credentials")
+
+	// ErrInvalidCredentials is returned by an Authenticator if the credentials
+	// of the request are not valid
+	ErrInvalidCredentials = errors.New("Invalid credentials")
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	// Subject identifies the caller: the name of the API key or the sub claim of the token
+	Subject string
+
+	// Claims are the claims of the token; empty if authenticated with an API key
+	Claims map[string]interface{}
+
+	// Grant contains the roles and party of the caller
+	Grant
+}
+
+// Authenticator authenticates the callers of the REST API
+type Authenticator interface {
+	// Authenticate returns the caller of r, ErrNoCredentials if r does not contain
+	// credentials for this authenticator or another error if they are not valid.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Authenticators is an Authenticator that tries a list of authenticators, returning
+// the result of the first one that finds credentials in the request.
+type Authenticators []Authenticator
+
+// Authenticate implements Authenticator interface
+func (as Authenticators) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range as {
+		p, err := a.Authenticate(r)
+		if err != ErrNoCredentials {
+			return p, err
+		}
+	}
+	return nil, ErrNoCredentials
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries p
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the Principal carried by ctx, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(*Principal)
+	return p, ok
+}
+
+/*
+New creates the Authenticator configured in config, or nil if authentication is disabled.
+
+The settings are:
+  - apiKeys: list (or comma separated string) of "name:key" API keys
+  - jwks: path to a JWKS file with the keys that sign the tokens
+  - jwtPublicKey: path to a PEM public key that signs the tokens (ignored if jwks is set)
+  - jwtIssuer: if set, the expected iss claim of the tokens
+  - jwtAudience: if set, an expected aud claim of the tokens
+  - apiKeyRoles: list (or comma separated string) of "name:role" or "name:role:party"
+    grants of the API keys; the keys not listed are admins
+  - rolesClaim: claim of the tokens with the roles of the caller (default: roles)
+  - partyClaim: claim of the tokens with the party of the caller (default: sub)
+  - apiKeyTenants: list (or comma separated string) of "name:tenant" tenants of the
+    API keys; the keys not listed access the default tenant, or any tenant if admins
+  - tenantClaim: claim of the tokens with the tenant of the caller (default: tenant)
+*/
+func New(config *viper.Viper) (Authenticator, error) {
+	config.SetDefault(rolesClaimPropertyName, defaultRolesClaim)
+	config.SetDefault(partyClaimPropertyName, defaultPartyClaim)
+	config.SetDefault(tenantClaimPropertyName, defaultTenantClaim)
+	logConfig(config)
+
+	result := make(Authenticators, 0)
+
+	keys, err := parseAPIKeys(list(config, apiKeysPropertyName))
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		result = append(result, keys)
+	}
+
+	var keyset KeySet
+	if path := config.GetString(jwksPropertyName); path != "" {
+		keyset, err = ReadJWKS(path)
+	} else if path := config.GetString(jwtPublicKeyPropertyName); path != "" {
+		keyset, err = ReadPublicKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if keyset != nil {
+		result = append(result, &JWT{
+			Keys:     keyset,
+			Issuer:   config.GetString(jwtIssuerPropertyName),
+			Audience: config.GetString(jwtAudiencePropertyName),
+		})
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+
+	grants, err := parseAPIKeyRoles(list(config, apiKeyRolesPropertyName))
+	if err != nil {
+		return nil, err
+	}
+	tenants, err := parseAPIKeyTenants(list(config, apiKeyTenantsPropertyName))
+	if err != nil {
+		return nil, err
+	}
+	mapper := &RoleMapper{
+		APIKeys:       grants,
+		APIKeyTenants: tenants,
+		RolesClaim:    config.GetString(rolesClaimPropertyName),
+		PartyClaim:    config.GetString(partyClaimPropertyName),
+		TenantClaim:   config.GetString(tenantClaimPropertyName),
+	}
+	return mapped{inner: result, mapper: mapper}, nil
+}
+
+func logConfig(config *viper.Viper) {
+	log.Printf("Authentication configuration\n"+
+		"\tAPI keys: %d\n"+
+		"\tJWKS: %s\n"+
+		"\tJWT public key: %s\n"+
+		"\tJWT issuer: %s\n"+
+		"\tJWT audience: %s\n"+
+		"\tAPI key roles: %v\n"+
+		"\tRoles claim: %s\n"+
+		"\tParty claim: %s\n"+
+		"\tAPI key tenants: %v\n"+
+		"\tTenant claim: %s\n",
+		len(list(config, apiKeysPropertyName)),
+		config.GetString(jwksPropertyName),
+		config.GetString(jwtPublicKeyPropertyName),
+		config.GetString(jwtIssuerPropertyName),
+		config.GetString(jwtAudiencePropertyName),
+		list(config, apiKeyRolesPropertyName),
+		config.GetString(rolesClaimPropertyName),
+		config.GetString(partyClaimPropertyName),
+		list(config, apiKeyTenantsPropertyName),
+		config.GetString(tenantClaimPropertyName))
+}
+
+// list returns the values of a setting that may be a list or a comma separated string
+func list(config *viper.Viper, name string) []string {
+	result := make([]string, 0)
+	for _, item := range config.GetStringSlice(name) {
+		for _, value := range strings.Split(item, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				result = append(result, value)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func newRequest(header, value string) *http.Request {
+	r, _ := http.NewRequest("GET", "/agreements", nil)
+	if header != "" {
+		r.Header.Set(header, value)
+	}
+	return r
+}
+
+func TestAPIKeys(t *testing.T) {
+	keys, err := parseAPIKeys([]string{"admin:secret", "monitor:other:secret"})
+	if err != nil {
+		t.Fatalf("Error parsing API keys: %v", err)
+	}
+
+	p, err := keys.Authenticate(newRequest(APIKeyHeader, "secret"))
+	if err != nil || p.Subject != "admin" {
+		t.Errorf("Unexpected result: %v, %v", p, err)
+	}
+	p, err = keys.Authenticate(newRequest(APIKeyHeader, "other:secret"))
+	if err != nil || p.Subject != "monitor" {
+		t.Errorf("Unexpected result: %v, %v", p, err)
+	}
+	if _, err = keys.Authenticate(newRequest(APIKeyHeader, "wrong")); err != ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials. Actual: %v", err)
+	}
+	if _, err = keys.Authenticate(newRequest("", "")); err != ErrNoCredentials {
+		t.Errorf("Expected ErrNoCredentials. Actual: %v", err)
+	}
+
+	if _, err := parseAPIKeys([]string{"secret"}); err == nil {
+		t.Error("Expected error parsing API key without name")
+	}
+}
+
+func TestNew(t *testing.T) {
+	config := viper.New()
+	a, err := New(config)
+	if a != nil || err != nil {
+		t.Errorf("Expected disabled authentication. Actual: %v, %v", a, err)
+	}
+
+	config.Set(apiKeysPropertyName, "admin:secret, monitor:other")
+	a, err = New(config)
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+	if p, err := a.Authenticate(newRequest(APIKeyHeader, "other")); err != nil || p.Subject != "monitor" {
+		t.Errorf("Unexpected result: %v, %v", p, err)
+	}
+	if _, err := a.Authenticate(newRequest("Authorization", "Bearer token")); err != ErrNoCredentials {
+		t.Errorf("Expected ErrNoCredentials. Actual: %v", err)
+	}
+
+	config.Set(jwksPropertyName, "testdata/notexists.json")
+	if _, err = New(config); err == nil {
+		t.Error("Expected error reading JWKS")
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/spf13/viper"
+)
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Error signing token: %v", err)
+	}
+	return s
+}
+
+func bearer(token string) *http.Request {
+	return newRequest("Authorization", "Bearer "+token)
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa01", "use": "sig", "n": encodeInt(rsaKey.N), "e": encodeInt(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec01", "crv": "P-256", "x": encodeInt(ecKey.X), "y": encodeInt(ecKey.Y)},
+			{"kty": "oct", "kid": "hmac01", "k": "c2VjcmV0"},
+			{"kty": "RSA", "kid": "enc01", "use": "enc", "n": encodeInt(rsaKey.N), "e": "AQAB"},
+		},
+	})
+	keys, err := ParseJWKS(jwks)
+	if err != nil {
+		t.Fatalf("Error parsing JWKS: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Unexpected keys: %v", keys)
+	}
+
+	a := &JWT{Keys: keys, Issuer: "https://issuer", Audience: "slalite"}
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := jwt.MapClaims{"sub": "c02", "iss": "https://issuer", "aud": []string{"other", "slalite"}, "exp": exp}
+
+	p, err := a.Authenticate(bearer(sign(t, jwt.SigningMethodRS256, rsaKey, "rsa01", valid)))
+	if err != nil || p.Subject != "c02" || p.Claims["iss"] != "https://issuer" {
+		t.Errorf("Unexpected result: %v, %v", p, err)
+	}
+	p, err = a.Authenticate(bearer(sign(t, jwt.SigningMethodES256, ecKey, "ec01", valid)))
+	if err != nil || p.Subject != "c02" {
+		t.Errorf("Unexpected result: %v, %v", p, err)
+	}
+
+	invalid := map[string]string{
+		"Expired": sign(t, jwt.SigningMethodRS256, rsaKey, "rsa01",
+			jwt.MapClaims{"sub": "c02", "iss": "https://issuer", "aud": "slalite", "exp": time.Now().Add(-time.Hour).Unix()}),
+		"NoExpiration": sign(t, jwt.SigningMethodRS256, rsaKey, "rsa01",
+			jwt.MapClaims{"sub": "c02", "iss": "https://issuer", "aud": "slalite"}),
+		"WrongIssuer": sign(t, jwt.SigningMethodRS256, rsaKey, "rsa01",
+			jwt.MapClaims{"sub": "c02", "iss": "https://other", "aud": "slalite", "exp": exp}),
+		"WrongAudience": sign(t, jwt.SigningMethodRS256, rsaKey, "rsa01",
+			jwt.MapClaims{"sub": "c02", "iss": "https://issuer", "aud": "other", "exp": exp}),
+		"UnknownKey": sign(t, jwt.SigningMethodRS256, rsaKey, "rsa02", valid),
+		"NoKeyId":    sign(t, jwt.SigningMethodRS256, rsaKey, "", valid),
+		"WrongKey":   sign(t, jwt.SigningMethodES256, ecKey, "rsa01", valid),
+		"HMAC":       sign(t, jwt.SigningMethodHS256, []byte("secret"), "hmac01", valid),
+		"Malformed":  "not.a.token",
+	}
+	for name, token := range invalid {
+		if _, err := a.Authenticate(bearer(token)); err == nil || err == ErrNoCredentials {
+			t.Errorf("%s: expected invalid token. Actual: %v", name, err)
+		}
+	}
+
+	if _, err := a.Authenticate(newRequest(APIKeyHeader, "secret")); err != ErrNoCredentials {
+		t.Errorf("Expected ErrNoCredentials. Actual: %v", err)
+	}
+}
+
+func TestPublicKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	path := filepath.Join(dir, "key.pem")
+	ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+
+	config := viper.New()
+	config.Set(jwtPublicKeyPropertyName, path)
+	a, err := New(config)
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+
+	token := sign(t, jwt.SigningMethodES384, ecKey, "", jwt.MapClaims{"sub": "p01", "exp": time.Now().Add(time.Hour).Unix()})
+	if p, err := a.Authenticate(bearer(token)); err != nil || p.Subject != "p01" {
+		t.Errorf("Unexpected result: %v, %v", p, err)
+	}
+	/* the bearer scheme is case insensitive */
+	if _, err := a.Authenticate(newRequest("Authorization", "bearer "+token)); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := a.Authenticate(bearer(token[:len(token)-4] + "AAAA")); err == nil || !strings.HasPrefix(err.Error(), "Invalid token") {
+		t.Errorf("Expected invalid signature. Actual: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Role is a role of a caller of the REST API
+type Role string
+
+const (
+	// ADMIN can perform any operation
+	ADMIN Role = "admin"
+
+	// PROVIDER can access the agreements and templates whose provider is its party
+	PROVIDER Role = "provider"
+
+	// CLIENT can access the agreements whose client is its party, and read the templates
+	CLIENT Role = "client"
+
+	defaultRolesClaim  = "roles"
+	defaultPartyClaim  = "sub"
+	defaultTenantClaim = "tenant"
+)
+
+// Roles are the valid roles
+var Roles = []Role{ADMIN, PROVIDER, CLIENT}
+
+// ErrForbidden is returned if the caller is not allowed to perform an operation
+var ErrForbidden = errors.New("Operation not allowed")
+
+// Grant contains the roles of a caller, the id of the provider or client it acts as,
+// and the tenant whose entities it accesses (empty for the default tenant, or to let
+// an admin choose any tenant)
+type Grant struct {
+	Roles   []Role
+	PartyId string
+	Tenant  string
+}
+
+// HasRole returns if the caller has the role r
+func (g Grant) HasRole(r Role) bool {
+	for _, role := range g.Roles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleMapper assigns a Grant to the authenticated callers.
+//
+// The callers authenticated with an API key receive the grant of their name in
+// APIKeys, or ADMIN if their name is not there, and the tenant of their name in
+// APIKeyTenants. The callers authenticated with a token receive the roles in the
+// RolesClaim claim, the party in the PartyClaim claim and the tenant in the TenantClaim
+// claim. A claim name may be a dot separated path (e.g., "realm_access.roles").
+type RoleMapper struct {
+	APIKeys       map[string]Grant
+	APIKeyTenants map[string]string
+	RolesClaim    string
+	PartyClaim    string
+	TenantClaim   string
+}
+
+// Map sets the Grant of p
+func (m *RoleMapper) Map(p *Principal) {
+	if p.Claims == nil {
+		if g, ok := m.APIKeys[p.Subject]; ok {
+			p.Grant = g
+		} else {
+			p.Grant = Grant{Roles: []Role{ADMIN}}
+		}
+		p.Tenant = m.APIKeyTenants[p.Subject]
+		return
+	}
+
+	p.Grant = Grant{}
+	for _, name := range claimValues(lookupClaim(p.Claims, m.RolesClaim)) {
+		if role := Role(name); isRole(role) {
+			p.Roles = append(p.Roles, role)
+		}
+	}
+	if values := claimValues(lookupClaim(p.Claims, m.PartyClaim)); len(values) > 0 {
+		p.PartyId = values[0]
+	}
+	if values := claimValues(lookupClaim(p.Claims, m.TenantClaim)); len(values) > 0 {
+		p.Tenant = values[0]
+	}
+}
+
+// mapped is an Authenticator that assigns the Grant of the callers authenticated by inner
+type mapped struct {
+	inner  Authenticator
+	mapper *RoleMapper
+}
+
+// Authenticate implements Authenticator interface
+func (m mapped) Authenticate(r *http.Request) (*Principal, error) {
+	p, err := m.inner.Authenticate(r)
+	if err != nil {
+		return p, err
+	}
+	m.mapper.Map(p)
+	return p, nil
+}
+
+// parseAPIKeyRoles parses items of the form "name:role" or "name:role:party"
+func parseAPIKeyRoles(items []string) (map[string]Grant, error) {
+	result := make(map[string]Grant, len(items))
+	for _, item := range items {
+		parts := strings.SplitN(item, ":", 3)
+		if len(parts) < 2 || parts[0] == "" {
+			return nil, fmt.Errorf("API key role '%s' must be 'name:role' or 'name:role:party'", item)
+		}
+		role := Role(parts[1])
+		if !isRole(role) {
+			return nil, fmt.Errorf("Invalid role '%s' of API key '%s': valid roles are %v", role, parts[0], Roles)
+		}
+		g := result[parts[0]]
+		g.Roles = append(g.Roles, role)
+		if len(parts) == 3 {
+			g.PartyId = parts[2]
+		}
+		result[parts[0]] = g
+	}
+	return result, nil
+}
+
+// parseAPIKeyTenants parses items of the form "name:tenant"
+func parseAPIKeyTenants(items []string) (map[string]string, error) {
+	result := make(map[string]string, len(items))
+	for _, item := range items {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("API key tenant '%s' must be 'name:tenant'", item)
+		}
+		result[parts[0]] = parts[1]
+	}
+	return result, nil
+}
+
+func isRole(r Role) bool {
+	for _, role := range Roles {
+		if role == r {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupClaim returns the value of a claim, following the dots of the name into nested objects
+func lookupClaim(claims map[string]interface{}, name string) interface{} {
+	var value interface{} = claims
+	for _, key := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// claimValues returns the strings of a claim that is a string or a list of strings
+func claimValues(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package auth
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestRoleMapper(t *testing.T) {
+	grants, err := parseAPIKeyRoles([]string{"acme:provider:p01", "monitor:admin"})
+	if err != nil {
+		t.Fatalf("Error parsing API key roles: %v", err)
+	}
+	m := &RoleMapper{APIKeys: grants, APIKeyTenants: map[string]string{"acme": "t01"},
+		RolesClaim: "realm_access.roles", PartyClaim: "party", TenantClaim: "tenant"}
+
+	cases := []struct {
+		name      string
+		principal Principal
+		expected  Grant
+	}{
+		{"APIKeyWithGrant", Principal{Subject: "acme"}, Grant{Roles: []Role{PROVIDER}, PartyId: "p01", Tenant: "t01"}},
+		{"APIKeyWithoutGrant", Principal{Subject: "other"}, Grant{Roles: []Role{ADMIN}}},
+		{"Token", Principal{Subject: "u01", Claims: map[string]interface{}{
+			"realm_access": map[string]interface{}{"roles": []interface{}{"client", "offline_access"}},
+			"party":        "c02",
+			"tenant":       "t02",
+		}}, Grant{Roles: []Role{CLIENT}, PartyId: "c02", Tenant: "t02"}},
+		{"TokenWithoutRoles", Principal{Subject: "u01", Claims: map[string]interface{}{"sub": "u01"}}, Grant{}},
+	}
+	for _, c := range cases {
+		p := c.principal
+		m.Map(&p)
+		if !reflect.DeepEqual(p.Grant, c.expected) {
+			t.Errorf("%s: expected %v. Actual: %v", c.name, c.expected, p.Grant)
+		}
+	}
+
+	if !(Grant{Roles: []Role{CLIENT, PROVIDER}}).HasRole(PROVIDER) || (Grant{}).HasRole(ADMIN) {
+		t.Error("Unexpected result of HasRole")
+	}
+	if _, err := parseAPIKeyRoles([]string{"acme:superuser"}); err == nil {
+		t.Error("Expected error parsing invalid role")
+	}
+	if _, err := parseAPIKeyRoles([]string{"acme"}); err == nil {
+		t.Error("Expected error parsing API key role without role")
+	}
+	if _, err := parseAPIKeyTenants([]string{"acme:"}); err == nil {
+		t.Error("Expected error parsing API key tenant without tenant")
+	}
+}
+
+func TestNewWithRoles(t *testing.T) {
+	config := viper.New()
+	config.Set(apiKeysPropertyName, "admin:secret,acme:other")
+	config.Set(apiKeyRolesPropertyName, "acme:client:c02")
+	a, err := New(config)
+	if err != nil {
+		t.Fatalf("Error creating authenticator: %v", err)
+	}
+
+	p, err := a.Authenticate(newRequest(APIKeyHeader, "other"))
+	if err != nil || !p.HasRole(CLIENT) || p.HasRole(ADMIN) || p.PartyId != "c02" {
+		t.Errorf("Unexpected result: %v, %v", p, err)
+	}
+	p, err = a.Authenticate(newRequest(APIKeyHeader, "secret"))
+	if err != nil || !p.HasRole(ADMIN) {
+		t.Errorf("Unexpected result: %v, %v", p, err)
+	}
+	if _, err := a.Authenticate(newRequest(APIKeyHeader, "wrong")); err != ErrInvalidCredentials {
+		t.Errorf("Expected ErrInvalidCredentials. Actual: %v", err)
+	}
+
+	config.Set(apiKeyRolesPropertyName, "acme:root")
+	if _, err = New(config); err == nil {
+		t.Error("Expected error with invalid role")
+	}
+}
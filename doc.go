@@ -3,6 +3,21 @@
 // A lightweight solution to manage SLAs
 // Version: 1.0
 // License: Apache 2.0
+//
+// Security:
+// - api_key:
+// - bearer:
+//
+// SecurityDefinitions:
+// api_key:
+//   type: apiKey
+//   name: X-API-Key
+//   in: header
+// bearer:
+//   type: apiKey
+//   name: Authorization
+//   in: header
+//
 // swagger:meta
 /**
  * Copyright 2018 Atos
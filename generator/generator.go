@@ -26,7 +26,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/google/uuid"
@@ -39,6 +38,9 @@ const (
 const (
 	errValidation = "validation"
 	errUnreplaced = "unreplaced"
+	errParameters = "parameters"
+	errExecution  = "execution"
+	errDecode     = "decode"
 	errOther      = ""
 )
 
@@ -51,6 +53,9 @@ type Model struct {
 type generatorError interface {
 	IsErrValidation() bool
 	IsErrUnreplaced() bool
+	IsErrParameters() bool
+	IsErrExecution() bool
+	IsErrDecode() bool
 }
 
 // IsErrValidation checks that the err is an ErrValidation error
@@ -65,9 +70,63 @@ func IsErrUnreplaced(err error) bool {
 	return ok && v.IsErrUnreplaced()
 }
 
+// IsErrParameters checks that the err is an ErrParameters error
+func IsErrParameters(err error) bool {
+	v, ok := err.(generatorError)
+	return ok && v.IsErrParameters()
+}
+
+// IsErrExecution checks that the err is an ErrExecution error
+func IsErrExecution(err error) bool {
+	v, ok := err.(generatorError)
+	return ok && v.IsErrExecution()
+}
+
+// IsErrDecode checks that the err is an ErrDecode error
+func IsErrDecode(err error) bool {
+	v, ok := err.(generatorError)
+	return ok && v.IsErrDecode()
+}
+
+// ParameterErrors returns the error message of each invalid parameter, by parameter
+// name, if err is an ErrParameters error; nil otherwise
+func ParameterErrors(err error) map[string]string {
+	if e, ok := err.(*genError); ok && e.IsErrParameters() {
+		return e.params
+	}
+	return nil
+}
+
+// UnreplacedPlaceholders returns the placeholders without value, in order of path,
+// if err is an ErrUnreplaced error; nil otherwise
+func UnreplacedPlaceholders(err error) []Placeholder {
+	if e, ok := err.(*genError); ok && e.IsErrUnreplaced() {
+		return e.placeholders
+	}
+	return nil
+}
+
+// Errors returns the list of messages of a generation error (e.g., each failed
+// validation), or the message of err if it is not a generation error
+func Errors(err error) []string {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*genError); ok && len(e.errs) > 0 {
+		return e.errs
+	}
+	return []string{err.Error()}
+}
+
 type genError struct {
 	msg  string
 	kind string
+	// errs contains the message of each error found, if more than one may be found
+	errs []string
+	// params contains the errors of the parameters, if kind is errParameters
+	params map[string]string
+	// placeholders contains the placeholders without value, if kind is errUnreplaced
+	placeholders []Placeholder
 }
 
 func (e *genError) Error() string {
@@ -82,6 +141,18 @@ func (e *genError) IsErrUnreplaced() bool {
 	return e.kind == errUnreplaced
 }
 
+func (e *genError) IsErrParameters() bool {
+	return e.kind == errParameters
+}
+
+func (e *genError) IsErrExecution() bool {
+	return e.kind == errExecution
+}
+
+func (e *genError) IsErrDecode() bool {
+	return e.kind == errDecode
+}
+
 // "meta": {
 // 	"duration": "P1M"
 // },
@@ -103,6 +174,10 @@ The generator uses text/template syntax for the substitution of the placeholds,
 i.e., placeholders are of the type {{.var}} to substitute the value of {{.var}} with
 the value of the key 'var' in the model.Variables map.
 
+The variables declared in the Parameters of the template are checked before the
+substitution: the missing ones take their default value, and the required ones
+must be given, be of the declared type and satisfy the declared constraint.
+
 A right template should define placeholders in the following paths:
 
 - Details.Client
@@ -128,45 +203,62 @@ regardless of the template content:
 
 - Name: equal to agreement.Details.Name
 
-An error of type validation is returned if the validation on the generated agreement
-fails. An error of type unreplaced is returned if there is a placeholder that is
-not substituted. Use IsErrValidation and IsErrUnreplaced to check type of an error.
+- Template: id and version of the template, if the template has an id
+
+An error of type parameters is returned if any declared parameter is not valid
+(use ParameterErrors to get the error of each one). An error of type validation is
+returned if the validation on the generated agreement fails. An error of type
+unreplaced is returned if there are placeholders whose variable has no value
+(use UnreplacedPlaceholders to get the JSON path and variable of each one). An error
+of type execution is returned if a placeholder cannot be parsed or executed, and
+an error of type decode if the result of the substitution is not an agreement.
+Use IsErrParameters, IsErrValidation, IsErrUnreplaced, IsErrExecution and IsErrDecode
+to check type of an error, and Errors to get each error found.
+
+The generated agreement is also returned along with validation and unreplaced errors
+(e.g., to be previewed), keeping the non-replaced placeholders; it is nil on
+other errors.
 */
 func Do(genmodel *Model, val model.Validator, externalIDs bool) (*model.Agreement, error) {
 
-	// marshal template
-	marshalled, err := json.Marshal(genmodel.Template)
-	if err != nil {
-		/* should not happen */
-		return nil, err
+	variables, paramErrs := applyParameters(genmodel.Template.Parameters, genmodel.Variables)
+	if len(paramErrs) > 0 {
+		return nil, newParametersError(paramErrs)
 	}
-	str := string(marshalled)
 
-	// string replacement -> agreement generation
-	tmpl, err := template.New(genmodel.Template.Name).Parse(str)
+	// marshal template
+	document, err := toDocument(genmodel.Template)
 	if err != nil {
 		/* should not happen */
 		return nil, err
 	}
 
-	var b bytes.Buffer
-	tmpl.Execute(&b, genmodel.Variables)
-	if err != nil {
-		return nil, err
-	}
-
-	// check all placeholders has been replaced
-	s := b.String()
-	if i := strings.Index(s, nonReplacedTag); i != -1 {
+	// placeholders replacement -> agreement generation
+	r := replacer{variables: variables}
+	document = r.replace("", document)
+	if len(r.errs) > 0 {
 		return nil, &genError{
-			kind: errUnreplaced,
-			msg:  fmt.Sprintf("Found non-replaced placeholder at index %d. Agreement is %s", i, s),
+			kind: errExecution,
+			msg:  "Error executing placeholders: " + strings.Join(r.errs, "; "),
+			errs: r.errs,
 		}
 	}
+	var unreplaced error
+	if len(r.missing) > 0 {
+		unreplaced = newUnreplacedError(r.missing)
+	}
 
 	// unmarshal agreement
 	var agreement model.Agreement
-	err = json.NewDecoder(&b).Decode(&agreement)
+	if err := fromDocument(document, &agreement); err != nil {
+		if unreplaced != nil {
+			return nil, unreplaced
+		}
+		return nil, &genError{
+			kind: errDecode,
+			msg:  fmt.Sprintf("Error decoding generated agreement: %v", err),
+		}
+	}
 
 	// modify agreement where needed
 	agreement.Details.Type = model.AGREEMENT
@@ -178,6 +270,16 @@ func Do(genmodel *Model, val model.Validator, externalIDs bool) (*model.Agreemen
 	}
 	agreement.Details.Creation = time.Now()
 	agreement.Name = agreement.Details.Name
+	if genmodel.Template.Id != "" {
+		agreement.Template = &model.TemplateRef{
+			Id:      genmodel.Template.Id,
+			Version: genmodel.Template.Version,
+		}
+	}
+
+	if unreplaced != nil {
+		return &agreement, unreplaced
+	}
 
 	// validate agreement
 	errs := agreement.Validate(val, model.CREATE)
@@ -190,9 +292,50 @@ func Do(genmodel *Model, val model.Validator, externalIDs bool) (*model.Agreemen
 
 func newValidationError(errs []error) *genError {
 	var buffer bytes.Buffer
+	msgs := make([]string, 0, len(errs))
 	for _, err := range errs {
 		buffer.WriteString(err.Error())
 		buffer.WriteString(". ")
+		msgs = append(msgs, err.Error())
+	}
+	return &genError{kind: errValidation, msg: buffer.String(), errs: msgs}
+}
+
+func newUnreplacedError(placeholders []Placeholder) *genError {
+	msgs := make([]string, 0, len(placeholders))
+	for _, p := range placeholders {
+		msgs = append(msgs, p.String())
+	}
+	return &genError{
+		kind:         errUnreplaced,
+		msg:          "Non-replaced placeholders: " + strings.Join(msgs, "; "),
+		errs:         msgs,
+		placeholders: placeholders,
+	}
+}
+
+// toDocument returns the template as a decoded JSON document, without the
+// fields that are not part of an agreement
+func toDocument(t model.Template) (interface{}, error) {
+	marshalled, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(marshalled))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	delete(document, "parameters")
+	return document, nil
+}
+
+// fromDocument decodes the JSON document into agreement
+func fromDocument(document interface{}, agreement *model.Agreement) error {
+	marshalled, err := json.Marshal(document)
+	if err != nil {
+		return err
 	}
-	return &genError{kind: errValidation, msg: buffer.String()}
+	return json.Unmarshal(marshalled, agreement)
 }
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
@@ -64,6 +65,9 @@ func TestGenerateAgreement(t *testing.T) {
 		},
 	}
 	a, err := Do(&genmodel, val, false)
+	if err == nil && (a.Template == nil || *a.Template != model.TemplateRef{Id: tpl.Id, Version: tpl.Version}) {
+		t.Errorf("Unexpected template of agreement: %v", a.Template)
+	}
 	if err == nil {
 		var b bytes.Buffer
 		enc := json.NewEncoder(&b)
@@ -91,6 +95,9 @@ func TestGenerateAgreementMissingFields(t *testing.T) {
 		},
 	}
 	a, err := Do(&genmodel, val, false)
+	if a == nil || len(Errors(err)) != 1 {
+		t.Errorf("Expected agreement and one error. Actual: %v, %v", a, Errors(err))
+	}
 	if err == nil || !IsErrUnreplaced(err) {
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetEscapeHTML(false)
@@ -98,6 +105,97 @@ func TestGenerateAgreementMissingFields(t *testing.T) {
 		enc.Encode(a)
 		t.Errorf("Unexpected err. Expected: ErrUnreplaced; actual: %v", err)
 	}
+	expected := Placeholder{Path: "details.guarantees[0].constraint", Name: "M"}
+	if p := UnreplacedPlaceholders(err); len(p) != 1 || p[0] != expected {
+		t.Errorf("Unexpected placeholders. Expected: %v; actual: %v", expected, p)
+	}
+	if a != nil && a.Details.Guarantees[0].Constraint != "m < {{.M}} && n < {{.N}}" {
+		t.Errorf("Expected non-replaced constraint. Actual: %s", a.Details.Guarantees[0].Constraint)
+	}
+}
+
+func TestGenerateAgreementMissingVariables(t *testing.T) {
+	genmodel := Model{
+		Template: tpl,
+		Variables: map[string]interface{}{
+			"provider": model.Provider{Id: "<provider-id>", Name: "<provider-name>"},
+			"client":   map[string]interface{}{"Id": "<client-id>"},
+			"N":        "0.9",
+		},
+	}
+	_, err := Do(&genmodel, val, false)
+	if !IsErrUnreplaced(err) {
+		t.Fatalf("Unexpected err. Expected: ErrUnreplaced; actual: %v", err)
+	}
+	expected := []Placeholder{
+		{Path: "details.client.name", Name: "client.Name"},
+		{Path: "details.guarantees[0].constraint", Name: "M"},
+		{Path: "details.name", Name: "agreementname"},
+	}
+	p := UnreplacedPlaceholders(err)
+	if fmt.Sprint(p) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected placeholders. Expected: %v; actual: %v", expected, p)
+	}
+	if msgs := Errors(err); len(msgs) != 3 || msgs[2] != "details.name: placeholder {{.agreementname}} has no value" {
+		t.Errorf("Unexpected errors: %v", msgs)
+	}
+}
+
+func TestGenerateAgreementExecutionError(t *testing.T) {
+	template := tpl
+	template.Details.Guarantees = append([]model.Guarantee{}, tpl.Details.Guarantees...)
+	template.Details.Guarantees[0].Constraint = "m < {{.M"
+	genmodel := Model{
+		Template: template,
+		Variables: map[string]interface{}{
+			"agreementname": "<a-name>",
+			"provider":      model.Provider{Id: "<provider-id>", Name: "<provider-name>"},
+			"client":        model.Client{Id: "<client-id>", Name: "<client-name>"},
+			"M":             "500",
+		},
+	}
+	a, err := Do(&genmodel, val, false)
+	if a != nil || !IsErrExecution(err) {
+		t.Fatalf("Unexpected result. Expected: ErrExecution; actual: %v, %v", a, err)
+	}
+	if msgs := Errors(err); len(msgs) != 1 || !strings.HasPrefix(msgs[0], "details.guarantees[0].constraint: ") {
+		t.Errorf("Unexpected errors: %v", msgs)
+	}
+}
+
+func TestGenerateAgreementParameters(t *testing.T) {
+	template := tpl
+	template.Parameters = map[string]model.Parameter{
+		"M":             {Type: model.NumberType, Required: true, Constraint: "M >= 0 && M <= 100"},
+		"N":             {Type: model.NumberType, Default: 0.9},
+		"agreementname": {Type: model.StringType, Required: true},
+		"client":        {Type: model.ObjectType},
+	}
+	variables := map[string]interface{}{
+		"provider":      model.Provider{Id: "<provider-id>", Name: "<provider-name>"},
+		"client":        model.Client{Id: "<client-id>", Name: "<client-name>"},
+		"M":             50,
+		"agreementname": "<a-name>",
+	}
+	a, err := Do(&Model{Template: template, Variables: variables}, val, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c := a.Details.Guarantees[0].Constraint; c != "m < 50 && n < 0.9" {
+		t.Errorf("Unexpected constraint: %s", c)
+	}
+
+	variables["M"] = 500
+	variables["client"] = "<client-id>"
+	delete(variables, "agreementname")
+	_, err = Do(&Model{Template: template, Variables: variables}, val, false)
+	if !IsErrParameters(err) {
+		t.Fatalf("Unexpected err. Expected: ErrParameters; actual: %v", err)
+	}
+	params := ParameterErrors(err)
+	if len(params) != 3 || params["M"] == "" || params["agreementname"] == "" || params["client"] == "" {
+		t.Errorf("Unexpected parameter errors: %v", params)
+	}
 }
 
 func TestGenerateAgreementNonValid(t *testing.T) {
@@ -0,0 +1,81 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"SLALite/model"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// applyParameters returns the variables completed with the default values of the
+// declared parameters, and the error of each parameter that is missing, is not of
+// its type or does not satisfy its constraint.
+//
+// The variables that are not declared as parameters are not checked.
+func applyParameters(declared map[string]model.Parameter,
+	variables map[string]interface{}) (map[string]interface{}, map[string]string) {
+
+	result := make(map[string]interface{}, len(variables)+len(declared))
+	for name, value := range variables {
+		result[name] = value
+	}
+	errs := make(map[string]string)
+	for name, p := range declared {
+		if _, ok := result[name]; ok {
+			continue
+		}
+		if p.Default != nil {
+			result[name] = p.Default
+		} else if p.Required {
+			errs[name] = "is required"
+		}
+	}
+
+	for name, p := range declared {
+		value, ok := result[name]
+		if !ok || errs[name] != "" {
+			continue
+		}
+		if err := p.CheckType(value); err != nil {
+			errs[name] = err.Error()
+		} else if err := p.Satisfies(result); err != nil {
+			errs[name] = err.Error()
+		}
+	}
+	return result, errs
+}
+
+func newParametersError(errs map[string]string) *genError {
+	names := make([]string, 0, len(errs))
+	for name := range errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s: %s", name, errs[name]))
+	}
+	return &genError{
+		kind:   errParameters,
+		msg:    "Invalid parameters: " + strings.Join(parts, "; "),
+		errs:   parts,
+		params: errs,
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Placeholder is a placeholder of a template that has no value
+type Placeholder struct {
+	// Path is the JSON path of the template value that contains the placeholder
+	// (e.g., details.guarantees[0].constraint)
+	Path string `json:"path"`
+	// Name is the variable of the placeholder (e.g., M or provider.Id); empty if unknown
+	Name string `json:"name"`
+}
+
+func (p Placeholder) String() string {
+	if p.Name == "" {
+		return fmt.Sprintf("%s: non-replaced placeholder", p.Path)
+	}
+	return fmt.Sprintf("%s: placeholder {{.%s}} has no value", p.Path, p.Name)
+}
+
+// replacer substitutes the placeholders in the values of a decoded JSON document,
+// keeping the placeholders without value and the errors found
+type replacer struct {
+	variables map[string]interface{}
+	missing   []Placeholder
+	errs      []string
+}
+
+// replace returns value with the placeholders in its strings (and object keys)
+// substituted. path is the JSON path of value.
+func (r *replacer) replace(path string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.replaceString(path, v)
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.replace(fmt.Sprintf("%s[%d]", path, i), item)
+		}
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		result := make(map[string]interface{}, len(v))
+		for _, k := range keys {
+			itemPath := k
+			if path != "" {
+				itemPath = path + "." + k
+			}
+			result[r.replaceString(itemPath, k)] = r.replace(itemPath, v[k])
+		}
+		return result
+	}
+	return value
+}
+
+// replaceString executes s as a text/template, returning s unchanged if it
+// has placeholders without value or it cannot be executed
+func (r *replacer) replaceString(path, s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	tmpl, err := template.New(path).Parse(s)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Sprintf("%s: %v", path, err))
+		return s
+	}
+
+	missing := false
+	for _, field := range fields(tmpl.Tree.Root) {
+		if !resolve(r.variables, field) {
+			r.missing = append(r.missing, Placeholder{Path: path, Name: strings.Join(field, ".")})
+			missing = true
+		}
+	}
+	if missing {
+		return s
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, r.variables); err != nil {
+		r.errs = append(r.errs, fmt.Sprintf("%s: %v", path, err))
+		return s
+	}
+	result := b.String()
+	if strings.Contains(result, nonReplacedTag) {
+		r.missing = append(r.missing, Placeholder{Path: path})
+		return s
+	}
+	return result
+}
+
+// fields returns the fields (e.g., [provider Id] for {{.provider.Id}}) referenced
+// from the root variables in a template. The fields inside range and with actions
+// are relative to other values, and are not returned.
+func fields(node parse.Node) [][]string {
+	var result [][]string
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			for _, item := range n.Nodes {
+				result = append(result, fields(item)...)
+			}
+		}
+	case *parse.ActionNode:
+		result = fields(n.Pipe)
+	case *parse.IfNode:
+		result = append(fields(n.Pipe), fields(n.List)...)
+		result = append(result, fields(n.ElseList)...)
+	case *parse.RangeNode:
+		result = fields(n.Pipe)
+	case *parse.WithNode:
+		result = fields(n.Pipe)
+	case *parse.PipeNode:
+		if n != nil {
+			for _, cmd := range n.Cmds {
+				for _, arg := range cmd.Args {
+					result = append(result, fields(arg)...)
+				}
+			}
+		}
+	case *parse.FieldNode:
+		result = append(result, n.Ident)
+	}
+	return result
+}
+
+// resolve returns if the field path has a non-nil value in the variables
+func resolve(variables interface{}, field []string) bool {
+	v := reflect.ValueOf(variables)
+	for _, name := range field {
+		v = indirect(v)
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return false
+			}
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		case reflect.Struct:
+			v = v.FieldByName(name)
+		default:
+			return false
+		}
+		if !v.IsValid() {
+			return false
+		}
+	}
+	return indirect(v).IsValid()
+}
+
+// indirect returns the value pointed by v, or an invalid value if v is nil
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package health contains the checks of the availability of the components the
+SLALite depends on (e.g., the repository), used by the readiness probe.
+*/
+package health
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	// UP is the status of an available component
+	UP = "up"
+
+	// DOWN is the status of a component that is not available
+	DOWN = "down"
+)
+
+// Checker is implemented by the components whose availability can be checked
+type Checker interface {
+	// Check returns nil if the component is available
+	Check() error
+}
+
+// CheckerFunc is an adapter to use a function as a Checker
+type CheckerFunc func() error
+
+// Check implements Checker interface
+func (f CheckerFunc) Check() error {
+	return f()
+}
+
+// Status is the status of a component
+type Status struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the result of checking a set of components. Status is UP
+// only if all the components are UP.
+type Report struct {
+	Status     string            `json:"status"`
+	Components map[string]Status `json:"components"`
+}
+
+// Checks is a set of checkers by component name
+type Checks map[string]Checker
+
+type result struct {
+	name string
+	err  error
+}
+
+// Run checks all the components concurrently. The components that do not
+// answer before timeout are reported as DOWN.
+func (cs Checks) Run(timeout time.Duration) Report {
+	report := Report{
+		Status:     UP,
+		Components: make(map[string]Status, len(cs)),
+	}
+
+	results := make(chan result, len(cs))
+	for name, c := range cs {
+		go func(name string, c Checker) {
+			results <- result{name: name, err: c.Check()}
+		}(name, c)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+wait:
+	for len(report.Components) < len(cs) {
+		select {
+		case r := <-results:
+			report.Components[r.name] = newStatus(r.err)
+		case <-timer.C:
+			break wait
+		}
+	}
+	for name := range cs {
+		if _, ok := report.Components[name]; !ok {
+			report.Components[name] = newStatus(errors.New("Timeout checking component"))
+		}
+	}
+
+	for _, s := range report.Components {
+		if s.Status != UP {
+			report.Status = DOWN
+		}
+	}
+	return report
+}
+
+func newStatus(err error) Status {
+	if err != nil {
+		return Status{Status: DOWN, Error: err.Error()}
+	}
+	return Status{Status: UP}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	checks := Checks{
+		"up":   CheckerFunc(func() error { return nil }),
+		"down": CheckerFunc(func() error { return errors.New("error") }),
+		"slow": CheckerFunc(func() error {
+			time.Sleep(time.Second)
+			return nil
+		}),
+	}
+	report := checks.Run(100 * time.Millisecond)
+
+	if report.Status != DOWN {
+		t.Errorf("Unexpected status. Expected: %s; Actual: %s", DOWN, report.Status)
+	}
+	expected := map[string]string{"up": UP, "down": DOWN, "slow": DOWN}
+	for name, status := range expected {
+		if report.Components[name].Status != status {
+			t.Errorf("Unexpected status of %s. Expected: %s; Actual: %v", name, status, report.Components[name])
+		}
+	}
+	if report.Components["down"].Error != "error" {
+		t.Errorf("Unexpected error of down: %v", report.Components["down"])
+	}
+}
+
+func TestRunAllUp(t *testing.T) {
+	checks := Checks{
+		"up": CheckerFunc(func() error { return nil }),
+	}
+	if report := checks.Run(time.Second); report.Status != UP {
+		t.Errorf("Unexpected report %v", report)
+	}
+	if report := (Checks{}).Run(time.Second); report.Status != UP {
+		t.Errorf("Unexpected report without checks %v", report)
+	}
+}
@@ -19,17 +19,33 @@ import (
 	"SLALite/assessment"
 	"SLALite/assessment/monitor"
 	"SLALite/assessment/monitor/cimiadapter"
+	"SLALite/assessment/monitor/dummyadapter"
+	"SLALite/assessment/monitor/genericadapter"
 	"SLALite/assessment/notifier"
+	"SLALite/assessment/notifier/lognotifier"
+	"SLALite/assessment/notifier/sse"
+	"SLALite/assessment/notifier/webhook"
+	"SLALite/health"
 	"SLALite/mf2c"
 	"SLALite/model"
+	"SLALite/repositories/bolt"
 	"SLALite/repositories/cimi"
+	"SLALite/repositories/instrumented"
 	"SLALite/repositories/memrepository"
 	"SLALite/repositories/mongodb"
+	"SLALite/repositories/sqlrepository"
 	"SLALite/repositories/validation"
 	"SLALite/utils"
+	"context"
 	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -74,6 +90,10 @@ func main() {
 		repo, errRepo = memrepository.New(repoconfig)
 	case "mongodb":
 		repo, errRepo = mongodb.New(repoconfig)
+	case bolt.Name:
+		repo, errRepo = bolt.New(repoconfig)
+	case sqlrepository.Name:
+		repo, errRepo = sqlrepository.New(repoconfig)
 	case "cimi":
 		cimirepo, errRepo = cimi.New(repoconfig)
 		repo = cimirepo
@@ -83,23 +103,80 @@ func main() {
 		log.Fatal("Error creating repository: ", errRepo.Error())
 	}
 
+	ma, err := createMonitoringAdapter(config)
+	if err != nil {
+		log.Fatal("Error creating monitoring adapter: ", err.Error())
+	}
+	not, err := createNotifier(config, repoconfig, repo)
+	if err != nil {
+		log.Fatal("Error creating notifiers: ", err.Error())
+	}
+
 	var mF2C mf2c.Mf2c
-	var err error
 	mF2C, err = mf2c.New(config)
 	policies = mF2C.Policies
 	if err != nil {
 		log.Fatal("Error creating Policies: ", err.Error())
 	}
 
+	checks := createChecks(repo, mF2C, repoType == "cimi")
+	closer, _ := repo.(io.Closer)
+
 	validater := model.NewDefaultValidator(config.GetBool(utils.ExternalIDsPropertyName), false)
-	repo, _ = validation.New(repo, validater)
+	repo, _ = validation.New(instrumented.New(repo), validater)
 	if repo != nil {
-		a, _ := NewApp(config, repo, validater)
-		go createValidationThread(repo, nil, nil, checkPeriod)
-		a.Run()
+		events := sse.New(config.GetInt(utils.EventsBufferPropertyName))
+		notifiers := notifier.Notifiers{not, events}
+		a, err := NewApp(config, repo, validater, notifiers, events, checks)
+		if err != nil {
+			log.Fatal("Error creating REST API: ", err.Error())
+		}
+
+		ctx, cancel := shutdownOnSignal()
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			createValidationThread(ctx, repo, ma, notifiers, checkPeriod, repoType == "cimi")
+		}()
+		errRun := a.Run(ctx)
+
+		/* on error, stop the assessment too */
+		cancel()
+		wg.Wait()
+		if err := notifiers.Close(); err != nil {
+			log.Error("Error closing notifiers: ", err.Error())
+		}
+		if closer != nil {
+			if err := closer.Close(); err != nil {
+				log.Error("Error closing repository: ", err.Error())
+			}
+		}
+		if errRun != nil {
+			log.Fatal("Error running REST API: ", errRun.Error())
+		}
+		log.Info("SLALite stopped")
 	}
 }
 
+// shutdownOnSignal returns a context that is cancelled when the process receives
+// SIGINT or SIGTERM, and the function to cancel it.
+func shutdownOnSignal() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case s := <-sigs:
+			log.Infof("Received %v. Shutting down", s)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigs)
+	}()
+	return ctx, cancel
+}
+
 //
 // Creates the main Viper configuration.
 // file: if set, is the path to a configuration file. If not set, paths and basename will be used
@@ -114,6 +191,8 @@ func createMainConfig(file *string, paths *string, basename *string) *viper.Vipe
 	config.SetDefault(utils.CheckPeriodPropertyName, utils.DefaultCheckPeriod)
 	config.SetDefault(utils.RepositoryTypePropertyName, utils.DefaultRepositoryType)
 	config.SetDefault(utils.ExternalIDsPropertyName, utils.DefaultExternalIDs)
+	config.SetDefault(utils.NotifiersPropertyName, utils.DefaultNotifiers)
+	config.SetDefault(utils.EventsBufferPropertyName, utils.DefaultEventsBuffer)
 
 	if *file != "" {
 		config.SetConfigFile(*file)
@@ -142,8 +221,12 @@ func logMainConfig(config *viper.Viper) {
 		"\tConfigfile: %s\n"+
 		"\tRepository type: %s\n"+
 		"\tExternal IDs: %v\n"+
-		"\tCheck period:%d\n",
-		config.ConfigFileUsed(), repoType, externalIDs, checkPeriod)
+		"\tCheck period:%d\n"+
+		"\tMonitoring adapter: %s\n"+
+		"\tNotifiers: %v\n"+
+		"\tEvents buffer: %d\n",
+		config.ConfigFileUsed(), repoType, externalIDs, checkPeriod,
+		adapterType(config), notifierTypes(config), config.GetInt(utils.EventsBufferPropertyName))
 
 	caPath := config.GetString(utils.CAPathPropertyName)
 	if caPath != "" {
@@ -151,16 +234,146 @@ func logMainConfig(config *viper.Viper) {
 	}
 }
 
-func createValidationThread(repo model.IRepository, ma monitor.MonitoringAdapter,
-	not notifier.ViolationNotifier, checkPeriod time.Duration) {
+// adapterType returns the configured monitoring adapter type, defaulting to
+// the cimi adapter on mF2C (i.e., cimi repository) and to the dummy adapter otherwise.
+func adapterType(config *viper.Viper) string {
+	result := config.GetString(utils.AdapterPropertyName)
+	if result != "" {
+		return result
+	}
+	if config.GetString(utils.RepositoryTypePropertyName) == "cimi" {
+		return cimiadapter.Name
+	}
+	return dummyadapter.Name
+}
+
+// notifierTypes returns the configured notifier types. The setting may be a list
+// or a comma separated string.
+func notifierTypes(config *viper.Viper) []string {
+	result := make([]string, 0)
+	for _, item := range config.GetStringSlice(utils.NotifiersPropertyName) {
+		for _, name := range strings.Split(item, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				result = append(result, name)
+			}
+		}
+	}
+	return result
+}
+
+// createMonitoringAdapter creates the configured monitoring adapter
+func createMonitoringAdapter(config *viper.Viper) (monitor.MonitoringAdapter, error) {
+	switch name := adapterType(config); name {
+	case cimiadapter.Name:
+		if config.GetString(utils.RepositoryTypePropertyName) != "cimi" {
+			return nil, fmt.Errorf("Adapter '%s' needs the cimi repository", name)
+		}
+		return cimiadapter.New(cimirepo), nil
+	case dummyadapter.Name:
+		return dummyadapter.New(3), nil
+	case genericadapter.Name:
+		retrieve, err := createRetriever(config)
+		if err != nil {
+			return nil, err
+		}
+		return genericadapter.New(retrieve, genericadapter.Aggregate), nil
+	default:
+		return nil, fmt.Errorf("Unknown adapter '%s'", name)
+	}
+}
+
+// createRetriever creates the configured metrics retriever of the generic adapter
+func createRetriever(config *viper.Viper) (genericadapter.Retrieve, error) {
+	switch name := config.GetString(utils.RetrieverPropertyName); name {
+	case "prometheus":
+		url := config.GetString(utils.PrometheusURLPropertyName)
+		if url == "" {
+			return nil, fmt.Errorf("Retriever '%s' needs %s", name, utils.PrometheusURLPropertyName)
+		}
+		return genericadapter.PrometheusRetriever{URL: url}.Retrieve(), nil
+	case "file":
+		path := config.GetString(utils.MetricsFilePropertyName)
+		if path == "" {
+			return nil, fmt.Errorf("Retriever '%s' needs %s", name, utils.MetricsFilePropertyName)
+		}
+		return genericadapter.FileRetriever{Path: path}.Retrieve(), nil
+	case "":
+		return nil, fmt.Errorf("Adapter '%s' needs %s", genericadapter.Name, utils.RetrieverPropertyName)
+	default:
+		return nil, fmt.Errorf("Unknown retriever '%s'", name)
+	}
+}
+
+// createNotifier creates the configured notifiers. subconfig is the configuration of the
+// notifiers that need it (nil to read it from their default file). The subscriptions
+// are read from repo.
+//
+// The subscriptions are notified even if the webhook notifier is not configured, as
+// long as repo is not nil and stores subscriptions (i.e., it is not the cimi repository).
+func createNotifier(config *viper.Viper, subconfig *viper.Viper, repo model.IRepository) (notifier.ViolationNotifier, error) {
+	result := make(notifier.Notifiers, 0)
+	hasWebhook := false
+	for _, name := range notifierTypes(config) {
+		switch name {
+		case lognotifier.Name:
+			result = append(result, lognotifier.LogNotifier{})
+		case webhook.Name:
+			n, err := webhook.New(subconfig, repo)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, n)
+			hasWebhook = true
+		default:
+			return nil, fmt.Errorf("Unknown notifier '%s'", name)
+		}
+	}
+	if !hasWebhook && repo != nil && config.GetString(utils.RepositoryTypePropertyName) != "cimi" {
+		n, err := webhook.NewSubscriptions(subconfig, repo)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+// createChecks returns the checks of the readiness probe: the repository and,
+// on mF2C, the Policies and Analytics components.
+func createChecks(repo model.IRepository, mF2C mf2c.Mf2c, isMf2c bool) health.Checks {
+	checks := health.Checks{}
+	if c, ok := repo.(health.Checker); ok {
+		checks["repository"] = c
+	}
+	if isMf2c {
+		if c, ok := mF2C.Policies.(health.Checker); ok {
+			checks["policies"] = c
+		}
+		checks["analytics"] = &mF2C.Analytics
+	}
+	return checks
+}
+
+// createValidationThread assesses the agreements every checkPeriod seconds until ctx
+// is done. An assessment in progress is finished before returning.
+func createValidationThread(ctx context.Context, repo model.IRepository, ma monitor.MonitoringAdapter,
+	not notifier.ViolationNotifier, checkPeriod time.Duration, isMf2c bool) {
 
 	ticker := time.NewTicker(checkPeriod * time.Second)
+	defer ticker.Stop()
 
 	for {
-		<-ticker.C
-		assessMf2cAgreements(repo)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if isMf2c {
+			assessMf2cAgreements(repo, ma, not)
+		} else {
+			assessment.AssessTenants(repo, ma, not)
+		}
 	}
-
 }
 
 func validateProviders(repo model.IRepository) {
@@ -173,7 +386,6 @@ func validateProviders(repo model.IRepository) {
 	}
 }
 
-func assessMf2cAgreements(repo model.IRepository) {
-	ma := cimiadapter.New(cimirepo)
-	assessment.AssessMf2cAgreements(repo, cimirepo, ma, policies)
+func assessMf2cAgreements(repo model.IRepository, ma monitor.MonitoringAdapter, not notifier.ViolationNotifier) {
+	assessment.AssessMf2cAgreements(repo, cimirepo, ma, policies, not)
 }
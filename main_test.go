@@ -16,15 +16,26 @@ limitations under the License.
 package main
 
 import (
+	"SLALite/assessment/monitor/dummyadapter"
+	"SLALite/assessment/notifier"
+	"SLALite/assessment/notifier/sse"
+	"SLALite/assessment/notifier/webhook"
+	"SLALite/auth"
+	"SLALite/health"
+	"SLALite/mf2c"
 	"SLALite/model"
 	"SLALite/repositories/cimi"
 	"SLALite/utils"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"reflect"
 	"strconv"
@@ -38,12 +49,14 @@ import (
 )
 
 var a App
+var events = sse.New(10)
 var repo model.IRepository
 var p1 = model.Provider{Id: "p01", Name: "Provider01"}
 var p2 = model.Provider{Id: "p02", Name: "Provider02"}
 var c2 = model.Client{Id: "c02", Name: "A client"}
 var pdelete = model.Provider{Id: "pdelete", Name: "Removable provider"}
 var dbName = "test.db"
+var sqlDbName = "test.sqlite"
 var providerPrefix = "pf_" + strconv.Itoa(rand.Int())
 var agreementPrefix = "apf_" + strconv.Itoa(rand.Int())
 
@@ -68,7 +81,7 @@ func TestMain(m *testing.M) {
 			log.Fatalf("Error creating initial state: %v", err)
 		}
 		_, externalIds := repo.(cimi.Repository)
-		a, _ = NewApp(viper.New(), repo, model.NewDefaultValidator(externalIds, true))
+		a, _ = NewApp(viper.New(), repo, model.NewDefaultValidator(externalIds, true), nil, events, createChecks(repo, mf2c.Mf2c{}, false))
 	} else {
 		log.Fatal("Error initializing repository")
 	}
@@ -77,6 +90,8 @@ func TestMain(m *testing.M) {
 
 	//BBolt clear database
 	os.Remove(dbName)
+	//SQL clear database
+	os.Remove(sqlDbName)
 
 	os.Exit(result)
 }
@@ -296,6 +311,10 @@ func testGetActiveAgreements(t *testing.T) {
 			t.Errorf("Got unexpected active agreement %s", agreement.Id)
 		}
 	}
+
+	req, _ = http.NewRequest("GET", "/agreements?active=true&state=stopped", nil)
+	res = request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
 }
 
 func testGetAgreementExists(t *testing.T) {
@@ -550,6 +569,11 @@ func TestTemplates(t *testing.T) {
 	t.Run("GetTemplateNotExists", testGetTemplateNotExists)
 	t.Run("CreateTemplateThatExists", testCreateTemplateThatExists)
 	t.Run("CreateTemplate", testCreateTemplate)
+	t.Run("UpdateTemplate", testUpdateTemplate)
+	t.Run("UpdateTemplateNotExists", testUpdateTemplateNotExists)
+	t.Run("GetTemplateVersions", testGetTemplateVersions)
+	t.Run("StopAndStartTemplate", testStopAndStartTemplate)
+	t.Run("DeleteTemplate", testDeleteTemplate)
 }
 
 func testGetTemplates(t *testing.T) {
@@ -609,11 +633,839 @@ func testCreateTemplate(t *testing.T) {
 
 	var created model.Template
 	_ = json.NewDecoder(res.Body).Decode(&created)
+	posted.Version = 1
 	if !reflect.DeepEqual(created, posted) {
 		t.Errorf("Expected: %v. Actual: %v", posted, created)
 	}
 }
 
+func testUpdateTemplate(t *testing.T) {
+	template, _ := utils.ReadTemplate("model/testdata/template2.json")
+	template.Id = "ignored"
+	template.Name = "Template 02 updated"
+	body, _ := json.Marshal(template)
+	req, _ := http.NewRequest("PUT", "/templates/t02", bytes.NewBuffer(body))
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	updated, err := repo.GetTemplate("t02")
+	if err != nil || updated.Name != template.Name {
+		t.Errorf("Template not updated: %v, %v", updated, err)
+	}
+
+	template.Name = ""
+	body, _ = json.Marshal(template)
+	req, _ = http.NewRequest("PUT", "/templates/t02", bytes.NewBuffer(body))
+	res = request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+}
+
+func testUpdateTemplateNotExists(t *testing.T) {
+	template := t1
+	template.Details.Id = "doesnotexist"
+	body, _ := json.Marshal(template)
+	req, _ := http.NewRequest("PUT", "/templates/doesnotexist", bytes.NewBuffer(body))
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testGetTemplateVersions(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/templates/t02/versions", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var versions model.Templates
+	_ = json.NewDecoder(res.Body).Decode(&versions)
+	if len(versions) != 2 || versions[0].Name != "Template 02" || versions[1].Version != 2 {
+		t.Errorf("Unexpected versions: %v", versions)
+	}
+
+	req, _ = http.NewRequest("GET", "/templates/doesnotexist/versions", nil)
+	res = request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testStopAndStartTemplate(t *testing.T) {
+	createFromT02 := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(model.CreateAgreement{
+			TemplateID: "t02",
+			Parameters: map[string]interface{}{
+				"M":             1,
+				"N":             2,
+				"agreementname": "agreement-t02",
+				"provider":      map[string]string{"Id": "p01", "Name": "p01-name"},
+				"client":        map[string]string{"Id": "c01", "Name": "c01-name"},
+			},
+		})
+		req, _ := http.NewRequest("POST", "/create-agreement", bytes.NewBuffer(body))
+		return request(req)
+	}
+
+	before, _ := repo.GetTemplate("t02")
+	req, _ := http.NewRequest("PUT", "/templates/t02/stop", nil)
+	res := request(req)
+	checkStatus(t, http.StatusNoContent, res.Code)
+
+	res = createFromT02()
+	checkError(t, res, http.StatusConflict, res.Code)
+
+	req, _ = http.NewRequest("PUT", "/templates/t02/start", nil)
+	res = request(req)
+	checkStatus(t, http.StatusNoContent, res.Code)
+
+	res = createFromT02()
+	checkStatus(t, http.StatusCreated, res.Code)
+
+	var created model.CreateAgreement
+	_ = json.NewDecoder(res.Body).Decode(&created)
+	a, err := repo.GetAgreement(created.AgreementID)
+	tpl, _ := repo.GetTemplate("t02")
+	if err != nil || a.Template == nil || *a.Template != (model.TemplateRef{Id: "t02", Version: tpl.Version}) {
+		t.Errorf("Unexpected template of agreement: %v, %v", a, err)
+	}
+	if tpl.Version != before.Version {
+		t.Errorf("Unexpected version after stop and start. Expected: %d; Actual: %d", before.Version, tpl.Version)
+	}
+
+	req, _ = http.NewRequest("PUT", "/templates/doesnotexist/start", nil)
+	res = request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testDeleteTemplate(t *testing.T) {
+	req, _ := http.NewRequest("DELETE", "/templates/t02", nil)
+	res := request(req)
+	checkStatus(t, http.StatusNoContent, res.Code)
+
+	req, _ = http.NewRequest("DELETE", "/templates/t02", nil)
+	res = request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+/********************************************************************
+*****************VIOLATIONS******************************************
+********************************************************************/
+
+func TestViolations(t *testing.T) {
+	av := createAgreement("av01", p1, c2, "Agreement with violations", nil)
+	if _, err := repo.CreateAgreement(&av); err != nil {
+		t.Fatalf("Cannot create initial conditions for test: %v", err)
+	}
+	t0 := time.Now().Add(-time.Hour)
+	vs := []model.Violation{
+		createViolation("av01-v01", av.Id, "g1", t0),
+		createViolation("av01-v02", av.Id, "g2", t0.Add(time.Minute)),
+		createViolation("av01-v03", av.Id, "g1", t0.Add(2*time.Minute)),
+	}
+	for _, v := range vs {
+		if _, err := repo.CreateViolation(&v); err != nil {
+			t.Fatalf("Cannot create initial conditions for test: %v", err)
+		}
+	}
+	from := url.QueryEscape(t0.Add(time.Minute).Format(time.RFC3339))
+
+	t.Run("GetViolations", testGetViolations("/violations?agreement=av01", 3))
+	t.Run("GetViolationsByGuarantee", testGetViolations("/violations?agreement=av01&guarantee=g1", 2))
+	t.Run("GetViolationsFrom", testGetViolations("/violations?agreement=av01&from="+from, 2))
+	t.Run("GetViolationsTo", testGetViolations("/violations?agreement=av01&to="+from, 1))
+	t.Run("GetViolationsPage", testGetViolations("/violations?agreement=av01&offset=1&limit=1", 1))
+	t.Run("GetAgreementViolations", testGetViolations("/agreements/av01/violations", 3))
+	t.Run("GetAgreementViolationsByGuarantee", testGetViolations("/agreements/av01/violations?guarantee=g2", 1))
+	t.Run("GetAgreementViolationsNotExists", testGetAgreementViolationsNotExists)
+	t.Run("GetViolationsWrongParameters", testGetViolationsWrongParameters)
+	t.Run("GetViolationExists", testGetViolationExists)
+	t.Run("GetViolationNotExists", testGetViolationNotExists)
+}
+
+func testGetViolations(path string, expected int) func(t *testing.T) {
+	return func(t *testing.T) {
+		req, _ := http.NewRequest("GET", path, nil)
+		res := request(req)
+		checkStatus(t, http.StatusOK, res.Code)
+
+		var violations model.Violations
+		_ = json.NewDecoder(res.Body).Decode(&violations)
+		if len(violations) != expected {
+			t.Errorf("Expected %d violations. Received: %v", expected, violations)
+		}
+		for i := 1; i < len(violations); i++ {
+			if violations[i].Datetime.Before(violations[i-1].Datetime) {
+				t.Errorf("Violations not sorted by datetime: %v", violations)
+			}
+		}
+	}
+}
+
+func testGetAgreementViolationsNotExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/agreements/doesnotexist/violations", nil)
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+func testGetViolationsWrongParameters(t *testing.T) {
+	for _, path := range []string{
+		"/violations?from=yesterday",
+		"/violations?to=2019-01-01",
+		"/violations?limit=-1",
+		"/agreements/av01/violations?offset=a",
+	} {
+		req, _ := http.NewRequest("GET", path, nil)
+		res := request(req)
+		checkError(t, res, http.StatusBadRequest, res.Code)
+	}
+}
+
+func testGetViolationExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/violations/av01-v01", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	var violation model.Violation
+	_ = json.NewDecoder(res.Body).Decode(&violation)
+	if violation.Id != "av01-v01" {
+		t.Errorf("Expected: %v. Actual: %v", "av01-v01", violation.Id)
+	}
+}
+
+func testGetViolationNotExists(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/violations/doesnotexist", nil)
+	res := request(req)
+	checkError(t, res, http.StatusNotFound, res.Code)
+}
+
+/********************************************************************
+*****************PENALTIES*******************************************
+********************************************************************/
+
+func TestPenalties(t *testing.T) {
+	ap := createAgreement("ap01", p1, c2, "Agreement with penalties", nil)
+	if _, err := repo.CreateAgreement(&ap); err != nil {
+		t.Fatalf("Cannot create initial conditions for test: %v", err)
+	}
+	t0 := time.Now().Add(-time.Hour)
+	ps := []model.Penalty{
+		createPenalty("ap01-p01", ap.Id, "g1", t0),
+		createPenalty("ap01-p02", ap.Id, "g2", t0.Add(time.Minute)),
+	}
+	for _, p := range ps {
+		if _, err := repo.CreatePenalty(&p); err != nil {
+			t.Fatalf("Cannot create initial conditions for test: %v", err)
+		}
+	}
+
+	t.Run("GetAgreementPenalties", testGetPage("/agreements/ap01/penalties", []string{"ap01-p01", "ap01-p02"}, 2))
+	t.Run("GetAgreementPenaltiesByGuarantee", testGetPage("/agreements/ap01/penalties?guarantee=g2", []string{"ap01-p02"}, 1))
+	t.Run("GetAgreementPenaltiesPage", testGetPage("/agreements/ap01/penalties?offset=1&limit=1", []string{"ap01-p02"}, 2))
+	t.Run("GetAgreementPenaltiesNotExists", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/agreements/doesnotexist/penalties", nil)
+		res := request(req)
+		checkError(t, res, http.StatusNotFound, res.Code)
+	})
+	t.Run("GetAgreementPenaltiesWrongParameters", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/agreements/ap01/penalties?from=yesterday", nil)
+		res := request(req)
+		checkError(t, res, http.StatusBadRequest, res.Code)
+	})
+}
+
+/********************************************************************
+*****************WARNINGS********************************************
+********************************************************************/
+
+func TestWarnings(t *testing.T) {
+	aw := createAgreement("aw01", p1, c2, "Agreement with warnings", nil)
+	if _, err := repo.CreateAgreement(&aw); err != nil {
+		t.Fatalf("Cannot create initial conditions for test: %v", err)
+	}
+	t0 := time.Now().Add(-time.Hour)
+	ws := []model.Warning{
+		createWarning("aw01-w01", aw.Id, "g1", t0),
+		createWarning("aw01-w02", aw.Id, "g2", t0.Add(time.Minute)),
+	}
+	for _, w := range ws {
+		if _, err := repo.CreateWarning(&w); err != nil {
+			t.Fatalf("Cannot create initial conditions for test: %v", err)
+		}
+	}
+
+	t.Run("GetAgreementWarnings", testGetPage("/agreements/aw01/warnings", []string{"aw01-w01", "aw01-w02"}, 2))
+	t.Run("GetAgreementWarningsByGuarantee", testGetPage("/agreements/aw01/warnings?guarantee=g2", []string{"aw01-w02"}, 1))
+	t.Run("GetAgreementWarningsPage", testGetPage("/agreements/aw01/warnings?offset=1&limit=1", []string{"aw01-w02"}, 2))
+	t.Run("GetAgreementWarningsNotExists", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/agreements/doesnotexist/warnings", nil)
+		res := request(req)
+		checkError(t, res, http.StatusNotFound, res.Code)
+	})
+	t.Run("GetAgreementWarningsWrongParameters", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/agreements/aw01/warnings?from=yesterday", nil)
+		res := request(req)
+		checkError(t, res, http.StatusBadRequest, res.Code)
+	})
+}
+
+/********************************************************************
+***************************SUBSCRIPTIONS*****************************
+********************************************************************/
+
+func TestSubscriptions(t *testing.T) {
+	s1 := model.Subscription{
+		Id:          "s01",
+		Url:         "http://localhost/callback",
+		AgreementId: "a01",
+		Events:      []model.EventType{model.VIOLATION},
+	}
+
+	t.Run("CreateSubscription", func(t *testing.T) {
+		body, _ := json.Marshal(s1)
+		req, _ := http.NewRequest("POST", "/subscriptions", bytes.NewBuffer(body))
+		res := request(req)
+		checkStatus(t, http.StatusCreated, res.Code)
+
+		var created model.Subscription
+		_ = json.NewDecoder(res.Body).Decode(&created)
+		if created.Secret == "" {
+			t.Error("Expected a generated secret")
+		}
+		created.Secret = ""
+		if !reflect.DeepEqual(created, s1) {
+			t.Errorf("Expected: %v. Actual: %v", s1, created)
+		}
+	})
+	t.Run("CreateSubscriptionThatExists", func(t *testing.T) {
+		body, _ := json.Marshal(s1)
+		req, _ := http.NewRequest("POST", "/subscriptions", bytes.NewBuffer(body))
+		res := request(req)
+		checkStatus(t, http.StatusConflict, res.Code)
+	})
+	t.Run("CreateSubscriptionWrongUrl", func(t *testing.T) {
+		body := `{"id": "swrong", "url": "not an url"}`
+		req, _ := http.NewRequest("POST", "/subscriptions", strings.NewReader(body))
+		res := request(req)
+		checkError(t, res, http.StatusBadRequest, res.Code)
+	})
+	t.Run("CreateSubscriptionWrongEvent", func(t *testing.T) {
+		body := `{"id": "swrong", "url": "http://localhost", "events": ["notexists"]}`
+		req, _ := http.NewRequest("POST", "/subscriptions", strings.NewReader(body))
+		res := request(req)
+		checkError(t, res, http.StatusBadRequest, res.Code)
+	})
+	t.Run("GetSubscriptions", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/subscriptions", nil)
+		res := request(req)
+		checkStatus(t, http.StatusOK, res.Code)
+
+		var subscriptions model.Subscriptions
+		_ = json.NewDecoder(res.Body).Decode(&subscriptions)
+		if len(subscriptions) != 1 {
+			t.Errorf("Expected 1 subscription. Received: %v", subscriptions)
+		}
+	})
+	t.Run("GetSubscriptionExists", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/subscriptions/s01", nil)
+		res := request(req)
+		checkStatus(t, http.StatusOK, res.Code)
+	})
+	t.Run("GetSubscriptionNotExists", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/subscriptions/doesnotexist", nil)
+		res := request(req)
+		checkError(t, res, http.StatusNotFound, res.Code)
+	})
+	t.Run("UpdateSubscription", func(t *testing.T) {
+		body := `{"url": "https://localhost/other", "provider_id": "p01"}`
+		req, _ := http.NewRequest("PUT", "/subscriptions/s01", strings.NewReader(body))
+		res := request(req)
+		checkStatus(t, http.StatusOK, res.Code)
+
+		updated, _ := repo.GetSubscription("s01")
+		if updated.Url != "https://localhost/other" || updated.ProviderId != "p01" || updated.AgreementId != "" ||
+			updated.Secret == "" {
+			t.Errorf("Unexpected updated subscription: %v", updated)
+		}
+	})
+	t.Run("UpdateSubscriptionNotExists", func(t *testing.T) {
+		body := `{"url": "https://localhost/other"}`
+		req, _ := http.NewRequest("PUT", "/subscriptions/doesnotexist", strings.NewReader(body))
+		res := request(req)
+		checkError(t, res, http.StatusNotFound, res.Code)
+	})
+	t.Run("DeleteSubscription", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/subscriptions/s01", nil)
+		res := request(req)
+		checkStatus(t, http.StatusNoContent, res.Code)
+
+		if _, err := repo.GetSubscription("s01"); err != model.ErrNotFound {
+			t.Errorf("Expected ErrNotFound. Actual: %v", err)
+		}
+	})
+	t.Run("DeleteSubscriptionNotExists", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/subscriptions/s01", nil)
+		res := request(req)
+		checkStatus(t, http.StatusNotFound, res.Code)
+	})
+}
+
+// stateRecorder is a StateNotifier that records the notified changes of state
+type stateRecorder struct {
+	previous []model.State
+	current  []model.State
+}
+
+func (r *stateRecorder) NotifyStateChange(agreement *model.Agreement, previous model.State) {
+	r.previous = append(r.previous, previous)
+	r.current = append(r.current, agreement.State)
+}
+
+func (r *stateRecorder) NotifyExpiration(agreement *model.Agreement) {}
+
+func TestNotifyStateChange(t *testing.T) {
+	as := createAgreement("as01", p1, c2, "Agreement with state changes", nil)
+	if _, err := repo.CreateAgreement(&as); err != nil {
+		t.Fatalf("Cannot create initial conditions for test: %v", err)
+	}
+	recorder := &stateRecorder{}
+	app, _ := NewApp(viper.New(), repo, model.NewDefaultValidator(false, true), recorder, nil, nil)
+
+	for _, path := range []string{"/agreements/as01/start", "/agreements/as01/start", "/agreements/as01/stop"} {
+		req, _ := http.NewRequest("PUT", path, nil)
+		res := httptest.NewRecorder()
+		app.Router.ServeHTTP(res, req)
+		checkStatus(t, http.StatusNoContent, res.Code)
+	}
+
+	expectedPrevious := []model.State{model.STOPPED, model.STARTED}
+	expectedCurrent := []model.State{model.STARTED, model.STOPPED}
+	if !reflect.DeepEqual(recorder.previous, expectedPrevious) || !reflect.DeepEqual(recorder.current, expectedCurrent) {
+		t.Errorf("Unexpected state changes: %v -> %v", recorder.previous, recorder.current)
+	}
+}
+
+/********************************************************************
+*******************************EVENTS********************************
+********************************************************************/
+
+func TestEvents(t *testing.T) {
+	/* events is only fed by this test */
+	events.NotifyStateChange(&model.Agreement{Id: "ae01", State: model.STARTED}, model.STOPPED)
+	events.NotifyStateChange(&model.Agreement{Id: "ae02", State: model.STARTED}, model.STOPPED)
+
+	t.Run("GetEvents", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/events?agreement=ae01", nil)
+		req.Header.Set("Last-Event-ID", "0")
+		ctx, cancel := context.WithTimeout(req.Context(), 100*time.Millisecond)
+		defer cancel()
+		res := request(req.WithContext(ctx))
+		checkStatus(t, http.StatusOK, res.Code)
+
+		body := res.Body.String()
+		if !strings.Contains(body, "id: 1\nevent: state_change\n") ||
+			!strings.Contains(body, `"agreement_id":"ae01"`) || strings.Contains(body, "ae02") {
+			t.Errorf("Unexpected stream: %s", body)
+		}
+	})
+	t.Run("GetEventsWrongLastEventId", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/events", nil)
+		req.Header.Set("Last-Event-ID", "notanumber")
+		res := request(req)
+		checkError(t, res, http.StatusBadRequest, res.Code)
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/providers/p01", nil)
+	request(req)
+
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	body := res.Body.String()
+	for _, expected := range []string{
+		`slalite_http_requests_total{code="200",method="GET",route="/providers/{id}"}`,
+		`slalite_http_request_duration_seconds_count{method="GET",route="/providers/{id}"}`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Metric %s not found in %s", expected, body)
+		}
+	}
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("Healthz", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/healthz", nil)
+		res := request(req)
+		checkStatus(t, http.StatusOK, res.Code)
+	})
+	t.Run("Readyz", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		res := request(req)
+		checkStatus(t, http.StatusOK, res.Code)
+
+		var report health.Report
+		_ = json.NewDecoder(res.Body).Decode(&report)
+		if report.Status != health.UP || report.Components["repository"].Status != health.UP {
+			t.Errorf("Unexpected readiness report: %v", report)
+		}
+	})
+	t.Run("ReadyzNotReady", func(t *testing.T) {
+		checks := health.Checks{
+			"repository": health.CheckerFunc(func() error { return nil }),
+			"policies":   health.CheckerFunc(func() error { return errors.New("connection refused") }),
+		}
+		app, _ := NewApp(viper.New(), repo, model.NewDefaultValidator(false, true), nil, nil, checks)
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		res := httptest.NewRecorder()
+		app.Router.ServeHTTP(res, req)
+		checkStatus(t, http.StatusServiceUnavailable, res.Code)
+
+		var report health.Report
+		_ = json.NewDecoder(res.Body).Decode(&report)
+		if report.Status != health.DOWN || report.Components["policies"].Error != "connection refused" ||
+			report.Components["repository"].Status != health.UP {
+			t.Errorf("Unexpected readiness report: %v", report)
+		}
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	config := viper.New()
+	config.Set("apiKeys", "admin:secret")
+	app, err := NewApp(config, repo, model.NewDefaultValidator(false, true), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Error creating app: %v", err)
+	}
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		app.Router.ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("NoCredentials", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/agreements/a01", nil)
+		res := serve(req)
+		checkError(t, res, http.StatusUnauthorized, res.Code)
+	})
+	t.Run("InvalidCredentials", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/agreements/a01", nil)
+		req.Header.Set(auth.APIKeyHeader, "wrong")
+		res := serve(req)
+		checkError(t, res, http.StatusUnauthorized, res.Code)
+	})
+	t.Run("ValidCredentials", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/providers", nil)
+		req.Header.Set(auth.APIKeyHeader, "secret")
+		res := serve(req)
+		checkStatus(t, http.StatusOK, res.Code)
+	})
+	t.Run("ProbesAreOpen", func(t *testing.T) {
+		for _, path := range []string{"/healthz", "/readyz", "/metrics"} {
+			req, _ := http.NewRequest("GET", path, nil)
+			res := serve(req)
+			checkStatus(t, http.StatusOK, res.Code)
+		}
+	})
+	t.Run("WrongConfiguration", func(t *testing.T) {
+		config := viper.New()
+		config.Set("apiKeys", "secret")
+		if _, err := NewApp(config, repo, model.NewDefaultValidator(false, true), nil, nil, nil); err == nil {
+			t.Error("Expected error creating app")
+		}
+	})
+}
+
+func TestAuthorization(t *testing.T) {
+	config := viper.New()
+	config.Set("apiKeys", "admin:secret,c02:client-key,c99:other-key,p01:provider-key")
+	config.Set("apiKeyRoles", "c02:client:c02,c99:client:c99,p01:provider:p01")
+	app, err := NewApp(config, repo, model.NewDefaultValidator(false, true), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Error creating app: %v", err)
+	}
+	agreement := createAgreement("aauthz", p1, c2, "Authorization agreement", nil)
+	if _, err := repo.CreateAgreement(&agreement); err != nil {
+		t.Fatalf("Error creating agreement: %v", err)
+	}
+	serve := func(method, path, key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString("{}"))
+		req.Header.Set(auth.APIKeyHeader, key)
+		res := httptest.NewRecorder()
+		app.Router.ServeHTTP(res, req)
+		return res
+	}
+
+	t.Run("PartiesSeeTheirAgreements", func(t *testing.T) {
+		for _, key := range []string{"secret", "client-key", "provider-key"} {
+			res := serve("GET", "/agreements/aauthz", key)
+			checkStatus(t, http.StatusOK, res.Code)
+		}
+	})
+	t.Run("OtherClientsDoNot", func(t *testing.T) {
+		res := serve("GET", "/agreements/aauthz", "other-key")
+		checkError(t, res, http.StatusNotFound, res.Code)
+
+		res = serve("GET", "/agreements/aauthz/details", "other-key")
+		checkError(t, res, http.StatusNotFound, res.Code)
+
+		res = serve("GET", "/agreements", "other-key")
+		checkStatus(t, http.StatusOK, res.Code)
+		var agreements model.Agreements
+		_ = json.NewDecoder(res.Body).Decode(&agreements)
+		if len(agreements) != 0 {
+			t.Errorf("Expected no agreements. Actual: %v", agreements)
+		}
+	})
+	t.Run("OnlyAdminsCreateTemplates", func(t *testing.T) {
+		res := serve("POST", "/templates", "provider-key")
+		checkError(t, res, http.StatusForbidden, res.Code)
+	})
+	t.Run("OnlyAdminsTerminateAgreements", func(t *testing.T) {
+		res := serve("PUT", "/agreements/aauthz/terminate", "client-key")
+		checkError(t, res, http.StatusForbidden, res.Code)
+
+		req, _ := http.NewRequest("PUT", "/agreements/aauthz", bytes.NewBufferString(`{"state":"terminated"}`))
+		req.Header.Set(auth.APIKeyHeader, "client-key")
+		res = httptest.NewRecorder()
+		app.Router.ServeHTTP(res, req)
+		checkError(t, res, http.StatusForbidden, res.Code)
+	})
+	t.Run("PartiesManageTheirSubscriptions", func(t *testing.T) {
+		create := func(body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("POST", "/subscriptions", strings.NewReader(body))
+			req.Header.Set(auth.APIKeyHeader, "provider-key")
+			res := httptest.NewRecorder()
+			app.Router.ServeHTTP(res, req)
+			return res
+		}
+		res := create(`{"id": "sauthz", "url": "http://localhost/authz", "provider_id": "p02"}`)
+		checkError(t, res, http.StatusForbidden, res.Code)
+
+		res = create(`{"id": "sauthz", "url": "http://localhost/authz", "provider_id": "p01"}`)
+		checkStatus(t, http.StatusCreated, res.Code)
+
+		res = serve("GET", "/subscriptions/sauthz", "other-key")
+		checkError(t, res, http.StatusNotFound, res.Code)
+
+		res = serve("DELETE", "/subscriptions/sauthz", "provider-key")
+		checkStatus(t, http.StatusNoContent, res.Code)
+	})
+}
+
+func TestTenants(t *testing.T) {
+	tenantRepo, err := model.ForTenant(repo, "acme")
+	if err == model.ErrTenantsUnsupported {
+		t.Skip("Repository does not support tenants")
+	} else if err != nil {
+		t.Fatalf("Error getting tenant repository: %v", err)
+	}
+	agreement := createAgreement("atenant", p1, c2, "Tenant agreement", nil)
+	if _, err := tenantRepo.CreateAgreement(&agreement); err != nil {
+		t.Fatalf("Error creating agreement: %v", err)
+	}
+
+	config := viper.New()
+	config.Set("apiKeys", "admin:secret,acme:acme-key")
+	config.Set("apiKeyRoles", "acme:client:c02")
+	config.Set("apiKeyTenants", "acme:acme")
+	app, err := NewApp(config, repo, model.NewDefaultValidator(false, true), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Error creating app: %v", err)
+	}
+	serve := func(app App, key, tenant string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/agreements/atenant", nil)
+		req.Header.Set(auth.APIKeyHeader, key)
+		if tenant != "" {
+			req.Header.Set(tenantHeader, tenant)
+		}
+		res := httptest.NewRecorder()
+		app.Router.ServeHTTP(res, req)
+		return res
+	}
+
+	res := serve(app, "acme-key", "")
+	checkStatus(t, http.StatusOK, res.Code)
+	res = serve(app, "acme-key", "other")
+	checkError(t, res, http.StatusForbidden, res.Code)
+	res = serve(app, "secret", "")
+	checkError(t, res, http.StatusNotFound, res.Code)
+	res = serve(app, "secret", "acme")
+	checkStatus(t, http.StatusOK, res.Code)
+	res = serve(app, "secret", "not valid")
+	checkError(t, res, http.StatusBadRequest, res.Code)
+	res = serve(a, "", "acme")
+	checkStatus(t, http.StatusOK, res.Code)
+}
+
+func TestServe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Serve(ctx, l)
+	}()
+	base := "http://" + l.Addr().String()
+	/* idle keep-alive connections would delay the shutdown */
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	res, err := client.Get(base + "/healthz")
+	if err != nil {
+		t.Fatalf("Error requesting REST API: %v", err)
+	}
+	res.Body.Close()
+	checkStatus(t, http.StatusOK, res.StatusCode)
+
+	stream, err := client.Get(base + "/events")
+	if err != nil {
+		t.Fatalf("Error requesting events: %v", err)
+	}
+	defer stream.Body.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Unexpected error on shutdown: %v", err)
+		}
+	case <-time.After(shutdownTimeout + 5*time.Second):
+		t.Fatal("REST API not stopped")
+	}
+	/* the event stream has been closed on shutdown */
+	if _, err := ioutil.ReadAll(stream.Body); err != nil {
+		t.Errorf("Unexpected error reading closed stream: %v", err)
+	}
+	if _, err := client.Get(base + "/healthz"); err == nil {
+		t.Error("REST API still listening after shutdown")
+	}
+}
+
+func TestValidationThreadStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		createValidationThread(ctx, repo, dummyadapter.New(3), nil, 1, false)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Assessment not stopped")
+	}
+}
+
+func TestManageError(t *testing.T) {
+	res := httptest.NewRecorder()
+	manageError(model.ErrNotSupported, res)
+	checkError(t, res, http.StatusNotImplemented, res.Code)
+}
+
+/********************************************************************
+*******************************LISTS*********************************
+********************************************************************/
+
+func TestLists(t *testing.T) {
+	pq := model.Provider{Id: "pq01", Name: "Provider of queries"}
+	t0 := time.Now().Add(-time.Hour)
+	as := []model.Agreement{
+		createAgreement("aq01", pq, c2, "Query C", nil),
+		createAgreement("aq02", pq, c2, "Query A", nil),
+		createAgreement("aq03", pq, c2, "Query B", nil),
+	}
+	as[0].State = model.STARTED
+	as[2].State = model.TERMINATED
+	for i := range as {
+		as[i].Details.Creation = t0.Add(time.Duration(i) * time.Minute)
+		if _, err := repo.CreateAgreement(&as[i]); err != nil {
+			t.Fatalf("Cannot create initial conditions for test: %v", err)
+		}
+	}
+	creation := url.QueryEscape(t0.Add(time.Minute).Format(time.RFC3339))
+
+	t.Run("GetAgreementsByProvider",
+		testGetPage("/agreements?provider=pq01", []string{"aq01", "aq02", "aq03"}, 3))
+	t.Run("GetAgreementsByState",
+		testGetPage("/agreements?provider=pq01&state=started,terminated", []string{"aq01", "aq03"}, 2))
+	t.Run("GetAgreementsByRepeatedState",
+		testGetPage("/agreements?provider=pq01&state=started&state=stopped", []string{"aq01", "aq02"}, 2))
+	t.Run("GetAgreementsByCreation",
+		testGetPage("/agreements?provider=pq01&creation_from="+creation, []string{"aq02", "aq03"}, 2))
+	t.Run("GetAgreementsSortedByName",
+		testGetPage("/agreements?provider=pq01&sort=name", []string{"aq02", "aq03", "aq01"}, 3))
+	t.Run("GetAgreementsSortedByCreationDesc",
+		testGetPage("/agreements?provider=pq01&sort=-creation", []string{"aq03", "aq02", "aq01"}, 3))
+	t.Run("GetAgreementsPage",
+		testGetPage("/agreements?provider=pq01&sort=name&offset=1&limit=1", []string{"aq03"}, 3))
+	t.Run("GetAgreementsPageOutOfRange",
+		testGetPage("/agreements?provider=pq01&offset=5", []string{}, 3))
+	t.Run("GetViolationsPage",
+		testGetPage("/violations?agreement=av01&offset=1&limit=1", []string{"av01-v02"}, 3))
+	t.Run("GetProvidersPage", testGetProvidersPage)
+	t.Run("GetTemplatesByState", testGetPage("/templates?state=terminated", []string{}, 0))
+	t.Run("GetListsWrongParameters", testGetListsWrongParameters)
+}
+
+func testGetPage(path string, expected []string, total int) func(t *testing.T) {
+	return func(t *testing.T) {
+		req, _ := http.NewRequest("GET", path, nil)
+		res := request(req)
+		checkStatus(t, http.StatusOK, res.Code)
+
+		if actual := res.Header().Get(totalCountHeader); actual != strconv.Itoa(total) {
+			t.Errorf("Expected %s=%d. Actual: %s", totalCountHeader, total, actual)
+		}
+		var items []struct {
+			Id string `json:"id"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&items)
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.Id)
+		}
+		if !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Expected %v. Actual: %v", expected, ids)
+		}
+	}
+}
+
+func testGetProvidersPage(t *testing.T) {
+	providers, _ := repo.GetAllProviders()
+
+	req, _ := http.NewRequest("GET", "/providers?sort=-id&limit=1", nil)
+	res := request(req)
+	checkStatus(t, http.StatusOK, res.Code)
+
+	if actual := res.Header().Get(totalCountHeader); actual != strconv.Itoa(len(providers)) {
+		t.Errorf("Expected %s=%d. Actual: %s", totalCountHeader, len(providers), actual)
+	}
+	var page model.Providers
+	_ = json.NewDecoder(res.Body).Decode(&page)
+	if len(page) != 1 {
+		t.Fatalf("Expected 1 provider. Received: %v", page)
+	}
+	for _, p := range providers {
+		if p.Id > page[0].Id {
+			t.Errorf("Expected provider with greatest id. Received: %v", page[0])
+		}
+	}
+}
+
+func testGetListsWrongParameters(t *testing.T) {
+	for _, path := range []string{
+		"/agreements?sort=guarantees",
+		"/agreements?state=running",
+		"/agreements?expiration_to=tomorrow",
+		"/agreements?offset=-1",
+		"/templates?sort=client",
+		"/templates?limit=many",
+		"/providers?sort=creation",
+	} {
+		req, _ := http.NewRequest("GET", path, nil)
+		res := request(req)
+		checkError(t, res, http.StatusBadRequest, res.Code)
+	}
+}
+
 /********************************************************************
 *****************CREATEAGREEMENT(FROM TEMPLATE)**********************
 ********************************************************************/
@@ -622,6 +1474,9 @@ func TestCreateAgreements(t *testing.T) {
 	t.Run("Create agreement from template", testCreateAgreementFromTemplate)
 	t.Run("Missing fields in create agreement from template", testCreateAgreementFromTemplateMissingFields)
 	t.Run("Wrong templateID in create agreement from template", testCreateAgreementFromTemplateWrongID)
+	t.Run("Invalid parameters in create agreement from template", testCreateAgreementFromTemplateInvalidParameters)
+	t.Run("Preview agreement from template", testPreviewAgreement)
+	t.Run("Create agreement from template without state", testCreateAgreementFromTemplateWithoutState)
 }
 
 func testCreateAgreementFromTemplate(t *testing.T) {
@@ -664,6 +1519,32 @@ func testCreateAgreementFromTemplate(t *testing.T) {
 	log.Infof("Generated agreement: %#v", a)
 }
 
+func testCreateAgreementFromTemplateWithoutState(t *testing.T) {
+	tpl := t1
+	tpl.Id = "t01-legacy"
+	tpl.Details.Id = tpl.Id
+	tpl.State = ""
+	if _, err := repo.CreateTemplate(&tpl); err != nil {
+		t.Fatalf("Cannot create initial conditions for test: %v", err)
+	}
+
+	ca := model.CreateAgreement{
+		TemplateID: tpl.Id,
+		Parameters: map[string]interface{}{
+			"M":             1,
+			"N":             2,
+			"agreementname": "agreement-test",
+			"provider":      model.Provider{Id: "p01", Name: "p01-name"},
+			"client":        map[string]string{"Id": "c01", "Name": "c01-name"},
+		},
+	}
+	body, _ := json.Marshal(ca)
+	req, _ := http.NewRequest("POST", "/create-agreement", bytes.NewBuffer(body))
+	res := request(req)
+
+	checkStatus(t, http.StatusCreated, res.Code)
+}
+
 func testCreateAgreementFromTemplateWrongID(t *testing.T) {
 	ca := model.CreateAgreement{
 		TemplateID: "tnotexists",
@@ -706,6 +1587,81 @@ func testCreateAgreementFromTemplateMissingFields(t *testing.T) {
 	checkStatus(t, http.StatusBadRequest, res.Code)
 }
 
+func testCreateAgreementFromTemplateInvalidParameters(t *testing.T) {
+	tpl := t1
+	tpl.Id = "t01-parameters"
+	tpl.Details.Id = tpl.Id
+	tpl.Parameters = map[string]model.Parameter{
+		"M":             {Type: model.NumberType, Constraint: "M >= 0 && M <= 100"},
+		"N":             {Type: model.NumberType, Default: 0.5},
+		"agreementname": {Type: model.StringType, Required: true},
+	}
+	if _, err := repo.CreateTemplate(&tpl); err != nil {
+		t.Fatalf("Cannot create initial conditions for test: %v", err)
+	}
+
+	ca := model.CreateAgreement{
+		TemplateID: tpl.Id,
+		Parameters: map[string]interface{}{
+			"M":        500,
+			"provider": model.Provider{Id: "p01", Name: "p01-name"},
+			"client":   map[string]string{"Id": "c01", "Name": "c01-name"},
+		},
+	}
+	body, _ := json.Marshal(ca)
+	req, _ := http.NewRequest("POST", "/create-agreement", bytes.NewBuffer(body))
+	res := request(req)
+	checkStatus(t, http.StatusBadRequest, res.Code)
+
+	var e ApiError
+	_ = json.NewDecoder(res.Body).Decode(&e)
+	if len(e.Parameters) != 2 || e.Parameters["M"] == "" || e.Parameters["agreementname"] == "" {
+		t.Errorf("Unexpected parameter errors: %v", e)
+	}
+
+	tpl.Id = "t01-invalid-parameters"
+	tpl.Details.Id = tpl.Id
+	tpl.Parameters = map[string]model.Parameter{"M": {Type: "integer"}}
+	body, _ = json.Marshal(tpl)
+	req, _ = http.NewRequest("POST", "/templates", bytes.NewBuffer(body))
+	res = request(req)
+	checkError(t, res, http.StatusBadRequest, res.Code)
+}
+
+func testPreviewAgreement(t *testing.T) {
+	preview := func(id string, parameters map[string]interface{}) (*httptest.ResponseRecorder, model.AgreementPreview) {
+		var result model.AgreementPreview
+		body, _ := json.Marshal(model.CreateAgreement{Parameters: parameters})
+		req, _ := http.NewRequest("POST", "/templates/"+id+"/preview", bytes.NewBuffer(body))
+		res := request(req)
+		_ = json.NewDecoder(res.Body).Decode(&result)
+		return res, result
+	}
+
+	res, p := preview("t01", map[string]interface{}{
+		"M":             1,
+		"N":             2,
+		"agreementname": "agreement-preview",
+		"provider":      model.Provider{Id: "p01", Name: "p01-name"},
+		"client":        map[string]string{"Id": "c01", "Name": "c01-name"},
+	})
+	checkStatus(t, http.StatusOK, res.Code)
+	if p.Agreement == nil || p.Agreement.Name != "agreement-preview" || len(p.Errors) != 0 {
+		t.Errorf("Unexpected preview: %v", p)
+	} else if _, err := repo.GetAgreement(p.Agreement.Id); err != model.ErrNotFound {
+		t.Errorf("Previewed agreement should not be stored: %v", err)
+	}
+
+	res, p = preview("t01", map[string]interface{}{"M": 1, "N": 2})
+	checkStatus(t, http.StatusOK, res.Code)
+	if p.Agreement == nil || len(p.Errors) == 0 {
+		t.Errorf("Expected agreement with errors in preview: %v", p)
+	}
+
+	res, _ = preview("tnotexists", nil)
+	checkStatus(t, http.StatusNotFound, res.Code)
+}
+
 func request(req *http.Request) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
 	a.Router.ServeHTTP(rr, req)
@@ -746,7 +1702,7 @@ func executeCreate(b *testing.B) {
 	//log.Print("Running create test " + b.Name())
 	for i := 0; i < b.N; i++ {
 		key := getProviderId(i)
-		provider := model.Provider{key, "provider_" + key}
+		provider := model.Provider{Id: key, Name: "provider_" + key}
 		body, err := json.Marshal(provider)
 		if err != nil {
 			b.Error("Unexpected marshalling error")
@@ -818,3 +1774,114 @@ func createAgreement(aid string, provider model.Provider, client model.Client, n
 		},
 	}
 }
+
+func TestCreateMonitoringAdapter(t *testing.T) {
+	config := viper.New()
+	if ma, err := createMonitoringAdapter(config); err != nil || ma == nil {
+		t.Errorf("Unexpected error creating default adapter: %v", err)
+	}
+	if name := adapterType(config); name != dummyadapter.Name {
+		t.Errorf("Unexpected default adapter. Expected: %s; Actual: %s", dummyadapter.Name, name)
+	}
+	config.Set(utils.AdapterPropertyName, "dummy")
+	if ma, err := createMonitoringAdapter(config); err != nil || ma == nil {
+		t.Errorf("Unexpected error creating adapter dummy: %v", err)
+	}
+	config.Set(utils.AdapterPropertyName, "generic")
+	if _, err := createMonitoringAdapter(config); err == nil {
+		t.Error("Expected error creating generic adapter without retriever")
+	}
+	config.Set(utils.RetrieverPropertyName, "prometheus")
+	if _, err := createMonitoringAdapter(config); err == nil {
+		t.Error("Expected error creating prometheus retriever without URL")
+	}
+	config.Set(utils.PrometheusURLPropertyName, "http://localhost:9090")
+	if ma, err := createMonitoringAdapter(config); err != nil || ma == nil {
+		t.Errorf("Unexpected error creating adapter generic: %v", err)
+	}
+	config.Set(utils.RetrieverPropertyName, "file")
+	config.Set(utils.MetricsFilePropertyName, "metrics.json")
+	if ma, err := createMonitoringAdapter(config); err != nil || ma == nil {
+		t.Errorf("Unexpected error creating adapter generic: %v", err)
+	}
+	config.Set(utils.AdapterPropertyName, "cimi")
+	if _, err := createMonitoringAdapter(config); err == nil {
+		t.Error("Expected error creating cimi adapter without cimi repository")
+	}
+	config.Set(utils.AdapterPropertyName, "notexists")
+	if _, err := createMonitoringAdapter(config); err == nil {
+		t.Error("Expected error creating unknown adapter")
+	}
+}
+
+func TestCreateNotifier(t *testing.T) {
+	config := viper.New()
+	config.Set(utils.NotifiersPropertyName, "log, log")
+	not, err := createNotifier(config, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error creating notifiers: %v", err)
+	}
+	if n := len(not.(notifier.Notifiers)); n != 2 {
+		t.Errorf("Unexpected number of notifiers. Expected: 2; Actual: %d", n)
+	}
+	config.Set(utils.NotifiersPropertyName, []string{"log", "notexists"})
+	if _, err := createNotifier(config, nil, nil); err == nil {
+		t.Error("Expected error creating unknown notifier")
+	}
+
+	/* the subscriptions are notified without the webhook notifier */
+	subconfig := viper.New()
+	subconfig.Set("pending", "")
+	for _, notifiers := range []string{"log", "log,webhook"} {
+		config.Set(utils.NotifiersPropertyName, notifiers)
+		not, err = createNotifier(config, subconfig, repo)
+		if err != nil {
+			t.Fatalf("Unexpected error creating notifiers: %v", err)
+		}
+		ns := not.(notifier.Notifiers)
+		if _, ok := ns[len(ns)-1].(*webhook.Notifier); len(ns) != 2 || !ok {
+			t.Errorf("%s: unexpected notifiers: %#v", notifiers, ns)
+		}
+		ns.Close()
+	}
+	config.Set(utils.NotifiersPropertyName, "log")
+	config.Set(utils.RepositoryTypePropertyName, "cimi")
+	if not, _ := createNotifier(config, subconfig, repo); len(not.(notifier.Notifiers)) != 1 {
+		t.Errorf("Unexpected notifiers with cimi repository: %#v", not)
+	}
+}
+
+func createPenalty(id string, aid string, gt string, datetime time.Time) model.Penalty {
+	return model.Penalty{
+		Id:          id,
+		AgreementId: aid,
+		Guarantee:   gt,
+		ViolationId: id + "-v",
+		Datetime:    datetime,
+		Definition:  model.PenaltyDef{Type: "discount", Value: "10", Unit: "%"},
+	}
+}
+
+func createWarning(id string, aid string, gt string, datetime time.Time) model.Warning {
+	return model.Warning{
+		Id:          id,
+		AgreementId: aid,
+		Guarantee:   gt,
+		Datetime:    datetime,
+		Expression:  "m < 8",
+		Values:      []model.MetricValue{{Key: "m", Value: 9, DateTime: datetime}},
+	}
+}
+
+func createViolation(id string, aid string, gt string, datetime time.Time) model.Violation {
+	return model.Violation{
+		Id:          id,
+		AgreementId: aid,
+		Guarantee:   gt,
+		Datetime:    datetime,
+		Constraint:  "test_value > 10",
+		Values: []model.MetricValue{
+			model.MetricValue{Key: "test_value", Value: 5, DateTime: datetime},
+		},
+	}
+}
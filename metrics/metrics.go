@@ -0,0 +1,111 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package metrics contains the Prometheus metrics of the SLALite itself: the REST API,
+the assessment, the monitoring adapters and the repository.
+
+The metrics are registered in the default Prometheus registry, and exposed by Handler.
+*/
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "slalite"
+
+var (
+	// HTTPRequests counts the REST API requests by method, route and status code
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of REST API requests by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	// HTTPRequestDuration observes the latency of the REST API requests by method and route
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the REST API requests by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// AssessmentDuration observes the duration of the assessments of the active agreements
+	AssessmentDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "assessment_duration_seconds",
+		Help:      "Duration of the assessment cycles.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// AssessedAgreements is the number of agreements evaluated in the last assessment, of all tenants
+	AssessedAgreements = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "assessed_agreements",
+		Help:      "Number of agreements evaluated in the last assessment cycle.",
+	})
+
+	// AssessmentErrors counts the agreements that could not be evaluated
+	AssessmentErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "assessment_errors_total",
+		Help:      "Number of agreement evaluations that failed.",
+	})
+
+	// Violations counts the violations by tenant, agreement and guarantee term
+	Violations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "violations_total",
+		Help:      "Number of violations by tenant, agreement and guarantee term.",
+	}, []string{"tenant", "agreement", "guarantee"})
+
+	// MonitoringErrors counts the errors retrieving metrics by monitoring adapter
+	MonitoringErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "monitoring_errors_total",
+		Help:      "Number of errors retrieving metric values by monitoring adapter.",
+	}, []string{"adapter"})
+
+	// RepositoryDuration observes the latency of the repository calls by operation
+	RepositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_duration_seconds",
+		Help:      "Latency of the repository calls by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		HTTPRequests,
+		HTTPRequestDuration,
+		AssessmentDuration,
+		AssessedAgreements,
+		AssessmentErrors,
+		Violations,
+		MonitoringErrors,
+		RepositoryDuration,
+	)
+}
+
+// Handler returns the handler that exposes the metrics to Prometheus
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -102,3 +102,13 @@ func (o *Analytics) Optimal() ([]AnalyticsItem, error) {
 	return *target, nil
 
 }
+
+// Check implements health.Checker, returning an error if the Analytics component does not answer.
+// Any HTTP response, even an error status, is considered an answer.
+func (o *Analytics) Check() error {
+	err := o.client.Get("", nil)
+	if _, ok := err.(rest.Error); ok {
+		return nil
+	}
+	return err
+}
@@ -82,6 +82,17 @@ func (o Policies) IsLeader() (bool, error) {
 	return target.ImLeader, nil
 }
 
+// Check implements health.Checker, returning an error if the Policies component is not available
+func (o Policies) Check() error {
+	_, err := o.IsLeader()
+	return err
+}
+
+// Check implements health.Checker. A PoliciesMock is always available.
+func (o PoliciesMock) Check() error {
+	return nil
+}
+
 // IsLeader returns if the current agent is leader or not
 func (o PoliciesMock) IsLeader() (bool, error) {
 	return o.isLeader, nil
@@ -37,6 +37,17 @@ var ErrNotFound = errors.New("Entity not found")
 //
 var ErrAlreadyExist = errors.New("Entity already exists")
 
+//
+// ErrTemplateNotStarted is the sentinel error for generating an agreement from a template
+// that is not started
+//
+var ErrTemplateNotStarted = errors.New("Template is not started")
+
+//
+// ErrNotSupported is the sentinel error for an operation that the repository does not support
+//
+var ErrNotSupported = errors.New("Operation not supported by the repository")
+
 /*
  * ValidationErrors following behavioral errors
  * (https://dave.cheney.net/2016/04/27/dont-just-check-errors-handle-them-gracefully)
@@ -105,8 +116,23 @@ const (
 	NONE AggregationType = "none"
 	// AVERAGE is used to calculate average of a variable
 	AVERAGE AggregationType = "average"
+	// MAX is used to calculate the maximum value of a variable
+	MAX AggregationType = "max"
+	// MIN is used to calculate the minimum value of a variable
+	MIN AggregationType = "min"
+	// SUM is used to calculate the sum of the values of a variable
+	SUM AggregationType = "sum"
+	// COUNT is used to calculate the number of values of a variable
+	COUNT AggregationType = "count"
+	// PERCENTILE is used to calculate a percentile (set in Aggregation.Percentile) of a variable
+	PERCENTILE AggregationType = "percentile"
+	// RATE is used to calculate the per-second increase of a variable (e.g., a counter)
+	RATE AggregationType = "rate"
 )
 
+// AggregationTypes is the list of supported aggregation types
+var AggregationTypes = [...]AggregationType{NONE, AVERAGE, MAX, MIN, SUM, COUNT, PERCENTILE, RATE}
+
 // States is the list of possible states of an agreement/template
 var States = [...]State{STOPPED, STARTED, TERMINATED}
 
@@ -117,9 +143,14 @@ type Party struct {
 	Name string `json:"name"`
 }
 
-// Provider is the entity that represents a Provider
+// Provider is the entity that represents a Provider. Tenant is set by the
+// repositories that support tenants (see TenantRepository).
 // swagger:model
-type Provider Party
+type Provider struct {
+	Id     string `json:"id" bson:"_id"`
+	Name   string `json:"name"`
+	Tenant string `json:"tenant,omitempty" bson:"tenant,omitempty"`
+}
 
 // GetId returns the Id of a provider
 func (p *Provider) GetId() string {
@@ -149,19 +180,26 @@ func (c *Client) Validate(val Validator, mode ValidationMode) []error {
 //
 // The Details field of the template contains placeholders that are substituted
 // when generating an agreement from a template (see generator package).
-// The Constraints fields contains constraints that a variable used in a guarantee
-// must satisfy. F.e., if the guarantee expression is "cpu_usage < {{M}}", one could
-// specify in Constraints that "M" : "M >= 0 && M <= 100".Template
+// The Parameters field declares the variables of the placeholders: their type, default
+// value, if they are required and the constraint they must satisfy. F.e., if the
+// guarantee expression is "cpu_usage < {{.M}}", one could declare the parameter "M"
+// with type "number" and constraint "M >= 0 && M <= 100".
 //
 // The Id and Name are relative to the template itself, and should not match
 // the fields in Details.
+//
+// The Version is assigned by the repository: a template is created with version 1,
+// and each update stores a new immutable revision with the next version.
 // swagger:model
 type Template struct {
 	Id      string  `json:"id" bson:"_id"`
 	Name    string  `json:"name"`
 	State   State   `json:"state"`
+	Version int     `json:"version"`
 	Details Details `json:"details"`
-	// mF2C commented Constraints map[string]string `json:"constraints"`
+	Tenant  string  `json:"tenant,omitempty" bson:"tenant,omitempty"`
+
+	Parameters map[string]Parameter `json:"parameters,omitempty" bson:"parameters,omitempty"`
 }
 
 // CreateAgreement is the resource used to create an agreement from a template.
@@ -172,17 +210,37 @@ type CreateAgreement struct {
 	Parameters  map[string]interface{} `json:"parameters"`
 }
 
+// AgreementPreview is the resource returned by a dry-run generation of an agreement
+// from a template. Errors contains each error found in the generation, and Parameters
+// the error of each invalid template parameter.
+// swagger:model
+type AgreementPreview struct {
+	Agreement  *Agreement        `json:"agreement,omitempty"`
+	Errors     []string          `json:"errors,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// TemplateRef identifies the revision of a template.
+// swagger:model
+type TemplateRef struct {
+	Id      string `json:"id"`
+	Version int    `json:"version"`
+}
+
 // Agreement is the entity that represents an agreement between a provider and a client.
 // The Text is ReadOnly in normal conditions, with the exception of a renegotiation.
 // The Assessment cannot be modified externally.
+// The Template is the revision of the template the agreement was generated from, if any.
 // The Signature is the Text digitally signed by the Client (not used yet)
 // swagger:model
 type Agreement struct {
-	Id         string      `json:"id" bson:"_id"`
-	Name       string      `json:"name"`
-	State      State       `json:"state"`
-	Assessment *Assessment `json:"assessment,omitempty"`
-	Details    Details     `json:"details"`
+	Id         string       `json:"id" bson:"_id"`
+	Name       string       `json:"name"`
+	State      State        `json:"state"`
+	Assessment *Assessment  `json:"assessment,omitempty"`
+	Details    Details      `json:"details"`
+	Template   *TemplateRef `json:"template,omitempty" bson:"template,omitempty"`
+	Tenant     string       `json:"tenant,omitempty" bson:"tenant,omitempty"`
 
 	/* Signature string `json:"signature"` */
 }
@@ -236,10 +294,14 @@ type Variable struct {
 // If defined and value is not NONE, the metric must be aggregated
 // in the specified window in seconds.
 // I.e. (average, 3600) means that the average over a period of one hour is calculated.
+//
+// Percentile is the percentile to calculate (in the interval (0, 100]) when Type is PERCENTILE.
+// I.e. (percentile, 3600, 95) means that the 95th percentile over a period of one hour is calculated.
 // swagger:model
 type Aggregation struct {
-	Type   AggregationType `json:"type"`
-	Window int             `json:"window"`
+	Type       AggregationType `json:"type"`
+	Window     int             `json:"window"`
+	Percentile float64         `json:"percentile,omitempty"`
 }
 
 // Guarantee is the struct that represents an SLO
@@ -256,7 +318,9 @@ type Guarantee struct {
 // Scope is the resources a guarantee term applies on
 type Scope string
 
-// Schedule is the frequency a guarantee term is evaluated
+// Schedule is the frequency a guarantee term is evaluated. It is a duration (e.g. "1h30m"),
+// or a cron expression (e.g. "0 * * * *" or "@hourly"). A guarantee term with empty
+// schedule is evaluated on every assessment.
 type Schedule string
 
 // PenaltyDef is the struct that represents a penalty in case of an SLO violation
@@ -282,23 +346,78 @@ func (v MetricValue) String() string {
 // Violation is generated when a guarantee term is not fulfilled
 // swagger:model
 type Violation struct {
-	Id          string        `json:"id"`
+	Id          string        `json:"id" bson:"_id"`
 	AgreementId string        `json:"agreement_id"`
 	Guarantee   string        `json:"guarantee"`
 	Datetime    time.Time     `json:"datetime"`
 	Constraint  string        `json:"constraint"`
 	Values      []MetricValue `json:"values"`
+	Tenant      string        `json:"tenant,omitempty" bson:"tenant,omitempty"`
+}
+
+// Warning is generated when the metric values of a guarantee term fulfill the constraint,
+// but not the warning expression of the term. It allows to react before a violation occurs.
+// swagger:model
+type Warning struct {
+	Id          string        `json:"id" bson:"_id"`
+	AgreementId string        `json:"agreement_id"`
+	Guarantee   string        `json:"guarantee"`
+	Datetime    time.Time     `json:"datetime"`
+	Expression  string        `json:"expression"`
+	Values      []MetricValue `json:"values"`
+	Tenant      string        `json:"tenant,omitempty" bson:"tenant,omitempty"`
 }
 
 // Penalty is generated when a guarantee term is violated is the term has
 // PenaltyDefs associated.
 // swagger:model
 type Penalty struct {
-	Id          string     `json:"id"`
+	Id          string     `json:"id" bson:"_id"`
 	AgreementId string     `json:"agreement_id"`
 	Guarantee   string     `json:"guarantee"`
+	ViolationId string     `json:"violation_id"`
 	Datetime    time.Time  `json:"datetime"`
 	Definition  PenaltyDef `json:"definition"`
+	Tenant      string     `json:"tenant,omitempty" bson:"tenant,omitempty"`
+}
+
+// EventType is the type of the events that are sent to the subscriptions
+type EventType string
+
+const (
+	// VIOLATION is the event of a violation of a guarantee term
+	VIOLATION EventType = "violation"
+
+	// WARNING is the event of a warning raised by a guarantee term
+	WARNING EventType = "warning"
+
+	// STATE_CHANGE is the event of a change of state of an agreement
+	STATE_CHANGE EventType = "state_change"
+
+	// EXPIRATION is the event of an agreement terminated because it has expired
+	EXPIRATION EventType = "expiration"
+)
+
+// EventTypes is the list of possible event types
+var EventTypes = [...]EventType{VIOLATION, WARNING, STATE_CHANGE, EXPIRATION}
+
+// Subscription is a callback Url where the events of the agreements are sent.
+//
+// AgreementId, ProviderId and ClientId optionally filter the agreements whose events
+// are sent; Events optionally filters the types of the events. An empty filter
+// matches everything.
+//
+// Secret signs the events sent to the subscription (see the webhook notifier).
+// swagger:model
+type Subscription struct {
+	Id          string      `json:"id" bson:"_id"`
+	Url         string      `json:"url"`
+	AgreementId string      `json:"agreement_id,omitempty"`
+	ProviderId  string      `json:"provider_id,omitempty"`
+	ClientId    string      `json:"client_id,omitempty"`
+	Events      []EventType `json:"events,omitempty"`
+	Secret      string      `json:"secret,omitempty" bson:"secret,omitempty"`
+	Tenant      string      `json:"tenant,omitempty" bson:"tenant,omitempty"`
 }
 
 // GetId returns the id of an template
@@ -306,6 +425,12 @@ func (t *Template) GetId() string {
 	return t.Id
 }
 
+// IsStarted is true if the template state is STARTED, i.e., agreements can be
+// generated from it. A template stored without state is considered started.
+func (t *Template) IsStarted() bool {
+	return t.State == STARTED || t.State == ""
+}
+
 // Validate validates the consistency of a Template.
 func (t *Template) Validate(val Validator, mode ValidationMode) []error {
 	return val.ValidateTemplate(t, mode)
@@ -414,6 +539,54 @@ func (v *Violation) Validate(val Validator, mode ValidationMode) []error {
 	return val.ValidateViolation(v, mode)
 }
 
+// GetId returns the Id of a warning
+func (w *Warning) GetId() string {
+	return w.Id
+}
+
+// Validate validates the consistency of a Warning entity
+func (w *Warning) Validate(val Validator, mode ValidationMode) []error {
+	return val.ValidateWarning(w, mode)
+}
+
+// GetId returns the Id of a penalty
+func (p *Penalty) GetId() string {
+	return p.Id
+}
+
+// Validate validates the consistency of a Penalty entity
+func (p *Penalty) Validate(val Validator, mode ValidationMode) []error {
+	return val.ValidatePenalty(p, mode)
+}
+
+// GetId returns the Id of a subscription
+func (s *Subscription) GetId() string {
+	return s.Id
+}
+
+// Validate validates the consistency of a Subscription entity
+func (s *Subscription) Validate(val Validator, mode ValidationMode) []error {
+	return val.ValidateSubscription(s, mode)
+}
+
+// Matches returns if an event of type t of the agreement a must be sent to the subscription
+func (s *Subscription) Matches(a *Agreement, t EventType) bool {
+	if s.AgreementId != "" && s.AgreementId != a.Id ||
+		s.ProviderId != "" && s.ProviderId != a.Details.Provider.Id ||
+		s.ClientId != "" && s.ClientId != a.Details.Client.Id {
+		return false
+	}
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
 // Normalize returns an always valid state: any different value from contained in States is STOPPED.
 func (s State) Normalize() State {
 	return normalizeState(s)
@@ -430,3 +603,19 @@ type Agreements []Agreement
 // Templates is the type of an slice of Template
 // swagger:model
 type Templates []Template
+
+// Violations is the type of an slice of Violation
+// swagger:model
+type Violations []Violation
+
+// Warnings is the type of an slice of Warning
+// swagger:model
+type Warnings []Warning
+
+// Penalties is the type of an slice of Penalty
+// swagger:model
+type Penalties []Penalty
+
+// Subscriptions is the type of an slice of Subscription
+// swagger:model
+type Subscriptions []Subscription
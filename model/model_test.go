@@ -74,6 +74,23 @@ func TestGuarantee(t *testing.T) {
 	g = Guarantee{Name: "name", Constraint: ""}
 	checkNumber(t, &g, 1)
 
+	g = Guarantee{Name: "name", Constraint: "a LT 10", Schedule: "1h"}
+	checkNumber(t, &g, 0)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10", Schedule: "0 * * * *"}
+	checkNumber(t, &g, 0)
+
+	g = Guarantee{Name: "name", Constraint: "a LT 10", Schedule: "every hour"}
+	checkNumber(t, &g, 1)
+
+	g = Guarantee{Name: "name", Constraint: "a < 10", Warning: "a < 8"}
+	checkNumber(t, &g, 0)
+
+	g = Guarantee{Name: "name", Constraint: "a < 10", Warning: "a < && 8"}
+	checkNumber(t, &g, 1)
+
+	g = Guarantee{Name: "name", Constraint: "a < 10", Warning: "a < {{.M}}"}
+	checkNumber(t, &g, 0)
 }
 
 func TestDetails(t *testing.T) {
@@ -104,6 +121,27 @@ func TestDetails(t *testing.T) {
 	checkNumber(t, &at, 2)
 }
 
+func TestDetailsAggregation(t *testing.T) {
+	at := Details{Id: "id", Name: "name", Provider: pr, Client: cl}
+	check := func(ag *Aggregation, expected int) {
+		at.Variables = []Variable{Variable{Name: "v", Metric: "v", Aggregation: ag}}
+		checkNumber(t, &at, expected)
+	}
+	check(nil, 0)
+	check(&Aggregation{}, 0)
+	check(&Aggregation{Type: AVERAGE, Window: 60}, 0)
+	check(&Aggregation{Type: MAX}, 0)
+	check(&Aggregation{Type: MIN}, 0)
+	check(&Aggregation{Type: SUM}, 0)
+	check(&Aggregation{Type: COUNT, Window: 3600}, 0)
+	check(&Aggregation{Type: RATE, Window: 3600}, 0)
+	check(&Aggregation{Type: PERCENTILE, Window: 3600, Percentile: 95}, 0)
+	check(&Aggregation{Type: PERCENTILE, Window: 3600}, 1)
+	check(&Aggregation{Type: PERCENTILE, Percentile: 101}, 1)
+	check(&Aggregation{Type: "median"}, 1)
+	check(&Aggregation{Type: MAX, Window: -1}, 1)
+}
+
 func TestAgreement(t *testing.T) {
 
 	a := Agreement{
@@ -351,6 +389,31 @@ func TestViolation(t *testing.T) {
 	}
 }
 
+func TestWarning(t *testing.T) {
+	var w = Warning{}
+	checkNumber(t, &w, 6)
+	if w.GetId() != w.Id {
+		t.Errorf("Warning.Id and Warning.GetId() do not match")
+	}
+}
+
+func TestPenalty(t *testing.T) {
+	var p = Penalty{}
+	checkNumber(t, &p, 5)
+
+	p = Penalty{
+		Id:          "p",
+		AgreementId: "a",
+		Guarantee:   "g",
+		Datetime:    time.Now(),
+		Definition:  PenaltyDef{Type: "discount", Value: "10", Unit: "%"},
+	}
+	checkNumber(t, &p, 0)
+	if p.GetId() != p.Id {
+		t.Errorf("Penalty.Id and Penalty.GetId() do not match")
+	}
+}
+
 func TestViolationSerialization(t *testing.T) {
 	var v Violation
 	s := `{
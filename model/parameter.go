@@ -0,0 +1,164 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/Knetic/govaluate"
+)
+
+// ParameterType is the type of the value of a template parameter
+type ParameterType string
+
+const (
+	// AnyType accepts values of any type
+	AnyType ParameterType = ""
+	// StringType accepts strings
+	StringType ParameterType = "string"
+	// NumberType accepts integer and floating point numbers
+	NumberType ParameterType = "number"
+	// BooleanType accepts true and false
+	BooleanType ParameterType = "boolean"
+	// ObjectType accepts objects (e.g., a provider or a client)
+	ObjectType ParameterType = "object"
+	// ArrayType accepts lists of values
+	ArrayType ParameterType = "array"
+)
+
+// ParameterTypes is the list of valid parameter types
+var ParameterTypes = [...]ParameterType{AnyType, StringType, NumberType, BooleanType, ObjectType, ArrayType}
+
+// Parameter declares a variable of the placeholders of a template.
+//
+// When generating an agreement, a parameter that is not given takes the Default
+// value, if any; it is an error if there is no default and the parameter is Required.
+// The Constraint is a govaluate expression on the parameters that the given value
+// must satisfy (e.g., "M >= 0 && M <= 100").
+// swagger:model
+type Parameter struct {
+	Type       ParameterType `json:"type,omitempty" bson:"type,omitempty"`
+	Default    interface{}   `json:"default,omitempty" bson:"default,omitempty"`
+	Required   bool          `json:"required,omitempty" bson:"required,omitempty"`
+	Constraint string        `json:"constraint,omitempty" bson:"constraint,omitempty"`
+}
+
+// Check checks the consistency of the declaration of a parameter: the type is valid,
+// the default value is of that type and the constraint is a valid expression.
+func (p Parameter) Check() error {
+	valid := false
+	for _, t := range ParameterTypes {
+		valid = valid || p.Type == t
+	}
+	if !valid {
+		return fmt.Errorf("type '%s' is not valid", p.Type)
+	}
+	if p.Default != nil {
+		if err := p.CheckType(p.Default); err != nil {
+			return fmt.Errorf("default value: %v", err)
+		}
+	}
+	if p.Constraint != "" {
+		if _, err := govaluate.NewEvaluableExpression(p.Constraint); err != nil {
+			return fmt.Errorf("constraint '%s' is not valid: %v", p.Constraint, err)
+		}
+	}
+	return nil
+}
+
+// CheckType checks that value is of the type of the parameter
+func (p Parameter) CheckType(value interface{}) error {
+	var ok bool
+
+	switch p.Type {
+	case AnyType:
+		ok = true
+	case StringType:
+		ok = kindOf(value) == reflect.String
+	case NumberType:
+		_, ok = toNumber(value)
+	case BooleanType:
+		ok = kindOf(value) == reflect.Bool
+	case ObjectType:
+		kind := kindOf(value)
+		ok = kind == reflect.Map || kind == reflect.Struct
+	case ArrayType:
+		kind := kindOf(value)
+		ok = kind == reflect.Slice || kind == reflect.Array
+	}
+	if !ok {
+		return fmt.Errorf("value %v is not of type %s", value, p.Type)
+	}
+	return nil
+}
+
+// Satisfies checks that the parameters satisfy the constraint of p, if any.
+//
+// Numbers are converted to float64 before the evaluation, as required by govaluate.
+func (p Parameter) Satisfies(parameters map[string]interface{}) error {
+	if p.Constraint == "" {
+		return nil
+	}
+	expression, err := govaluate.NewEvaluableExpression(p.Constraint)
+	if err != nil {
+		return fmt.Errorf("constraint '%s' is not valid: %v", p.Constraint, err)
+	}
+	values := make(map[string]interface{}, len(parameters))
+	for name, value := range parameters {
+		if n, ok := toNumber(value); ok {
+			value = n
+		}
+		values[name] = value
+	}
+	result, err := expression.Evaluate(values)
+	if err != nil {
+		return fmt.Errorf("error evaluating constraint '%s': %v", p.Constraint, err)
+	}
+	if satisfied, ok := result.(bool); !ok || !satisfied {
+		return fmt.Errorf("does not satisfy '%s'", p.Constraint)
+	}
+	return nil
+}
+
+// toNumber returns the float64 value of value, if it is a number
+func toNumber(value interface{}) (float64, bool) {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// kindOf returns the kind of value, following pointers
+func kindOf(value interface{}) reflect.Kind {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	return v.Kind()
+}
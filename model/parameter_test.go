@@ -0,0 +1,88 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+)
+
+func TestParameterCheck(t *testing.T) {
+	valid := []Parameter{
+		{},
+		{Type: NumberType, Default: 50.0, Constraint: "M >= 0 && M <= 100"},
+		{Type: StringType, Default: "gold", Required: true},
+		{Type: ObjectType, Default: map[string]interface{}{"id": "p01"}},
+	}
+	for _, p := range valid {
+		if err := p.Check(); err != nil {
+			t.Errorf("Parameter %v should be valid: %v", p, err)
+		}
+	}
+	invalid := []Parameter{
+		{Type: "integer"},
+		{Type: NumberType, Default: "50"},
+		{Type: BooleanType, Default: 1},
+		{Constraint: "M >= && 0"},
+	}
+	for _, p := range invalid {
+		if err := p.Check(); err == nil {
+			t.Errorf("Parameter %v should be invalid", p)
+		}
+	}
+}
+
+func TestParameterCheckType(t *testing.T) {
+	cases := []struct {
+		typ   ParameterType
+		value interface{}
+		valid bool
+	}{
+		{AnyType, "x", true},
+		{StringType, "x", true},
+		{StringType, 1, false},
+		{NumberType, 1, true},
+		{NumberType, 0.5, true},
+		{NumberType, "1", false},
+		{BooleanType, true, true},
+		{ObjectType, Provider{Id: "p01"}, true},
+		{ObjectType, &Provider{Id: "p01"}, true},
+		{ObjectType, "p01", false},
+		{ArrayType, []interface{}{1, 2}, true},
+	}
+	for _, c := range cases {
+		err := Parameter{Type: c.typ}.CheckType(c.value)
+		if (err == nil) != c.valid {
+			t.Errorf("Type %s, value %v. Expected valid: %v; Actual: %v", c.typ, c.value, c.valid, err)
+		}
+	}
+}
+
+func TestParameterSatisfies(t *testing.T) {
+	p := Parameter{Constraint: "M >= 0 && M <= N"}
+	if err := p.Satisfies(map[string]interface{}{"M": 50, "N": 100.0}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := p.Satisfies(map[string]interface{}{"M": 500, "N": 100}); err == nil {
+		t.Error("Expected error with value out of range")
+	}
+	if err := p.Satisfies(map[string]interface{}{"M": 50}); err == nil {
+		t.Error("Expected error with missing parameter in constraint")
+	}
+	if err := (Parameter{}).Satisfies(nil); err != nil {
+		t.Errorf("Unexpected error without constraint: %v", err)
+	}
+}
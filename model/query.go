@@ -0,0 +1,373 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Names of the fields that lists can be sorted by
+const (
+	FieldId         = "id"
+	FieldName       = "name"
+	FieldState      = "state"
+	FieldProvider   = "provider"
+	FieldClient     = "client"
+	FieldCreation   = "creation"
+	FieldExpiration = "expiration"
+)
+
+// AgreementSortFields are the fields an agreement list can be sorted by
+var AgreementSortFields = []string{
+	FieldId, FieldName, FieldState, FieldProvider, FieldClient, FieldCreation, FieldExpiration,
+}
+
+// TemplateSortFields are the fields a template list can be sorted by
+var TemplateSortFields = []string{
+	FieldId, FieldName, FieldState, FieldProvider, FieldCreation,
+}
+
+// ProviderSortFields are the fields a provider list can be sorted by
+var ProviderSortFields = []string{
+	FieldId, FieldName,
+}
+
+// SortKey is a field to sort a list by. Desc is true if the order is descending.
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Sort is a list of sort keys; the first key has the highest precedence.
+//
+// Lists are always sorted by id after the keys in Sort.
+type Sort []SortKey
+
+// ParseSort builds a Sort from a comma-separated list of fields,
+// where each field is prefixed by '-' if the order is descending
+// (e.g. "state,-creation"). Only fields in allowed are accepted.
+func ParseSort(s string, allowed []string) (Sort, error) {
+	result := Sort{}
+	if s == "" {
+		return result, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		key := SortKey{Field: strings.TrimSpace(item)}
+		if strings.HasPrefix(key.Field, "-") {
+			key.Field = key.Field[1:]
+			key.Desc = true
+		}
+		if !contains(allowed, key.Field) {
+			return result, fmt.Errorf("Cannot sort by '%s'. Valid fields are %v", key.Field, allowed)
+		}
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+// less applies the sort keys using cmp to compare the value of a field in two items.
+func (s Sort) less(cmp func(field string) int) bool {
+	for _, key := range s {
+		if c := cmp(key.Field); c != 0 {
+			return (c < 0) != key.Desc
+		}
+	}
+	return cmp(FieldId) < 0
+}
+
+// Page contains the paging parameters of a query.
+//
+// Offset is the number of items to skip; a Limit equal to zero means
+// that all the remaining items are returned.
+type Page struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// Bounds returns the slice bounds [begin, end) of the page in a list of n items.
+func (p Page) Bounds(n int) (begin, end int) {
+	begin = p.Offset
+	if begin < 0 {
+		begin = 0
+	}
+	if begin > n {
+		begin = n
+	}
+	end = n
+	if p.Limit > 0 && begin+p.Limit < n {
+		end = begin + p.Limit
+	}
+	return begin, end
+}
+
+// AgreementQuery contains the filters, sort and paging to retrieve a list of agreements.
+//
+// Empty fields are not used to filter. Time intervals are closed on the From
+// side and open on the To side. Agreements without expiration never match an
+// expiration interval.
+type AgreementQuery struct {
+	ProviderId     string
+	ClientId       string
+	States         []State
+	CreationFrom   time.Time
+	CreationTo     time.Time
+	ExpirationFrom time.Time
+	ExpirationTo   time.Time
+	Sort           Sort
+	Page           Page
+}
+
+// Match returns if an agreement fulfills the filters of the query (paging is not considered)
+func (q *AgreementQuery) Match(a *Agreement) bool {
+	if q.ProviderId != "" && q.ProviderId != a.Details.Provider.Id {
+		return false
+	}
+	if q.ClientId != "" && q.ClientId != a.Details.Client.Id {
+		return false
+	}
+	if len(q.States) > 0 && !containsState(q.States, a.State) {
+		return false
+	}
+	if !inInterval(a.Details.Creation, q.CreationFrom, q.CreationTo) {
+		return false
+	}
+	if !q.ExpirationFrom.IsZero() || !q.ExpirationTo.IsZero() {
+		if a.Details.Expiration == nil ||
+			!inInterval(*a.Details.Expiration, q.ExpirationFrom, q.ExpirationTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// Less returns if agreement a goes before agreement b according to q.Sort
+func (q *AgreementQuery) Less(a, b *Agreement) bool {
+	return q.Sort.less(func(field string) int {
+		switch field {
+		case FieldName:
+			return strings.Compare(a.Name, b.Name)
+		case FieldState:
+			return strings.Compare(string(a.State), string(b.State))
+		case FieldProvider:
+			return strings.Compare(a.Details.Provider.Id, b.Details.Provider.Id)
+		case FieldClient:
+			return strings.Compare(a.Details.Client.Id, b.Details.Client.Id)
+		case FieldCreation:
+			return compareTimes(a.Details.Creation, b.Details.Creation)
+		case FieldExpiration:
+			return compareTimes(expirationOf(a), expirationOf(b))
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+}
+
+// TemplateQuery contains the filters, sort and paging to retrieve a list of templates.
+//
+// Empty fields are not used to filter.
+type TemplateQuery struct {
+	ProviderId string
+	States     []State
+	Sort       Sort
+	Page       Page
+}
+
+// Match returns if a template fulfills the filters of the query (paging is not considered)
+func (q *TemplateQuery) Match(t *Template) bool {
+	if q.ProviderId != "" && q.ProviderId != t.Details.Provider.Id {
+		return false
+	}
+	if len(q.States) > 0 && !containsState(q.States, t.State) {
+		return false
+	}
+	return true
+}
+
+// Less returns if template a goes before template b according to q.Sort
+func (q *TemplateQuery) Less(a, b *Template) bool {
+	return q.Sort.less(func(field string) int {
+		switch field {
+		case FieldName:
+			return strings.Compare(a.Name, b.Name)
+		case FieldState:
+			return strings.Compare(string(a.State), string(b.State))
+		case FieldProvider:
+			return strings.Compare(a.Details.Provider.Id, b.Details.Provider.Id)
+		case FieldCreation:
+			return compareTimes(a.Details.Creation, b.Details.Creation)
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+}
+
+// ProviderQuery contains the sort and paging to retrieve a list of providers.
+type ProviderQuery struct {
+	Sort Sort
+	Page Page
+}
+
+// Less returns if provider a goes before provider b according to q.Sort
+func (q *ProviderQuery) Less(a, b *Provider) bool {
+	return q.Sort.less(func(field string) int {
+		if field == FieldName {
+			return strings.Compare(a.Name, b.Name)
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+}
+
+// ViolationQuery contains the filters to retrieve a list of violations.
+//
+// Empty fields are not used to filter. If From is set, only violations
+// with Datetime >= From are returned; if To is set, only violations with
+// Datetime < To are returned.
+type ViolationQuery struct {
+	AgreementId string
+	Guarantee   string
+	From        time.Time
+	To          time.Time
+	Page        Page
+}
+
+// Match returns if a violation fulfills the filters of the query (paging is not considered)
+func (q *ViolationQuery) Match(v *Violation) bool {
+	if q.AgreementId != "" && q.AgreementId != v.AgreementId {
+		return false
+	}
+	if q.Guarantee != "" && q.Guarantee != v.Guarantee {
+		return false
+	}
+	return inInterval(v.Datetime, q.From, q.To)
+}
+
+// Less returns if violation a goes before violation b (i.e., sorted by datetime)
+func (q *ViolationQuery) Less(a, b *Violation) bool {
+	if c := compareTimes(a.Datetime, b.Datetime); c != 0 {
+		return c < 0
+	}
+	return a.Id < b.Id
+}
+
+// WarningQuery contains the filters to retrieve a list of warnings.
+//
+// Empty fields are not used to filter. If From is set, only warnings
+// with Datetime >= From are returned; if To is set, only warnings with
+// Datetime < To are returned.
+type WarningQuery struct {
+	AgreementId string
+	Guarantee   string
+	From        time.Time
+	To          time.Time
+	Page        Page
+}
+
+// Match returns if a warning fulfills the filters of the query (paging is not considered)
+func (q *WarningQuery) Match(w *Warning) bool {
+	if q.AgreementId != "" && q.AgreementId != w.AgreementId {
+		return false
+	}
+	if q.Guarantee != "" && q.Guarantee != w.Guarantee {
+		return false
+	}
+	return inInterval(w.Datetime, q.From, q.To)
+}
+
+// Less returns if warning a goes before warning b (i.e., sorted by datetime)
+func (q *WarningQuery) Less(a, b *Warning) bool {
+	if c := compareTimes(a.Datetime, b.Datetime); c != 0 {
+		return c < 0
+	}
+	return a.Id < b.Id
+}
+
+// PenaltyQuery contains the filters to retrieve a list of penalties.
+//
+// Empty fields are not used to filter. If From is set, only penalties
+// with Datetime >= From are returned; if To is set, only penalties with
+// Datetime < To are returned.
+type PenaltyQuery struct {
+	AgreementId string
+	Guarantee   string
+	From        time.Time
+	To          time.Time
+	Page        Page
+}
+
+// Match returns if a penalty fulfills the filters of the query (paging is not considered)
+func (q *PenaltyQuery) Match(p *Penalty) bool {
+	if q.AgreementId != "" && q.AgreementId != p.AgreementId {
+		return false
+	}
+	if q.Guarantee != "" && q.Guarantee != p.Guarantee {
+		return false
+	}
+	return inInterval(p.Datetime, q.From, q.To)
+}
+
+// Less returns if penalty a goes before penalty b (i.e., sorted by datetime)
+func (q *PenaltyQuery) Less(a, b *Penalty) bool {
+	if c := compareTimes(a.Datetime, b.Datetime); c != 0 {
+		return c < 0
+	}
+	return a.Id < b.Id
+}
+
+func inInterval(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
+
+func compareTimes(t1, t2 time.Time) int {
+	if t1.Before(t2) {
+		return -1
+	}
+	if t1.After(t2) {
+		return 1
+	}
+	return 0
+}
+
+func expirationOf(a *Agreement) time.Time {
+	if a.Details.Expiration == nil {
+		return time.Time{}
+	}
+	return *a.Details.Expiration
+}
+
+func containsState(states []State, state State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, item string) bool {
+	for _, s := range list {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
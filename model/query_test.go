@@ -0,0 +1,136 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPageBounds(t *testing.T) {
+	cases := []struct {
+		page       Page
+		n          int
+		begin, end int
+	}{
+		{Page{}, 5, 0, 5},
+		{Page{Offset: 2}, 5, 2, 5},
+		{Page{Limit: 2}, 5, 0, 2},
+		{Page{Offset: 2, Limit: 2}, 5, 2, 4},
+		{Page{Offset: 4, Limit: 2}, 5, 4, 5},
+		{Page{Offset: 6, Limit: 2}, 5, 5, 5},
+		{Page{Offset: 0, Limit: 2}, 0, 0, 0},
+	}
+	for _, c := range cases {
+		begin, end := c.page.Bounds(c.n)
+		if begin != c.begin || end != c.end {
+			t.Errorf("%v.Bounds(%d). Expected: [%d, %d); Actual: [%d, %d)",
+				c.page, c.n, c.begin, c.end, begin, end)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	cases := []struct {
+		s        string
+		expected Sort
+		valid    bool
+	}{
+		{"", Sort{}, true},
+		{"name", Sort{{Field: FieldName}}, true},
+		{"-creation,id", Sort{{Field: FieldCreation, Desc: true}, {Field: FieldId}}, true},
+		{"guarantees", nil, false},
+		{"name,", nil, false},
+	}
+	for _, c := range cases {
+		actual, err := ParseSort(c.s, AgreementSortFields)
+		if (err == nil) != c.valid {
+			t.Errorf("ParseSort(%q). Expected valid: %v; Actual error: %v", c.s, c.valid, err)
+			continue
+		}
+		if c.valid && !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("ParseSort(%q). Expected: %v; Actual: %v", c.s, c.expected, actual)
+		}
+	}
+}
+
+func TestAgreementQuery(t *testing.T) {
+	t0 := time.Now()
+	a := Agreement{Id: "a", Name: "b", State: STARTED,
+		Details: Details{Provider: Provider{Id: "p"}, Client: Client{Id: "c"}, Creation: t0}}
+	b := Agreement{Id: "b", Name: "a", State: STOPPED,
+		Details: Details{Provider: Provider{Id: "p"}, Client: Client{Id: "c"}, Creation: t0}}
+
+	matches := []struct {
+		q        AgreementQuery
+		expected bool
+	}{
+		{AgreementQuery{}, true},
+		{AgreementQuery{ProviderId: "p", ClientId: "c"}, true},
+		{AgreementQuery{ClientId: "d"}, false},
+		{AgreementQuery{States: []State{STOPPED, TERMINATED}}, false},
+		{AgreementQuery{CreationFrom: t0, CreationTo: t0.Add(time.Second)}, true},
+		{AgreementQuery{ExpirationFrom: t0}, false},
+	}
+	for _, c := range matches {
+		if actual := c.q.Match(&a); actual != c.expected {
+			t.Errorf("%#v.Match(). Expected: %v; Actual: %v", c.q, c.expected, actual)
+		}
+	}
+
+	sorts := []struct {
+		sort     Sort
+		expected bool
+	}{
+		{Sort{}, true},
+		{Sort{{Field: FieldName}}, false},
+		{Sort{{Field: FieldState, Desc: true}}, false},
+		{Sort{{Field: FieldCreation}}, true},
+		{Sort{{Field: FieldCreation, Desc: true}}, true},
+	}
+	for _, c := range sorts {
+		q := AgreementQuery{Sort: c.sort}
+		if actual := q.Less(&a, &b); actual != c.expected {
+			t.Errorf("Less() with sort %v. Expected: %v; Actual: %v", c.sort, c.expected, actual)
+		}
+	}
+}
+
+func TestViolationQueryMatch(t *testing.T) {
+	t0 := time.Now()
+	v := Violation{Id: "v", AgreementId: "a", Guarantee: "g", Datetime: t0}
+
+	cases := []struct {
+		q        ViolationQuery
+		expected bool
+	}{
+		{ViolationQuery{}, true},
+		{ViolationQuery{AgreementId: "a"}, true},
+		{ViolationQuery{AgreementId: "b"}, false},
+		{ViolationQuery{Guarantee: "g"}, true},
+		{ViolationQuery{Guarantee: "h"}, false},
+		{ViolationQuery{From: t0}, true},
+		{ViolationQuery{From: t0.Add(time.Second)}, false},
+		{ViolationQuery{To: t0}, false},
+		{ViolationQuery{To: t0.Add(time.Second)}, true},
+	}
+	for _, c := range cases {
+		if actual := c.q.Match(&v); actual != c.expected {
+			t.Errorf("%#v.Match(). Expected: %v; Actual: %v", c.q, c.expected, actual)
+		}
+	}
+}
@@ -31,6 +31,15 @@ type IRepository interface {
 	 */
 	GetAllProviders() (Providers, error)
 
+	/*
+	 * GetProviders returns the page of providers specified in the query,
+	 * and the total number of providers.
+	 *
+	 * The list is empty when there are no providers in the page;
+	 * error != nil on error
+	 */
+	GetProviders(q ProviderQuery) (Providers, int, error)
+
 	/*
 	 * GetProvider returns the Provider identified by id.
 	 *
@@ -63,6 +72,15 @@ type IRepository interface {
 	 */
 	GetAllAgreements() (Agreements, error)
 
+	/*
+	 * GetAgreements returns the page of agreements that match the query,
+	 * and the total number of agreements that match the query filters.
+	 *
+	 * The list is empty when there are no matching agreements in the page;
+	 * error != nil on error
+	 */
+	GetAgreements(q AgreementQuery) (Agreements, int, error)
+
 	/*
 	 * GetAgreement returns the Agreement identified by id.
 	 * error != nil on error;
@@ -106,6 +124,15 @@ type IRepository interface {
 	 */
 	GetAllTemplates() (Templates, error)
 
+	/*
+	 * GetTemplates returns the page of templates that match the query,
+	 * and the total number of templates that match the query filters.
+	 *
+	 * The list is empty when there are no matching templates in the page;
+	 * error != nil on error
+	 */
+	GetTemplates(q TemplateQuery) (Templates, int, error)
+
 	/*
 	 * GetTemplate returns the Template identified by id.
 	 * error != nil on error;
@@ -114,13 +141,51 @@ type IRepository interface {
 	GetTemplate(id string) (*Template, error)
 
 	/*
-	 * CreateTemplate stores a new Template.
+	 * CreateTemplate stores a new Template, with version 1, or the next version of
+	 * the revisions of a deleted Template with the same id.
 	 *
 	 * error != nil on error;
 	 * error is sql.ErrNoRows if the Template already exists
 	 */
 	CreateTemplate(template *Template) (*Template, error)
 
+	/*
+	 * UpdateTemplate updates the information of an already saved instance of a template,
+	 * storing it as a new revision with the next version.
+	 *
+	 * error != nil on error;
+	 * error is ErrNotFound if the Template does not exist
+	 */
+	UpdateTemplate(template *Template) (*Template, error)
+
+	/*
+	 * DeleteTemplate deletes from the repository the Template whose id is template.Id.
+	 * Its revisions are kept.
+	 *
+	 * error != nil on error;
+	 * error is ErrNotFound if the Template does not exist.
+	 */
+	DeleteTemplate(template *Template) error
+
+	/*
+	 * GetTemplateVersions returns the revisions of the Template identified by id,
+	 * sorted by version. The last one is the current Template, if it has not been
+	 * deleted.
+	 *
+	 * error != nil on error;
+	 * error is ErrNotFound if the Template never existed
+	 */
+	GetTemplateVersions(id string) (Templates, error)
+
+	/*
+	 * UpdateTemplateState changes the state of a Template, without storing a new
+	 * revision (i.e., the version is not changed).
+	 *
+	 * Returns the updated template; error != nil on error
+	 * error is ErrNotFound if the Template does not exist
+	 */
+	UpdateTemplateState(id string, newState State) (*Template, error)
+
 	/*
 	 * CreateViolation stores a new Violation.
 	 *
@@ -137,6 +202,105 @@ type IRepository interface {
 	 */
 	GetViolation(id string) (*Violation, error)
 
+	/*
+	 * GetViolations returns the page of violations that match the query, sorted by datetime,
+	 * and the total number of violations that match the query filters.
+	 *
+	 * The list is empty when there are no matching violations in the page;
+	 * error != nil on error
+	 */
+	GetViolations(q ViolationQuery) (Violations, int, error)
+
+	/*
+	 * CreateWarning stores a new Warning.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Warning already exists
+	 */
+	CreateWarning(w *Warning) (*Warning, error)
+
+	/*
+	 * GetWarning returns the Warning identified by id.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Warning is not found
+	 */
+	GetWarning(id string) (*Warning, error)
+
+	/*
+	 * GetWarnings returns the page of warnings that match the query, sorted by datetime,
+	 * and the total number of warnings that match the query filters.
+	 *
+	 * The list is empty when there are no matching warnings in the page;
+	 * error != nil on error
+	 */
+	GetWarnings(q WarningQuery) (Warnings, int, error)
+
+	/*
+	 * CreatePenalty stores a new Penalty.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Penalty already exists
+	 */
+	CreatePenalty(p *Penalty) (*Penalty, error)
+
+	/*
+	 * GetPenalty returns the Penalty identified by id.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Penalty is not found
+	 */
+	GetPenalty(id string) (*Penalty, error)
+
+	/*
+	 * GetPenalties returns the page of penalties that match the query, sorted by datetime,
+	 * and the total number of penalties that match the query filters.
+	 *
+	 * The list is empty when there are no matching penalties in the page;
+	 * error != nil on error
+	 */
+	GetPenalties(q PenaltyQuery) (Penalties, int, error)
+
+	/*
+	 * GetAllSubscriptions returns the list of subscriptions.
+	 *
+	 * The list is empty when there are no subscriptions;
+	 * error != nil on error
+	 */
+	GetAllSubscriptions() (Subscriptions, error)
+
+	/*
+	 * GetSubscription returns the Subscription identified by id.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Subscription is not found
+	 */
+	GetSubscription(id string) (*Subscription, error)
+
+	/*
+	 * CreateSubscription stores a new Subscription.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Subscription already exists
+	 */
+	CreateSubscription(s *Subscription) (*Subscription, error)
+
+	/*
+	 * UpdateSubscription updates an already saved Subscription.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Subscription does not exist
+	 */
+	UpdateSubscription(s *Subscription) (*Subscription, error)
+
+	/*
+	 * DeleteSubscription deletes from the repository the Subscription whose id is s.Id.
+	 *
+	 * error != nil on error;
+	 * error is sql.ErrNoRows if the Subscription does not exist.
+	 */
+	DeleteSubscription(s *Subscription) error
+
 	/*
 	 * UpdateAgreementState changes the state of an Agreement.
 	 *
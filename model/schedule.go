@@ -0,0 +1,73 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// Parse checks the syntax of the schedule
+func (s Schedule) Parse() error {
+	_, err := s.parse()
+	return err
+}
+
+// Next returns the time when a guarantee term with this schedule is due, given the
+// last time it was evaluated.
+//
+// It returns the zero time if the guarantee term is due on every assessment
+// (i.e., schedule is empty or it has never been evaluated).
+func (s Schedule) Next(last time.Time) (time.Time, error) {
+	sched, err := s.parse()
+	if err != nil || sched == nil || last.IsZero() {
+		return time.Time{}, err
+	}
+	return sched.Next(last), nil
+}
+
+// IsDue returns if a guarantee term with this schedule must be evaluated at now,
+// given the last time it was evaluated.
+func (s Schedule) IsDue(last, now time.Time) (bool, error) {
+	next, err := s.Next(last)
+	if err != nil {
+		return false, err
+	}
+	return !now.Before(next), nil
+}
+
+// parse returns the cron.Schedule of s, or nil if s is empty
+func (s Schedule) parse() (cron.Schedule, error) {
+	value := strings.TrimSpace(string(s))
+	if value == "" {
+		return nil, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("schedule '%s' is not a positive duration", s)
+		}
+		return cron.Every(d), nil
+	}
+	sched, err := cron.ParseStandard(value)
+	if err != nil {
+		return nil, fmt.Errorf("schedule '%s' is not a duration nor a cron expression: %v", s, err)
+	}
+	return sched, nil
+}
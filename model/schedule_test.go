@@ -0,0 +1,70 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleParse(t *testing.T) {
+	valid := []Schedule{"", "10s", "1h30m", "*/5 * * * *", "0 0 * * MON", "@hourly", "@every 2m"}
+	for _, s := range valid {
+		if err := s.Parse(); err != nil {
+			t.Errorf("Schedule '%s' should be valid: %v", s, err)
+		}
+	}
+	invalid := []Schedule{"0s", "-1m", "1x", "* * *", "61 * * * *", "@sometimes"}
+	for _, s := range invalid {
+		if err := s.Parse(); err == nil {
+			t.Errorf("Schedule '%s' should be invalid", s)
+		}
+	}
+}
+
+func TestScheduleIsDue(t *testing.T) {
+	last := time.Date(2020, 1, 1, 10, 15, 0, 0, time.UTC)
+	cases := []struct {
+		schedule Schedule
+		last     time.Time
+		now      time.Time
+		expected bool
+	}{
+		{"", last, last.Add(time.Second), true},
+		{"1h", time.Time{}, last, true},
+		{"1h", last, last.Add(59 * time.Minute), false},
+		{"1h", last, last.Add(time.Hour), true},
+		{"0 * * * *", last, last.Add(44 * time.Minute), false},
+		{"0 * * * *", last, last.Add(45 * time.Minute), true},
+		{"@daily", last, last.Add(13 * time.Hour), false},
+		{"@daily", last, last.Add(14 * time.Hour), true},
+	}
+	for _, c := range cases {
+		actual, err := c.schedule.IsDue(c.last, c.now)
+		if err != nil {
+			t.Errorf("Unexpected error in schedule '%s': %v", c.schedule, err)
+		}
+		if actual != c.expected {
+			t.Errorf("Schedule '%s'. Last: %v; now: %v. Expected: %v; Actual: %v",
+				c.schedule, c.last, c.now, c.expected, actual)
+		}
+	}
+
+	if _, err := Schedule("wrong").IsDue(last, last); err == nil {
+		t.Errorf("Expected error in wrong schedule")
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// DefaultTenant is the tenant of the entities when multi-tenancy is not used
+const DefaultTenant = ""
+
+// ErrTenantsUnsupported is returned when a tenant other than DefaultTenant is requested
+// to a repository that does not implement TenantRepository
+var ErrTenantsUnsupported = errors.New("Repository does not support tenants")
+
+// tenantPattern restricts the tenant ids, as they may be part of database names
+var tenantPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// TenantRepository is implemented by the repositories that keep apart the entities of
+// several tenants (e.g., organisations).
+//
+// The repository itself contains the entities of DefaultTenant.
+type TenantRepository interface {
+	/*
+	 * ForTenant returns a repository that only reads and writes the entities of tenant.
+	 * The entities created through it are assigned to tenant.
+	 *
+	 * error != nil if tenant is not a valid tenant id
+	 */
+	ForTenant(tenant string) (IRepository, error)
+
+	/*
+	 * GetTenants returns the tenants that have entities in the repository,
+	 * always including DefaultTenant.
+	 *
+	 * error != nil on error
+	 */
+	GetTenants() ([]string, error)
+}
+
+// ValidateTenant checks that tenant is a valid tenant id: DefaultTenant, or up to 64
+// letters, digits, '_' or '-'
+func ValidateTenant(tenant string) error {
+	if tenant != DefaultTenant && !tenantPattern.MatchString(tenant) {
+		return fmt.Errorf("Invalid tenant '%s': must be up to 64 letters, digits, '_' or '-'", tenant)
+	}
+	return nil
+}
+
+// ForTenant returns the repository of the entities of tenant in r (see TenantRepository).
+//
+// r is returned for DefaultTenant; ErrTenantsUnsupported if r does not support tenants.
+func ForTenant(r IRepository, tenant string) (IRepository, error) {
+	if tenant == DefaultTenant {
+		return r, nil
+	}
+	tr, ok := r.(TenantRepository)
+	if !ok {
+		return nil, ErrTenantsUnsupported
+	}
+	return tr.ForTenant(tenant)
+}
+
+// GetTenants returns the tenants of r (see TenantRepository), or only DefaultTenant
+// if r does not support tenants.
+func GetTenants(r IRepository) ([]string, error) {
+	if tr, ok := r.(TenantRepository); ok {
+		return tr.GetTenants()
+	}
+	return []string{DefaultTenant}, nil
+}
@@ -16,7 +16,14 @@ limitations under the License.
 
 package model
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/Knetic/govaluate"
+)
 
 /*
 Validator is the interface that contains validate functions for the model entities.
@@ -32,6 +39,9 @@ type Validator interface {
 	ValidateDetails(t *Details, mode ValidationMode) []error
 	ValidateGuarantee(g *Guarantee, mode ValidationMode) []error
 	ValidateViolation(v *Violation, mode ValidationMode) []error
+	ValidateWarning(w *Warning, mode ValidationMode) []error
+	ValidatePenalty(p *Penalty, mode ValidationMode) []error
+	ValidateSubscription(s *Subscription, mode ValidationMode) []error
 }
 
 // ValidationMode is the type of possible validations
@@ -94,6 +104,7 @@ func (val DefaultValidator) ValidateClient(c *Client, mode ValidationMode) []err
 func (val DefaultValidator) ValidateTemplate(t *Template, mode ValidationMode) []error {
 	result := make([]error, 0)
 
+	t.State = normalizeTemplateState(t.State)
 	result = checkEmpty(mode == CREATE && val.externalIDs, t.Id, "Template.Id", result)
 	result = checkNotEmpty(t.Name, "Template.Name", result)
 
@@ -107,6 +118,17 @@ func (val DefaultValidator) ValidateTemplate(t *Template, mode ValidationMode) [
 	if t.Details.Type != TEMPLATE {
 		result = append(result, fmt.Errorf("Template.Details.Type must be equal to '%s'", TEMPLATE))
 	}
+
+	names := make([]string, 0, len(t.Parameters))
+	for name := range t.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := t.Parameters[name].Check(); err != nil {
+			result = append(result, fmt.Errorf("Template.Parameters['%s']: %v", name, err))
+		}
+	}
 	return result
 }
 
@@ -151,6 +173,9 @@ func (val DefaultValidator) ValidateDetails(t *Details, mode ValidationMode) []e
 	for _, e := range t.Client.Validate(val, UPDATE) {
 		result = append(result, e)
 	}
+	for _, v := range t.Variables {
+		result = checkAggregation(v, result)
+	}
 	for _, g := range t.Guarantees {
 		for _, e := range g.Validate(val, mode) {
 			result = append(result, e)
@@ -159,6 +184,29 @@ func (val DefaultValidator) ValidateDetails(t *Details, mode ValidationMode) []e
 	return result
 }
 
+func checkAggregation(v Variable, current []error) []error {
+	if v.Aggregation == nil {
+		return current
+	}
+	desc := fmt.Sprintf("Variable['%s'].Aggregation", v.Name)
+	ag := v.Aggregation
+
+	valid := ag.Type == ""
+	for _, t := range AggregationTypes {
+		valid = valid || ag.Type == t
+	}
+	if !valid {
+		current = append(current, fmt.Errorf("%s.Type '%s' is not supported", desc, ag.Type))
+	}
+	if ag.Window < 0 {
+		current = append(current, fmt.Errorf("%s.Window cannot be negative", desc))
+	}
+	if ag.Type == PERCENTILE && (ag.Percentile <= 0 || ag.Percentile > 100) {
+		current = append(current, fmt.Errorf("%s.Percentile must be in the interval (0, 100]", desc))
+	}
+	return current
+}
+
 // ValidateViolation implements model.Validator.ValidateViolation
 func (val DefaultValidator) ValidateViolation(v *Violation, mode ValidationMode) []error {
 	result := make([]error, 0)
@@ -177,11 +225,73 @@ func (val DefaultValidator) ValidateViolation(v *Violation, mode ValidationMode)
 	return result
 }
 
+// ValidateWarning implements model.Validator.ValidateWarning
+func (val DefaultValidator) ValidateWarning(w *Warning, mode ValidationMode) []error {
+	result := make([]error, 0)
+
+	result = checkEmpty(mode == CREATE && val.externalIDs, w.Id, "Warning.Id", result)
+	result = checkNotEmpty(w.AgreementId, "Warning.AgreementId", result)
+	result = checkNotEmpty(w.Guarantee, "Warning.Guarantee", result)
+	if w.Datetime.IsZero() {
+		result = append(result, fmt.Errorf("%v is not a valid date", w.Datetime))
+	}
+	if w.Values == nil || len(w.Values) == 0 {
+		result = append(result, fmt.Errorf("Warning.Values cannot be empty"))
+	}
+	result = checkNotEmpty(w.Expression, "Warning.Expression", result)
+
+	return result
+}
+
+// ValidatePenalty implements model.Validator.ValidatePenalty
+func (val DefaultValidator) ValidatePenalty(p *Penalty, mode ValidationMode) []error {
+	result := make([]error, 0)
+
+	result = checkEmpty(mode == CREATE && val.externalIDs, p.Id, "Penalty.Id", result)
+	result = checkNotEmpty(p.AgreementId, "Penalty.AgreementId", result)
+	result = checkNotEmpty(p.Guarantee, "Penalty.Guarantee", result)
+	if p.Datetime.IsZero() {
+		result = append(result, fmt.Errorf("%v is not a valid date", p.Datetime))
+	}
+	result = checkNotEmpty(p.Definition.Type, "Penalty.Definition.Type", result)
+
+	return result
+}
+
+// ValidateSubscription implements model.Validator.ValidateSubscription
+func (val DefaultValidator) ValidateSubscription(s *Subscription, mode ValidationMode) []error {
+	result := make([]error, 0)
+
+	result = checkEmpty(mode == CREATE && val.externalIDs, s.Id, "Subscription.Id", result)
+	if u, err := url.Parse(s.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		result = append(result, fmt.Errorf("Subscription.Url '%s' is not a valid http(s) url", s.Url))
+	}
+	for _, e := range s.Events {
+		valid := false
+		for _, t := range EventTypes {
+			valid = valid || e == t
+		}
+		if !valid {
+			result = append(result, fmt.Errorf("Subscription.Events: '%s' is not a valid event type", e))
+		}
+	}
+	return result
+}
+
 // ValidateGuarantee implements model.Validator.ValidateGuarantee
 func (val DefaultValidator) ValidateGuarantee(g *Guarantee, mode ValidationMode) []error {
 	result := make([]error, 0)
 	result = checkNotEmpty(g.Name, "Guarantee.Name", result)
 	result = checkNotEmpty(g.Constraint, fmt.Sprintf("Guarantee['%s'].Constraint", g.Name), result)
+	if err := g.Schedule.Parse(); err != nil {
+		result = append(result, fmt.Errorf("Guarantee['%s'].Schedule: %v", g.Name, err))
+	}
+	// the placeholders of a template are checked once replaced in the agreement
+	if g.Warning != "" && !strings.Contains(g.Warning, "{{") {
+		if _, err := govaluate.NewEvaluableExpression(g.Warning); err != nil {
+			result = append(result, fmt.Errorf("Guarantee['%s'].Warning '%s' is not valid: %v", g.Name, g.Warning, err))
+		}
+	}
 
 	return result
 }
@@ -222,3 +332,12 @@ func normalizeState(s State) State {
 	}
 	return STOPPED
 }
+
+// normalizeTemplateState is normalizeState, but a template without state is
+// STARTED, as templates were always usable before they had a state
+func normalizeTemplateState(s State) State {
+	if s == "" {
+		return STARTED
+	}
+	return normalizeState(s)
+}
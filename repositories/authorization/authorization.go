@@ -0,0 +1,543 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package authorization provides a repository decorator that restricts the entities
+// and operations to the ones allowed to a caller of the REST API.
+//
+// An ADMIN is allowed everything. A PROVIDER sees the agreements and templates whose
+// provider is its party; a CLIENT sees the agreements whose client is its party and
+// all the templates. The violations and penalties follow the visibility of their
+// agreement. The entities not visible to the caller are not found.
+//
+// Only admins create, update and delete templates, create providers, and delete and
+// terminate agreements; these operations return auth.ErrForbidden to other callers.
+//
+// A PROVIDER manages the subscriptions filtered by its party as provider, and a CLIENT
+// the ones filtered by its party as client.
+//
+// Usage (on each request):
+//   repo = authorization.New(repo, principal)
+//
+package authorization
+
+import (
+	"SLALite/auth"
+	"SLALite/model"
+)
+
+type repository struct {
+	backend   model.IRepository
+	principal *auth.Principal
+}
+
+// New returns an IRepository that restricts the calls to backend to the ones allowed to p.
+func New(backend model.IRepository, p *auth.Principal) model.IRepository {
+	return repository{
+		backend:   backend,
+		principal: p,
+	}
+}
+
+func (r repository) isAdmin() bool {
+	return r.principal.HasRole(auth.ADMIN)
+}
+
+// isProvider returns if the caller acts as the provider with id
+func (r repository) isProvider(id string) bool {
+	return r.principal.HasRole(auth.PROVIDER) && r.principal.PartyId != "" && r.principal.PartyId == id
+}
+
+// isClient returns if the caller acts as the client with id
+func (r repository) isClient(id string) bool {
+	return r.principal.HasRole(auth.CLIENT) && r.principal.PartyId != "" && r.principal.PartyId == id
+}
+
+func (r repository) canSeeAgreement(a *model.Agreement) bool {
+	return r.isAdmin() || r.isProvider(a.Details.Provider.Id) || r.isClient(a.Details.Client.Id)
+}
+
+func (r repository) canSeeTemplate(t *model.Template) bool {
+	return r.isAdmin() || r.principal.HasRole(auth.CLIENT) || r.isProvider(t.Details.Provider.Id)
+}
+
+func (r repository) canSeeSubscription(s *model.Subscription) bool {
+	return r.isAdmin() || r.isProvider(s.ProviderId) || r.isClient(s.ClientId)
+}
+
+func (r repository) requireAdmin() error {
+	if !r.isAdmin() {
+		return auth.ErrForbidden
+	}
+	return nil
+}
+
+// GetAllProviders gets all providers.
+func (r repository) GetAllProviders() (model.Providers, error) {
+	return r.backend.GetAllProviders()
+}
+
+// GetProviders gets a page of providers.
+func (r repository) GetProviders(q model.ProviderQuery) (model.Providers, int, error) {
+	return r.backend.GetProviders(q)
+}
+
+// GetProvider gets a provider.
+func (r repository) GetProvider(id string) (*model.Provider, error) {
+	return r.backend.GetProvider(id)
+}
+
+// CreateProvider persists a provider, if the caller is an admin.
+func (r repository) CreateProvider(provider *model.Provider) (*model.Provider, error) {
+	if err := r.requireAdmin(); err != nil {
+		return nil, err
+	}
+	return r.backend.CreateProvider(provider)
+}
+
+// DeleteProvider deletes a provider, if the caller is an admin.
+func (r repository) DeleteProvider(provider *model.Provider) error {
+	if err := r.requireAdmin(); err != nil {
+		return err
+	}
+	return r.backend.DeleteProvider(provider)
+}
+
+// GetAllAgreements gets the agreements visible to the caller.
+func (r repository) GetAllAgreements() (model.Agreements, error) {
+	all, err := r.backend.GetAllAgreements()
+	if err != nil || r.isAdmin() {
+		return all, err
+	}
+	return r.filterAgreements(all), nil
+}
+
+// GetAgreements gets the agreements visible to the caller that match the query.
+func (r repository) GetAgreements(q model.AgreementQuery) (model.Agreements, int, error) {
+	if r.isAdmin() {
+		return r.backend.GetAgreements(q)
+	}
+
+	party := r.principal.PartyId
+	provider := r.principal.HasRole(auth.PROVIDER)
+	client := r.principal.HasRole(auth.CLIENT)
+	switch {
+	case party == "" || !provider && !client:
+		return model.Agreements{}, 0, nil
+	case provider && !client:
+		if q.ProviderId != "" && q.ProviderId != party {
+			return model.Agreements{}, 0, nil
+		}
+		q.ProviderId = party
+		return r.backend.GetAgreements(q)
+	case client && !provider:
+		if q.ClientId != "" && q.ClientId != party {
+			return model.Agreements{}, 0, nil
+		}
+		q.ClientId = party
+		return r.backend.GetAgreements(q)
+	}
+
+	/* provider or client: the query can't express it, so the page is built here */
+	page := q.Page
+	q.Page = model.Page{}
+	all, _, err := r.backend.GetAgreements(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := r.filterAgreements(all)
+	begin, end := page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+// GetAgreement gets an agreement by id, if visible to the caller.
+func (r repository) GetAgreement(id string) (*model.Agreement, error) {
+	a, err := r.backend.GetAgreement(id)
+	if err != nil {
+		return a, err
+	}
+	if !r.canSeeAgreement(a) {
+		return nil, model.ErrNotFound
+	}
+	return a, nil
+}
+
+// GetAgreementsByState returns the agreements visible to the caller that have one of the items in states.
+func (r repository) GetAgreementsByState(states ...model.State) (model.Agreements, error) {
+	all, err := r.backend.GetAgreementsByState(states...)
+	if err != nil || r.isAdmin() {
+		return all, err
+	}
+	return r.filterAgreements(all), nil
+}
+
+// CreateAgreement persists an agreement, if the caller is an admin or one of its parties.
+func (r repository) CreateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	if !r.canSeeAgreement(agreement) {
+		return nil, auth.ErrForbidden
+	}
+	return r.backend.CreateAgreement(agreement)
+}
+
+// UpdateAgreement updates an agreement, if the caller is an admin or one of its parties.
+func (r repository) UpdateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	if _, err := r.GetAgreement(agreement.Id); err != nil {
+		return nil, err
+	}
+	if !r.canSeeAgreement(agreement) {
+		return nil, auth.ErrForbidden
+	}
+	return r.backend.UpdateAgreement(agreement)
+}
+
+// DeleteAgreement deletes an agreement, if the caller is an admin.
+func (r repository) DeleteAgreement(agreement *model.Agreement) error {
+	if err := r.requireAdmin(); err != nil {
+		return err
+	}
+	return r.backend.DeleteAgreement(agreement)
+}
+
+// UpdateAgreementState changes the state of an agreement visible to the caller.
+// Only admins terminate agreements.
+func (r repository) UpdateAgreementState(id string, newState model.State) (*model.Agreement, error) {
+	if _, err := r.GetAgreement(id); err != nil {
+		return nil, err
+	}
+	if newState.Normalize() == model.TERMINATED && !r.isAdmin() {
+		return nil, auth.ErrForbidden
+	}
+	return r.backend.UpdateAgreementState(id, newState)
+}
+
+// GetAllTemplates gets the templates visible to the caller.
+func (r repository) GetAllTemplates() (model.Templates, error) {
+	all, err := r.backend.GetAllTemplates()
+	if err != nil || r.isAdmin() {
+		return all, err
+	}
+	result := make(model.Templates, 0, len(all))
+	for i := range all {
+		if r.canSeeTemplate(&all[i]) {
+			result = append(result, all[i])
+		}
+	}
+	return result, nil
+}
+
+// GetTemplates gets the templates visible to the caller that match the query.
+func (r repository) GetTemplates(q model.TemplateQuery) (model.Templates, int, error) {
+	if r.isAdmin() || r.principal.HasRole(auth.CLIENT) {
+		return r.backend.GetTemplates(q)
+	}
+	party := r.principal.PartyId
+	if !r.isProvider(party) || q.ProviderId != "" && q.ProviderId != party {
+		return model.Templates{}, 0, nil
+	}
+	q.ProviderId = party
+	return r.backend.GetTemplates(q)
+}
+
+// GetTemplate gets a template by id, if visible to the caller.
+func (r repository) GetTemplate(id string) (*model.Template, error) {
+	t, err := r.backend.GetTemplate(id)
+	if err != nil {
+		return t, err
+	}
+	if !r.canSeeTemplate(t) {
+		return nil, model.ErrNotFound
+	}
+	return t, nil
+}
+
+// CreateTemplate persists a template, if the caller is an admin.
+func (r repository) CreateTemplate(template *model.Template) (*model.Template, error) {
+	if err := r.requireAdmin(); err != nil {
+		return nil, err
+	}
+	return r.backend.CreateTemplate(template)
+}
+
+// UpdateTemplate updates a template, if the caller is an admin.
+func (r repository) UpdateTemplate(template *model.Template) (*model.Template, error) {
+	if err := r.requireAdmin(); err != nil {
+		return nil, err
+	}
+	return r.backend.UpdateTemplate(template)
+}
+
+// DeleteTemplate deletes a template, if the caller is an admin.
+func (r repository) DeleteTemplate(template *model.Template) error {
+	if err := r.requireAdmin(); err != nil {
+		return err
+	}
+	return r.backend.DeleteTemplate(template)
+}
+
+// GetTemplateVersions gets the revisions of a template, if its last revision is visible
+// to the caller (the template may have been deleted).
+func (r repository) GetTemplateVersions(id string) (model.Templates, error) {
+	versions, err := r.backend.GetTemplateVersions(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) > 0 && !r.canSeeTemplate(&versions[len(versions)-1]) {
+		return nil, model.ErrNotFound
+	}
+	return versions, nil
+}
+
+// UpdateTemplateState changes the state of a template, if the caller is an admin.
+func (r repository) UpdateTemplateState(id string, newState model.State) (*model.Template, error) {
+	if err := r.requireAdmin(); err != nil {
+		return nil, err
+	}
+	return r.backend.UpdateTemplateState(id, newState)
+}
+
+// CreateViolation persists a violation, if the caller is an admin.
+func (r repository) CreateViolation(v *model.Violation) (*model.Violation, error) {
+	if err := r.requireAdmin(); err != nil {
+		return nil, err
+	}
+	return r.backend.CreateViolation(v)
+}
+
+// GetViolation returns the Violation identified by id, if its agreement is visible to the caller.
+func (r repository) GetViolation(id string) (*model.Violation, error) {
+	v, err := r.backend.GetViolation(id)
+	if err != nil || r.isAdmin() {
+		return v, err
+	}
+	if _, err := r.GetAgreement(v.AgreementId); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// GetViolations returns the violations of the agreements visible to the caller that match the query.
+func (r repository) GetViolations(q model.ViolationQuery) (model.Violations, int, error) {
+	if r.isAdmin() {
+		return r.backend.GetViolations(q)
+	}
+	if q.AgreementId != "" {
+		if _, err := r.GetAgreement(q.AgreementId); err != nil {
+			return model.Violations{}, 0, nil
+		}
+		return r.backend.GetViolations(q)
+	}
+
+	ids, err := r.agreementIds()
+	if err != nil {
+		return nil, 0, err
+	}
+	page := q.Page
+	q.Page = model.Page{}
+	all, _, err := r.backend.GetViolations(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make(model.Violations, 0, len(all))
+	for _, v := range all {
+		if ids[v.AgreementId] {
+			result = append(result, v)
+		}
+	}
+	begin, end := page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+// CreateWarning persists a warning, if the caller is an admin.
+func (r repository) CreateWarning(w *model.Warning) (*model.Warning, error) {
+	if err := r.requireAdmin(); err != nil {
+		return nil, err
+	}
+	return r.backend.CreateWarning(w)
+}
+
+// GetWarning returns the Warning identified by id, if its agreement is visible to the caller.
+func (r repository) GetWarning(id string) (*model.Warning, error) {
+	w, err := r.backend.GetWarning(id)
+	if err != nil || r.isAdmin() {
+		return w, err
+	}
+	if _, err := r.GetAgreement(w.AgreementId); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// GetWarnings returns the warnings of the agreements visible to the caller that match the query.
+func (r repository) GetWarnings(q model.WarningQuery) (model.Warnings, int, error) {
+	if r.isAdmin() {
+		return r.backend.GetWarnings(q)
+	}
+	if q.AgreementId != "" {
+		if _, err := r.GetAgreement(q.AgreementId); err != nil {
+			return model.Warnings{}, 0, nil
+		}
+		return r.backend.GetWarnings(q)
+	}
+
+	ids, err := r.agreementIds()
+	if err != nil {
+		return nil, 0, err
+	}
+	page := q.Page
+	q.Page = model.Page{}
+	all, _, err := r.backend.GetWarnings(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make(model.Warnings, 0, len(all))
+	for _, w := range all {
+		if ids[w.AgreementId] {
+			result = append(result, w)
+		}
+	}
+	begin, end := page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+// CreatePenalty persists a penalty, if the caller is an admin.
+func (r repository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+	if err := r.requireAdmin(); err != nil {
+		return nil, err
+	}
+	return r.backend.CreatePenalty(p)
+}
+
+// GetPenalty returns the Penalty identified by id, if its agreement is visible to the caller.
+func (r repository) GetPenalty(id string) (*model.Penalty, error) {
+	p, err := r.backend.GetPenalty(id)
+	if err != nil || r.isAdmin() {
+		return p, err
+	}
+	if _, err := r.GetAgreement(p.AgreementId); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetPenalties returns the penalties of the agreements visible to the caller that match the query.
+func (r repository) GetPenalties(q model.PenaltyQuery) (model.Penalties, int, error) {
+	if r.isAdmin() {
+		return r.backend.GetPenalties(q)
+	}
+	if q.AgreementId != "" {
+		if _, err := r.GetAgreement(q.AgreementId); err != nil {
+			return model.Penalties{}, 0, nil
+		}
+		return r.backend.GetPenalties(q)
+	}
+
+	ids, err := r.agreementIds()
+	if err != nil {
+		return nil, 0, err
+	}
+	page := q.Page
+	q.Page = model.Page{}
+	all, _, err := r.backend.GetPenalties(q)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make(model.Penalties, 0, len(all))
+	for _, p := range all {
+		if ids[p.AgreementId] {
+			result = append(result, p)
+		}
+	}
+	begin, end := page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+// GetAllSubscriptions returns the list of subscriptions visible to the caller.
+func (r repository) GetAllSubscriptions() (model.Subscriptions, error) {
+	all, err := r.backend.GetAllSubscriptions()
+	if err != nil || r.isAdmin() {
+		return all, err
+	}
+	result := make(model.Subscriptions, 0, len(all))
+	for i := range all {
+		if r.canSeeSubscription(&all[i]) {
+			result = append(result, all[i])
+		}
+	}
+	return result, nil
+}
+
+// GetSubscription returns the Subscription identified by id, if visible to the caller.
+func (r repository) GetSubscription(id string) (*model.Subscription, error) {
+	s, err := r.backend.GetSubscription(id)
+	if err != nil {
+		return s, err
+	}
+	if !r.canSeeSubscription(s) {
+		return nil, model.ErrNotFound
+	}
+	return s, nil
+}
+
+// CreateSubscription persists a new Subscription, if the caller is an admin or the
+// subscription is filtered by the party of the caller.
+func (r repository) CreateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	if !r.canSeeSubscription(s) {
+		return nil, auth.ErrForbidden
+	}
+	return r.backend.CreateSubscription(s)
+}
+
+// UpdateSubscription updates a Subscription visible to the caller, if the caller is an
+// admin or the new subscription is filtered by the party of the caller.
+func (r repository) UpdateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	if _, err := r.GetSubscription(s.Id); err != nil {
+		return nil, err
+	}
+	if !r.canSeeSubscription(s) {
+		return nil, auth.ErrForbidden
+	}
+	return r.backend.UpdateSubscription(s)
+}
+
+// DeleteSubscription deletes a Subscription, if visible to the caller.
+func (r repository) DeleteSubscription(s *model.Subscription) error {
+	if _, err := r.GetSubscription(s.Id); err != nil {
+		return err
+	}
+	return r.backend.DeleteSubscription(s)
+}
+
+func (r repository) filterAgreements(all model.Agreements) model.Agreements {
+	result := make(model.Agreements, 0, len(all))
+	for i := range all {
+		if r.canSeeAgreement(&all[i]) {
+			result = append(result, all[i])
+		}
+	}
+	return result
+}
+
+// agreementIds returns the set of ids of the agreements visible to the caller
+func (r repository) agreementIds() (map[string]bool, error) {
+	agreements, err := r.GetAllAgreements()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool, len(agreements))
+	for _, a := range agreements {
+		result[a.Id] = true
+	}
+	return result, nil
+}
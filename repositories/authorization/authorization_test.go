@@ -0,0 +1,245 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package authorization
+
+import (
+	"SLALite/auth"
+	"SLALite/model"
+	"SLALite/repositories/memrepository"
+	"testing"
+	"time"
+)
+
+func agreement(id, provider, client string) *model.Agreement {
+	return &model.Agreement{
+		Id:    id,
+		Name:  id,
+		State: model.STOPPED,
+		Details: model.Details{
+			Id:       id,
+			Provider: model.Provider{Id: provider},
+			Client:   model.Client{Id: client},
+			Creation: time.Now(),
+		},
+	}
+}
+
+func template(id, provider string) *model.Template {
+	return &model.Template{
+		Id:      id,
+		Name:    id,
+		Details: model.Details{Id: id, Provider: model.Provider{Id: provider}},
+	}
+}
+
+func principal(role auth.Role, party string) *auth.Principal {
+	return &auth.Principal{Subject: party, Grant: auth.Grant{Roles: []auth.Role{role}, PartyId: party}}
+}
+
+func newBackend(t *testing.T) model.IRepository {
+	backend, _ := memrepository.New(nil)
+	for _, a := range []*model.Agreement{
+		agreement("a01", "p01", "c01"),
+		agreement("a02", "p01", "c02"),
+		agreement("a03", "p02", "c01"),
+	} {
+		if _, err := backend.CreateAgreement(a); err != nil {
+			t.Fatalf("Error creating agreement: %v", err)
+		}
+	}
+	for _, tpl := range []*model.Template{template("t01", "p01"), template("t02", "p02")} {
+		if _, err := backend.CreateTemplate(tpl); err != nil {
+			t.Fatalf("Error creating template: %v", err)
+		}
+	}
+	for _, id := range []string{"a01", "a02", "a03"} {
+		v := &model.Violation{Id: "v" + id[1:], AgreementId: id, Guarantee: "g", Datetime: time.Now()}
+		if _, err := backend.CreateViolation(v); err != nil {
+			t.Fatalf("Error creating violation: %v", err)
+		}
+	}
+	return backend
+}
+
+func ids(as model.Agreements) []string {
+	result := make([]string, 0, len(as))
+	for _, a := range as {
+		result = append(result, a.Id)
+	}
+	return result
+}
+
+func TestAgreements(t *testing.T) {
+	backend := newBackend(t)
+
+	cases := []struct {
+		name     string
+		p        *auth.Principal
+		expected int
+	}{
+		{"Admin", principal(auth.ADMIN, ""), 3},
+		{"Provider", principal(auth.PROVIDER, "p01"), 2},
+		{"Client", principal(auth.CLIENT, "c02"), 1},
+		{"ProviderAndClient", &auth.Principal{Grant: auth.Grant{
+			Roles: []auth.Role{auth.PROVIDER, auth.CLIENT}, PartyId: "p02"}}, 1},
+		{"NoRoles", &auth.Principal{Subject: "u01"}, 0},
+	}
+	for _, c := range cases {
+		r := New(backend, c.p)
+		all, err := r.GetAllAgreements()
+		if err != nil || len(all) != c.expected {
+			t.Errorf("%s: unexpected agreements: %v, %v", c.name, ids(all), err)
+		}
+		page, total, err := r.GetAgreements(model.AgreementQuery{Page: model.Page{Limit: 1}})
+		if err != nil || total != c.expected || len(page) > 1 {
+			t.Errorf("%s: unexpected page: %v, %d, %v", c.name, ids(page), total, err)
+		}
+	}
+
+	r := New(backend, principal(auth.CLIENT, "c01"))
+	if _, err := r.GetAgreement("a02"); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound. Actual: %v", err)
+	}
+	if _, err := r.GetAgreement("a03"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if list, _, _ := r.GetAgreements(model.AgreementQuery{ClientId: "c02"}); len(list) != 0 {
+		t.Errorf("Expected no agreements of other client. Actual: %v", ids(list))
+	}
+	if _, err := r.CreateAgreement(agreement("a04", "p01", "c02")); err != auth.ErrForbidden {
+		t.Errorf("Expected ErrForbidden. Actual: %v", err)
+	}
+	if _, err := r.CreateAgreement(agreement("a04", "p01", "c01")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := r.UpdateAgreementState("a01", model.STARTED); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := r.UpdateAgreementState("a01", model.TERMINATED); err != auth.ErrForbidden {
+		t.Errorf("Expected ErrForbidden. Actual: %v", err)
+	}
+	if _, err := r.UpdateAgreementState("a02", model.STARTED); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound. Actual: %v", err)
+	}
+	if err := r.DeleteAgreement(&model.Agreement{Id: "a01"}); err != auth.ErrForbidden {
+		t.Errorf("Expected ErrForbidden. Actual: %v", err)
+	}
+
+	admin := New(backend, principal(auth.ADMIN, ""))
+	if _, err := admin.UpdateAgreementState("a01", model.TERMINATED); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	backend := newBackend(t)
+
+	provider := New(backend, principal(auth.PROVIDER, "p01"))
+	if list, total, err := provider.GetTemplates(model.TemplateQuery{}); err != nil || total != 1 || list[0].Id != "t01" {
+		t.Errorf("Unexpected templates: %v, %d, %v", list, total, err)
+	}
+	if _, err := provider.GetTemplate("t02"); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound. Actual: %v", err)
+	}
+	if _, err := provider.GetTemplateVersions("t02"); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound. Actual: %v", err)
+	}
+	if list, err := provider.GetTemplateVersions("t01"); err != nil || len(list) != 1 {
+		t.Errorf("Unexpected versions: %v, %v", list, err)
+	}
+	if _, err := provider.CreateTemplate(template("t03", "p01")); err != auth.ErrForbidden {
+		t.Errorf("Expected ErrForbidden. Actual: %v", err)
+	}
+	if _, err := provider.UpdateTemplate(template("t01", "p01")); err != auth.ErrForbidden {
+		t.Errorf("Expected ErrForbidden. Actual: %v", err)
+	}
+	if err := provider.DeleteTemplate(template("t01", "p01")); err != auth.ErrForbidden {
+		t.Errorf("Expected ErrForbidden. Actual: %v", err)
+	}
+
+	client := New(backend, principal(auth.CLIENT, "c01"))
+	if list, err := client.GetAllTemplates(); err != nil || len(list) != 2 {
+		t.Errorf("Unexpected templates: %v, %v", list, err)
+	}
+
+	admin := New(backend, principal(auth.ADMIN, ""))
+	if _, err := admin.CreateTemplate(template("t03", "p01")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestViolations(t *testing.T) {
+	backend := newBackend(t)
+
+	r := New(backend, principal(auth.PROVIDER, "p02"))
+	list, total, err := r.GetViolations(model.ViolationQuery{})
+	if err != nil || total != 1 || list[0].AgreementId != "a03" {
+		t.Errorf("Unexpected violations: %v, %d, %v", list, total, err)
+	}
+	if list, _, _ := r.GetViolations(model.ViolationQuery{AgreementId: "a01"}); len(list) != 0 {
+		t.Errorf("Expected no violations of other provider. Actual: %v", list)
+	}
+	if _, err := r.GetViolation("v01"); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound. Actual: %v", err)
+	}
+}
+
+func TestSubscriptions(t *testing.T) {
+	backend := newBackend(t)
+	for _, s := range []*model.Subscription{
+		{Id: "s01", Url: "http://localhost/s01", ProviderId: "p01"},
+		{Id: "s02", Url: "http://localhost/s02", ClientId: "c01"},
+		{Id: "s03", Url: "http://localhost/s03"},
+	} {
+		if _, err := backend.CreateSubscription(s); err != nil {
+			t.Fatalf("Error creating subscription: %v", err)
+		}
+	}
+
+	provider := New(backend, principal(auth.PROVIDER, "p01"))
+	if list, err := provider.GetAllSubscriptions(); err != nil || len(list) != 1 || list[0].Id != "s01" {
+		t.Errorf("Unexpected subscriptions: %v, %v", list, err)
+	}
+	if _, err := provider.GetSubscription("s02"); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound. Actual: %v", err)
+	}
+	if _, err := provider.CreateSubscription(&model.Subscription{Id: "s04", ProviderId: "p02"}); err != auth.ErrForbidden {
+		t.Errorf("Expected ErrForbidden. Actual: %v", err)
+	}
+	if _, err := provider.CreateSubscription(&model.Subscription{Id: "s04", ProviderId: "p01"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := provider.UpdateSubscription(&model.Subscription{Id: "s01"}); err != auth.ErrForbidden {
+		t.Errorf("Expected ErrForbidden. Actual: %v", err)
+	}
+	if err := provider.DeleteSubscription(&model.Subscription{Id: "s03"}); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound. Actual: %v", err)
+	}
+
+	client := New(backend, principal(auth.CLIENT, "c01"))
+	if list, err := client.GetAllSubscriptions(); err != nil || len(list) != 1 || list[0].Id != "s02" {
+		t.Errorf("Unexpected subscriptions: %v, %v", list, err)
+	}
+	if err := client.DeleteSubscription(&model.Subscription{Id: "s02"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	admin := New(backend, principal(auth.ADMIN, ""))
+	if list, err := admin.GetAllSubscriptions(); err != nil || len(list) != 3 {
+		t.Errorf("Unexpected subscriptions: %v, %v", list, err)
+	}
+}
@@ -13,11 +13,26 @@ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
 See the License for the specific language governing permissions and
 limitations under the License.
 */
+
+/*
+Package bolt is an implementation of a model.IRepository backed up by a bbolt
+single file database, intended for devices where a database server is not available.
+
+Each entity is stored JSON encoded in the bucket of its type, using the entity id as key.
+The revisions of a template are stored in a nested bucket of the template versions bucket,
+using the big endian encoded version as key.
+
+Tenants are not supported (see model.TenantRepository): all the entities belong to
+the default tenant.
+*/
 package bolt
 
 import (
 	"SLALite/model"
-	"errors"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
 
 	log "github.com/sirupsen/logrus"
 
@@ -26,24 +41,54 @@ import (
 )
 
 const (
-	providerBucket string = "Providers"
-	bboltDatabase  string = "slalite.db"
+	// Name is the unique identifier of this repository
+	Name string = "bolt"
+
+	providerBucket  string = "Providers"
+	agreementBucket string = "Agreements"
+	templateBucket  string = "Templates"
+	violationBucket string = "Violations"
+	warningBucket   string = "Warnings"
+	penaltyBucket   string = "Penalties"
+
+	subscriptionBucket    string = "Subscriptions"
+	templateVersionBucket string = "TemplateVersions"
+
+	defaultDatabase string = "slalite.db"
+	defaultTimeout  string = "1s"
 
 	bboltConfigName = "bbolt.yml"
 
 	databasePropertyName = "database"
+	timeoutPropertyName  = "timeout"
+	clearOnBoot          = "clear_on_boot"
 )
 
+var buckets = []string{
+	providerBucket,
+	agreementBucket,
+	templateBucket,
+	violationBucket,
+	warningBucket,
+	penaltyBucket,
+	subscriptionBucket,
+	templateVersionBucket,
+}
+
+// BBoltRepository contains the repository persistence implementation based on bbolt
 type BBoltRepository struct {
-	dbFile string
+	db *bolt.DB
 }
 
-func New() (BBoltRepository, error) {
+// NewDefaultConfig gets a default configuration for a BBoltRepository
+func NewDefaultConfig() (*viper.Viper, error) {
 	config := viper.New()
 
+	config.SetEnvPrefix("sla") // Env vars start with 'SLA_'
+	config.AutomaticEnv()
 	config.SetConfigName(bboltConfigName)
 	config.AddConfigPath(model.UnixConfigPath)
-	config.SetDefault(databasePropertyName, bboltDatabase)
+	setDefaults(config)
 
 	confError := config.ReadInConfig()
 	if confError != nil {
@@ -51,132 +96,847 @@ func New() (BBoltRepository, error) {
 		log.Println("Using defaults")
 	}
 
-	dbName := config.GetString(databasePropertyName)
-	repo := BBoltRepository{dbName}
+	return config, confError
+}
+
+func setDefaults(config *viper.Viper) {
+	config.SetDefault(databasePropertyName, defaultDatabase)
+	config.SetDefault(timeoutPropertyName, defaultTimeout)
+	config.SetDefault(clearOnBoot, false)
+}
+
+// New creates a new instance of the BBoltRepository with the database configuration read from a configuration file.
+//
+// The database file is kept open until Close is called.
+func New(config *viper.Viper) (BBoltRepository, error) {
+	if config == nil {
+		config, _ = NewDefaultConfig()
+	} else {
+		setDefaults(config)
+	}
 
-	err := repo.SetDatabase(dbName)
+	logConfig(config)
 
+	var repo BBoltRepository
+	options := &bolt.Options{Timeout: config.GetDuration(timeoutPropertyName)}
+	db, err := bolt.Open(config.GetString(databasePropertyName), 0600, options)
+	if err != nil {
+		return repo, err
+	}
+	repo.db = db
+
+	if config.GetBool(clearOnBoot) {
+		err = repo.clear()
+	}
+	if err == nil {
+		err = repo.createBuckets()
+	}
+	if err != nil {
+		db.Close()
+	}
 	return repo, err
 }
 
-func (r *BBoltRepository) SetDatabase(database string) error {
-	r.dbFile = database
-	return r.ExecuteTx(nil, func(db *bolt.DB) error {
-		return db.Update(func(tx *bolt.Tx) error {
-			_, err2 := tx.CreateBucketIfNotExists([]byte(providerBucket))
-			return err2
-		})
+func logConfig(config *viper.Viper) {
+	log.Printf("BBolt configuration\n"+
+		"\tdatabase: %v\n"+
+		"\ttimeout: %v\n"+
+		"\tclear on boot: %v\n",
+		config.GetString(databasePropertyName),
+		config.GetDuration(timeoutPropertyName),
+		config.GetBool(clearOnBoot))
+}
+
+// Check implements health.Checker, returning an error if the database file is not open
+func (r BBoltRepository) Check() error {
+	return r.db.View(func(tx *bolt.Tx) error {
+		return nil
 	})
 }
 
-func (r BBoltRepository) ExecuteTx(options *bolt.Options, f func(db *bolt.DB) error) error {
-	db, err := bolt.Open(r.dbFile, 0666, options)
-	if err != nil {
-		return err
-	}
-	defer db.Close()
-	return f(db)
+// Close releases the database file
+func (r BBoltRepository) Close() error {
+	return r.db.Close()
 }
 
-func (r BBoltRepository) ExecuteOperation(ft func(fn func(tx *bolt.Tx) error) error, fb func(b *bolt.Bucket) error) error {
-	return ft(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(providerBucket))
-		if b != nil {
-			return fb(b)
-		} else {
-			return errors.New("Error getting providers bucket")
+func (r BBoltRepository) createBuckets() error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
 		}
+		return nil
 	})
 }
 
-func (r BBoltRepository) ExecuteReadOperation(f func(b *bolt.Bucket) error) error {
-	return r.ExecuteTx(&bolt.Options{ReadOnly: true}, func(db *bolt.DB) error {
-		return r.ExecuteOperation(db.View, f)
+func (r BBoltRepository) clear() error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			err := tx.DeleteBucket([]byte(name))
+			if err != nil && err != bolt.ErrBucketNotFound {
+				return err
+			}
+		}
+		return nil
 	})
 }
 
-func (r BBoltRepository) ExecuteWriteOperation(f func(b *bolt.Bucket) error) error {
-	return r.ExecuteTx(nil, func(db *bolt.DB) error {
-		return r.ExecuteOperation(db.Update, f)
-	})
+func bucket(tx *bolt.Tx, name string) (*bolt.Bucket, error) {
+	b := tx.Bucket([]byte(name))
+	if b == nil {
+		return nil, fmt.Errorf("Error getting %s bucket", name)
+	}
+	return b, nil
 }
 
-func (r BBoltRepository) GetAllProviders() (model.Providers, error) {
+func (r BBoltRepository) view(name string, f func(b *bolt.Bucket) error) error {
+	return r.db.View(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, name)
+		if err != nil {
+			return err
+		}
+		return f(b)
+	})
+}
 
-	var providers model.Providers
+func (r BBoltRepository) update(name string, f func(b *bolt.Bucket) error) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, name)
+		if err != nil {
+			return err
+		}
+		return f(b)
+	})
+}
 
-	err := r.ExecuteReadOperation(func(b *bolt.Bucket) error {
-		b.ForEach(func(k, v []byte) error {
-			providers = append(providers, model.Provider{string(k), string(v)})
+// forEach decodes every item in a bucket, calling f with a new item obtained from newItem
+func (r BBoltRepository) forEach(name string, newItem func() interface{}, f func(item interface{})) error {
+	return r.view(name, func(b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			item := newItem()
+			if err := json.Unmarshal(v, item); err != nil {
+				return err
+			}
+			f(item)
 			return nil
 		})
-
-		return nil
 	})
-	return providers, err
 }
 
-func (r BBoltRepository) GetProvider(id string) (*model.Provider, error) {
-	var provider *model.Provider = nil
-	err := r.ExecuteReadOperation(func(b *bolt.Bucket) error {
+func (r BBoltRepository) get(name string, id string, result model.Identity) (model.Identity, error) {
+	err := r.view(name, func(b *bolt.Bucket) error {
 		value := b.Get([]byte(id))
-		if value != nil {
-			provider = &model.Provider{id, string(value)}
-		} else {
+		if value == nil {
 			return model.ErrNotFound
 		}
-		return nil
+		return json.Unmarshal(value, result)
 	})
-	return provider, err
+	return result, err
 }
 
-func (r BBoltRepository) CreateProvider(provider *model.Provider) (*model.Provider, error) {
-	//log.Info("Trying to create provider " + provider.Id)
-	return provider, r.ExecuteWriteOperation(func(b *bolt.Bucket) error {
-		existing := b.Get([]byte(provider.Id))
-		if existing != nil {
+func (r BBoltRepository) create(name string, object model.Identity) (model.Identity, error) {
+	err := r.update(name, func(b *bolt.Bucket) error {
+		key := []byte(object.GetId())
+		if b.Get(key) != nil {
 			return model.ErrAlreadyExist
-		} else {
-			return b.Put([]byte(provider.Id), []byte(provider.Name))
 		}
+		return put(b, key, object)
 	})
+	return object, err
 }
 
-func (r BBoltRepository) DeleteProvider(provider *model.Provider) error {
-	return r.ExecuteWriteOperation(func(b *bolt.Bucket) error {
-		existing := b.Get([]byte(provider.Id))
-		if existing == nil {
+func (r BBoltRepository) replace(name string, object model.Identity) (model.Identity, error) {
+	err := r.update(name, func(b *bolt.Bucket) error {
+		key := []byte(object.GetId())
+		if b.Get(key) == nil {
+			return model.ErrNotFound
+		}
+		return put(b, key, object)
+	})
+	return object, err
+}
+
+func (r BBoltRepository) delete(name string, id string) error {
+	return r.update(name, func(b *bolt.Bucket) error {
+		key := []byte(id)
+		if b.Get(key) == nil {
 			return model.ErrNotFound
-		} else {
-			return b.Delete([]byte(provider.Id))
 		}
+		return b.Delete(key)
 	})
 }
 
+func put(b *bolt.Bucket, key []byte, object interface{}) error {
+	value, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, value)
+}
+
+/*
+GetAllProviders returns the list of providers.
+
+The list is empty when there are no providers;
+error != nil on error
+*/
+func (r BBoltRepository) GetAllProviders() (model.Providers, error) {
+	result := make(model.Providers, 0)
+
+	err := r.forEach(providerBucket,
+		func() interface{} { return new(model.Provider) },
+		func(item interface{}) {
+			result = append(result, *item.(*model.Provider))
+		})
+	return result, err
+}
+
+/*
+GetProviders returns the page of providers specified in the query, and the total
+number of providers.
+
+The list is empty when there are no providers in the page;
+error != nil on error
+*/
+func (r BBoltRepository) GetProviders(q model.ProviderQuery) (model.Providers, int, error) {
+	result, err := r.GetAllProviders()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+/*
+GetProvider returns the Provider identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the provider is not found
+*/
+func (r BBoltRepository) GetProvider(id string) (*model.Provider, error) {
+	res, err := r.get(providerBucket, id, new(model.Provider))
+	return res.(*model.Provider), err
+}
+
+/*
+CreateProvider stores a new provider.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the provider already exists
+*/
+func (r BBoltRepository) CreateProvider(provider *model.Provider) (*model.Provider, error) {
+	res, err := r.create(providerBucket, provider)
+	return res.(*model.Provider), err
+}
+
+/*
+DeleteProvider deletes from the repository the provider whose id is provider.Id.
+
+error != nil on error;
+error is model.ErrNotFound if the provider does not exist.
+*/
+func (r BBoltRepository) DeleteProvider(provider *model.Provider) error {
+	return r.delete(providerBucket, provider.Id)
+}
+
+// getAgreements returns the agreements that fulfill the filter
+func (r BBoltRepository) getAgreements(filter func(a *model.Agreement) bool) (model.Agreements, error) {
+	result := make(model.Agreements, 0)
+
+	err := r.forEach(agreementBucket,
+		func() interface{} { return new(model.Agreement) },
+		func(item interface{}) {
+			if a := item.(*model.Agreement); filter(a) {
+				result = append(result, *a)
+			}
+		})
+	return result, err
+}
+
+/*
+GetAllAgreements returns the list of agreements.
+
+The list is empty when there are no agreements;
+error != nil on error
+*/
 func (r BBoltRepository) GetAllAgreements() (model.Agreements, error) {
-	return nil, nil
+	return r.getAgreements(func(a *model.Agreement) bool {
+		return true
+	})
 }
 
-func (r BBoltRepository) GetActiveAgreements() (model.Agreements, error) {
-	return nil, nil
+/*
+GetAgreements returns the page of agreements that match the query, and the total
+number of matching agreements.
+
+The list is empty when there are no matching agreements in the page;
+error != nil on error
+*/
+func (r BBoltRepository) GetAgreements(q model.AgreementQuery) (model.Agreements, int, error) {
+	result, err := r.getAgreements(q.Match)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
 }
 
+/*
+GetAgreementsByState returns the agreements that have one of the items in states.
+
+error != nil on error;
+*/
+func (r BBoltRepository) GetAgreementsByState(states ...model.State) (model.Agreements, error) {
+	return r.getAgreements(func(a *model.Agreement) bool {
+		for _, state := range states {
+			if a.State == state {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+/*
+GetAgreement returns the Agreement identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Agreement is not found
+*/
 func (r BBoltRepository) GetAgreement(id string) (*model.Agreement, error) {
-	return nil, nil
+	res, err := r.get(agreementBucket, id, new(model.Agreement))
+	return res.(*model.Agreement), err
 }
 
+/*
+CreateAgreement stores a new Agreement.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Agreement already exists
+*/
 func (r BBoltRepository) CreateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
-	return nil, nil
+	res, err := r.create(agreementBucket, agreement)
+	return res.(*model.Agreement), err
+}
+
+/*
+UpdateAgreement updates the information of an already saved instance of an agreement
+*/
+func (r BBoltRepository) UpdateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	res, err := r.replace(agreementBucket, agreement)
+	return res.(*model.Agreement), err
+}
+
+/*
+UpdateAgreementState transits the state of the agreement
+*/
+func (r BBoltRepository) UpdateAgreementState(id string, newState model.State) (*model.Agreement, error) {
+	var result *model.Agreement
+
+	err := r.update(agreementBucket, func(b *bolt.Bucket) error {
+		key := []byte(id)
+		value := b.Get(key)
+		if value == nil {
+			return model.ErrNotFound
+		}
+		agreement := new(model.Agreement)
+		if err := json.Unmarshal(value, agreement); err != nil {
+			return err
+		}
+		agreement.State = newState
+		result = agreement
+		return put(b, key, agreement)
+	})
+	return result, err
 }
 
+/*
+DeleteAgreement deletes from the repository the Agreement whose id is agreement.Id.
+
+error != nil on error;
+error is model.ErrNotFound if the Agreement does not exist.
+*/
 func (r BBoltRepository) DeleteAgreement(agreement *model.Agreement) error {
-	return nil
+	return r.delete(agreementBucket, agreement.Id)
 }
 
-func (r BBoltRepository) StartAgreement(id string) error {
-	return nil
+/*
+CreateViolation stores a new Violation.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Violation already exists
+*/
+func (r BBoltRepository) CreateViolation(v *model.Violation) (*model.Violation, error) {
+	res, err := r.create(violationBucket, v)
+	return res.(*model.Violation), err
+}
+
+/*
+GetViolation returns the Violation identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Violation is not found
+*/
+func (r BBoltRepository) GetViolation(id string) (*model.Violation, error) {
+	res, err := r.get(violationBucket, id, new(model.Violation))
+	return res.(*model.Violation), err
 }
 
-func (r BBoltRepository) StopAgreement(id string) error {
-	return nil
+/*
+GetViolations returns the page of violations that match the query, sorted by datetime,
+and the total number of matching violations.
+
+The list is empty when there are no matching violations in the page;
+error != nil on error
+*/
+func (r BBoltRepository) GetViolations(q model.ViolationQuery) (model.Violations, int, error) {
+	result := make(model.Violations, 0)
+
+	err := r.forEach(violationBucket,
+		func() interface{} { return new(model.Violation) },
+		func(item interface{}) {
+			if v := item.(*model.Violation); q.Match(v) {
+				result = append(result, *v)
+			}
+		})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+/*
+CreateWarning stores a new Warning.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Warning already exists
+*/
+func (r BBoltRepository) CreateWarning(w *model.Warning) (*model.Warning, error) {
+	res, err := r.create(warningBucket, w)
+	return res.(*model.Warning), err
+}
+
+/*
+GetWarning returns the Warning identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Warning is not found
+*/
+func (r BBoltRepository) GetWarning(id string) (*model.Warning, error) {
+	res, err := r.get(warningBucket, id, new(model.Warning))
+	return res.(*model.Warning), err
+}
+
+/*
+GetWarnings returns the page of warnings that match the query, sorted by datetime,
+and the total number of matching warnings.
+
+The list is empty when there are no matching warnings in the page;
+error != nil on error
+*/
+func (r BBoltRepository) GetWarnings(q model.WarningQuery) (model.Warnings, int, error) {
+	result := make(model.Warnings, 0)
+
+	err := r.forEach(warningBucket,
+		func() interface{} { return new(model.Warning) },
+		func(item interface{}) {
+			if w := item.(*model.Warning); q.Match(w) {
+				result = append(result, *w)
+			}
+		})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+/*
+CreatePenalty stores a new Penalty.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Penalty already exists
+*/
+func (r BBoltRepository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+	res, err := r.create(penaltyBucket, p)
+	return res.(*model.Penalty), err
+}
+
+/*
+GetPenalty returns the Penalty identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Penalty is not found
+*/
+func (r BBoltRepository) GetPenalty(id string) (*model.Penalty, error) {
+	res, err := r.get(penaltyBucket, id, new(model.Penalty))
+	return res.(*model.Penalty), err
+}
+
+/*
+GetPenalties returns the page of penalties that match the query, sorted by datetime,
+and the total number of matching penalties.
+
+The list is empty when there are no matching penalties in the page;
+error != nil on error
+*/
+func (r BBoltRepository) GetPenalties(q model.PenaltyQuery) (model.Penalties, int, error) {
+	result := make(model.Penalties, 0)
+
+	err := r.forEach(penaltyBucket,
+		func() interface{} { return new(model.Penalty) },
+		func(item interface{}) {
+			if p := item.(*model.Penalty); q.Match(p) {
+				result = append(result, *p)
+			}
+		})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+/*
+GetAllTemplates returns the list of templates.
+
+The list is empty when there are no templates;
+error != nil on error
+*/
+func (r BBoltRepository) GetAllTemplates() (model.Templates, error) {
+	result := make(model.Templates, 0)
+
+	err := r.forEach(templateBucket,
+		func() interface{} { return new(model.Template) },
+		func(item interface{}) {
+			result = append(result, *item.(*model.Template))
+		})
+	return result, err
+}
+
+/*
+GetTemplates returns the page of templates that match the query, and the total
+number of matching templates.
+
+The list is empty when there are no matching templates in the page;
+error != nil on error
+*/
+func (r BBoltRepository) GetTemplates(q model.TemplateQuery) (model.Templates, int, error) {
+	result := make(model.Templates, 0)
+
+	err := r.forEach(templateBucket,
+		func() interface{} { return new(model.Template) },
+		func(item interface{}) {
+			if t := item.(*model.Template); q.Match(t) {
+				result = append(result, *t)
+			}
+		})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+/*
+GetTemplate returns the Template identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Template is not found
+*/
+func (r BBoltRepository) GetTemplate(id string) (*model.Template, error) {
+	res, err := r.get(templateBucket, id, new(model.Template))
+	return res.(*model.Template), err
+}
+
+/*
+CreateTemplate stores a new Template, with version 1, or the next version of the
+revisions of a deleted Template with the same id.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Template already exists
+*/
+func (r BBoltRepository) CreateTemplate(template *model.Template) (*model.Template, error) {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, templateBucket)
+		if err != nil {
+			return err
+		}
+		key := []byte(template.Id)
+		if b.Get(key) != nil {
+			return model.ErrAlreadyExist
+		}
+		version, err := lastTemplateVersion(tx, template.Id)
+		if err != nil {
+			return err
+		}
+		template.Version = version + 1
+		if err := put(b, key, template); err != nil {
+			return err
+		}
+		return putTemplateVersion(tx, template)
+	})
+	return template, err
+}
+
+/*
+UpdateTemplate updates the information of an already saved instance of a template,
+storing it as a new revision with the next version.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist
+*/
+func (r BBoltRepository) UpdateTemplate(template *model.Template) (*model.Template, error) {
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, templateBucket)
+		if err != nil {
+			return err
+		}
+		key := []byte(template.Id)
+		value := b.Get(key)
+		if value == nil {
+			return model.ErrNotFound
+		}
+		var current model.Template
+		if err := json.Unmarshal(value, &current); err != nil {
+			return err
+		}
+		template.Version = current.Version + 1
+		if err := put(b, key, template); err != nil {
+			return err
+		}
+		return putTemplateVersion(tx, template)
+	})
+	return template, err
+}
+
+/*
+DeleteTemplate deletes from the repository the Template whose id is template.Id.
+Its revisions are kept.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist.
+*/
+func (r BBoltRepository) DeleteTemplate(template *model.Template) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, templateBucket)
+		if err != nil {
+			return err
+		}
+		key := []byte(template.Id)
+		value := b.Get(key)
+		if value == nil {
+			return model.ErrNotFound
+		}
+		versions, err := bucket(tx, templateVersionBucket)
+		if err != nil {
+			return err
+		}
+		if versions.Bucket(key) == nil {
+			/* the template was stored before templates were versioned: keep it as revision */
+			var t model.Template
+			if err := json.Unmarshal(value, &t); err != nil {
+				return err
+			}
+			if t.Version == 0 {
+				t.Version = 1
+			}
+			if err := putTemplateVersion(tx, &t); err != nil {
+				return err
+			}
+		}
+		return b.Delete(key)
+	})
+}
+
+/*
+GetTemplateVersions returns the revisions of the Template identified by id,
+sorted by version, even if the Template has been deleted.
+
+error != nil on error;
+error is model.ErrNotFound if the Template never existed
+*/
+func (r BBoltRepository) GetTemplateVersions(id string) (model.Templates, error) {
+	result := make(model.Templates, 0)
+
+	err := r.db.View(func(tx *bolt.Tx) error {
+		b, err := bucket(tx, templateBucket)
+		if err != nil {
+			return err
+		}
+		key := []byte(id)
+		versions, err := bucket(tx, templateVersionBucket)
+		if err != nil {
+			return err
+		}
+		vb := versions.Bucket(key)
+		if vb == nil {
+			value := b.Get(key)
+			if value == nil {
+				return model.ErrNotFound
+			}
+			/* the template was stored before templates were versioned */
+			var t model.Template
+			err := json.Unmarshal(value, &t)
+			result = append(result, t)
+			return err
+		}
+		return vb.ForEach(func(k, v []byte) error {
+			var t model.Template
+			if err := json.Unmarshal(v, &t); err != nil {
+				return err
+			}
+			result = append(result, t)
+			return nil
+		})
+	})
+	return result, err
+}
+
+/*
+UpdateTemplateState changes the state of a Template, without storing a new revision.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist
+*/
+func (r BBoltRepository) UpdateTemplateState(id string, newState model.State) (*model.Template, error) {
+	var result *model.Template
+
+	err := r.update(templateBucket, func(b *bolt.Bucket) error {
+		key := []byte(id)
+		value := b.Get(key)
+		if value == nil {
+			return model.ErrNotFound
+		}
+		template := new(model.Template)
+		if err := json.Unmarshal(value, template); err != nil {
+			return err
+		}
+		template.State = newState
+		result = template
+		return put(b, key, template)
+	})
+	return result, err
+}
+
+// lastTemplateVersion returns the last version of the revisions of a template,
+// or 0 if there are none
+func lastTemplateVersion(tx *bolt.Tx, id string) (int, error) {
+	versions, err := bucket(tx, templateVersionBucket)
+	if err != nil {
+		return 0, err
+	}
+	vb := versions.Bucket([]byte(id))
+	if vb == nil {
+		return 0, nil
+	}
+	k, _ := vb.Cursor().Last()
+	if k == nil {
+		return 0, nil
+	}
+	return int(binary.BigEndian.Uint64(k)), nil
+}
+
+// putTemplateVersion stores the revision t of a template, which must not exist
+func putTemplateVersion(tx *bolt.Tx, t *model.Template) error {
+	versions, err := bucket(tx, templateVersionBucket)
+	if err != nil {
+		return err
+	}
+	vb, err := versions.CreateBucketIfNotExists([]byte(t.Id))
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.Version))
+	if vb.Get(key) != nil {
+		return fmt.Errorf("Version %d of template %s already exists", t.Version, t.Id)
+	}
+	return put(vb, key, t)
+}
+
+/*
+GetAllSubscriptions returns the list of subscriptions.
+
+The list is empty when there are no subscriptions;
+error != nil on error
+*/
+func (r BBoltRepository) GetAllSubscriptions() (model.Subscriptions, error) {
+	result := make(model.Subscriptions, 0)
+
+	err := r.forEach(subscriptionBucket,
+		func() interface{} { return new(model.Subscription) },
+		func(item interface{}) {
+			result = append(result, *item.(*model.Subscription))
+		})
+	return result, err
+}
+
+/*
+GetSubscription returns the Subscription identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Subscription is not found
+*/
+func (r BBoltRepository) GetSubscription(id string) (*model.Subscription, error) {
+	res, err := r.get(subscriptionBucket, id, new(model.Subscription))
+	return res.(*model.Subscription), err
+}
+
+/*
+CreateSubscription stores a new Subscription.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Subscription already exists
+*/
+func (r BBoltRepository) CreateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	res, err := r.create(subscriptionBucket, s)
+	return res.(*model.Subscription), err
+}
+
+/*
+UpdateSubscription updates an already saved Subscription.
+
+error != nil on error;
+error is model.ErrNotFound if the Subscription does not exist
+*/
+func (r BBoltRepository) UpdateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	res, err := r.replace(subscriptionBucket, s)
+	return res.(*model.Subscription), err
+}
+
+/*
+DeleteSubscription deletes from the repository the Subscription whose id is s.Id.
+
+error != nil on error;
+error is model.ErrNotFound if the Subscription does not exist.
+*/
+func (r BBoltRepository) DeleteSubscription(s *model.Subscription) error {
+	return r.delete(subscriptionBucket, s.Id)
 }
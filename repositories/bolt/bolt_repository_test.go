@@ -0,0 +1,174 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+This tests the bolt repository, making use of the repository_testbase file.
+The database is created in a temporary directory.
+*/
+
+package bolt
+
+import (
+	"SLALite/model"
+	"SLALite/repositories"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var repo model.IRepository
+
+func TestMain(m *testing.M) {
+	result := -1
+
+	dir, err := ioutil.TempDir("", "slalite-bolt")
+	if err != nil {
+		log.Fatal("Error creating temporary directory: ", err.Error())
+	}
+
+	boltRepo, err := createRepository(filepath.Join(dir, "test.db"))
+	if err == nil {
+		repo = boltRepo
+		result = m.Run()
+		boltRepo.Close()
+	} else {
+		log.Error("Error creating repository: ", err.Error())
+	}
+
+	os.RemoveAll(dir)
+	os.Exit(result)
+}
+
+func createRepository(path string) (BBoltRepository, error) {
+	config := viper.New()
+	config.Set("database", path)
+	config.Set("clear_on_boot", true)
+	return New(config)
+}
+
+func TestReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slalite-bolt")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reopen.db")
+
+	r, err := createRepository(path)
+	if err != nil {
+		t.Fatalf("Error creating repository: %v", err)
+	}
+	a := model.Agreement{Id: "a01", Name: "Agreement01", State: model.STARTED}
+	if _, err := r.CreateAgreement(&a); err != nil {
+		t.Fatalf("Error creating agreement: %v", err)
+	}
+	r.Close()
+
+	config := viper.New()
+	config.Set("database", path)
+	r, err = New(config)
+	if err != nil {
+		t.Fatalf("Error reopening repository: %v", err)
+	}
+	defer r.Close()
+
+	stored, err := r.GetAgreement(a.Id)
+	if err != nil {
+		t.Fatalf("Error reading agreement: %v", err)
+	}
+	if stored.Name != a.Name || stored.State != a.State {
+		t.Errorf("Expected: %v; Actual: %v", a, *stored)
+	}
+}
+
+func TestRepository(t *testing.T) {
+	ctx := repositories.TestContext{Repo: repo}
+	/* Providers */
+	t.Run("CreateProvider", ctx.TestCreateProvider)
+	t.Run("CreateProviderExists", ctx.TestCreateProviderExists)
+	t.Run("GetAllProviders", ctx.TestGetAllProviders)
+	t.Run("GetProviders", ctx.TestGetProviders)
+	t.Run("GetProvider", ctx.TestGetProvider)
+	t.Run("GetProviderNotExists", ctx.TestGetProviderNotExists)
+	t.Run("DeleteProvider", ctx.TestDeleteProvider)
+	t.Run("DeleteProviderNotExists", ctx.TestDeleteProviderNotExists)
+
+	/* Agreements */
+	t.Run("CreateAgreement", ctx.TestCreateAgreement)
+	t.Run("CreateAgreementExists", ctx.TestCreateAgreementExists)
+	t.Run("GetAllAgreements", ctx.TestGetAllAgreements)
+	t.Run("GetAgreement", ctx.TestGetAgreement)
+	t.Run("GetAgreementNotExists", ctx.TestGetAgreementNotExists)
+	t.Run("UpdateAgreementState", ctx.TestUpdateAgreementState)
+	t.Run("UpdateAgreementStateNotExists", ctx.TestUpdateAgreementStateNotExists)
+	t.Run("GetAgreementsByState", ctx.TestGetAgreementsByState)
+	t.Run("GetAgreements", ctx.TestGetAgreements)
+	t.Run("UpdateAgreement", ctx.TestUpdateAgreement)
+	t.Run("UpdateAgreementNotExists", ctx.TestUpdateAgreementNotExists)
+	t.Run("DeleteAgreement", ctx.TestDeleteAgreement)
+	t.Run("DeleteAgreementNotExists", ctx.TestDeleteAgreementNotExists)
+
+	/* Violations */
+	t.Run("CreateViolation", ctx.TestCreateViolation)
+	t.Run("CreateViolationExists", ctx.TestCreateViolationExists)
+
+	t.Run("GetViolation", ctx.TestGetViolation)
+	t.Run("GetViolationNotExists", ctx.TestGetViolationNotExists)
+	t.Run("GetViolations", ctx.TestGetViolations)
+
+	/* Warnings */
+	t.Run("CreateWarning", ctx.TestCreateWarning)
+	t.Run("CreateWarningExists", ctx.TestCreateWarningExists)
+	t.Run("GetWarningNotExists", ctx.TestGetWarningNotExists)
+	t.Run("GetWarnings", ctx.TestGetWarnings)
+
+	/* Penalties */
+	t.Run("CreatePenalty", ctx.TestCreatePenalty)
+	t.Run("CreatePenaltyExists", ctx.TestCreatePenaltyExists)
+	t.Run("GetPenaltyNotExists", ctx.TestGetPenaltyNotExists)
+	t.Run("GetPenalties", ctx.TestGetPenalties)
+
+	/* Subscriptions */
+	t.Run("CreateSubscription", ctx.TestCreateSubscription)
+	t.Run("CreateSubscriptionExists", ctx.TestCreateSubscriptionExists)
+	t.Run("GetSubscriptionNotExists", ctx.TestGetSubscriptionNotExists)
+	t.Run("GetAllSubscriptions", ctx.TestGetAllSubscriptions)
+	t.Run("UpdateSubscription", ctx.TestUpdateSubscription)
+	t.Run("UpdateSubscriptionNotExists", ctx.TestUpdateSubscriptionNotExists)
+	t.Run("DeleteSubscription", ctx.TestDeleteSubscription)
+	t.Run("DeleteSubscriptionNotExists", ctx.TestDeleteSubscriptionNotExists)
+
+	/* Templates */
+	t.Run("CreateTemplate", ctx.TestCreateTemplate)
+	t.Run("CreateTemplateExists", ctx.TestCreateTemplateExists)
+	t.Run("GetAllTemplates", ctx.TestGetAllTemplates)
+	t.Run("GetTemplates", ctx.TestGetTemplates)
+	t.Run("GetTemplate", ctx.TestGetTemplate)
+	t.Run("GetTemplateNotExists", ctx.TestGetTemplateNotExists)
+	t.Run("UpdateTemplate", ctx.TestUpdateTemplate)
+	t.Run("UpdateTemplateNotExists", ctx.TestUpdateTemplateNotExists)
+	t.Run("GetTemplateVersions", ctx.TestGetTemplateVersions)
+	t.Run("GetTemplateVersionsNotExists", ctx.TestGetTemplateVersionsNotExists)
+	t.Run("UpdateTemplateState", ctx.TestUpdateTemplateState)
+	t.Run("UpdateTemplateStateNotExists", ctx.TestUpdateTemplateStateNotExists)
+	t.Run("DeleteTemplate", ctx.TestDeleteTemplate)
+	t.Run("DeletedTemplateVersions", ctx.TestDeletedTemplateVersions)
+	t.Run("DeleteTemplateNotExists", ctx.TestDeleteTemplateNotExists)
+}
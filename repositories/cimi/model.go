@@ -99,6 +99,32 @@ func (v *Violation) GetId() string {
 	return v.Id
 }
 
+// toModel converts a CIMI violation to a model.Violation
+func (v *Violation) toModel() *model.Violation {
+	values := make([]model.MetricValue, 0, len(v.Values))
+	for k, value := range v.Values {
+		m := model.MetricValue{
+			Key:      k,
+			Value:    value,
+			DateTime: v.Datetime,
+		}
+		values = append(values, m)
+	}
+	return &model.Violation{
+		Id:          v.Id,
+		AgreementId: v.AgreementId.Href,
+		Datetime:    v.Datetime,
+		Guarantee:   v.Guarantee,
+		Constraint:  v.Constraint,
+		Values:      values,
+	}
+}
+
+type violationCollection struct {
+	Count      int         `json:"count"`
+	Violations []Violation `json:"slaViolations"`
+}
+
 // ServiceOperationReport represents the execution time of a service operation in DER
 // A ServiceOperationReport is created when an operation is executed, and it is
 // updated periodically until the operation has finished. ExecutionTime
@@ -17,6 +17,9 @@ limitations under the License.
 /*
 Package cimi contains the implementation of a repository using a CIMI server as backend.
 
+Tenants are not supported (see model.TenantRepository); the access to the entities
+is controlled by the ACLs of the CIMI server.
+
 See New() for usage.
 */
 package cimi
@@ -33,6 +36,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/cookiejar"
+	"sort"
 	"strings"
 	"time"
 
@@ -225,6 +229,21 @@ func login(repo *Repository) error {
 	return err
 }
 
+// Check implements health.Checker, returning an error if the repository is not logged in
+// to the CIMI server and the login fails.
+func (r Repository) Check() error {
+	if r.logged {
+		return nil
+	}
+	if err := login(&r); err != nil {
+		return err
+	}
+	if !r.logged {
+		return errors.New("Could not login to CIMI server")
+	}
+	return nil
+}
+
 func (r Repository) path(resource path) string {
 	return r.baseurl + "/" + string(resource)
 }
@@ -283,7 +302,81 @@ func (r Repository) request(method method, url string, content interface{}, targ
 	return err
 }
 
+// collectionQuery contains the parameters of a query on a CIMI collection
+type collectionQuery struct {
+	filter  string
+	orderby string
+	page    model.Page
+}
+
+// String returns the query part of the url; CIMI $first and $last are 1-based
+func (q collectionQuery) String() string {
+	parts := make([]string, 0, 4)
+	if q.filter != "" {
+		parts = append(parts, "$filter="+q.filter)
+	}
+	if q.orderby != "" {
+		parts = append(parts, "$orderby="+q.orderby)
+	}
+	if q.page.Offset > 0 || q.page.Limit > 0 {
+		parts = append(parts, fmt.Sprintf("$first=%d", q.page.Offset+1))
+	}
+	if q.page.Limit > 0 {
+		parts = append(parts, fmt.Sprintf("$last=%d", q.page.Offset+q.page.Limit))
+	}
+	return strings.Join(parts, "&")
+}
+
+// orderFields are the names of the CIMI attributes to sort by
+var orderFields = map[string]string{
+	model.FieldId:         "id",
+	model.FieldName:       "name",
+	model.FieldState:      "state",
+	model.FieldProvider:   "details/provider/id",
+	model.FieldClient:     "details/client/id",
+	model.FieldCreation:   "details/creation",
+	model.FieldExpiration: "details/expiration",
+}
+
+// toOrderBy converts a model.Sort to the CIMI $orderby format, adding the id as last key
+func toOrderBy(keys model.Sort) string {
+	parts := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		field := orderFields[key.Field]
+		if key.Desc {
+			field = field + ":desc"
+		}
+		parts = append(parts, field)
+	}
+	parts = append(parts, "id")
+	return strings.Join(parts, ",")
+}
+
+// stateFilter returns a CIMI filter that matches any of the states
+func stateFilter(states []model.State) string {
+	parts := make([]string, 0, len(states))
+	for _, state := range states {
+		parts = append(parts, fmt.Sprintf("(state=\"%s\")", state))
+	}
+	return "(" + strings.Join(parts, "or") + ")"
+}
+
+// intervalFilter appends to parts the filters for an attribute in the interval [from, to)
+func intervalFilter(parts []string, attribute string, from, to time.Time) []string {
+	if !from.IsZero() {
+		parts = append(parts, fmt.Sprintf("(%s>=\"%s\")", attribute, from.UTC().Format(time.RFC3339)))
+	}
+	if !to.IsZero() {
+		parts = append(parts, fmt.Sprintf("(%s<\"%s\")", attribute, to.UTC().Format(time.RFC3339)))
+	}
+	return parts
+}
+
 func (r Repository) get(resource path, filter string, target interface{}) error {
+	return r.query(resource, collectionQuery{filter: filter}, target)
+}
+
+func (r Repository) query(resource path, q collectionQuery, target interface{}) error {
 	if !r.logged {
 		err := login(&r)
 		if err != nil {
@@ -292,8 +385,8 @@ func (r Repository) get(resource path, filter string, target interface{}) error
 	}
 
 	url := r.path(resource)
-	if filter != "" {
-		url = fmt.Sprintf("%s?$filter=%s", url, filter)
+	if params := q.String(); params != "" {
+		url = fmt.Sprintf("%s?%s", url, params)
 	}
 	log.Printf("CimiRepository.read() url=%s", url)
 
@@ -376,6 +469,20 @@ func (r Repository) GetAllProviders() (model.Providers, error) {
 	return result, nil
 }
 
+// GetProviders (see model.Repository)
+func (r Repository) GetProviders(q model.ProviderQuery) (model.Providers, int, error) {
+	result, err := r.GetAllProviders()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
 // GetProvider (see model.Repository)
 func (r Repository) GetProvider(id string) (*model.Provider, error) {
 	var err error
@@ -430,6 +537,32 @@ func (r Repository) GetAllAgreements() (model.Agreements, error) {
 	return target.Agreements, err
 }
 
+// GetAgreements (see model.Repository)
+func (r Repository) GetAgreements(q model.AgreementQuery) (model.Agreements, int, error) {
+	var parts = make([]string, 0, 7)
+	if q.ProviderId != "" {
+		parts = append(parts, fmt.Sprintf("(details/provider/id=\"%s\")", q.ProviderId))
+	}
+	if q.ClientId != "" {
+		parts = append(parts, fmt.Sprintf("(details/client/id=\"%s\")", q.ClientId))
+	}
+	if len(q.States) > 0 {
+		parts = append(parts, stateFilter(q.States))
+	}
+	parts = intervalFilter(parts, "details/creation", q.CreationFrom, q.CreationTo)
+	parts = intervalFilter(parts, "details/expiration", q.ExpirationFrom, q.ExpirationTo)
+
+	target := new(agreementCollection)
+	cq := collectionQuery{
+		filter:  strings.Join(parts, "and"),
+		orderby: toOrderBy(q.Sort),
+		page:    q.Page,
+	}
+	err := r.query(pathAgreements, cq, target)
+
+	return target.Agreements, target.Count, err
+}
+
 // GetAgreement (see model.Repository)
 func (r Repository) GetAgreement(id string) (*model.Agreement, error) {
 	target := new(model.Agreement)
@@ -544,6 +677,27 @@ func (r Repository) GetAllTemplates() (model.Templates, error) {
 
 }
 
+// GetTemplates implements model.IRepository.GetTemplates
+func (r Repository) GetTemplates(q model.TemplateQuery) (model.Templates, int, error) {
+	var parts = make([]string, 0, 2)
+	if q.ProviderId != "" {
+		parts = append(parts, fmt.Sprintf("(details/provider/id=\"%s\")", q.ProviderId))
+	}
+	if len(q.States) > 0 {
+		parts = append(parts, stateFilter(q.States))
+	}
+
+	target := new(templateCollection)
+	cq := collectionQuery{
+		filter:  strings.Join(parts, "and"),
+		orderby: toOrderBy(q.Sort),
+		page:    q.Page,
+	}
+	err := r.query(pathTemplates, cq, target)
+
+	return target.Templates, target.Count, err
+}
+
 // GetTemplate implements model.IRepository.GetTemplate
 func (r Repository) GetTemplate(id string) (*model.Template, error) {
 	target := new(model.Template)
@@ -568,6 +722,47 @@ func (r Repository) CreateTemplate(template *model.Template) (*model.Template, e
 	return template, err
 }
 
+// UpdateTemplate implements model.IRepository.UpdateTemplate
+func (r Repository) UpdateTemplate(template *model.Template) (*model.Template, error) {
+	var acl = r.getACL()
+
+	cimit := &Template{
+		*template,
+		acl,
+	}
+
+	subpath := r.subpath(pathTemplates, template.Id)
+	err := r.put(subpath, cimit)
+	return template, err
+}
+
+// DeleteTemplate implements model.IRepository.DeleteTemplate
+func (r Repository) DeleteTemplate(template *model.Template) error {
+	subpath := r.subpath(pathTemplates, template.Id)
+	err := r.delete(subpath)
+
+	return err
+}
+
+// GetTemplateVersions (see model.Repository). Template versions are not supported by the CIMI server
+func (r Repository) GetTemplateVersions(id string) (model.Templates, error) {
+	return nil, model.ErrNotSupported
+}
+
+// UpdateTemplateState (see model.Repository)
+func (r Repository) UpdateTemplateState(id string, newState model.State) (*model.Template, error) {
+	t := new(Template)
+
+	subpath := r.subpath(pathTemplates, id)
+	err := r.get(subpath, "", t)
+	if err != nil {
+		return nil, err
+	}
+	t.State = newState
+	err = r.put(subpath, t)
+	return &t.Template, err
+}
+
 // CreateViolation stores a violation in the CIMI server
 func (r Repository) CreateViolation(v *model.Violation) (*model.Violation, error) {
 	var acl = r.getACL()
@@ -598,24 +793,87 @@ func (r Repository) GetViolation(id string) (*model.Violation, error) {
 	target := new(Violation)
 	subpath := r.subpath(pathViolations, id)
 	err := r.get(subpath, "", target)
-	values := make([]model.MetricValue, 0, 1)
-	for k, v := range target.Values {
-		m := model.MetricValue{
-			Key:      k,
-			Value:    v,
-			DateTime: target.Datetime,
-		}
-		values = append(values, m)
-	}
-	v := &model.Violation{
-		Id:          target.Id,
-		AgreementId: target.AgreementId.Href,
-		Datetime:    target.Datetime,
-		Guarantee:   target.Guarantee,
-		Constraint:  target.Constraint,
-		Values:      values,
+	return target.toModel(), err
+}
+
+// GetViolations gets the violations that match a query from the CIMI server
+func (r Repository) GetViolations(q model.ViolationQuery) (model.Violations, int, error) {
+	var parts = make([]string, 0, 4)
+	if q.AgreementId != "" {
+		parts = append(parts, fmt.Sprintf("(agreement_id/href=\"%s\")", q.AgreementId))
 	}
-	return v, err
+	if q.Guarantee != "" {
+		parts = append(parts, fmt.Sprintf("(guarantee=\"%s\")", q.Guarantee))
+	}
+	parts = intervalFilter(parts, "datetime", q.From, q.To)
+	target := new(violationCollection)
+	cq := collectionQuery{
+		filter:  strings.Join(parts, "and"),
+		orderby: "datetime",
+		page:    q.Page,
+	}
+	err := r.query(pathViolations, cq, target)
+
+	result := make(model.Violations, 0, len(target.Violations))
+	for _, v := range target.Violations {
+		result = append(result, *v.toModel())
+	}
+	return result, target.Count, err
+}
+
+// CreateWarning (see model.Repository). Warnings are not supported by the CIMI server
+func (r Repository) CreateWarning(w *model.Warning) (*model.Warning, error) {
+	return nil, model.ErrNotSupported
+}
+
+// GetWarning (see model.Repository). Warnings are not supported by the CIMI server
+func (r Repository) GetWarning(id string) (*model.Warning, error) {
+	return nil, model.ErrNotSupported
+}
+
+// GetWarnings (see model.Repository). Warnings are not supported by the CIMI server
+func (r Repository) GetWarnings(q model.WarningQuery) (model.Warnings, int, error) {
+	return nil, 0, model.ErrNotSupported
+}
+
+// CreatePenalty (see model.Repository). Penalties are not supported by the CIMI server
+func (r Repository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+	return nil, model.ErrNotSupported
+}
+
+// GetPenalty (see model.Repository). Penalties are not supported by the CIMI server
+func (r Repository) GetPenalty(id string) (*model.Penalty, error) {
+	return nil, model.ErrNotSupported
+}
+
+// GetPenalties (see model.Repository). Penalties are not supported by the CIMI server
+func (r Repository) GetPenalties(q model.PenaltyQuery) (model.Penalties, int, error) {
+	return nil, 0, model.ErrNotSupported
+}
+
+// GetAllSubscriptions (see model.Repository). Subscriptions are not supported by the CIMI server
+func (r Repository) GetAllSubscriptions() (model.Subscriptions, error) {
+	return nil, model.ErrNotSupported
+}
+
+// GetSubscription (see model.Repository). Subscriptions are not supported by the CIMI server
+func (r Repository) GetSubscription(id string) (*model.Subscription, error) {
+	return nil, model.ErrNotSupported
+}
+
+// CreateSubscription (see model.Repository). Subscriptions are not supported by the CIMI server
+func (r Repository) CreateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	return nil, model.ErrNotSupported
+}
+
+// UpdateSubscription (see model.Repository). Subscriptions are not supported by the CIMI server
+func (r Repository) UpdateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	return nil, model.ErrNotSupported
+}
+
+// DeleteSubscription (see model.Repository). Subscriptions are not supported by the CIMI server
+func (r Repository) DeleteSubscription(s *model.Subscription) error {
+	return model.ErrNotSupported
 }
 
 // CreateServiceOperationReport stores an execution log in the CIMI server
@@ -131,13 +131,17 @@ func TestRepository(t *testing.T) {
 	// N/A in CIMI t.Run("CreateViolationExists", ctx.TestCreateViolationExists)
 	t.Run("GetViolation", ctx.TestGetViolation)
 	t.Run("GetViolationNotExists", ctx.TestGetViolationNotExists)
+	t.Run("GetViolations", ctx.TestGetViolations)
 
 	/* Templates */
 	t.Run("CreateTemplate", ctx.TestCreateTemplate)
 	// N/A in CIMI t.Run("CreateTemplateExists", ctx.TestCreateTemplateExists)
 	t.Run("GetAllTemplates", ctx.TestGetAllTemplates)
+	t.Run("GetTemplates", ctx.TestGetTemplates)
 	t.Run("GetTemplate", ctx.TestGetTemplate)
 	t.Run("GetTemplateNotExists", ctx.TestGetTemplateNotExists)
+	t.Run("UpdateTemplate", ctx.TestUpdateTemplate)
+	t.Run("DeleteTemplate", ctx.TestDeleteTemplate)
 
 	//
 	// TODO tests on ServiceOperationReport and ServiceInstance
@@ -0,0 +1,279 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package instrumented provides a repository decorator that observes the latency of
+// the calls to the decorated repository (see metrics.RepositoryDuration).
+//
+// Usage:
+//   repo, err := mongodb.New(config)
+//   repo = instrumented.New(repo)
+//
+package instrumented
+
+import (
+	"SLALite/health"
+	"SLALite/metrics"
+	"SLALite/model"
+	"time"
+)
+
+type repository struct {
+	backend model.IRepository
+}
+
+// New returns an IRepository that observes the latency of the calls to backend.
+func New(backend model.IRepository) model.IRepository {
+	return repository{
+		backend: backend,
+	}
+}
+
+// Check checks the backend, if it implements health.Checker.
+func (r repository) Check() error {
+	if c, ok := r.backend.(health.Checker); ok {
+		return c.Check()
+	}
+	return nil
+}
+
+// ForTenant returns the instrumented repository of tenant in the backend (see model.ForTenant).
+func (r repository) ForTenant(tenant string) (model.IRepository, error) {
+	backend, err := model.ForTenant(r.backend, tenant)
+	if err != nil {
+		return nil, err
+	}
+	return New(backend), nil
+}
+
+// GetTenants returns the tenants of the backend (see model.GetTenants).
+func (r repository) GetTenants() ([]string, error) {
+	defer observe("GetTenants", time.Now())
+	return model.GetTenants(r.backend)
+}
+
+func observe(operation string, start time.Time) {
+	metrics.RepositoryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// GetAllProviders (see model.IRepository)
+func (r repository) GetAllProviders() (model.Providers, error) {
+	defer observe("GetAllProviders", time.Now())
+	return r.backend.GetAllProviders()
+}
+
+// GetProviders (see model.IRepository)
+func (r repository) GetProviders(q model.ProviderQuery) (model.Providers, int, error) {
+	defer observe("GetProviders", time.Now())
+	return r.backend.GetProviders(q)
+}
+
+// GetProvider (see model.IRepository)
+func (r repository) GetProvider(id string) (*model.Provider, error) {
+	defer observe("GetProvider", time.Now())
+	return r.backend.GetProvider(id)
+}
+
+// CreateProvider (see model.IRepository)
+func (r repository) CreateProvider(provider *model.Provider) (*model.Provider, error) {
+	defer observe("CreateProvider", time.Now())
+	return r.backend.CreateProvider(provider)
+}
+
+// DeleteProvider (see model.IRepository)
+func (r repository) DeleteProvider(provider *model.Provider) error {
+	defer observe("DeleteProvider", time.Now())
+	return r.backend.DeleteProvider(provider)
+}
+
+// GetAllAgreements (see model.IRepository)
+func (r repository) GetAllAgreements() (model.Agreements, error) {
+	defer observe("GetAllAgreements", time.Now())
+	return r.backend.GetAllAgreements()
+}
+
+// GetAgreements (see model.IRepository)
+func (r repository) GetAgreements(q model.AgreementQuery) (model.Agreements, int, error) {
+	defer observe("GetAgreements", time.Now())
+	return r.backend.GetAgreements(q)
+}
+
+// GetAgreement (see model.IRepository)
+func (r repository) GetAgreement(id string) (*model.Agreement, error) {
+	defer observe("GetAgreement", time.Now())
+	return r.backend.GetAgreement(id)
+}
+
+// GetAgreementsByState (see model.IRepository)
+func (r repository) GetAgreementsByState(states ...model.State) (model.Agreements, error) {
+	defer observe("GetAgreementsByState", time.Now())
+	return r.backend.GetAgreementsByState(states...)
+}
+
+// CreateAgreement (see model.IRepository)
+func (r repository) CreateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	defer observe("CreateAgreement", time.Now())
+	return r.backend.CreateAgreement(agreement)
+}
+
+// UpdateAgreement (see model.IRepository)
+func (r repository) UpdateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	defer observe("UpdateAgreement", time.Now())
+	return r.backend.UpdateAgreement(agreement)
+}
+
+// DeleteAgreement (see model.IRepository)
+func (r repository) DeleteAgreement(agreement *model.Agreement) error {
+	defer observe("DeleteAgreement", time.Now())
+	return r.backend.DeleteAgreement(agreement)
+}
+
+// GetAllTemplates (see model.IRepository)
+func (r repository) GetAllTemplates() (model.Templates, error) {
+	defer observe("GetAllTemplates", time.Now())
+	return r.backend.GetAllTemplates()
+}
+
+// GetTemplates (see model.IRepository)
+func (r repository) GetTemplates(q model.TemplateQuery) (model.Templates, int, error) {
+	defer observe("GetTemplates", time.Now())
+	return r.backend.GetTemplates(q)
+}
+
+// GetTemplate (see model.IRepository)
+func (r repository) GetTemplate(id string) (*model.Template, error) {
+	defer observe("GetTemplate", time.Now())
+	return r.backend.GetTemplate(id)
+}
+
+// CreateTemplate (see model.IRepository)
+func (r repository) CreateTemplate(template *model.Template) (*model.Template, error) {
+	defer observe("CreateTemplate", time.Now())
+	return r.backend.CreateTemplate(template)
+}
+
+// UpdateTemplate (see model.IRepository)
+func (r repository) UpdateTemplate(template *model.Template) (*model.Template, error) {
+	defer observe("UpdateTemplate", time.Now())
+	return r.backend.UpdateTemplate(template)
+}
+
+// DeleteTemplate (see model.IRepository)
+func (r repository) DeleteTemplate(template *model.Template) error {
+	defer observe("DeleteTemplate", time.Now())
+	return r.backend.DeleteTemplate(template)
+}
+
+// GetTemplateVersions (see model.IRepository)
+func (r repository) GetTemplateVersions(id string) (model.Templates, error) {
+	defer observe("GetTemplateVersions", time.Now())
+	return r.backend.GetTemplateVersions(id)
+}
+
+// UpdateTemplateState (see model.IRepository)
+func (r repository) UpdateTemplateState(id string, newState model.State) (*model.Template, error) {
+	defer observe("UpdateTemplateState", time.Now())
+	return r.backend.UpdateTemplateState(id, newState)
+}
+
+// CreateViolation (see model.IRepository)
+func (r repository) CreateViolation(v *model.Violation) (*model.Violation, error) {
+	defer observe("CreateViolation", time.Now())
+	return r.backend.CreateViolation(v)
+}
+
+// GetViolation (see model.IRepository)
+func (r repository) GetViolation(id string) (*model.Violation, error) {
+	defer observe("GetViolation", time.Now())
+	return r.backend.GetViolation(id)
+}
+
+// GetViolations (see model.IRepository)
+func (r repository) GetViolations(q model.ViolationQuery) (model.Violations, int, error) {
+	defer observe("GetViolations", time.Now())
+	return r.backend.GetViolations(q)
+}
+
+// CreateWarning (see model.IRepository)
+func (r repository) CreateWarning(w *model.Warning) (*model.Warning, error) {
+	defer observe("CreateWarning", time.Now())
+	return r.backend.CreateWarning(w)
+}
+
+// GetWarning (see model.IRepository)
+func (r repository) GetWarning(id string) (*model.Warning, error) {
+	defer observe("GetWarning", time.Now())
+	return r.backend.GetWarning(id)
+}
+
+// GetWarnings (see model.IRepository)
+func (r repository) GetWarnings(q model.WarningQuery) (model.Warnings, int, error) {
+	defer observe("GetWarnings", time.Now())
+	return r.backend.GetWarnings(q)
+}
+
+// CreatePenalty (see model.IRepository)
+func (r repository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+	defer observe("CreatePenalty", time.Now())
+	return r.backend.CreatePenalty(p)
+}
+
+// GetPenalty (see model.IRepository)
+func (r repository) GetPenalty(id string) (*model.Penalty, error) {
+	defer observe("GetPenalty", time.Now())
+	return r.backend.GetPenalty(id)
+}
+
+// GetPenalties (see model.IRepository)
+func (r repository) GetPenalties(q model.PenaltyQuery) (model.Penalties, int, error) {
+	defer observe("GetPenalties", time.Now())
+	return r.backend.GetPenalties(q)
+}
+
+// GetAllSubscriptions (see model.IRepository)
+func (r repository) GetAllSubscriptions() (model.Subscriptions, error) {
+	defer observe("GetAllSubscriptions", time.Now())
+	return r.backend.GetAllSubscriptions()
+}
+
+// GetSubscription (see model.IRepository)
+func (r repository) GetSubscription(id string) (*model.Subscription, error) {
+	defer observe("GetSubscription", time.Now())
+	return r.backend.GetSubscription(id)
+}
+
+// CreateSubscription (see model.IRepository)
+func (r repository) CreateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	defer observe("CreateSubscription", time.Now())
+	return r.backend.CreateSubscription(s)
+}
+
+// UpdateSubscription (see model.IRepository)
+func (r repository) UpdateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	defer observe("UpdateSubscription", time.Now())
+	return r.backend.UpdateSubscription(s)
+}
+
+// DeleteSubscription (see model.IRepository)
+func (r repository) DeleteSubscription(s *model.Subscription) error {
+	defer observe("DeleteSubscription", time.Now())
+	return r.backend.DeleteSubscription(s)
+}
+
+// UpdateAgreementState (see model.IRepository)
+func (r repository) UpdateAgreementState(id string, newState model.State) (*model.Agreement, error) {
+	defer observe("UpdateAgreementState", time.Now())
+	return r.backend.UpdateAgreementState(id, newState)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package instrumented
+
+import (
+	"SLALite/model"
+	"SLALite/repositories/memrepository"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestObserveCalls(t *testing.T) {
+	r, _ := memrepository.New(nil)
+	repo := New(r)
+
+	if _, err := repo.CreateProvider(&model.Provider{Id: "p01", Name: "Provider 01"}); err != nil {
+		t.Fatalf("Error creating provider: %v", err)
+	}
+	if _, err := repo.GetProvider("p01"); err != nil {
+		t.Errorf("Error getting provider: %v", err)
+	}
+	if _, err := repo.GetProvider("notexists"); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound. Actual: %v", err)
+	}
+	repo.GetAgreementsByState(model.STARTED, model.STOPPED)
+
+	expected := map[string]uint64{"CreateProvider": 1, "GetProvider": 2, "GetAgreementsByState": 1}
+	for operation, count := range observations(t) {
+		if expected[operation] != count {
+			t.Errorf("Unexpected observations of %s. Expected: %d; Actual: %d", operation, expected[operation], count)
+		}
+		delete(expected, operation)
+	}
+	if len(expected) > 0 {
+		t.Errorf("Operations not observed: %v", expected)
+	}
+}
+
+// observations returns the number of observations of each repository operation
+func observations(t *testing.T) map[string]uint64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %v", err)
+	}
+	result := make(map[string]uint64)
+	for _, f := range families {
+		if f.GetName() != "slalite_repository_duration_seconds" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "operation" {
+					result[l.GetValue()] = m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return result
+}
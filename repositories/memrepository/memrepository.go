@@ -17,22 +17,59 @@ limitations under the License.
 /*
 Package memrepository is a simple implementation of a model.IRepository intended for
 developing purposes.
+
+A MemRepository is safe for concurrent use. Entities are copied when they are stored
+and when they are returned, so callers never share memory with the repository.
+
+The entities of each tenant are kept in separate maps (see ForTenant).
+
+Optionally, the content of the repository is periodically saved to a JSON snapshot
+file, which is loaded on startup.
 */
 package memrepository
 
 import (
 	"SLALite/model"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
 
 	"github.com/spf13/viper"
 )
 
+const (
+	// Name is the unique identifier of this repository
+	Name string = "memory"
+
+	defaultSnapshotPeriod string = "60s"
+
+	memConfigName string = "memory.yml"
+
+	snapshotPropertyName       = "snapshot"
+	snapshotPeriodPropertyName = "snapshot_period"
+)
+
 // MemRepository is a repository in memory
 type MemRepository struct {
+	// mu is a pointer so that copies of the repository share the lock
+	mu       *sync.RWMutex
+	snapshot *snapshotter
+	// tenant is the tenant of the entities in the maps of this repository
+	tenant string
+	// tenants contains the repositories of all the tenants, sharing mu and snapshot
+	tenants map[string]MemRepository
+
 	providers  map[string]model.Provider
 	agreements map[string]model.Agreement
 	violations map[string]model.Violation
+	warnings   map[string]model.Warning
 	penalties  map[string]model.Penalty
 	templates  map[string]model.Template
+	// templateVersions contains the revisions of each template, sorted by version
+	templateVersions map[string]model.Templates
+	// subscriptions is always initially empty
+	subscriptions map[string]model.Subscription
 }
 
 // NewMemRepository creates a MemRepository with an initial state set by the parameters
@@ -57,18 +94,149 @@ func NewMemRepository(providers map[string]model.Provider, agreements map[string
 		templates = make(map[string]model.Template)
 	}
 	r = MemRepository{
-		providers:  providers,
-		agreements: agreements,
-		violations: violations,
-		penalties:  penalties,
-		templates:  templates,
+		mu:            new(sync.RWMutex),
+		providers:     providers,
+		agreements:    agreements,
+		violations:    violations,
+		warnings:      make(map[string]model.Warning),
+		penalties:     penalties,
+		templates:     templates,
+		subscriptions: make(map[string]model.Subscription),
+		tenant:        model.DefaultTenant,
+		tenants:       make(map[string]MemRepository),
+
+		templateVersions: make(map[string]model.Templates),
 	}
+	r.tenants[model.DefaultTenant] = r
+	r.addMissingVersions()
 	return r
 }
 
-//New creates a new instance of MemRepository
+// newTenant returns an empty repository of tenant that shares the state of r
+func (r MemRepository) newTenant(tenant string) MemRepository {
+	return MemRepository{
+		mu:            r.mu,
+		snapshot:      r.snapshot,
+		tenant:        tenant,
+		tenants:       r.tenants,
+		providers:     make(map[string]model.Provider),
+		agreements:    make(map[string]model.Agreement),
+		violations:    make(map[string]model.Violation),
+		warnings:      make(map[string]model.Warning),
+		penalties:     make(map[string]model.Penalty),
+		templates:     make(map[string]model.Template),
+		subscriptions: make(map[string]model.Subscription),
+
+		templateVersions: make(map[string]model.Templates),
+	}
+}
+
+/*
+ForTenant returns the repository of the entities of tenant, which is created empty
+the first time a tenant is requested. The repositories of all the tenants share the
+lock and the snapshot file.
+
+error != nil if tenant is not valid
+*/
+func (r MemRepository) ForTenant(tenant string) (model.IRepository, error) {
+	if err := model.ValidateTenant(tenant); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.tenantLocked(tenant), nil
+}
+
+// tenantLocked returns the repository of tenant, creating it if needed. r.mu must be locked.
+func (r MemRepository) tenantLocked(tenant string) MemRepository {
+	result, ok := r.tenants[tenant]
+	if !ok {
+		result = r.newTenant(tenant)
+		r.tenants[tenant] = result
+	}
+	return result
+}
+
+/*
+GetTenants returns the tenants of the repository, sorted by id.
+*/
+func (r MemRepository) GetTenants() ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]string, 0, len(r.tenants))
+	for tenant := range r.tenants {
+		result = append(result, tenant)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// NewDefaultConfig gets a default configuration for a MemRepository
+func NewDefaultConfig() (*viper.Viper, error) {
+	config := viper.New()
+
+	config.SetEnvPrefix("sla") // Env vars start with 'SLA_'
+	config.AutomaticEnv()
+	config.SetConfigName(memConfigName)
+	config.AddConfigPath(model.UnixConfigPath)
+	setDefaults(config)
+
+	confError := config.ReadInConfig()
+	if confError != nil {
+		log.Println("Can't find memory repository configuration file: " + confError.Error())
+		log.Println("Using defaults")
+	}
+
+	return config, confError
+}
+
+func setDefaults(config *viper.Viper) {
+	config.SetDefault(snapshotPropertyName, "")
+	config.SetDefault(snapshotPeriodPropertyName, defaultSnapshotPeriod)
+}
+
+// New creates a new instance of MemRepository.
+//
+// If a snapshot file is configured, the repository is loaded from it and a snapshot
+// is written every snapshot period until Close is called.
 func New(config *viper.Viper) (MemRepository, error) {
-	return NewMemRepository(nil, nil, nil, nil, nil), nil
+	if config == nil {
+		config, _ = NewDefaultConfig()
+	} else {
+		setDefaults(config)
+	}
+
+	logConfig(config)
+
+	r := NewMemRepository(nil, nil, nil, nil, nil)
+	path := config.GetString(snapshotPropertyName)
+	if path == "" {
+		return r, nil
+	}
+	r.snapshot = newSnapshotter(path)
+	r.tenants[model.DefaultTenant] = r
+	if err := r.load(path); err != nil {
+		return r, err
+	}
+	go r.runSnapshots(config.GetDuration(snapshotPeriodPropertyName))
+	return r, nil
+}
+
+func logConfig(config *viper.Viper) {
+	log.Printf("Memory repository configuration\n"+
+		"\tsnapshot: %v\n"+
+		"\tsnapshot period: %v\n",
+		config.GetString(snapshotPropertyName),
+		config.GetDuration(snapshotPeriodPropertyName))
+}
+
+/*
+Check implements health.Checker. The memory repository is always available.
+*/
+func (r MemRepository) Check() error {
+	return nil
 }
 
 /*
@@ -78,6 +246,9 @@ The list is empty when there are no providers;
 error != nil on error
 */
 func (r MemRepository) GetAllProviders() (model.Providers, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	result := make(model.Providers, 0, len(r.providers))
 
 	for _, value := range r.providers {
@@ -86,6 +257,29 @@ func (r MemRepository) GetAllProviders() (model.Providers, error) {
 	return result, nil
 }
 
+/*
+GetProviders returns the page of providers specified in the query, and the total
+number of providers.
+
+The list is empty when there are no providers in the page;
+error != nil on error
+*/
+func (r MemRepository) GetProviders(q model.ProviderQuery) (model.Providers, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(model.Providers, 0, len(r.providers))
+
+	for _, value := range r.providers {
+		result = append(result, value)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
 /*
 GetProvider returns the Provider identified by id.
 
@@ -93,6 +287,9 @@ error != nil on error;
 error is sql.ErrNoRows if the provider is not found
 */
 func (r MemRepository) GetProvider(id string) (*model.Provider, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	var err error
 
 	item, ok := r.providers[id]
@@ -112,6 +309,9 @@ error != nil on error;
 error is sql.ErrNoRows if the provider already exists
 */
 func (r MemRepository) CreateProvider(provider *model.Provider) (*model.Provider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	var err error
 
 	id := provider.Id
@@ -120,6 +320,7 @@ func (r MemRepository) CreateProvider(provider *model.Provider) (*model.Provider
 	if ok {
 		err = model.ErrAlreadyExist
 	} else {
+		provider.Tenant = r.tenant
 		r.providers[id] = *provider
 		err = nil
 	}
@@ -133,6 +334,9 @@ error != nil on error;
 error is sql.ErrNoRows if the provider does not exist.
 */
 func (r MemRepository) DeleteProvider(provider *model.Provider) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	var err error
 
 	id := provider.Id
@@ -154,26 +358,57 @@ The list is empty when there are no agreements;
 error != nil on error
 */
 func (r MemRepository) GetAllAgreements() (model.Agreements, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	result := make(model.Agreements, 0, len(r.agreements))
 
 	for _, value := range r.agreements {
-		result = append(result, value)
+		result = append(result, copyAgreement(value))
 	}
 	return result, nil
 }
 
+/*
+GetAgreements returns the page of agreements that match the query, and the total
+number of matching agreements.
+
+The list is empty when there are no matching agreements in the page;
+error != nil on error
+*/
+func (r MemRepository) GetAgreements(q model.AgreementQuery) (model.Agreements, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(model.Agreements, 0)
+
+	for _, a := range r.agreements {
+		if q.Match(&a) {
+			result = append(result, copyAgreement(a))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
 /*
 GetAgreementsByState returns the agreements that match any of the items in states.
 
 error != nil on error
 */
 func (r MemRepository) GetAgreementsByState(states ...model.State) (model.Agreements, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	result := make(model.Agreements, 0)
 
 	for _, a := range r.agreements {
 		for _, state := range states {
 			if a.State == state {
-				result = append(result, a)
+				result = append(result, copyAgreement(a))
 			}
 		}
 	}
@@ -187,12 +422,15 @@ error != nil on error;
 error is sql.ErrNoRows if the Agreement is not found
 */
 func (r MemRepository) GetAgreement(id string) (*model.Agreement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	var err error
 
 	item, ok := r.agreements[id]
 
 	if ok {
-		err = nil
+		item = copyAgreement(item)
 	} else {
 		err = model.ErrNotFound
 	}
@@ -206,6 +444,9 @@ error != nil on error;
 error is sql.ErrNoRows if the Agreement already exists
 */
 func (r MemRepository) CreateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	var err error
 
 	id := agreement.Id
@@ -214,7 +455,8 @@ func (r MemRepository) CreateAgreement(agreement *model.Agreement) (*model.Agree
 	if ok {
 		err = model.ErrAlreadyExist
 	} else {
-		r.agreements[id] = *agreement
+		agreement.Tenant = r.tenant
+		r.agreements[id] = copyAgreement(*agreement)
 	}
 	return agreement, err
 }
@@ -223,6 +465,9 @@ func (r MemRepository) CreateAgreement(agreement *model.Agreement) (*model.Agree
 UpdateAgreement updates the information of an already saved instance of an agreement
 */
 func (r MemRepository) UpdateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	var err error
 
 	id := agreement.Id
@@ -231,7 +476,8 @@ func (r MemRepository) UpdateAgreement(agreement *model.Agreement) (*model.Agree
 	if !ok {
 		err = model.ErrNotFound
 	} else {
-		r.agreements[id] = *agreement
+		agreement.Tenant = r.tenant
+		r.agreements[id] = copyAgreement(*agreement)
 	}
 	return agreement, err
 }
@@ -243,6 +489,9 @@ error != nil on error;
 error is sql.ErrNoRows if the Agreement does not exist.
 */
 func (r MemRepository) DeleteAgreement(agreement *model.Agreement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	var err error
 
 	id := agreement.Id
@@ -263,6 +512,9 @@ error != nil on error;
 error is sql.ErrNoRows if the Violation already exists
 */
 func (r MemRepository) CreateViolation(v *model.Violation) (*model.Violation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	var err error
 
 	id := v.Id
@@ -270,7 +522,8 @@ func (r MemRepository) CreateViolation(v *model.Violation) (*model.Violation, er
 	if _, ok := r.violations[id]; ok {
 		err = model.ErrAlreadyExist
 	} else {
-		r.violations[id] = *v
+		v.Tenant = r.tenant
+		r.violations[id] = copyViolation(*v)
 	}
 	return v, err
 }
@@ -282,22 +535,190 @@ error != nil on error;
 error is sql.ErrNoRows if the Violation is not found
 */
 func (r MemRepository) GetViolation(id string) (*model.Violation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	var err error
 
 	item, ok := r.violations[id]
 
 	if ok {
-		err = nil
+		item = copyViolation(item)
+	} else {
+		err = model.ErrNotFound
+	}
+	return &item, err
+}
+
+/*
+GetViolations returns the page of violations that match the query, sorted by datetime,
+and the total number of matching violations.
+
+The list is empty when there are no matching violations in the page;
+error != nil on error
+*/
+func (r MemRepository) GetViolations(q model.ViolationQuery) (model.Violations, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(model.Violations, 0)
+
+	for _, v := range r.violations {
+		if q.Match(&v) {
+			result = append(result, copyViolation(v))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+/*
+CreateWarning stores a new Warning.
+
+error != nil on error;
+error is sql.ErrNoRows if the Warning already exists
+*/
+func (r MemRepository) CreateWarning(w *model.Warning) (*model.Warning, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+
+	id := w.Id
+
+	if _, ok := r.warnings[id]; ok {
+		err = model.ErrAlreadyExist
+	} else {
+		w.Tenant = r.tenant
+		r.warnings[id] = copyWarning(*w)
+	}
+	return w, err
+}
+
+/*
+GetWarning returns the Warning identified by id.
+
+error != nil on error;
+error is sql.ErrNoRows if the Warning is not found
+*/
+func (r MemRepository) GetWarning(id string) (*model.Warning, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var err error
+
+	item, ok := r.warnings[id]
+
+	if ok {
+		item = copyWarning(item)
+	} else {
+		err = model.ErrNotFound
+	}
+	return &item, err
+}
+
+/*
+GetWarnings returns the page of warnings that match the query, sorted by datetime,
+and the total number of matching warnings.
+
+The list is empty when there are no matching warnings in the page;
+error != nil on error
+*/
+func (r MemRepository) GetWarnings(q model.WarningQuery) (model.Warnings, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(model.Warnings, 0)
+
+	for _, w := range r.warnings {
+		if q.Match(&w) {
+			result = append(result, copyWarning(w))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
+/*
+CreatePenalty stores a new Penalty.
+
+error != nil on error;
+error is sql.ErrNoRows if the Penalty already exists
+*/
+func (r MemRepository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+
+	id := p.Id
+
+	if _, ok := r.penalties[id]; ok {
+		err = model.ErrAlreadyExist
 	} else {
+		p.Tenant = r.tenant
+		r.penalties[id] = *p
+	}
+	return p, err
+}
+
+/*
+GetPenalty returns the Penalty identified by id.
+
+error != nil on error;
+error is sql.ErrNoRows if the Penalty is not found
+*/
+func (r MemRepository) GetPenalty(id string) (*model.Penalty, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var err error
+
+	item, ok := r.penalties[id]
+
+	if !ok {
 		err = model.ErrNotFound
 	}
 	return &item, err
 }
 
+/*
+GetPenalties returns the page of penalties that match the query, sorted by datetime,
+and the total number of matching penalties.
+
+The list is empty when there are no matching penalties in the page;
+error != nil on error
+*/
+func (r MemRepository) GetPenalties(q model.PenaltyQuery) (model.Penalties, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(model.Penalties, 0)
+
+	for _, p := range r.penalties {
+		if q.Match(&p) {
+			result = append(result, p)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
 /*
 UpdateAgreementState transits the state of the agreement
 */
 func (r MemRepository) UpdateAgreementState(id string, newState model.State) (*model.Agreement, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
 	var ok bool
 	var err error
@@ -311,6 +732,7 @@ func (r MemRepository) UpdateAgreementState(id string, newState model.State) (*m
 	} else {
 		current.State = newState
 		r.agreements[id] = current
+		current = copyAgreement(current)
 		result = &current
 	}
 	return result, err
@@ -323,15 +745,42 @@ The list is empty when there are no templates;
 error != nil on error
 */
 func (r MemRepository) GetAllTemplates() (model.Templates, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
 	result := make(model.Templates, 0, len(r.templates))
 
 	for _, value := range r.templates {
-		result = append(result, value)
+		result = append(result, copyTemplate(value))
 	}
 	return result, nil
 }
 
+/*
+GetTemplates returns the page of templates that match the query, and the total
+number of matching templates.
+
+The list is empty when there are no matching templates in the page;
+error != nil on error
+*/
+func (r MemRepository) GetTemplates(q model.TemplateQuery) (model.Templates, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(model.Templates, 0)
+
+	for _, t := range r.templates {
+		if q.Match(&t) {
+			result = append(result, copyTemplate(t))
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return q.Less(&result[i], &result[j])
+	})
+	begin, end := q.Page.Bounds(len(result))
+	return result[begin:end], len(result), nil
+}
+
 /*
 GetTemplate returns the Template identified by id.
 
@@ -339,12 +788,15 @@ error != nil on error;
 error is sql.ErrNoRows if the Template is not found
 */
 func (r MemRepository) GetTemplate(id string) (*model.Template, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	var err error
 
 	item, ok := r.templates[id]
 
 	if ok {
-		err = nil
+		item = copyTemplate(item)
 	} else {
 		err = model.ErrNotFound
 	}
@@ -352,12 +804,16 @@ func (r MemRepository) GetTemplate(id string) (*model.Template, error) {
 }
 
 /*
-CreateTemplate stores a new Template.
+CreateTemplate stores a new Template, with version 1, or the next version of the
+revisions of a deleted Template with the same id.
 
 error != nil on error;
 error is sql.ErrNoRows if the Template already exists
 */
 func (r MemRepository) CreateTemplate(template *model.Template) (*model.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	var err error
 
 	id := template.Id
@@ -366,7 +822,328 @@ func (r MemRepository) CreateTemplate(template *model.Template) (*model.Template
 	if ok {
 		err = model.ErrAlreadyExist
 	} else {
-		r.templates[id] = *template
+		versions := r.templateVersions[id]
+		template.Tenant = r.tenant
+		template.Version = 1
+		if len(versions) > 0 {
+			template.Version = versions[len(versions)-1].Version + 1
+		}
+		r.templates[id] = copyTemplate(*template)
+		r.templateVersions[id] = append(versions, copyTemplate(*template))
 	}
 	return template, err
 }
+
+/*
+UpdateTemplate updates the information of an already saved instance of a template.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist
+*/
+func (r MemRepository) UpdateTemplate(template *model.Template) (*model.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+
+	id := template.Id
+	current, ok := r.templates[id]
+
+	if !ok {
+		err = model.ErrNotFound
+	} else {
+		template.Tenant = r.tenant
+		template.Version = current.Version + 1
+		r.templates[id] = copyTemplate(*template)
+		r.templateVersions[id] = append(r.templateVersions[id], copyTemplate(*template))
+	}
+	return template, err
+}
+
+/*
+DeleteTemplate deletes from the repository the Template whose id is template.Id.
+Its revisions are kept.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist.
+*/
+func (r MemRepository) DeleteTemplate(template *model.Template) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+
+	id := template.Id
+
+	_, ok := r.templates[id]
+	if ok {
+		delete(r.templates, id)
+	} else {
+		err = model.ErrNotFound
+	}
+	return err
+}
+
+/*
+GetTemplateVersions returns the revisions of the Template identified by id,
+sorted by version, even if the Template has been deleted.
+
+error != nil on error;
+error is model.ErrNotFound if the Template never existed
+*/
+func (r MemRepository) GetTemplateVersions(id string) (model.Templates, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.templateVersions[id]
+	if len(versions) == 0 {
+		return nil, model.ErrNotFound
+	}
+	result := make(model.Templates, 0, len(versions))
+	for _, t := range versions {
+		result = append(result, copyTemplate(t))
+	}
+	return result, nil
+}
+
+/*
+UpdateTemplateState changes the state of a Template, without storing a new revision.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist
+*/
+func (r MemRepository) UpdateTemplateState(id string, newState model.State) (*model.Template, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, ok := r.templates[id]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	current.State = newState
+	r.templates[id] = current
+	result := copyTemplate(current)
+	return &result, nil
+}
+
+// addMissingVersions records the current revision of the templates stored without
+// revisions, as those given to NewMemRepository or read from old snapshots.
+// r.mu must be locked, if r is shared.
+func (r MemRepository) addMissingVersions() {
+	for id, t := range r.templates {
+		if len(r.templateVersions[id]) > 0 {
+			continue
+		}
+		if t.Version == 0 {
+			t.Version = 1
+			r.templates[id] = t
+		}
+		r.templateVersions[id] = model.Templates{copyTemplate(t)}
+	}
+}
+
+/*
+GetAllSubscriptions returns the list of subscriptions.
+
+The list is empty when there are no subscriptions;
+error != nil on error
+*/
+func (r MemRepository) GetAllSubscriptions() (model.Subscriptions, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(model.Subscriptions, 0, len(r.subscriptions))
+
+	for _, value := range r.subscriptions {
+		result = append(result, copySubscription(value))
+	}
+	return result, nil
+}
+
+/*
+GetSubscription returns the Subscription identified by id.
+
+error != nil on error;
+error is sql.ErrNoRows if the Subscription is not found
+*/
+func (r MemRepository) GetSubscription(id string) (*model.Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var err error
+
+	item, ok := r.subscriptions[id]
+
+	if !ok {
+		err = model.ErrNotFound
+	}
+	item = copySubscription(item)
+	return &item, err
+}
+
+/*
+CreateSubscription stores a new Subscription.
+
+error != nil on error;
+error is sql.ErrNoRows if the Subscription already exists
+*/
+func (r MemRepository) CreateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+
+	id := s.Id
+
+	if _, ok := r.subscriptions[id]; ok {
+		err = model.ErrAlreadyExist
+	} else {
+		s.Tenant = r.tenant
+		r.subscriptions[id] = copySubscription(*s)
+	}
+	return s, err
+}
+
+/*
+UpdateSubscription updates an already saved Subscription.
+
+error != nil on error;
+error is sql.ErrNoRows if the Subscription does not exist
+*/
+func (r MemRepository) UpdateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+
+	id := s.Id
+
+	if _, ok := r.subscriptions[id]; !ok {
+		err = model.ErrNotFound
+	} else {
+		s.Tenant = r.tenant
+		r.subscriptions[id] = copySubscription(*s)
+	}
+	return s, err
+}
+
+/*
+DeleteSubscription deletes from the repository the Subscription whose id is s.Id.
+
+error != nil on error;
+error is sql.ErrNoRows if the Subscription does not exist.
+*/
+func (r MemRepository) DeleteSubscription(s *model.Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+
+	id := s.Id
+
+	if _, ok := r.subscriptions[id]; ok {
+		delete(r.subscriptions, id)
+	} else {
+		err = model.ErrNotFound
+	}
+	return err
+}
+
+// copyAgreement returns a copy of a that does not share memory with it
+func copyAgreement(a model.Agreement) model.Agreement {
+	if a.Assessment != nil {
+		assessment := *a.Assessment
+		if assessment.Guarantees != nil {
+			guarantees := make(map[string]model.AssessmentGuarantee, len(assessment.Guarantees))
+			for k, g := range assessment.Guarantees {
+				g.LastValues = copyLastValues(g.LastValues)
+				guarantees[k] = g
+			}
+			assessment.Guarantees = guarantees
+		}
+		a.Assessment = &assessment
+	}
+	if a.Template != nil {
+		ref := *a.Template
+		a.Template = &ref
+	}
+	a.Details = copyDetails(a.Details)
+	return a
+}
+
+// copyTemplate returns a copy of t that does not share memory with it
+func copyTemplate(t model.Template) model.Template {
+	t.Details = copyDetails(t.Details)
+	if t.Parameters != nil {
+		parameters := make(map[string]model.Parameter, len(t.Parameters))
+		for name, p := range t.Parameters {
+			parameters[name] = p
+		}
+		t.Parameters = parameters
+	}
+	return t
+}
+
+// copyViolation returns a copy of v that does not share memory with it
+func copyViolation(v model.Violation) model.Violation {
+	if v.Values != nil {
+		v.Values = append([]model.MetricValue(nil), v.Values...)
+	}
+	return v
+}
+
+// copyWarning returns a copy of w that does not share memory with it
+func copyWarning(w model.Warning) model.Warning {
+	if w.Values != nil {
+		w.Values = append([]model.MetricValue(nil), w.Values...)
+	}
+	return w
+}
+
+// copySubscription returns a copy of s that does not share memory with it
+func copySubscription(s model.Subscription) model.Subscription {
+	if s.Events != nil {
+		s.Events = append([]model.EventType(nil), s.Events...)
+	}
+	return s
+}
+
+func copyDetails(d model.Details) model.Details {
+	if d.Expiration != nil {
+		expiration := *d.Expiration
+		d.Expiration = &expiration
+	}
+	if d.Variables != nil {
+		variables := make([]model.Variable, len(d.Variables))
+		for i, v := range d.Variables {
+			if v.Aggregation != nil {
+				aggregation := *v.Aggregation
+				v.Aggregation = &aggregation
+			}
+			variables[i] = v
+		}
+		d.Variables = variables
+	}
+	if d.Guarantees != nil {
+		guarantees := make([]model.Guarantee, len(d.Guarantees))
+		for i, g := range d.Guarantees {
+			if g.Penalties != nil {
+				g.Penalties = append([]model.PenaltyDef(nil), g.Penalties...)
+			}
+			guarantees[i] = g
+		}
+		d.Guarantees = guarantees
+	}
+	return d
+}
+
+func copyLastValues(values model.LastValues) model.LastValues {
+	if values == nil {
+		return nil
+	}
+	result := make(model.LastValues, len(values))
+	for k, v := range values {
+		result[k] = v
+	}
+	return result
+}
@@ -23,12 +23,20 @@ and remove/add methods as necessary.
 package memrepository
 
 import (
+	"SLALite/assessment"
+	"SLALite/assessment/monitor/dummyadapter"
 	"SLALite/model"
 	"SLALite/repositories"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 var repo model.IRepository
@@ -54,6 +62,7 @@ func TestRepository(t *testing.T) {
 	t.Run("CreateProvider", ctx.TestCreateProvider)
 	t.Run("CreateProviderExists", ctx.TestCreateProviderExists)
 	t.Run("GetAllProviders", ctx.TestGetAllProviders)
+	t.Run("GetProviders", ctx.TestGetProviders)
 	t.Run("GetProvider", ctx.TestGetProvider)
 	t.Run("GetProviderNotExists", ctx.TestGetProviderNotExists)
 	t.Run("DeleteProvider", ctx.TestDeleteProvider)
@@ -68,6 +77,7 @@ func TestRepository(t *testing.T) {
 	t.Run("UpdateAgreementState", ctx.TestUpdateAgreementState)
 	t.Run("UpdateAgreementStateNotExists", ctx.TestUpdateAgreementStateNotExists)
 	t.Run("GetAgreementsByState", ctx.TestGetAgreementsByState)
+	t.Run("GetAgreements", ctx.TestGetAgreements)
 	t.Run("UpdateAgreement", ctx.TestUpdateAgreement)
 	t.Run("UpdateAgreementNotExists", ctx.TestUpdateAgreementNotExists)
 	t.Run("DeleteAgreement", ctx.TestDeleteAgreement)
@@ -79,11 +89,269 @@ func TestRepository(t *testing.T) {
 
 	t.Run("GetViolation", ctx.TestGetViolation)
 	t.Run("GetViolationNotExists", ctx.TestGetViolationNotExists)
+	t.Run("GetViolations", ctx.TestGetViolations)
+
+	/* Warnings */
+	t.Run("CreateWarning", ctx.TestCreateWarning)
+	t.Run("CreateWarningExists", ctx.TestCreateWarningExists)
+	t.Run("GetWarningNotExists", ctx.TestGetWarningNotExists)
+	t.Run("GetWarnings", ctx.TestGetWarnings)
+
+	/* Penalties */
+	t.Run("CreatePenalty", ctx.TestCreatePenalty)
+	t.Run("CreatePenaltyExists", ctx.TestCreatePenaltyExists)
+	t.Run("GetPenaltyNotExists", ctx.TestGetPenaltyNotExists)
+	t.Run("GetPenalties", ctx.TestGetPenalties)
+
+	/* Subscriptions */
+	t.Run("CreateSubscription", ctx.TestCreateSubscription)
+	t.Run("CreateSubscriptionExists", ctx.TestCreateSubscriptionExists)
+	t.Run("GetSubscriptionNotExists", ctx.TestGetSubscriptionNotExists)
+	t.Run("GetAllSubscriptions", ctx.TestGetAllSubscriptions)
+	t.Run("UpdateSubscription", ctx.TestUpdateSubscription)
+	t.Run("UpdateSubscriptionNotExists", ctx.TestUpdateSubscriptionNotExists)
+	t.Run("DeleteSubscription", ctx.TestDeleteSubscription)
+	t.Run("DeleteSubscriptionNotExists", ctx.TestDeleteSubscriptionNotExists)
 
 	/* Templates */
 	t.Run("CreateTemplate", ctx.TestCreateTemplate)
 	t.Run("CreateTemplateExists", ctx.TestCreateTemplateExists)
 	t.Run("GetAllTemplates", ctx.TestGetAllTemplates)
+	t.Run("GetTemplates", ctx.TestGetTemplates)
 	t.Run("GetTemplate", ctx.TestGetTemplate)
 	t.Run("GetTemplateNotExists", ctx.TestGetTemplateNotExists)
+	t.Run("UpdateTemplate", ctx.TestUpdateTemplate)
+	t.Run("UpdateTemplateNotExists", ctx.TestUpdateTemplateNotExists)
+	t.Run("GetTemplateVersions", ctx.TestGetTemplateVersions)
+	t.Run("GetTemplateVersionsNotExists", ctx.TestGetTemplateVersionsNotExists)
+	t.Run("UpdateTemplateState", ctx.TestUpdateTemplateState)
+	t.Run("UpdateTemplateStateNotExists", ctx.TestUpdateTemplateStateNotExists)
+	t.Run("DeleteTemplate", ctx.TestDeleteTemplate)
+	t.Run("DeletedTemplateVersions", ctx.TestDeletedTemplateVersions)
+	t.Run("DeleteTemplateNotExists", ctx.TestDeleteTemplateNotExists)
+
+	/* Tenants */
+	t.Run("Tenants", ctx.TestTenants)
+}
+
+// TestConcurrentAccess is intended to be run with the race detector (go test -race).
+// It updates agreements from several goroutines while the assessment is running.
+func TestConcurrentAccess(t *testing.T) {
+	const n = 20
+	const iterations = 50
+
+	r, _ := New(nil)
+	newAgreement := func(id string) *model.Agreement {
+		return &model.Agreement{
+			Id:         id,
+			Name:       id,
+			State:      model.STARTED,
+			Assessment: &model.Assessment{},
+			Details: model.Details{
+				Id:       id,
+				Name:     id,
+				Type:     model.AGREEMENT,
+				Provider: model.Provider{Id: "p01", Name: "Provider01"},
+				Client:   model.Client{Id: "c01", Name: "Client01"},
+				Creation: time.Now(),
+				Guarantees: []model.Guarantee{
+					{Name: "gt", Constraint: "m < 0.5"},
+				},
+			},
+		}
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	/* assessment */
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ma := dummyadapter.New(3)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				assessment.AssessActiveAgreements(r, ma, nil)
+			}
+		}
+	}()
+
+	/* writers */
+	var writers sync.WaitGroup
+	for i := 0; i < n; i++ {
+		writers.Add(1)
+		go func(i int) {
+			defer writers.Done()
+			a := newAgreement(fmt.Sprintf("a%02d", i))
+			if _, err := r.CreateAgreement(a); err != nil {
+				t.Errorf("Error creating agreement %s: %v", a.Id, err)
+				return
+			}
+			for j := 0; j < iterations; j++ {
+				a.Assessment.LastExecution = time.Now()
+				a.Details.Guarantees[0].Constraint = fmt.Sprintf("m < %d", j)
+				if _, err := r.UpdateAgreement(a); err != nil {
+					t.Errorf("Error updating agreement %s: %v", a.Id, err)
+				}
+				v := model.Violation{Id: fmt.Sprintf("%s-%d", a.Id, j), AgreementId: a.Id, Guarantee: "gt"}
+				if _, err := r.CreateViolation(&v); err != nil {
+					t.Errorf("Error creating violation %s: %v", v.Id, err)
+				}
+			}
+		}(i)
+	}
+
+	/* readers */
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				as, _, _ := r.GetAgreements(model.AgreementQuery{States: []model.State{model.STARTED}})
+				for _, a := range as {
+					for _, gt := range a.Assessment.Guarantees {
+						_ = len(gt.LastValues)
+					}
+					a.Details.Guarantees[0].Constraint = ""
+				}
+				r.GetViolations(model.ViolationQuery{AgreementId: "a00"})
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(stop)
+	wg.Wait()
+
+	as, err := r.GetAllAgreements()
+	if err != nil || len(as) != n {
+		t.Fatalf("Unexpected agreements. Expected: %d; Actual: %d, err: %v", n, len(as), err)
+	}
+	for _, a := range as {
+		if a.Details.Guarantees[0].Constraint == "" {
+			t.Errorf("Agreement %s modified outside the repository", a.Id)
+		}
+	}
+	_, total, _ := r.GetViolations(model.ViolationQuery{})
+	if total != n*iterations {
+		t.Errorf("Unexpected violations. Expected: %d; Actual: %d", n*iterations, total)
+	}
+}
+
+func newSnapshotRepository(t *testing.T, path string, period string) MemRepository {
+	config := viper.New()
+	config.Set("snapshot", path)
+	config.Set("snapshot_period", period)
+	r, err := New(config)
+	if err != nil {
+		t.Fatalf("Error creating repository: %v", err)
+	}
+	return r
+}
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slalite-mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+
+	r := newSnapshotRepository(t, path, "1h")
+	r.CreateProvider(&model.Provider{Id: "p01", Name: "Provider01"})
+	r.CreateAgreement(&model.Agreement{Id: "a01", Name: "Agreement01", State: model.STARTED})
+	r.CreateTemplate(&model.Template{Id: "t01", Name: "Template01"})
+	r.UpdateTemplate(&model.Template{Id: "t01", Name: "Template01", State: model.STARTED})
+	r.CreateViolation(&model.Violation{Id: "v01", AgreementId: "a01", Guarantee: "gt"})
+	r.CreateWarning(&model.Warning{Id: "w01", AgreementId: "a01", Guarantee: "gt"})
+	r.CreatePenalty(&model.Penalty{Id: "pn01", AgreementId: "a01", Guarantee: "gt"})
+	r.CreateSubscription(&model.Subscription{Id: "s01", Url: "http://localhost"})
+	tr, _ := r.ForTenant("acme")
+	tr.CreateAgreement(&model.Agreement{Id: "a02", Name: "Agreement02", State: model.STOPPED})
+	if err := r.Close(); err != nil {
+		t.Fatalf("Error closing repository: %v", err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("Unexpected files in snapshot dir: %d", len(files))
+	}
+
+	r = newSnapshotRepository(t, path, "1h")
+	defer r.Close()
+	if _, err := r.GetProvider("p01"); err != nil {
+		t.Errorf("Provider not loaded: %v", err)
+	}
+	if a, err := r.GetAgreement("a01"); err != nil || a.State != model.STARTED {
+		t.Errorf("Agreement not loaded: %v", err)
+	}
+	if _, err := r.GetTemplate("t01"); err != nil {
+		t.Errorf("Template not loaded: %v", err)
+	}
+	if versions, err := r.GetTemplateVersions("t01"); err != nil || len(versions) != 2 || versions[1].Version != 2 {
+		t.Errorf("Template versions not loaded: %v, %v", versions, err)
+	}
+	if _, err := r.GetViolation("v01"); err != nil {
+		t.Errorf("Violation not loaded: %v", err)
+	}
+	if _, err := r.GetWarning("w01"); err != nil {
+		t.Errorf("Warning not loaded: %v", err)
+	}
+	if _, err := r.GetPenalty("pn01"); err != nil {
+		t.Errorf("Penalty not loaded: %v", err)
+	}
+	if _, err := r.GetSubscription("s01"); err != nil {
+		t.Errorf("Subscription not loaded: %v", err)
+	}
+	tr, _ = r.ForTenant("acme")
+	if a, err := tr.GetAgreement("a02"); err != nil || a.Tenant != "acme" {
+		t.Errorf("Agreement of tenant not loaded: %v", err)
+	}
+	if _, err := r.GetAgreement("a02"); err != model.ErrNotFound {
+		t.Errorf("Expected ErrNotFound. Actual: %v", err)
+	}
+}
+
+func TestPeriodicSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slalite-mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+
+	r := newSnapshotRepository(t, path, "10ms")
+	defer r.Close()
+	r.CreateProvider(&model.Provider{Id: "p01", Name: "Provider01"})
+
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		other := NewMemRepository(nil, nil, nil, nil, nil)
+		if err := other.load(path); err != nil {
+			t.Fatalf("Error loading snapshot: %v", err)
+		}
+		if _, err := other.GetProvider("p01"); err == nil {
+			return
+		}
+	}
+	t.Errorf("Snapshot not written")
+}
+
+func TestSnapshotCorrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slalite-mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+	ioutil.WriteFile(path, []byte(`{"providers": [`), 0600)
+
+	config := viper.New()
+	config.Set("snapshot", path)
+	if _, err := New(config); err == nil {
+		t.Errorf("Expected error loading a corrupted snapshot")
+	}
 }
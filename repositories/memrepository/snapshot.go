@@ -0,0 +1,244 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memrepository
+
+import (
+	"SLALite/model"
+	"SLALite/utils/fileutil"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// snapshot is the content of a snapshot file
+type snapshot struct {
+	Providers  []model.Provider  `json:"providers"`
+	Agreements []model.Agreement `json:"agreements"`
+	Templates  []model.Template  `json:"templates"`
+	Violations []model.Violation `json:"violations"`
+	Penalties  []model.Penalty   `json:"penalties"`
+	// Warnings is optional, to read snapshots written before warnings were stored
+	Warnings []model.Warning `json:"warnings,omitempty"`
+	// Subscriptions is optional, to read snapshots written before subscriptions existed
+	Subscriptions []model.Subscription `json:"subscriptions,omitempty"`
+	// TemplateVersions contains the revisions of the templates
+	TemplateVersions []model.Template `json:"template_versions,omitempty"`
+	// Tenants contains the entities of the tenants other than the default one
+	Tenants map[string]snapshot `json:"tenants,omitempty"`
+}
+
+// snapshotter keeps the state of the periodic snapshots of a repository
+type snapshotter struct {
+	path string
+	// mu serializes the writes of the snapshot file
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func newSnapshotter(path string) *snapshotter {
+	return &snapshotter{
+		path: path,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// runSnapshots writes a snapshot every period until the repository is closed.
+// Snapshots are only written on Close if period is not positive.
+func (r MemRepository) runSnapshots(period time.Duration) {
+	defer close(r.snapshot.done)
+
+	if period <= 0 {
+		<-r.snapshot.stop
+		return
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.snapshot.stop:
+			return
+		case <-ticker.C:
+			if err := r.Snapshot(); err != nil {
+				log.Errorf("Error writing snapshot %s: %v", r.snapshot.path, err)
+			}
+		}
+	}
+}
+
+/*
+Snapshot writes the content of the repository to the snapshot file.
+
+The file is replaced atomically, so it always contains a complete snapshot.
+It does nothing if the repository has no snapshot file configured.
+*/
+func (r MemRepository) Snapshot() error {
+	if r.snapshot == nil {
+		return nil
+	}
+
+	data, err := r.marshal()
+	if err != nil {
+		return err
+	}
+
+	r.snapshot.mu.Lock()
+	defer r.snapshot.mu.Unlock()
+	return fileutil.WriteFile(r.snapshot.path, data)
+}
+
+/*
+Close stops the periodic snapshots and writes a last snapshot.
+*/
+func (r MemRepository) Close() error {
+	if r.snapshot == nil {
+		return nil
+	}
+	r.snapshot.once.Do(func() {
+		close(r.snapshot.stop)
+		<-r.snapshot.done
+	})
+	return r.Snapshot()
+}
+
+func (r MemRepository) marshal() ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s := r.tenants[model.DefaultTenant].contents()
+	for tenant, repo := range r.tenants {
+		if tenant != model.DefaultTenant {
+			if s.Tenants == nil {
+				s.Tenants = make(map[string]snapshot)
+			}
+			s.Tenants[tenant] = repo.contents()
+		}
+	}
+	return json.Marshal(s)
+}
+
+// contents returns the entities of the tenant of r. r.mu must be locked.
+func (r MemRepository) contents() snapshot {
+	s := snapshot{
+		Providers:  make([]model.Provider, 0, len(r.providers)),
+		Agreements: make([]model.Agreement, 0, len(r.agreements)),
+		Templates:  make([]model.Template, 0, len(r.templates)),
+		Violations: make([]model.Violation, 0, len(r.violations)),
+		Penalties:  make([]model.Penalty, 0, len(r.penalties)),
+		Warnings:   make([]model.Warning, 0, len(r.warnings)),
+
+		Subscriptions: make([]model.Subscription, 0, len(r.subscriptions)),
+	}
+	for _, p := range r.providers {
+		s.Providers = append(s.Providers, p)
+	}
+	for _, a := range r.agreements {
+		s.Agreements = append(s.Agreements, a)
+	}
+	for _, t := range r.templates {
+		s.Templates = append(s.Templates, t)
+	}
+	for _, v := range r.violations {
+		s.Violations = append(s.Violations, v)
+	}
+	for _, p := range r.penalties {
+		s.Penalties = append(s.Penalties, p)
+	}
+	for _, w := range r.warnings {
+		s.Warnings = append(s.Warnings, w)
+	}
+	for _, sub := range r.subscriptions {
+		s.Subscriptions = append(s.Subscriptions, sub)
+	}
+	for _, versions := range r.templateVersions {
+		s.TemplateVersions = append(s.TemplateVersions, versions...)
+	}
+	return s
+}
+
+// load fills the repository with the content of the snapshot file, if it exists
+func (r MemRepository) load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Infof("Snapshot %s not found. Starting with an empty repository", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("Error reading snapshot %s: %v", path, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fill(s)
+	for tenant, ts := range s.Tenants {
+		if err := model.ValidateTenant(tenant); err != nil {
+			return fmt.Errorf("Error reading snapshot %s: %v", path, err)
+		}
+		r.tenantLocked(tenant).fill(ts)
+	}
+	log.Infof("Loaded snapshot %s: %d providers, %d agreements, %d templates, %d violations, %d warnings, "+
+		"%d penalties, %d subscriptions, %d other tenants", path, len(s.Providers), len(s.Agreements),
+		len(s.Templates), len(s.Violations), len(s.Warnings), len(s.Penalties), len(s.Subscriptions),
+		len(s.Tenants))
+	return nil
+}
+
+// fill adds the entities of s to r. r.mu must be locked.
+func (r MemRepository) fill(s snapshot) {
+	for _, p := range s.Providers {
+		r.providers[p.Id] = p
+	}
+	for _, a := range s.Agreements {
+		r.agreements[a.Id] = a
+	}
+	for _, t := range s.Templates {
+		r.templates[t.Id] = t
+	}
+	for _, v := range s.Violations {
+		r.violations[v.Id] = v
+	}
+	for _, p := range s.Penalties {
+		r.penalties[p.Id] = p
+	}
+	for _, w := range s.Warnings {
+		r.warnings[w.Id] = w
+	}
+	for _, sub := range s.Subscriptions {
+		r.subscriptions[sub.Id] = sub
+	}
+	for _, t := range s.TemplateVersions {
+		r.templateVersions[t.Id] = append(r.templateVersions[t.Id], t)
+	}
+	for _, versions := range r.templateVersions {
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	}
+	r.addMissingVersions()
+}
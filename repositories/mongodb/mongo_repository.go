@@ -16,12 +16,17 @@ limitations under the License.
 
 /*
 Package mongodb is an implementation of a model.IRepository backed up by a mongodb.
+
+The entities of each tenant other than the default one are stored in their own
+database, named after the configured database and the tenant (e.g. slalite_acme).
 */
 package mongodb
 
 import (
 	"SLALite/model"
 	"fmt"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -37,6 +42,13 @@ const (
 	repositoryDbName        string = "slalite"
 	providersCollectionName string = "Providers"
 	agreementCollectionName string = "Agreements"
+	violationCollectionName string = "Violations"
+	warningCollectionName   string = "Warnings"
+	penaltyCollectionName   string = "Penalties"
+	templateCollectionName  string = "Templates"
+
+	subscriptionCollectionName    string = "Subscriptions"
+	templateVersionCollectionName string = "TemplateVersions"
 
 	mongoConfigName string = "mongodb.yml"
 
@@ -49,6 +61,9 @@ const (
 type MongoDBRepository struct {
 	session  *mgo.Session
 	database *mgo.Database
+	// name is the configured database name, which prefixes the databases of the tenants
+	name   string
+	tenant string
 }
 
 // NewDefaultConfig gets a default configuration for a MongoDBRepository
@@ -104,10 +119,98 @@ func New(config *viper.Viper) (MongoDBRepository, error) {
 
 	repo.session = session
 	repo.database = database
+	repo.name = database.Name
+	repo.tenant = model.DefaultTenant
 
+	if err == nil {
+		err = repo.migrateIds(violationCollectionName, penaltyCollectionName)
+	}
 	return *repo, err
 }
 
+/*
+migrateIds moves the ids of the documents of collections saved in the "id" field
+(violations and penalties were saved so before their id was the "_id") to the "_id".
+*/
+func (r MongoDBRepository) migrateIds(collections ...string) error {
+	for _, name := range collections {
+		c := r.database.C(name)
+		iter := c.Find(bson.M{"id": bson.M{"$exists": true}}).Iter()
+		for {
+			var doc bson.M
+			if !iter.Next(&doc) {
+				break
+			}
+			old := doc["_id"]
+			doc["_id"] = doc["id"]
+			delete(doc, "id")
+
+			var err error
+			if old == doc["_id"] {
+				err = c.UpdateId(old, bson.M{"$unset": bson.M{"id": ""}})
+			} else if err = c.Insert(doc); err == nil {
+				err = c.RemoveId(old)
+			}
+			if err != nil {
+				iter.Close()
+				return fmt.Errorf("Error migrating the id of %v in %s: %v", doc["_id"], name, err)
+			}
+		}
+		if err := iter.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+ForTenant returns a repository of the entities of tenant, stored in the database
+<database>_<tenant> of the same session.
+*/
+func (r MongoDBRepository) ForTenant(tenant string) (model.IRepository, error) {
+	if err := model.ValidateTenant(tenant); err != nil {
+		return nil, err
+	}
+	r.tenant = tenant
+	r.database = r.session.DB(r.name)
+	if tenant != model.DefaultTenant {
+		r.database = r.session.DB(r.name + "_" + tenant)
+	}
+	return r, nil
+}
+
+/*
+GetTenants returns the tenants that have a database in the server, sorted by id.
+*/
+func (r MongoDBRepository) GetTenants() ([]string, error) {
+	names, err := r.session.DatabaseNames()
+	if err != nil {
+		return nil, err
+	}
+	result := []string{model.DefaultTenant}
+	for _, name := range names {
+		if strings.HasPrefix(name, r.name+"_") {
+			result = append(result, strings.TrimPrefix(name, r.name+"_"))
+		}
+	}
+	return result, nil
+}
+
+/*
+Check implements health.Checker, returning an error if the database does not answer a ping
+*/
+func (r MongoDBRepository) Check() error {
+	return r.session.Ping()
+}
+
+/*
+Close closes the session to the database
+*/
+func (r MongoDBRepository) Close() error {
+	r.session.Close()
+	return nil
+}
+
 func logConfig(config *viper.Viper) {
 	log.Printf("MongoDB configuration\n"+
 		"\tconnectionURL: %v\n"+
@@ -122,6 +225,59 @@ func (r MongoDBRepository) getList(collection string, query, result interface{})
 	return result, err
 }
 
+func (r MongoDBRepository) getPage(collection string, query interface{}, sort []string,
+	page model.Page, result interface{}) (interface{}, int, error) {
+
+	total, err := r.database.C(collection).Find(query).Count()
+	if err != nil {
+		return result, 0, err
+	}
+	q := r.database.C(collection).Find(query).Sort(sort...).Skip(page.Offset)
+	if page.Limit > 0 {
+		q = q.Limit(page.Limit)
+	}
+	err = q.All(result)
+	return result, total, err
+}
+
+// sortFields are the names of the document fields to sort by
+var sortFields = map[string]string{
+	model.FieldId:         "_id",
+	model.FieldName:       "name",
+	model.FieldState:      "state",
+	model.FieldProvider:   "details.provider._id",
+	model.FieldClient:     "details.client._id",
+	model.FieldCreation:   "details.creation",
+	model.FieldExpiration: "details.expiration",
+}
+
+// toMongoSort converts a model.Sort to the mgo sort format, adding the id as last key
+func toMongoSort(sort model.Sort) []string {
+	result := make([]string, 0, len(sort)+1)
+	for _, key := range sort {
+		field := sortFields[key.Field]
+		if key.Desc {
+			field = "-" + field
+		}
+		result = append(result, field)
+	}
+	return append(result, "_id")
+}
+
+// addInterval adds to query a filter for field in the interval [from, to)
+func addInterval(query bson.M, field string, from, to time.Time) {
+	interval := bson.M{}
+	if !from.IsZero() {
+		interval["$gte"] = from
+	}
+	if !to.IsZero() {
+		interval["$lt"] = to
+	}
+	if len(interval) > 0 {
+		query[field] = interval
+	}
+}
+
 func (r MongoDBRepository) getAll(collection string, result interface{}) (interface{}, error) {
 	return r.getList(collection, bson.M{}, result)
 }
@@ -172,6 +328,18 @@ func (r MongoDBRepository) GetAllProviders() (model.Providers, error) {
 	return *((res).(*model.Providers)), err
 }
 
+/*
+GetProviders returns the page of providers specified in the query, and the total
+number of providers.
+
+The list is empty when there are no providers in the page;
+error != nil on error
+*/
+func (r MongoDBRepository) GetProviders(q model.ProviderQuery) (model.Providers, int, error) {
+	res, total, err := r.getPage(providersCollectionName, bson.M{}, toMongoSort(q.Sort), q.Page, new(model.Providers))
+	return *((res).(*model.Providers)), total, err
+}
+
 /*
 GetProvider returns the Provider identified by id.
 
@@ -190,6 +358,7 @@ error != nil on error;
 error is sql.ErrNoRows if the provider already exists
 */
 func (r MongoDBRepository) CreateProvider(provider *model.Provider) (*model.Provider, error) {
+	provider.Tenant = r.tenant
 	res, err := r.create(providersCollectionName, provider)
 	return res.(*model.Provider), err
 }
@@ -215,6 +384,31 @@ func (r MongoDBRepository) GetAllAgreements() (model.Agreements, error) {
 	return *((res).(*model.Agreements)), err
 }
 
+/*
+GetAgreements returns the page of agreements that match the query, and the total
+number of matching agreements.
+
+The list is empty when there are no matching agreements in the page;
+error != nil on error
+*/
+func (r MongoDBRepository) GetAgreements(q model.AgreementQuery) (model.Agreements, int, error) {
+	query := bson.M{}
+	if q.ProviderId != "" {
+		query["details.provider._id"] = q.ProviderId
+	}
+	if q.ClientId != "" {
+		query["details.client._id"] = q.ClientId
+	}
+	if len(q.States) > 0 {
+		query["state"] = bson.M{"$in": q.States}
+	}
+	addInterval(query, "details.creation", q.CreationFrom, q.CreationTo)
+	addInterval(query, "details.expiration", q.ExpirationFrom, q.ExpirationTo)
+
+	res, total, err := r.getPage(agreementCollectionName, query, toMongoSort(q.Sort), q.Page, new(model.Agreements))
+	return *((res).(*model.Agreements)), total, err
+}
+
 /*
 GetAgreement returns the Agreement identified by id.
 
@@ -246,6 +440,7 @@ error != nil on error;
 error is sql.ErrNoRows if the Agreement already exists
 */
 func (r MongoDBRepository) CreateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	agreement.Tenant = r.tenant
 	res, err := r.create(agreementCollectionName, agreement)
 	return res.(*model.Agreement), err
 }
@@ -254,6 +449,7 @@ func (r MongoDBRepository) CreateAgreement(agreement *model.Agreement) (*model.A
 UpdateAgreement updates the information of an already saved instance of an agreement
 */
 func (r MongoDBRepository) UpdateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	agreement.Tenant = r.tenant
 	err := r.update(agreementCollectionName, agreement.Id, agreement)
 	return agreement, err
 }
@@ -275,7 +471,9 @@ error != nil on error;
 error is sql.ErrNoRows if the Violation already exists
 */
 func (r MongoDBRepository) CreateViolation(v *model.Violation) (*model.Violation, error) {
-	return nil, fmt.Errorf("Not implemented")
+	v.Tenant = r.tenant
+	res, err := r.create(violationCollectionName, v)
+	return res.(*model.Violation), err
 }
 
 /*
@@ -285,7 +483,120 @@ error != nil on error;
 error is sql.ErrNoRows if the Violation is not found
 */
 func (r MongoDBRepository) GetViolation(id string) (*model.Violation, error) {
-	return nil, fmt.Errorf("Not implemented")
+	res, err := r.get(violationCollectionName, id, new(model.Violation))
+	return res.(*model.Violation), err
+}
+
+/*
+GetViolations returns the page of violations that match the query, sorted by datetime,
+and the total number of matching violations.
+
+The list is empty when there are no matching violations in the page;
+error != nil on error
+*/
+func (r MongoDBRepository) GetViolations(q model.ViolationQuery) (model.Violations, int, error) {
+	output := new(model.Violations)
+
+	query := bson.M{}
+	if q.AgreementId != "" {
+		query["agreementid"] = q.AgreementId
+	}
+	if q.Guarantee != "" {
+		query["guarantee"] = q.Guarantee
+	}
+	addInterval(query, "datetime", q.From, q.To)
+	result, total, err := r.getPage(violationCollectionName, query, []string{"datetime", "_id"}, q.Page, output)
+	return *((result).(*model.Violations)), total, err
+}
+
+/*
+CreateWarning stores a new Warning.
+
+error != nil on error;
+error is sql.ErrNoRows if the Warning already exists
+*/
+func (r MongoDBRepository) CreateWarning(w *model.Warning) (*model.Warning, error) {
+	w.Tenant = r.tenant
+	res, err := r.create(warningCollectionName, w)
+	return res.(*model.Warning), err
+}
+
+/*
+GetWarning returns the Warning identified by id.
+
+error != nil on error;
+error is sql.ErrNoRows if the Warning is not found
+*/
+func (r MongoDBRepository) GetWarning(id string) (*model.Warning, error) {
+	res, err := r.get(warningCollectionName, id, new(model.Warning))
+	return res.(*model.Warning), err
+}
+
+/*
+GetWarnings returns the page of warnings that match the query, sorted by datetime,
+and the total number of matching warnings.
+
+The list is empty when there are no matching warnings in the page;
+error != nil on error
+*/
+func (r MongoDBRepository) GetWarnings(q model.WarningQuery) (model.Warnings, int, error) {
+	output := new(model.Warnings)
+
+	query := bson.M{}
+	if q.AgreementId != "" {
+		query["agreementid"] = q.AgreementId
+	}
+	if q.Guarantee != "" {
+		query["guarantee"] = q.Guarantee
+	}
+	addInterval(query, "datetime", q.From, q.To)
+	result, total, err := r.getPage(warningCollectionName, query, []string{"datetime", "_id"}, q.Page, output)
+	return *((result).(*model.Warnings)), total, err
+}
+
+/*
+CreatePenalty stores a new Penalty.
+
+error != nil on error;
+error is sql.ErrNoRows if the Penalty already exists
+*/
+func (r MongoDBRepository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+	p.Tenant = r.tenant
+	res, err := r.create(penaltyCollectionName, p)
+	return res.(*model.Penalty), err
+}
+
+/*
+GetPenalty returns the Penalty identified by id.
+
+error != nil on error;
+error is sql.ErrNoRows if the Penalty is not found
+*/
+func (r MongoDBRepository) GetPenalty(id string) (*model.Penalty, error) {
+	res, err := r.get(penaltyCollectionName, id, new(model.Penalty))
+	return res.(*model.Penalty), err
+}
+
+/*
+GetPenalties returns the page of penalties that match the query, sorted by datetime,
+and the total number of matching penalties.
+
+The list is empty when there are no matching penalties in the page;
+error != nil on error
+*/
+func (r MongoDBRepository) GetPenalties(q model.PenaltyQuery) (model.Penalties, int, error) {
+	output := new(model.Penalties)
+
+	query := bson.M{}
+	if q.AgreementId != "" {
+		query["agreementid"] = q.AgreementId
+	}
+	if q.Guarantee != "" {
+		query["guarantee"] = q.Guarantee
+	}
+	addInterval(query, "datetime", q.From, q.To)
+	result, total, err := r.getPage(penaltyCollectionName, query, []string{"datetime", "_id"}, q.Page, output)
+	return *((result).(*model.Penalties)), total, err
 }
 
 /*
@@ -310,7 +621,28 @@ The list is empty when there are no templates;
 error != nil on error
 */
 func (r MongoDBRepository) GetAllTemplates() (model.Templates, error) {
-	return nil, fmt.Errorf("Not implemented")
+	res, err := r.getAll(templateCollectionName, new(model.Templates))
+	return *((res).(*model.Templates)), err
+}
+
+/*
+GetTemplates returns the page of templates that match the query, and the total
+number of matching templates.
+
+The list is empty when there are no matching templates in the page;
+error != nil on error
+*/
+func (r MongoDBRepository) GetTemplates(q model.TemplateQuery) (model.Templates, int, error) {
+	query := bson.M{}
+	if q.ProviderId != "" {
+		query["details.provider._id"] = q.ProviderId
+	}
+	if len(q.States) > 0 {
+		query["state"] = bson.M{"$in": q.States}
+	}
+
+	res, total, err := r.getPage(templateCollectionName, query, toMongoSort(q.Sort), q.Page, new(model.Templates))
+	return *((res).(*model.Templates)), total, err
 }
 
 /*
@@ -320,15 +652,184 @@ error != nil on error;
 error is sql.ErrNoRows if the Template is not found
 */
 func (r MongoDBRepository) GetTemplate(id string) (*model.Template, error) {
-	return nil, fmt.Errorf("Not implemented")
+	res, err := r.get(templateCollectionName, id, new(model.Template))
+	return res.(*model.Template), err
 }
 
 /*
-CreateTemplate stores a new Template.
+CreateTemplate stores a new Template, with version 1, or the next version of the
+revisions of a deleted Template with the same id.
 
 error != nil on error;
 error is sql.ErrNoRows if the Template already exists
 */
 func (r MongoDBRepository) CreateTemplate(template *model.Template) (*model.Template, error) {
-	return nil, fmt.Errorf("Not implemented")
+	var last templateVersion
+	err := r.database.C(templateVersionCollectionName).
+		Find(bson.M{"template._id": template.Id}).Sort("-template.version").One(&last)
+	if err != nil && err != mgo.ErrNotFound {
+		return template, err
+	}
+	template.Tenant = r.tenant
+	template.Version = last.Template.Version + 1
+	res, err := r.create(templateCollectionName, template)
+	if err != nil {
+		return res.(*model.Template), err
+	}
+	err = r.createTemplateVersion(template)
+	return template, err
+}
+
+/*
+UpdateTemplate updates the information of an already saved instance of a template,
+storing it as a new revision with the next version.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist
+*/
+func (r MongoDBRepository) UpdateTemplate(template *model.Template) (*model.Template, error) {
+	current, err := r.GetTemplate(template.Id)
+	if err != nil {
+		return template, err
+	}
+	template.Tenant = r.tenant
+	template.Version = current.Version + 1
+	if err = r.update(templateCollectionName, template.Id, template); err != nil {
+		return template, err
+	}
+	err = r.createTemplateVersion(template)
+	return template, err
+}
+
+/*
+DeleteTemplate deletes from the repository the Template whose id is template.Id.
+Its revisions are kept.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist.
+*/
+func (r MongoDBRepository) DeleteTemplate(template *model.Template) error {
+	versions, err := r.GetTemplateVersions(template.Id)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 1 && versions[0].Version == 0 {
+		/* the template was stored before templates were versioned: keep it as revision */
+		versions[0].Version = 1
+		if err := r.createTemplateVersion(&versions[0]); err != nil {
+			return err
+		}
+	}
+	return r.delete(templateCollectionName, template.Id)
+}
+
+/*
+GetTemplateVersions returns the revisions of the Template identified by id,
+sorted by version, even if the Template has been deleted.
+
+error != nil on error;
+error is model.ErrNotFound if the Template never existed
+*/
+func (r MongoDBRepository) GetTemplateVersions(id string) (model.Templates, error) {
+	var versions []templateVersion
+	err := r.database.C(templateVersionCollectionName).
+		Find(bson.M{"template._id": id}).Sort("template.version").All(&versions)
+	if err != nil {
+		return nil, err
+	}
+	result := make(model.Templates, 0, len(versions))
+	for _, v := range versions {
+		result = append(result, v.Template)
+	}
+	if len(result) == 0 {
+		/* the template does not exist, or was stored before templates were versioned */
+		t, err := r.GetTemplate(id)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *t)
+	}
+	return result, nil
+}
+
+/*
+UpdateTemplateState changes the state of a Template, without storing a new revision.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist
+*/
+func (r MongoDBRepository) UpdateTemplateState(id string, newState model.State) (*model.Template, error) {
+	err := r.update(templateCollectionName, id, bson.M{"$set": bson.M{"state": newState}})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetTemplate(id)
+}
+
+// templateVersion is the document of a revision of a template
+type templateVersion struct {
+	Id       string         `bson:"_id"`
+	Template model.Template `bson:"template"`
+}
+
+// createTemplateVersion stores the revision t of a template, which must not exist
+func (r MongoDBRepository) createTemplateVersion(t *model.Template) error {
+	v := templateVersion{Id: fmt.Sprintf("%s:%d", t.Id, t.Version), Template: *t}
+	return r.database.C(templateVersionCollectionName).Insert(v)
+}
+
+/*
+GetAllSubscriptions returns the list of subscriptions.
+
+The list is empty when there are no subscriptions;
+error != nil on error
+*/
+func (r MongoDBRepository) GetAllSubscriptions() (model.Subscriptions, error) {
+	res, err := r.getAll(subscriptionCollectionName, new(model.Subscriptions))
+	return *((res).(*model.Subscriptions)), err
+}
+
+/*
+GetSubscription returns the Subscription identified by id.
+
+error != nil on error;
+error is sql.ErrNoRows if the Subscription is not found
+*/
+func (r MongoDBRepository) GetSubscription(id string) (*model.Subscription, error) {
+	res, err := r.get(subscriptionCollectionName, id, new(model.Subscription))
+	return res.(*model.Subscription), err
+}
+
+/*
+CreateSubscription stores a new Subscription.
+
+error != nil on error;
+error is sql.ErrNoRows if the Subscription already exists
+*/
+func (r MongoDBRepository) CreateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	s.Tenant = r.tenant
+	res, err := r.create(subscriptionCollectionName, s)
+	return res.(*model.Subscription), err
+}
+
+/*
+UpdateSubscription updates an already saved Subscription.
+
+error != nil on error;
+error is sql.ErrNoRows if the Subscription does not exist
+*/
+func (r MongoDBRepository) UpdateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	s.Tenant = r.tenant
+	err := r.update(subscriptionCollectionName, s.Id, s)
+	return s, err
+}
+
+/*
+DeleteSubscription deletes from the repository the Subscription whose id is s.Id.
+
+error != nil on error;
+error is sql.ErrNoRows if the Subscription does not exist.
+*/
+func (r MongoDBRepository) DeleteSubscription(s *model.Subscription) error {
+	return r.delete(subscriptionCollectionName, s.Id)
 }
@@ -72,6 +72,7 @@ func TestRepository(t *testing.T) {
 	t.Run("CreateProvider", ctx.TestCreateProvider)
 	t.Run("CreateProviderExists", ctx.TestCreateProviderExists)
 	t.Run("GetAllProviders", ctx.TestGetAllProviders)
+	t.Run("GetProviders", ctx.TestGetProviders)
 	t.Run("GetProvider", ctx.TestGetProvider)
 	t.Run("GetProviderNotExists", ctx.TestGetProviderNotExists)
 	t.Run("DeleteProvider", ctx.TestDeleteProvider)
@@ -86,22 +87,56 @@ func TestRepository(t *testing.T) {
 	t.Run("UpdateAgreementState", ctx.TestUpdateAgreementState)
 	t.Run("UpdateAgreementStateNotExists", ctx.TestUpdateAgreementStateNotExists)
 	t.Run("GetAgreementsByState", ctx.TestGetAgreementsByState)
+	t.Run("GetAgreements", ctx.TestGetAgreements)
 	t.Run("UpdateAgreement", ctx.TestUpdateAgreement)
 	t.Run("UpdateAgreementNotExists", ctx.TestUpdateAgreementNotExists)
 	t.Run("DeleteAgreement", ctx.TestDeleteAgreement)
 	t.Run("DeleteAgreementNotExists", ctx.TestDeleteAgreementNotExists)
 
 	/* Violations */
-	// t.Run("CreateViolation", ctx.TestCreateViolation)
-	// t.Run("CreateViolationExists", ctx.TestCreateViolationExists)
-
-	// t.Run("GetViolation", ctx.TestGetViolation)
-	// t.Run("GetViolationNotExists", ctx.TestGetViolationNotExists)
+	t.Run("CreateViolation", ctx.TestCreateViolation)
+	t.Run("CreateViolationExists", ctx.TestCreateViolationExists)
+
+	t.Run("GetViolation", ctx.TestGetViolation)
+	t.Run("GetViolationNotExists", ctx.TestGetViolationNotExists)
+	t.Run("GetViolations", ctx.TestGetViolations)
+
+	/* Warnings */
+	t.Run("CreateWarning", ctx.TestCreateWarning)
+	t.Run("CreateWarningExists", ctx.TestCreateWarningExists)
+	t.Run("GetWarningNotExists", ctx.TestGetWarningNotExists)
+	t.Run("GetWarnings", ctx.TestGetWarnings)
+
+	/* Penalties */
+	t.Run("CreatePenalty", ctx.TestCreatePenalty)
+	t.Run("CreatePenaltyExists", ctx.TestCreatePenaltyExists)
+	t.Run("GetPenaltyNotExists", ctx.TestGetPenaltyNotExists)
+	t.Run("GetPenalties", ctx.TestGetPenalties)
+
+	/* Subscriptions */
+	t.Run("CreateSubscription", ctx.TestCreateSubscription)
+	t.Run("CreateSubscriptionExists", ctx.TestCreateSubscriptionExists)
+	t.Run("GetSubscriptionNotExists", ctx.TestGetSubscriptionNotExists)
+	t.Run("GetAllSubscriptions", ctx.TestGetAllSubscriptions)
+	t.Run("UpdateSubscription", ctx.TestUpdateSubscription)
+	t.Run("UpdateSubscriptionNotExists", ctx.TestUpdateSubscriptionNotExists)
+	t.Run("DeleteSubscription", ctx.TestDeleteSubscription)
+	t.Run("DeleteSubscriptionNotExists", ctx.TestDeleteSubscriptionNotExists)
 
 	/* Templates */
-	// t.Run("CreateTemplate", ctx.TestCreateTemplate)
-	// t.Run("CreateTemplateExists", ctx.TestCreateTemplateExists)
-	// t.Run("GetAllTemplates", ctx.TestGetAllTemplates)
-	// t.Run("GetTemplate", ctx.TestGetTemplate)
-	// t.Run("GetTemplateNotExists", ctx.TestGetTemplateNotExists)
+	t.Run("CreateTemplate", ctx.TestCreateTemplate)
+	t.Run("CreateTemplateExists", ctx.TestCreateTemplateExists)
+	t.Run("GetAllTemplates", ctx.TestGetAllTemplates)
+	t.Run("GetTemplates", ctx.TestGetTemplates)
+	t.Run("GetTemplate", ctx.TestGetTemplate)
+	t.Run("GetTemplateNotExists", ctx.TestGetTemplateNotExists)
+	t.Run("UpdateTemplate", ctx.TestUpdateTemplate)
+	t.Run("UpdateTemplateNotExists", ctx.TestUpdateTemplateNotExists)
+	t.Run("GetTemplateVersions", ctx.TestGetTemplateVersions)
+	t.Run("GetTemplateVersionsNotExists", ctx.TestGetTemplateVersionsNotExists)
+	t.Run("UpdateTemplateState", ctx.TestUpdateTemplateState)
+	t.Run("UpdateTemplateStateNotExists", ctx.TestUpdateTemplateStateNotExists)
+	t.Run("DeleteTemplate", ctx.TestDeleteTemplate)
+	t.Run("DeletedTemplateVersions", ctx.TestDeletedTemplateVersions)
+	t.Run("DeleteTemplateNotExists", ctx.TestDeleteTemplateNotExists)
 }
@@ -35,7 +35,14 @@ type TestData struct {
 	A03        model.Agreement
 	Anotexists model.Agreement
 	V01        model.Violation
+	V02        model.Violation
 	Vnotexists model.Violation
+	W01        model.Warning
+	W02        model.Warning
+	PN01       model.Penalty
+	PN02       model.Penalty
+	S01        model.Subscription
+	Snotexists model.Subscription
 	T01        model.Template
 }
 
@@ -58,6 +65,7 @@ var Data = TestData{
 		Name:       "Agreement01",
 		State:      model.STOPPED,
 		Assessment: &model.Assessment{},
+		Template:   &model.TemplateRef{Id: "t01", Version: 1},
 	},
 	A02: model.Agreement{
 		Id:         "a02",
@@ -87,13 +95,73 @@ var Data = TestData{
 			model.MetricValue{DateTime: time.Now(), Key: "t", Value: 101},
 		},
 	},
+	V02: model.Violation{
+		Id:          "v02",
+		AgreementId: "a01",
+		Datetime:    time.Now().Add(-1 * time.Hour),
+		Constraint:  "u < 10",
+		Guarantee:   "gt2",
+		Values: []model.MetricValue{
+			model.MetricValue{DateTime: time.Now().Add(-1 * time.Hour), Key: "u", Value: 11},
+		},
+	},
 	Vnotexists: model.Violation{
 		Id:          "vnotexists",
 		AgreementId: "a01",
 	},
+	W01: model.Warning{
+		Id:          "w01",
+		AgreementId: "a01",
+		Guarantee:   "gt1",
+		Datetime:    time.Now(),
+		Expression:  "u < 8",
+		Values: []model.MetricValue{
+			model.MetricValue{DateTime: time.Now(), Key: "u", Value: 9},
+		},
+	},
+	W02: model.Warning{
+		Id:          "w02",
+		AgreementId: "a01",
+		Guarantee:   "gt2",
+		Datetime:    time.Now().Add(-1 * time.Hour),
+		Expression:  "u < 8",
+		Values: []model.MetricValue{
+			model.MetricValue{DateTime: time.Now().Add(-1 * time.Hour), Key: "u", Value: 8},
+		},
+	},
+	PN01: model.Penalty{
+		Id:          "pn01",
+		AgreementId: "a01",
+		Guarantee:   "gt1",
+		ViolationId: "v01",
+		Datetime:    time.Now(),
+		Definition:  model.PenaltyDef{Type: "discount", Value: "10", Unit: "%"},
+	},
+	PN02: model.Penalty{
+		Id:          "pn02",
+		AgreementId: "a01",
+		Guarantee:   "gt2",
+		ViolationId: "v02",
+		Datetime:    time.Now().Add(-1 * time.Hour),
+		Definition:  model.PenaltyDef{Type: "discount", Value: "5", Unit: "euro"},
+	},
+	S01: model.Subscription{
+		Id:          "s01",
+		Url:         "http://localhost/s01",
+		AgreementId: "a01",
+		Events:      []model.EventType{model.VIOLATION, model.WARNING},
+		Secret:      "s01-secret",
+	},
+	Snotexists: model.Subscription{
+		Id:  "snotexists",
+		Url: "http://localhost/snotexists",
+	},
 	T01: model.Template{
 		Id:   "t01",
 		Name: "Template01",
+		Parameters: map[string]model.Parameter{
+			"M": {Type: model.NumberType, Default: 0.5, Constraint: "M > 0"},
+		},
 	},
 }
 
@@ -154,6 +222,20 @@ func (r *TestContext) TestGetAllProviders(t *testing.T) {
 	assertEquals(t, "Unexpected len(providers). Expected: %d; Actual: %d", 2, len(actual))
 }
 
+// TestGetProviders executes this test
+func (r *TestContext) TestGetProviders(t *testing.T) {
+	actual, total, err := r.Repo.GetProviders(model.ProviderQuery{
+		Sort: model.Sort{{Field: model.FieldName, Desc: true}},
+		Page: model.Page{Limit: 1},
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(providers). Expected: %d; Actual: %d", 1, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 2, total)
+	if len(actual) == 1 {
+		assertEquals(t, "Unexpected provider. Expected: %v; Actual: %v", Data.P02.Id, actual[0].Id)
+	}
+}
+
 // TestGetProvider executes this test
 func (r *TestContext) TestGetProvider(t *testing.T) {
 	actual, err := r.Repo.GetProvider(Data.P01.Id)
@@ -210,11 +292,44 @@ func (r *TestContext) TestGetAllAgreements(t *testing.T) {
 	assertEquals(t, "Unexpected len(Agreements). Expected: %d; Actual: %d", 3, len(actual))
 }
 
+// TestGetAgreements executes this test
+func (r *TestContext) TestGetAgreements(t *testing.T) {
+	actual, total, err := r.Repo.GetAgreements(model.AgreementQuery{
+		Sort: model.Sort{{Field: model.FieldId, Desc: true}},
+		Page: model.Page{Limit: 2},
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(agreements). Expected: %d; Actual: %d", 2, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 3, total)
+	if len(actual) == 2 {
+		assertEquals(t, "Unexpected order. Expected: %v; Actual: %v", Data.A03.Id, actual[0].Id)
+		assertEquals(t, "Unexpected order. Expected: %v; Actual: %v", Data.A02.Id, actual[1].Id)
+	}
+
+	actual, total, err = r.Repo.GetAgreements(model.AgreementQuery{
+		States: []model.State{model.TERMINATED},
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 1, total)
+	if len(actual) == 1 {
+		assertEquals(t, "Unexpected agreement. Expected: %v; Actual: %v", Data.A03.Id, actual[0].Id)
+	}
+
+	actual, total, err = r.Repo.GetAgreements(model.AgreementQuery{
+		ProviderId: Data.Pnotexists.Id,
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(agreements). Expected: %d; Actual: %d", 0, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 0, total)
+}
+
 // TestGetAgreement executes this test
 func (r *TestContext) TestGetAgreement(t *testing.T) {
 	result, err := r.Repo.GetAgreement(Data.A01.Id)
 	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
 	assertEquals(t, "Unexpected result. Expected: %v; Actual: %v", Data.A01.Id, result.Id)
+	assertEquals(t, "Unexpected template. Expected: %v; Actual: %v",
+		fmt.Sprint(Data.A01.Template), fmt.Sprint(result.Template))
 }
 
 // TestGetAgreementNotExists executes this test
@@ -336,6 +451,254 @@ func (r *TestContext) TestGetViolationNotExists(t *testing.T) {
 	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
 }
 
+// TestGetViolations executes this test
+func (r *TestContext) TestGetViolations(t *testing.T) {
+	Data.V02.AgreementId = Data.A01.Id
+	v, err := r.Repo.CreateViolation(&Data.V02)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	Data.V02 = *v
+
+	actual, total, err := r.Repo.GetViolations(model.ViolationQuery{AgreementId: Data.A01.Id})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 2, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 2, total)
+	if len(actual) == 2 {
+		assertEquals(t, "Unexpected order. Expected: %v; Actual: %v", Data.V02.Id, actual[0].Id)
+	}
+
+	actual, _, err = r.Repo.GetViolations(model.ViolationQuery{AgreementId: Data.A01.Id, Guarantee: "gt1"})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 1, len(actual))
+
+	actual, _, err = r.Repo.GetViolations(model.ViolationQuery{
+		AgreementId: Data.A01.Id,
+		From:        Data.V02.Datetime.Add(time.Minute),
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 1, len(actual))
+
+	actual, _, err = r.Repo.GetViolations(model.ViolationQuery{
+		AgreementId: Data.A01.Id,
+		To:          Data.V02.Datetime.Add(time.Minute),
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 1, len(actual))
+
+	actual, total, err = r.Repo.GetViolations(model.ViolationQuery{
+		AgreementId: Data.A01.Id,
+		Page:        model.Page{Offset: 1, Limit: 1},
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 1, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 2, total)
+	if len(actual) == 1 {
+		assertEquals(t, "Unexpected violation. Expected: %v; Actual: %v", Data.V01.Id, actual[0].Id)
+	}
+
+	actual, total, err = r.Repo.GetViolations(model.ViolationQuery{AgreementId: Data.Anotexists.Id})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(violations). Expected: %d; Actual: %d", 0, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 0, total)
+}
+
+// TestCreateWarning executes this test
+func (r *TestContext) TestCreateWarning(t *testing.T) {
+	// When on externalId repo, we have to sync w.AgreementId
+	Data.W01.AgreementId = Data.A01.Id
+	w, err := r.Repo.CreateWarning(&Data.W01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	Data.W01 = *w
+
+	w, err = r.Repo.GetWarning(Data.W01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected warning. Expected: %v; Actual: %v", Data.W01.Id, w.Id)
+	assertEquals(t, "Unexpected warning. Expected: %v; Actual: %v", Data.W01.Expression, w.Expression)
+	assertEquals(t, "Unexpected len(values). Expected: %d; Actual: %d", len(Data.W01.Values), len(w.Values))
+}
+
+// TestCreateWarningExists executes this test
+func (r *TestContext) TestCreateWarningExists(t *testing.T) {
+	_, err := r.Repo.CreateWarning(&Data.W01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrAlreadyExist, err)
+}
+
+// TestGetWarningNotExists executes this test
+func (r *TestContext) TestGetWarningNotExists(t *testing.T) {
+	_, err := r.Repo.GetWarning("notexists")
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestGetWarnings executes this test
+func (r *TestContext) TestGetWarnings(t *testing.T) {
+	Data.W02.AgreementId = Data.A01.Id
+	w, err := r.Repo.CreateWarning(&Data.W02)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	Data.W02 = *w
+
+	actual, total, err := r.Repo.GetWarnings(model.WarningQuery{AgreementId: Data.A01.Id})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(warnings). Expected: %d; Actual: %d", 2, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 2, total)
+	if len(actual) == 2 {
+		assertEquals(t, "Unexpected order. Expected: %v; Actual: %v", Data.W02.Id, actual[0].Id)
+	}
+
+	actual, _, err = r.Repo.GetWarnings(model.WarningQuery{AgreementId: Data.A01.Id, Guarantee: "gt1"})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(warnings). Expected: %d; Actual: %d", 1, len(actual))
+
+	actual, total, err = r.Repo.GetWarnings(model.WarningQuery{
+		AgreementId: Data.A01.Id,
+		To:          Data.W02.Datetime.Add(time.Minute),
+		Page:        model.Page{Limit: 5},
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(warnings). Expected: %d; Actual: %d", 1, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 1, total)
+
+	actual, total, err = r.Repo.GetWarnings(model.WarningQuery{AgreementId: Data.Anotexists.Id})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(warnings). Expected: %d; Actual: %d", 0, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 0, total)
+}
+
+// TestCreatePenalty executes this test
+func (r *TestContext) TestCreatePenalty(t *testing.T) {
+	// When on externalId repo, we have to sync p.AgreementId
+	Data.PN01.AgreementId = Data.A01.Id
+	p, err := r.Repo.CreatePenalty(&Data.PN01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	Data.PN01 = *p
+
+	p, err = r.Repo.GetPenalty(Data.PN01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected penalty. Expected: %v; Actual: %v", Data.PN01.Id, p.Id)
+	assertEquals(t, "Unexpected penalty. Expected: %v; Actual: %v", Data.PN01.ViolationId, p.ViolationId)
+	assertEquals(t, "Unexpected penalty. Expected: %v; Actual: %v", Data.PN01.Definition, p.Definition)
+}
+
+// TestCreatePenaltyExists executes this test
+func (r *TestContext) TestCreatePenaltyExists(t *testing.T) {
+	_, err := r.Repo.CreatePenalty(&Data.PN01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrAlreadyExist, err)
+}
+
+// TestGetPenaltyNotExists executes this test
+func (r *TestContext) TestGetPenaltyNotExists(t *testing.T) {
+	_, err := r.Repo.GetPenalty("notexists")
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestGetPenalties executes this test
+func (r *TestContext) TestGetPenalties(t *testing.T) {
+	Data.PN02.AgreementId = Data.A01.Id
+	p, err := r.Repo.CreatePenalty(&Data.PN02)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	Data.PN02 = *p
+
+	actual, total, err := r.Repo.GetPenalties(model.PenaltyQuery{AgreementId: Data.A01.Id})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(penalties). Expected: %d; Actual: %d", 2, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 2, total)
+	if len(actual) == 2 {
+		assertEquals(t, "Unexpected order. Expected: %v; Actual: %v", Data.PN02.Id, actual[0].Id)
+	}
+
+	actual, _, err = r.Repo.GetPenalties(model.PenaltyQuery{AgreementId: Data.A01.Id, Guarantee: "gt1"})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(penalties). Expected: %d; Actual: %d", 1, len(actual))
+
+	actual, _, err = r.Repo.GetPenalties(model.PenaltyQuery{
+		AgreementId: Data.A01.Id,
+		From:        Data.PN02.Datetime.Add(time.Minute),
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(penalties). Expected: %d; Actual: %d", 1, len(actual))
+
+	actual, total, err = r.Repo.GetPenalties(model.PenaltyQuery{
+		AgreementId: Data.A01.Id,
+		To:          Data.PN02.Datetime.Add(time.Minute),
+		Page:        model.Page{Limit: 5},
+	})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(penalties). Expected: %d; Actual: %d", 1, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 1, total)
+
+	actual, total, err = r.Repo.GetPenalties(model.PenaltyQuery{AgreementId: Data.Anotexists.Id})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(penalties). Expected: %d; Actual: %d", 0, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 0, total)
+}
+
+// TestCreateSubscription executes this test
+func (r *TestContext) TestCreateSubscription(t *testing.T) {
+	s, err := r.Repo.CreateSubscription(&Data.S01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	Data.S01 = *s
+
+	s, err = r.Repo.GetSubscription(Data.S01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected subscription. Expected: %v; Actual: %v", Data.S01.Id, s.Id)
+	assertEquals(t, "Unexpected subscription. Expected: %v; Actual: %v", Data.S01.Url, s.Url)
+	assertEquals(t, "Unexpected subscription. Expected: %v; Actual: %v", Data.S01.AgreementId, s.AgreementId)
+	assertEquals(t, "Unexpected len(events). Expected: %v; Actual: %v", len(Data.S01.Events), len(s.Events))
+	assertEquals(t, "Unexpected secret. Expected: %v; Actual: %v", Data.S01.Secret, s.Secret)
+}
+
+// TestCreateSubscriptionExists executes this test
+func (r *TestContext) TestCreateSubscriptionExists(t *testing.T) {
+	_, err := r.Repo.CreateSubscription(&Data.S01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrAlreadyExist, err)
+}
+
+// TestGetSubscriptionNotExists executes this test
+func (r *TestContext) TestGetSubscriptionNotExists(t *testing.T) {
+	_, err := r.Repo.GetSubscription(Data.Snotexists.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestGetAllSubscriptions executes this test
+func (r *TestContext) TestGetAllSubscriptions(t *testing.T) {
+	actual, err := r.Repo.GetAllSubscriptions()
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(subscriptions). Expected: %d; Actual: %d", 1, len(actual))
+}
+
+// TestUpdateSubscription executes this test
+func (r *TestContext) TestUpdateSubscription(t *testing.T) {
+	Data.S01.Url = "https://localhost/s01"
+	Data.S01.Events = []model.EventType{model.EXPIRATION}
+
+	_, err := r.Repo.UpdateSubscription(&Data.S01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+
+	s, err := r.Repo.GetSubscription(Data.S01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected url. Expected: %v; Actual: %v", Data.S01.Url, s.Url)
+	assertEquals(t, "Unexpected len(events). Expected: %v; Actual: %v", 1, len(s.Events))
+}
+
+// TestUpdateSubscriptionNotExists executes this test
+func (r *TestContext) TestUpdateSubscriptionNotExists(t *testing.T) {
+	_, err := r.Repo.UpdateSubscription(&Data.Snotexists)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestDeleteSubscription executes this test
+func (r *TestContext) TestDeleteSubscription(t *testing.T) {
+	err := r.Repo.DeleteSubscription(&Data.S01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+
+	_, err = r.Repo.GetSubscription(Data.S01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestDeleteSubscriptionNotExists executes this test
+func (r *TestContext) TestDeleteSubscriptionNotExists(t *testing.T) {
+	err := r.Repo.DeleteSubscription(&Data.Snotexists)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
 // TestCreateTemplate executes this test
 func (r *TestContext) TestCreateTemplate(t *testing.T) {
 	var tpl *model.Template
@@ -348,6 +711,7 @@ func (r *TestContext) TestCreateTemplate(t *testing.T) {
 	tpl, err = r.Repo.GetTemplate(Data.T01.Id)
 	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
 	assertEquals(t, "Unexpected violation. Expected: %v; Actual: %v", Data.T01.Id, tpl.Id)
+	assertEquals(t, "Unexpected version. Expected: %v; Actual: %v", 1, tpl.Version)
 }
 
 // TestCreateTemplateExists executes this test
@@ -363,11 +727,26 @@ func (r *TestContext) TestGetAllTemplates(t *testing.T) {
 	assertEquals(t, "Unexpected len(Templates). Expected: %d; Actual: %d", 1, len(actual))
 }
 
+// TestGetTemplates executes this test
+func (r *TestContext) TestGetTemplates(t *testing.T) {
+	actual, total, err := r.Repo.GetTemplates(model.TemplateQuery{})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(templates). Expected: %d; Actual: %d", 1, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 1, total)
+
+	actual, total, err = r.Repo.GetTemplates(model.TemplateQuery{ProviderId: Data.Pnotexists.Id})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(templates). Expected: %d; Actual: %d", 0, len(actual))
+	assertEquals(t, "Unexpected total. Expected: %d; Actual: %d", 0, total)
+}
+
 // TestGetTemplate executes this test
 func (r *TestContext) TestGetTemplate(t *testing.T) {
 	result, err := r.Repo.GetTemplate(Data.T01.Id)
 	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
 	assertEquals(t, "Unexpected result. Expected: %v; Actual: %v", Data.T01.Id, result.Id)
+	assertEquals(t, "Unexpected parameters. Expected: %v; Actual: %v",
+		fmt.Sprint(Data.T01.Parameters), fmt.Sprint(result.Parameters))
 }
 
 // TestGetTemplateNotExists executes this test
@@ -376,6 +755,143 @@ func (r *TestContext) TestGetTemplateNotExists(t *testing.T) {
 	assertEquals(t, "Expected error: %v; actual: %v", model.ErrNotFound, err)
 }
 
+// TestUpdateTemplate executes this test
+func (r *TestContext) TestUpdateTemplate(t *testing.T) {
+	tpl := Data.T01
+	tpl.State = model.STOPPED
+	_, err := r.Repo.UpdateTemplate(&tpl)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+
+	result, err := r.Repo.GetTemplate(Data.T01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected state. Expected: %v; Actual: %v", model.STOPPED, result.State)
+	assertEquals(t, "Unexpected version. Expected: %v; Actual: %v", 2, result.Version)
+}
+
+// TestGetTemplateVersions executes this test, after TestUpdateTemplate
+func (r *TestContext) TestGetTemplateVersions(t *testing.T) {
+	versions, err := r.Repo.GetTemplateVersions(Data.T01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(versions). Expected: %d; Actual: %d", 2, len(versions))
+	for i, v := range versions {
+		assertEquals(t, "Unexpected version. Expected: %v; Actual: %v", i+1, v.Version)
+	}
+	assertEquals(t, "Unexpected state. Expected: %v; Actual: %v", Data.T01.State, versions[0].State)
+	assertEquals(t, "Unexpected state. Expected: %v; Actual: %v", model.STOPPED, versions[1].State)
+}
+
+// TestGetTemplateVersionsNotExists executes this test
+func (r *TestContext) TestGetTemplateVersionsNotExists(t *testing.T) {
+	_, err := r.Repo.GetTemplateVersions("notexists")
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestUpdateTemplateState executes this test, after TestGetTemplateVersions
+func (r *TestContext) TestUpdateTemplateState(t *testing.T) {
+	updated, err := r.Repo.UpdateTemplateState(Data.T01.Id, model.STARTED)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected state. Expected: %v; Actual: %v", model.STARTED, updated.State)
+	assertEquals(t, "Unexpected version. Expected: %v; Actual: %v", 2, updated.Version)
+
+	stored, err := r.Repo.GetTemplate(Data.T01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected state. Expected: %v; Actual: %v", model.STARTED, stored.State)
+	versions, err := r.Repo.GetTemplateVersions(Data.T01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(versions). Expected: %d; Actual: %d", 2, len(versions))
+}
+
+// TestUpdateTemplateStateNotExists executes this test
+func (r *TestContext) TestUpdateTemplateStateNotExists(t *testing.T) {
+	_, err := r.Repo.UpdateTemplateState("notexists", model.STARTED)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestUpdateTemplateNotExists executes this test
+func (r *TestContext) TestUpdateTemplateNotExists(t *testing.T) {
+	_, err := r.Repo.UpdateTemplate(&model.Template{Id: "notexists", Name: "TemplateNotExists"})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestDeleteTemplate executes this test
+func (r *TestContext) TestDeleteTemplate(t *testing.T) {
+	err := r.Repo.DeleteTemplate(&Data.T01)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+
+	_, err = r.Repo.GetTemplate(Data.T01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestDeletedTemplateVersions executes this test, after TestGetTemplateVersions and
+// TestDeleteTemplate. The revisions of a deleted template are kept, and continue if the
+// template is created again.
+func (r *TestContext) TestDeletedTemplateVersions(t *testing.T) {
+	versions, err := r.Repo.GetTemplateVersions(Data.T01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(versions). Expected: %d; Actual: %d", 2, len(versions))
+
+	recreated := Data.T01
+	_, err = r.Repo.CreateTemplate(&recreated)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected version. Expected: %v; Actual: %v", 3, recreated.Version)
+	versions, err = r.Repo.GetTemplateVersions(Data.T01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(versions). Expected: %d; Actual: %d", 3, len(versions))
+
+	err = r.Repo.DeleteTemplate(&recreated)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+}
+
+// TestDeleteTemplateNotExists executes this test
+func (r *TestContext) TestDeleteTemplateNotExists(t *testing.T) {
+	err := r.Repo.DeleteTemplate(&model.Template{Id: "notexists"})
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+}
+
+// TestTenants executes this test. The repository must implement model.TenantRepository.
+func (r *TestContext) TestTenants(t *testing.T) {
+	_, err := model.ForTenant(r.Repo, "not valid")
+	if err == nil {
+		t.Error("Expected error with invalid tenant")
+	}
+	tr, err := model.ForTenant(r.Repo, "tenant01")
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+
+	a := Data.A01
+	created, err := tr.CreateAgreement(&a)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected tenant. Expected: %v; Actual: %v", "tenant01", created.Tenant)
+
+	all, err := tr.GetAllAgreements()
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(Agreements). Expected: %d; Actual: %d", 1, len(all))
+	_, err = tr.GetAgreement(Data.A03.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+	_, err = tr.GetTemplate(Data.T01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+
+	p := model.Provider{Id: "ptenant01", Name: "Tenant provider"}
+	_, err = tr.CreateProvider(&p)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	providers, err := tr.GetAllProviders()
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected len(Providers). Expected: %d; Actual: %d", 1, len(providers))
+	assertEquals(t, "Unexpected tenant. Expected: %v; Actual: %v", "tenant01", providers[0].Tenant)
+	_, err = r.Repo.GetProvider(p.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", model.ErrNotFound, err)
+
+	tenants, err := model.GetTenants(r.Repo)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected tenants. Expected: %v; Actual: %v",
+		fmt.Sprint([]string{model.DefaultTenant, "tenant01"}), fmt.Sprint(tenants))
+
+	err = tr.DeleteAgreement(&a)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	def, err := r.Repo.GetAgreement(Data.A01.Id)
+	assertEquals(t, "Unexpected error. Expected: %v; Actual: %v", nil, err)
+	assertEquals(t, "Unexpected tenant. Expected: %v; Actual: %v", model.DefaultTenant, def.Tenant)
+}
+
 /*
  * The functions below are kept to maintain backwards compatibility, but should
  * be removed at some point
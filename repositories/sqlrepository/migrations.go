@@ -0,0 +1,292 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sqlrepository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dialect contains the differences in SQL syntax between the supported drivers
+type dialect struct {
+	// jsonType is the column type of JSON encoded values
+	jsonType string
+	// timeType is the column type of datetimes
+	timeType string
+	// unlimited is the LIMIT value to set when only OFFSET is needed
+	unlimited string
+}
+
+var dialects = map[string]dialect{
+	"sqlite3": {
+		jsonType:  "TEXT",
+		timeType:  "TIMESTAMP",
+		unlimited: "-1",
+	},
+	"postgres": {
+		jsonType:  "JSONB",
+		timeType:  "TIMESTAMPTZ",
+		unlimited: "ALL",
+	},
+}
+
+// migrations is the list of changes of the schema. Migration i upgrades the schema to version i+1.
+//
+// Applied migrations must never be modified; schema changes are appended as new migrations.
+// {{.JSON}} and {{.Time}} are replaced by the column types of the dialect.
+var migrations = []string{
+	`CREATE TABLE providers (
+		id VARCHAR(255) PRIMARY KEY,
+		name VARCHAR(255) NOT NULL
+	);
+	CREATE TABLE agreements (
+		id VARCHAR(255) PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		state VARCHAR(32) NOT NULL,
+		provider_id VARCHAR(255) NOT NULL,
+		client_id VARCHAR(255) NOT NULL,
+		creation {{.Time}} NOT NULL,
+		expiration {{.Time}},
+		assessment {{.JSON}},
+		details {{.JSON}} NOT NULL
+	);
+	CREATE INDEX agreements_state ON agreements (state);
+	CREATE INDEX agreements_provider ON agreements (provider_id);
+	CREATE INDEX agreements_client ON agreements (client_id);
+	CREATE TABLE templates (
+		id VARCHAR(255) PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		state VARCHAR(32) NOT NULL,
+		provider_id VARCHAR(255) NOT NULL,
+		creation {{.Time}} NOT NULL,
+		details {{.JSON}} NOT NULL
+	);
+	CREATE TABLE violations (
+		id VARCHAR(255) PRIMARY KEY,
+		agreement_id VARCHAR(255) NOT NULL,
+		guarantee VARCHAR(255) NOT NULL,
+		datetime {{.Time}} NOT NULL,
+		constraint_expr TEXT NOT NULL,
+		metric_values {{.JSON}}
+	);
+	CREATE INDEX violations_agreement ON violations (agreement_id, datetime);
+	CREATE TABLE penalties (
+		id VARCHAR(255) PRIMARY KEY,
+		agreement_id VARCHAR(255) NOT NULL,
+		guarantee VARCHAR(255) NOT NULL,
+		datetime {{.Time}} NOT NULL,
+		definition {{.JSON}} NOT NULL
+	);
+	CREATE INDEX penalties_agreement ON penalties (agreement_id, datetime);`,
+	`ALTER TABLE penalties ADD COLUMN violation_id VARCHAR(255) NOT NULL DEFAULT ''`,
+	`CREATE TABLE subscriptions (
+		id VARCHAR(255) PRIMARY KEY,
+		url TEXT NOT NULL,
+		agreement_id VARCHAR(255) NOT NULL,
+		provider_id VARCHAR(255) NOT NULL,
+		client_id VARCHAR(255) NOT NULL,
+		events {{.JSON}}
+	)`,
+	/* adds the tenant to the primary keys, rebuilding the tables as sqlite can't alter them */
+	`CREATE TABLE providers_tenant (
+		tenant VARCHAR(255) NOT NULL DEFAULT '',
+		id VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		PRIMARY KEY (tenant, id)
+	);
+	INSERT INTO providers_tenant (id, name) SELECT id, name FROM providers;
+	DROP TABLE providers;
+	ALTER TABLE providers_tenant RENAME TO providers;
+	CREATE TABLE agreements_tenant (
+		tenant VARCHAR(255) NOT NULL DEFAULT '',
+		id VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		state VARCHAR(32) NOT NULL,
+		provider_id VARCHAR(255) NOT NULL,
+		client_id VARCHAR(255) NOT NULL,
+		creation {{.Time}} NOT NULL,
+		expiration {{.Time}},
+		assessment {{.JSON}},
+		details {{.JSON}} NOT NULL,
+		PRIMARY KEY (tenant, id)
+	);
+	INSERT INTO agreements_tenant (id, name, state, provider_id, client_id, creation, expiration, assessment, details)
+		SELECT id, name, state, provider_id, client_id, creation, expiration, assessment, details FROM agreements;
+	DROP TABLE agreements;
+	ALTER TABLE agreements_tenant RENAME TO agreements;
+	CREATE INDEX agreements_state ON agreements (tenant, state);
+	CREATE INDEX agreements_provider ON agreements (tenant, provider_id);
+	CREATE INDEX agreements_client ON agreements (tenant, client_id);
+	CREATE TABLE templates_tenant (
+		tenant VARCHAR(255) NOT NULL DEFAULT '',
+		id VARCHAR(255) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		state VARCHAR(32) NOT NULL,
+		provider_id VARCHAR(255) NOT NULL,
+		creation {{.Time}} NOT NULL,
+		details {{.JSON}} NOT NULL,
+		PRIMARY KEY (tenant, id)
+	);
+	INSERT INTO templates_tenant (id, name, state, provider_id, creation, details)
+		SELECT id, name, state, provider_id, creation, details FROM templates;
+	DROP TABLE templates;
+	ALTER TABLE templates_tenant RENAME TO templates;
+	CREATE TABLE violations_tenant (
+		tenant VARCHAR(255) NOT NULL DEFAULT '',
+		id VARCHAR(255) NOT NULL,
+		agreement_id VARCHAR(255) NOT NULL,
+		guarantee VARCHAR(255) NOT NULL,
+		datetime {{.Time}} NOT NULL,
+		constraint_expr TEXT NOT NULL,
+		metric_values {{.JSON}},
+		PRIMARY KEY (tenant, id)
+	);
+	INSERT INTO violations_tenant (id, agreement_id, guarantee, datetime, constraint_expr, metric_values)
+		SELECT id, agreement_id, guarantee, datetime, constraint_expr, metric_values FROM violations;
+	DROP TABLE violations;
+	ALTER TABLE violations_tenant RENAME TO violations;
+	CREATE INDEX violations_agreement ON violations (tenant, agreement_id, datetime);
+	CREATE TABLE penalties_tenant (
+		tenant VARCHAR(255) NOT NULL DEFAULT '',
+		id VARCHAR(255) NOT NULL,
+		agreement_id VARCHAR(255) NOT NULL,
+		guarantee VARCHAR(255) NOT NULL,
+		datetime {{.Time}} NOT NULL,
+		definition {{.JSON}} NOT NULL,
+		violation_id VARCHAR(255) NOT NULL DEFAULT '',
+		PRIMARY KEY (tenant, id)
+	);
+	INSERT INTO penalties_tenant (id, agreement_id, guarantee, datetime, definition, violation_id)
+		SELECT id, agreement_id, guarantee, datetime, definition, violation_id FROM penalties;
+	DROP TABLE penalties;
+	ALTER TABLE penalties_tenant RENAME TO penalties;
+	CREATE INDEX penalties_agreement ON penalties (tenant, agreement_id, datetime);
+	CREATE TABLE subscriptions_tenant (
+		tenant VARCHAR(255) NOT NULL DEFAULT '',
+		id VARCHAR(255) NOT NULL,
+		url TEXT NOT NULL,
+		agreement_id VARCHAR(255) NOT NULL,
+		provider_id VARCHAR(255) NOT NULL,
+		client_id VARCHAR(255) NOT NULL,
+		events {{.JSON}},
+		PRIMARY KEY (tenant, id)
+	);
+	INSERT INTO subscriptions_tenant (id, url, agreement_id, provider_id, client_id, events)
+		SELECT id, url, agreement_id, provider_id, client_id, events FROM subscriptions;
+	DROP TABLE subscriptions;
+	ALTER TABLE subscriptions_tenant RENAME TO subscriptions`,
+	/* keeps the revisions of the templates, and the template revision of the agreements */
+	`ALTER TABLE templates ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+	CREATE TABLE template_versions (
+		tenant VARCHAR(255) NOT NULL DEFAULT '',
+		id VARCHAR(255) NOT NULL,
+		version INTEGER NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		state VARCHAR(32) NOT NULL,
+		provider_id VARCHAR(255) NOT NULL,
+		creation {{.Time}} NOT NULL,
+		details {{.JSON}} NOT NULL,
+		PRIMARY KEY (tenant, id, version)
+	);
+	INSERT INTO template_versions (tenant, id, version, name, state, provider_id, creation, details)
+		SELECT tenant, id, version, name, state, provider_id, creation, details FROM templates;
+	ALTER TABLE agreements ADD COLUMN template_id VARCHAR(255);
+	ALTER TABLE agreements ADD COLUMN template_version INTEGER`,
+	`ALTER TABLE templates ADD COLUMN parameters {{.JSON}};
+	ALTER TABLE template_versions ADD COLUMN parameters {{.JSON}}`,
+	`CREATE TABLE warnings (
+		tenant VARCHAR(255) NOT NULL DEFAULT '',
+		id VARCHAR(255) NOT NULL,
+		agreement_id VARCHAR(255) NOT NULL,
+		guarantee VARCHAR(255) NOT NULL,
+		datetime {{.Time}} NOT NULL,
+		expression TEXT NOT NULL,
+		metric_values {{.JSON}},
+		PRIMARY KEY (tenant, id)
+	);
+	CREATE INDEX warnings_agreement ON warnings (tenant, agreement_id, datetime)`,
+	`ALTER TABLE subscriptions ADD COLUMN secret VARCHAR(255) NOT NULL DEFAULT ''`,
+}
+
+// statements returns the statements of a migration for a dialect
+func (d dialect) statements(migration string) []string {
+	migration = strings.NewReplacer("{{.JSON}}", d.jsonType, "{{.Time}}", d.timeType).Replace(migration)
+
+	result := make([]string, 0)
+	for _, stmt := range strings.Split(migration, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			result = append(result, stmt)
+		}
+	}
+	return result
+}
+
+// migrate applies the migrations that are not applied yet in the database.
+// Each migration is applied in its own transaction.
+func migrate(db *sql.DB, d dialect) error {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)")
+	if err != nil {
+		return err
+	}
+
+	var version int
+	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		log.Infof("Migrating SQL schema to version %d", i+1)
+		if err := applyMigration(db, d, i); err != nil {
+			return fmt.Errorf("Error migrating schema to version %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, d dialect, i int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, stmt := range d.statements(migrations[i]) {
+		if _, err = tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err = tx.Exec("INSERT INTO schema_version (version) VALUES ($1)", i+1); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// dropAll removes all the tables of the schema
+func dropAll(db *sql.DB) error {
+	tables := []string{"subscriptions", "penalties", "warnings", "violations", "template_versions", "templates",
+		"agreements", "providers", "schema_version"}
+	for _, table := range tables {
+		if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,1198 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package sqlrepository is an implementation of a model.IRepository backed up by a
+relational database, accessed through database/sql.
+
+The supported drivers are sqlite3 and postgres. The schema is created and upgraded
+on startup (see migrations.go). The fields that are used in queries are stored in
+their own columns; the rest of the entity (e.g. agreement details and assessment)
+is stored in JSON columns.
+
+The rows of all the tables have a tenant column, which every query filters by the
+tenant of the repository (see ForTenant).
+*/
+package sqlrepository
+
+import (
+	"SLALite/model"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	// Registers the postgres driver
+	_ "github.com/lib/pq"
+	// Registers the sqlite3 driver
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/viper"
+)
+
+const (
+	// Name is the unique identifier of this repository
+	Name string = "sql"
+
+	defaultDriver     string = "sqlite3"
+	defaultDatasource string = "slalite.sqlite"
+
+	sqlConfigName string = "sql.yml"
+
+	driverPropertyName     = "driver"
+	datasourcePropertyName = "datasource"
+	clearOnBoot            = "clear_on_boot"
+
+	agreementColumns = "id, name, state, assessment, details, tenant, template_id, template_version"
+	templateColumns  = "id, name, state, version, details, tenant, parameters"
+	violationColumns = "id, agreement_id, guarantee, datetime, constraint_expr, metric_values, tenant"
+	warningColumns   = "id, agreement_id, guarantee, datetime, expression, metric_values, tenant"
+	penaltyColumns   = "id, agreement_id, guarantee, violation_id, datetime, definition, tenant"
+
+	subscriptionColumns = "id, url, agreement_id, provider_id, client_id, events, secret, tenant"
+)
+
+// SQLRepository contains the repository persistence implementation based on a SQL database
+type SQLRepository struct {
+	db      *sql.DB
+	dialect dialect
+	// tenant is the tenant of the rows read and written by this repository
+	tenant string
+}
+
+// NewDefaultConfig gets a default configuration for a SQLRepository
+func NewDefaultConfig() (*viper.Viper, error) {
+	config := viper.New()
+
+	config.SetEnvPrefix("sla") // Env vars start with 'SLA_'
+	config.AutomaticEnv()
+	config.SetConfigName(sqlConfigName)
+	config.AddConfigPath(model.UnixConfigPath)
+	setDefaults(config)
+
+	confError := config.ReadInConfig()
+	if confError != nil {
+		log.Println("Can't find SQL configuration file: " + confError.Error())
+		log.Println("Using defaults")
+	}
+
+	return config, confError
+}
+
+func setDefaults(config *viper.Viper) {
+	config.SetDefault(driverPropertyName, defaultDriver)
+	config.SetDefault(datasourcePropertyName, defaultDatasource)
+	config.SetDefault(clearOnBoot, false)
+}
+
+// New creates a new instance of the SQLRepository with the database configuration read from a configuration file.
+// The schema is migrated to the last version if needed.
+func New(config *viper.Viper) (SQLRepository, error) {
+	if config == nil {
+		config, _ = NewDefaultConfig()
+	} else {
+		setDefaults(config)
+	}
+
+	logConfig(config)
+
+	var repo SQLRepository
+	driver := config.GetString(driverPropertyName)
+	d, ok := dialects[driver]
+	if !ok {
+		return repo, fmt.Errorf("Not supported SQL driver '%s'", driver)
+	}
+
+	db, err := sql.Open(driver, config.GetString(datasourcePropertyName))
+	if err != nil {
+		return repo, err
+	}
+	if driver == "sqlite3" {
+		// sqlite does not support concurrent writers
+		db.SetMaxOpenConns(1)
+	}
+	repo.db = db
+	repo.dialect = d
+
+	if config.GetBool(clearOnBoot) {
+		err = dropAll(db)
+	}
+	if err == nil {
+		err = migrate(db, d)
+	}
+	if err != nil {
+		db.Close()
+	}
+	return repo, err
+}
+
+func logConfig(config *viper.Viper) {
+	log.Printf("SQL configuration\n"+
+		"\tdriver: %v\n"+
+		"\tclear on boot: %v\n",
+		config.GetString(driverPropertyName),
+		config.GetBool(clearOnBoot))
+}
+
+// Check implements health.Checker, returning an error if the database is not reachable
+func (r SQLRepository) Check() error {
+	return r.db.Ping()
+}
+
+// Close closes the database
+func (r SQLRepository) Close() error {
+	return r.db.Close()
+}
+
+// ForTenant returns a repository of the rows of tenant, which shares the database of r.
+func (r SQLRepository) ForTenant(tenant string) (model.IRepository, error) {
+	if err := model.ValidateTenant(tenant); err != nil {
+		return nil, err
+	}
+	r.tenant = tenant
+	return r, nil
+}
+
+// GetTenants returns the tenants that have rows in the database, sorted by id.
+func (r SQLRepository) GetTenants() ([]string, error) {
+	rows, err := r.db.Query("SELECT tenant FROM providers UNION SELECT tenant FROM agreements UNION " +
+		"SELECT tenant FROM templates UNION SELECT tenant FROM subscriptions ORDER BY tenant")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []string{model.DefaultTenant}
+	for rows.Next() {
+		var tenant string
+		if err := rows.Scan(&tenant); err != nil {
+			return nil, err
+		}
+		if tenant != model.DefaultTenant {
+			result = append(result, tenant)
+		}
+	}
+	return result, rows.Err()
+}
+
+// scanner is implemented by sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// conditions builds the WHERE clause of a query
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+// add appends a clause where each %s is replaced by the placeholder of the corresponding value
+func (c *conditions) add(clause string, values ...interface{}) {
+	placeholders := make([]interface{}, len(values))
+	for i, v := range values {
+		c.args = append(c.args, v)
+		placeholders[i] = fmt.Sprintf("$%d", len(c.args))
+	}
+	c.clauses = append(c.clauses, fmt.Sprintf(clause, placeholders...))
+}
+
+// addIn appends a clause that checks that column is one of values
+func (c *conditions) addIn(column string, values []interface{}) {
+	format := make([]string, len(values))
+	for i := range values {
+		format[i] = "%s"
+	}
+	c.add(column+" IN ("+strings.Join(format, ", ")+")", values...)
+}
+
+// addInterval appends the clauses that check that column is in the interval [from, to)
+func (c *conditions) addInterval(column string, from, to time.Time) {
+	if !from.IsZero() {
+		c.add(column+" >= %s", from.UTC())
+	}
+	if !to.IsZero() {
+		c.add(column+" < %s", to.UTC())
+	}
+}
+
+// where returns the conditions of a query, initially filtering by the tenant of r
+func (r SQLRepository) where() *conditions {
+	c := &conditions{}
+	c.add("tenant = %s", r.tenant)
+	return c
+}
+
+func (c *conditions) String() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// sortColumns are the names of the columns to sort by
+var sortColumns = map[string]string{
+	model.FieldId:         "id",
+	model.FieldName:       "name",
+	model.FieldState:      "state",
+	model.FieldProvider:   "provider_id",
+	model.FieldClient:     "client_id",
+	model.FieldCreation:   "creation",
+	model.FieldExpiration: "expiration",
+}
+
+// orderBy returns the ORDER BY clause of a model.Sort, adding the id as last key
+func orderBy(keys model.Sort) string {
+	parts := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		column := sortColumns[key.Field]
+		if key.Desc {
+			column = column + " DESC"
+		}
+		parts = append(parts, column)
+	}
+	parts = append(parts, "id")
+	return " ORDER BY " + strings.Join(parts, ", ")
+}
+
+// limit returns the LIMIT/OFFSET clause of a page
+func (r SQLRepository) limit(page model.Page) string {
+	result := ""
+	if page.Limit > 0 {
+		result = fmt.Sprintf(" LIMIT %d", page.Limit)
+	} else if page.Offset > 0 {
+		result = " LIMIT " + r.dialect.unlimited
+	}
+	if page.Offset > 0 {
+		result += fmt.Sprintf(" OFFSET %d", page.Offset)
+	}
+	return result
+}
+
+// list executes a query, calling scan on each row, and returns the total number of rows
+// that match the conditions (regardless of the paging).
+func (r SQLRepository) list(table, columns string, where *conditions, order string,
+	page model.Page, scan func(s scanner) error) (int, error) {
+
+	var total int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM "+table+where.String(), where.args...).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "SELECT " + columns + " FROM " + table + where.String() + order + r.limit(page)
+	rows, err := r.db.Query(query, where.args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return 0, err
+		}
+	}
+	return total, rows.Err()
+}
+
+// querier is implemented by sql.DB and sql.Tx
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// transaction calls f within a transaction, which is committed if f succeeds
+func (r SQLRepository) transaction(f func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := f(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r SQLRepository) insert(table, id string, columns []string, values ...interface{}) error {
+	return r.transaction(func(tx *sql.Tx) error {
+		return r.insertIn(tx, table, id, columns, values...)
+	})
+}
+
+// insertIn inserts a row in table, unless there is already a row with the same id
+func (r SQLRepository) insertIn(q querier, table, id string, columns []string, values ...interface{}) error {
+	var count int
+	err := q.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE tenant = $1 AND id = $2", r.tenant, id).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return model.ErrAlreadyExist
+	}
+	return r.insertRow(q, table, columns, values...)
+}
+
+// insertRow inserts a row in table, assigned to the tenant of r
+func (r SQLRepository) insertRow(q querier, table string, columns []string, values ...interface{}) error {
+	columns = append(columns[:len(columns):len(columns)], "tenant")
+	values = append(values, r.tenant)
+	placeholders := make([]string, len(values))
+	for i := range values {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	_, err := q.Exec(stmt, values...)
+	return err
+}
+
+func (r SQLRepository) update(table, id string, columns []string, values ...interface{}) error {
+	return r.updateIn(r.db, table, id, columns, values...)
+}
+
+func (r SQLRepository) updateIn(q querier, table, id string, columns []string, values ...interface{}) error {
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = fmt.Sprintf("%s = $%d", column, i+1)
+	}
+	stmt := fmt.Sprintf("UPDATE %s SET %s WHERE tenant = $%d AND id = $%d",
+		table, strings.Join(assignments, ", "), len(columns)+1, len(columns)+2)
+	res, err := q.Exec(stmt, append(values, r.tenant, id)...)
+	return checkAffected(res, err)
+}
+
+func (r SQLRepository) delete(table, id string) error {
+	return r.deleteIn(r.db, table, id)
+}
+
+func (r SQLRepository) deleteIn(q querier, table, id string) error {
+	res, err := q.Exec("DELETE FROM "+table+" WHERE tenant = $1 AND id = $2", r.tenant, id)
+	return checkAffected(res, err)
+}
+
+func checkAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		err = model.ErrNotFound
+	}
+	return err
+}
+
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return model.ErrNotFound
+	}
+	return err
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func nullableTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+/*
+GetAllProviders returns the list of providers.
+
+The list is empty when there are no providers;
+error != nil on error
+*/
+func (r SQLRepository) GetAllProviders() (model.Providers, error) {
+	result, _, err := r.GetProviders(model.ProviderQuery{})
+	return result, err
+}
+
+/*
+GetProviders returns the page of providers specified in the query, and the total
+number of providers.
+
+The list is empty when there are no providers in the page;
+error != nil on error
+*/
+func (r SQLRepository) GetProviders(q model.ProviderQuery) (model.Providers, int, error) {
+	result := make(model.Providers, 0)
+
+	total, err := r.list("providers", "id, name, tenant", r.where(), orderBy(q.Sort), q.Page,
+		func(s scanner) error {
+			var p model.Provider
+			err := s.Scan(&p.Id, &p.Name, &p.Tenant)
+			result = append(result, p)
+			return err
+		})
+	return result, total, err
+}
+
+/*
+GetProvider returns the Provider identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the provider is not found
+*/
+func (r SQLRepository) GetProvider(id string) (*model.Provider, error) {
+	p := new(model.Provider)
+	err := r.db.QueryRow("SELECT id, name, tenant FROM providers WHERE tenant = $1 AND id = $2", r.tenant, id).
+		Scan(&p.Id, &p.Name, &p.Tenant)
+	return p, notFound(err)
+}
+
+/*
+CreateProvider stores a new provider.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the provider already exists
+*/
+func (r SQLRepository) CreateProvider(provider *model.Provider) (*model.Provider, error) {
+	provider.Tenant = r.tenant
+	err := r.insert("providers", provider.Id, []string{"id", "name"}, provider.Id, provider.Name)
+	return provider, err
+}
+
+/*
+DeleteProvider deletes from the repository the provider whose id is provider.Id.
+
+error != nil on error;
+error is model.ErrNotFound if the provider does not exist.
+*/
+func (r SQLRepository) DeleteProvider(provider *model.Provider) error {
+	return r.delete("providers", provider.Id)
+}
+
+func scanAgreement(s scanner) (*model.Agreement, error) {
+	var a model.Agreement
+	var assessment sql.NullString
+	var details string
+	var templateID sql.NullString
+	var templateVersion sql.NullInt64
+
+	err := s.Scan(&a.Id, &a.Name, &a.State, &assessment, &details, &a.Tenant, &templateID, &templateVersion)
+	if err != nil {
+		return nil, err
+	}
+	if templateID.Valid {
+		a.Template = &model.TemplateRef{Id: templateID.String, Version: int(templateVersion.Int64)}
+	}
+	if assessment.Valid {
+		if err = json.Unmarshal([]byte(assessment.String), &a.Assessment); err != nil {
+			return nil, err
+		}
+	}
+	err = json.Unmarshal([]byte(details), &a.Details)
+	return &a, err
+}
+
+// agreementValues returns the values of the columns of an agreement, in the order of
+// agreementTableColumns
+func agreementValues(a *model.Agreement) ([]interface{}, error) {
+	assessment, err := toJSON(a.Assessment)
+	if err != nil {
+		return nil, err
+	}
+	details, err := toJSON(a.Details)
+	if err != nil {
+		return nil, err
+	}
+	var templateID, templateVersion interface{}
+	if a.Template != nil {
+		templateID, templateVersion = a.Template.Id, a.Template.Version
+	}
+	return []interface{}{
+		a.Id, a.Name, a.State, a.Details.Provider.Id, a.Details.Client.Id,
+		a.Details.Creation.UTC(), nullableTime(a.Details.Expiration), assessment, details,
+		templateID, templateVersion,
+	}, nil
+}
+
+var agreementTableColumns = []string{
+	"id", "name", "state", "provider_id", "client_id", "creation", "expiration", "assessment", "details",
+	"template_id", "template_version",
+}
+
+/*
+GetAllAgreements returns the list of agreements.
+
+The list is empty when there are no agreements;
+error != nil on error
+*/
+func (r SQLRepository) GetAllAgreements() (model.Agreements, error) {
+	result, _, err := r.GetAgreements(model.AgreementQuery{})
+	return result, err
+}
+
+/*
+GetAgreements returns the page of agreements that match the query, and the total
+number of matching agreements.
+
+The list is empty when there are no matching agreements in the page;
+error != nil on error
+*/
+func (r SQLRepository) GetAgreements(q model.AgreementQuery) (model.Agreements, int, error) {
+	result := make(model.Agreements, 0)
+
+	where := r.where()
+	if q.ProviderId != "" {
+		where.add("provider_id = %s", q.ProviderId)
+	}
+	if q.ClientId != "" {
+		where.add("client_id = %s", q.ClientId)
+	}
+	if len(q.States) > 0 {
+		where.addIn("state", statesToValues(q.States))
+	}
+	where.addInterval("creation", q.CreationFrom, q.CreationTo)
+	where.addInterval("expiration", q.ExpirationFrom, q.ExpirationTo)
+
+	total, err := r.list("agreements", agreementColumns, where, orderBy(q.Sort), q.Page,
+		func(s scanner) error {
+			a, err := scanAgreement(s)
+			if err == nil {
+				result = append(result, *a)
+			}
+			return err
+		})
+	return result, total, err
+}
+
+func statesToValues(states []model.State) []interface{} {
+	result := make([]interface{}, len(states))
+	for i, state := range states {
+		result[i] = string(state)
+	}
+	return result
+}
+
+/*
+GetAgreementsByState returns the agreements that have one of the items in states.
+
+error != nil on error;
+*/
+func (r SQLRepository) GetAgreementsByState(states ...model.State) (model.Agreements, error) {
+	if len(states) == 0 {
+		return make(model.Agreements, 0), nil
+	}
+	result, _, err := r.GetAgreements(model.AgreementQuery{States: states})
+	return result, err
+}
+
+/*
+GetAgreement returns the Agreement identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Agreement is not found
+*/
+func (r SQLRepository) GetAgreement(id string) (*model.Agreement, error) {
+	row := r.db.QueryRow("SELECT "+agreementColumns+" FROM agreements WHERE tenant = $1 AND id = $2", r.tenant, id)
+	a, err := scanAgreement(row)
+	if a == nil {
+		a = new(model.Agreement)
+	}
+	return a, notFound(err)
+}
+
+/*
+CreateAgreement stores a new Agreement.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Agreement already exists
+*/
+func (r SQLRepository) CreateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	agreement.Tenant = r.tenant
+	values, err := agreementValues(agreement)
+	if err != nil {
+		return agreement, err
+	}
+	err = r.insert("agreements", agreement.Id, agreementTableColumns, values...)
+	return agreement, err
+}
+
+/*
+UpdateAgreement updates the information of an already saved instance of an agreement
+*/
+func (r SQLRepository) UpdateAgreement(agreement *model.Agreement) (*model.Agreement, error) {
+	agreement.Tenant = r.tenant
+	values, err := agreementValues(agreement)
+	if err != nil {
+		return agreement, err
+	}
+	err = r.update("agreements", agreement.Id, agreementTableColumns[1:], values[1:]...)
+	return agreement, err
+}
+
+/*
+UpdateAgreementState transits the state of the agreement
+*/
+func (r SQLRepository) UpdateAgreementState(id string, newState model.State) (*model.Agreement, error) {
+	err := r.update("agreements", id, []string{"state"}, string(newState))
+	if err != nil {
+		return nil, err
+	}
+	return r.GetAgreement(id)
+}
+
+/*
+DeleteAgreement deletes from the repository the Agreement whose id is agreement.Id.
+
+error != nil on error;
+error is model.ErrNotFound if the Agreement does not exist.
+*/
+func (r SQLRepository) DeleteAgreement(agreement *model.Agreement) error {
+	return r.delete("agreements", agreement.Id)
+}
+
+func scanViolation(s scanner) (*model.Violation, error) {
+	var v model.Violation
+	var values sql.NullString
+
+	err := s.Scan(&v.Id, &v.AgreementId, &v.Guarantee, &v.Datetime, &v.Constraint, &values, &v.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	if values.Valid {
+		err = json.Unmarshal([]byte(values.String), &v.Values)
+	}
+	return &v, err
+}
+
+/*
+CreateViolation stores a new Violation.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Violation already exists
+*/
+func (r SQLRepository) CreateViolation(v *model.Violation) (*model.Violation, error) {
+	v.Tenant = r.tenant
+	values, err := toJSON(v.Values)
+	if err != nil {
+		return v, err
+	}
+	err = r.insert("violations", v.Id,
+		[]string{"id", "agreement_id", "guarantee", "datetime", "constraint_expr", "metric_values"},
+		v.Id, v.AgreementId, v.Guarantee, v.Datetime.UTC(), v.Constraint, values)
+	return v, err
+}
+
+/*
+GetViolation returns the Violation identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Violation is not found
+*/
+func (r SQLRepository) GetViolation(id string) (*model.Violation, error) {
+	row := r.db.QueryRow("SELECT "+violationColumns+" FROM violations WHERE tenant = $1 AND id = $2", r.tenant, id)
+	v, err := scanViolation(row)
+	if v == nil {
+		v = new(model.Violation)
+	}
+	return v, notFound(err)
+}
+
+/*
+GetViolations returns the page of violations that match the query, sorted by datetime,
+and the total number of matching violations.
+
+The list is empty when there are no matching violations in the page;
+error != nil on error
+*/
+func (r SQLRepository) GetViolations(q model.ViolationQuery) (model.Violations, int, error) {
+	result := make(model.Violations, 0)
+
+	where := r.where()
+	if q.AgreementId != "" {
+		where.add("agreement_id = %s", q.AgreementId)
+	}
+	if q.Guarantee != "" {
+		where.add("guarantee = %s", q.Guarantee)
+	}
+	where.addInterval("datetime", q.From, q.To)
+
+	total, err := r.list("violations", violationColumns, where, " ORDER BY datetime, id", q.Page,
+		func(s scanner) error {
+			v, err := scanViolation(s)
+			if err == nil {
+				result = append(result, *v)
+			}
+			return err
+		})
+	return result, total, err
+}
+
+func scanWarning(s scanner) (*model.Warning, error) {
+	var w model.Warning
+	var values sql.NullString
+
+	err := s.Scan(&w.Id, &w.AgreementId, &w.Guarantee, &w.Datetime, &w.Expression, &values, &w.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	if values.Valid {
+		err = json.Unmarshal([]byte(values.String), &w.Values)
+	}
+	return &w, err
+}
+
+/*
+CreateWarning stores a new Warning.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Warning already exists
+*/
+func (r SQLRepository) CreateWarning(w *model.Warning) (*model.Warning, error) {
+	w.Tenant = r.tenant
+	values, err := toJSON(w.Values)
+	if err != nil {
+		return w, err
+	}
+	err = r.insert("warnings", w.Id,
+		[]string{"id", "agreement_id", "guarantee", "datetime", "expression", "metric_values"},
+		w.Id, w.AgreementId, w.Guarantee, w.Datetime.UTC(), w.Expression, values)
+	return w, err
+}
+
+/*
+GetWarning returns the Warning identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Warning is not found
+*/
+func (r SQLRepository) GetWarning(id string) (*model.Warning, error) {
+	row := r.db.QueryRow("SELECT "+warningColumns+" FROM warnings WHERE tenant = $1 AND id = $2", r.tenant, id)
+	w, err := scanWarning(row)
+	if w == nil {
+		w = new(model.Warning)
+	}
+	return w, notFound(err)
+}
+
+/*
+GetWarnings returns the page of warnings that match the query, sorted by datetime,
+and the total number of matching warnings.
+
+The list is empty when there are no matching warnings in the page;
+error != nil on error
+*/
+func (r SQLRepository) GetWarnings(q model.WarningQuery) (model.Warnings, int, error) {
+	result := make(model.Warnings, 0)
+
+	where := r.where()
+	if q.AgreementId != "" {
+		where.add("agreement_id = %s", q.AgreementId)
+	}
+	if q.Guarantee != "" {
+		where.add("guarantee = %s", q.Guarantee)
+	}
+	where.addInterval("datetime", q.From, q.To)
+
+	total, err := r.list("warnings", warningColumns, where, " ORDER BY datetime, id", q.Page,
+		func(s scanner) error {
+			w, err := scanWarning(s)
+			if err == nil {
+				result = append(result, *w)
+			}
+			return err
+		})
+	return result, total, err
+}
+
+func scanPenalty(s scanner) (*model.Penalty, error) {
+	var p model.Penalty
+	var definition string
+
+	err := s.Scan(&p.Id, &p.AgreementId, &p.Guarantee, &p.ViolationId, &p.Datetime, &definition, &p.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(definition), &p.Definition)
+	return &p, err
+}
+
+/*
+CreatePenalty stores a new Penalty.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Penalty already exists
+*/
+func (r SQLRepository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+	p.Tenant = r.tenant
+	definition, err := toJSON(p.Definition)
+	if err != nil {
+		return p, err
+	}
+	err = r.insert("penalties", p.Id,
+		[]string{"id", "agreement_id", "guarantee", "violation_id", "datetime", "definition"},
+		p.Id, p.AgreementId, p.Guarantee, p.ViolationId, p.Datetime.UTC(), definition)
+	return p, err
+}
+
+/*
+GetPenalty returns the Penalty identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Penalty is not found
+*/
+func (r SQLRepository) GetPenalty(id string) (*model.Penalty, error) {
+	row := r.db.QueryRow("SELECT "+penaltyColumns+" FROM penalties WHERE tenant = $1 AND id = $2", r.tenant, id)
+	p, err := scanPenalty(row)
+	if p == nil {
+		p = new(model.Penalty)
+	}
+	return p, notFound(err)
+}
+
+/*
+GetPenalties returns the page of penalties that match the query, sorted by datetime,
+and the total number of matching penalties.
+
+The list is empty when there are no matching penalties in the page;
+error != nil on error
+*/
+func (r SQLRepository) GetPenalties(q model.PenaltyQuery) (model.Penalties, int, error) {
+	result := make(model.Penalties, 0)
+
+	where := r.where()
+	if q.AgreementId != "" {
+		where.add("agreement_id = %s", q.AgreementId)
+	}
+	if q.Guarantee != "" {
+		where.add("guarantee = %s", q.Guarantee)
+	}
+	where.addInterval("datetime", q.From, q.To)
+
+	total, err := r.list("penalties", penaltyColumns, where, " ORDER BY datetime, id", q.Page,
+		func(s scanner) error {
+			p, err := scanPenalty(s)
+			if err == nil {
+				result = append(result, *p)
+			}
+			return err
+		})
+	return result, total, err
+}
+
+func scanTemplate(s scanner) (*model.Template, error) {
+	var t model.Template
+	var details string
+	var parameters sql.NullString
+
+	err := s.Scan(&t.Id, &t.Name, &t.State, &t.Version, &details, &t.Tenant, &parameters)
+	if err != nil {
+		return nil, err
+	}
+	if parameters.Valid {
+		if err = json.Unmarshal([]byte(parameters.String), &t.Parameters); err != nil {
+			return nil, err
+		}
+	}
+	err = json.Unmarshal([]byte(details), &t.Details)
+	return &t, err
+}
+
+/*
+GetAllTemplates returns the list of templates.
+
+The list is empty when there are no templates;
+error != nil on error
+*/
+func (r SQLRepository) GetAllTemplates() (model.Templates, error) {
+	result, _, err := r.GetTemplates(model.TemplateQuery{})
+	return result, err
+}
+
+/*
+GetTemplates returns the page of templates that match the query, and the total
+number of matching templates.
+
+The list is empty when there are no matching templates in the page;
+error != nil on error
+*/
+func (r SQLRepository) GetTemplates(q model.TemplateQuery) (model.Templates, int, error) {
+	result := make(model.Templates, 0)
+
+	where := r.where()
+	if q.ProviderId != "" {
+		where.add("provider_id = %s", q.ProviderId)
+	}
+	if len(q.States) > 0 {
+		where.addIn("state", statesToValues(q.States))
+	}
+
+	total, err := r.list("templates", templateColumns, where, orderBy(q.Sort), q.Page,
+		func(s scanner) error {
+			t, err := scanTemplate(s)
+			if err == nil {
+				result = append(result, *t)
+			}
+			return err
+		})
+	return result, total, err
+}
+
+/*
+GetTemplate returns the Template identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Template is not found
+*/
+func (r SQLRepository) GetTemplate(id string) (*model.Template, error) {
+	row := r.db.QueryRow("SELECT "+templateColumns+" FROM templates WHERE tenant = $1 AND id = $2", r.tenant, id)
+	t, err := scanTemplate(row)
+	if t == nil {
+		t = new(model.Template)
+	}
+	return t, notFound(err)
+}
+
+/*
+CreateTemplate stores a new Template, with version 1, or the next version of the
+revisions of a deleted Template with the same id.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Template already exists
+*/
+func (r SQLRepository) CreateTemplate(template *model.Template) (*model.Template, error) {
+	template.Tenant = r.tenant
+	err := r.transaction(func(tx *sql.Tx) error {
+		var version int
+		err := tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM template_versions WHERE tenant = $1 AND id = $2",
+			r.tenant, template.Id).Scan(&version)
+		if err != nil {
+			return err
+		}
+		template.Version = version + 1
+		values, err := templateValues(template)
+		if err != nil {
+			return err
+		}
+		if err := r.insertIn(tx, "templates", template.Id, templateTableColumns, values...); err != nil {
+			return err
+		}
+		return r.insertRow(tx, "template_versions", templateTableColumns, values...)
+	})
+	return template, err
+}
+
+/*
+UpdateTemplate updates the information of an already saved instance of a template,
+storing it as a new revision with the next version.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist
+*/
+func (r SQLRepository) UpdateTemplate(template *model.Template) (*model.Template, error) {
+	template.Tenant = r.tenant
+	err := r.transaction(func(tx *sql.Tx) error {
+		var version int
+		err := tx.QueryRow("SELECT version FROM templates WHERE tenant = $1 AND id = $2",
+			r.tenant, template.Id).Scan(&version)
+		if err != nil {
+			return notFound(err)
+		}
+		template.Version = version + 1
+		values, err := templateValues(template)
+		if err != nil {
+			return err
+		}
+		if err := r.updateIn(tx, "templates", template.Id, templateTableColumns[1:], values[1:]...); err != nil {
+			return err
+		}
+		return r.insertRow(tx, "template_versions", templateTableColumns, values...)
+	})
+	return template, err
+}
+
+/*
+DeleteTemplate deletes from the repository the Template whose id is template.Id.
+Its revisions are kept.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist.
+*/
+func (r SQLRepository) DeleteTemplate(template *model.Template) error {
+	return r.delete("templates", template.Id)
+}
+
+/*
+GetTemplateVersions returns the revisions of the Template identified by id,
+sorted by version, even if the Template has been deleted.
+
+error != nil on error;
+error is model.ErrNotFound if the Template never existed
+*/
+func (r SQLRepository) GetTemplateVersions(id string) (model.Templates, error) {
+	result := make(model.Templates, 0)
+
+	where := r.where()
+	where.add("id = %s", id)
+	total, err := r.list("template_versions", templateColumns, where, " ORDER BY version", model.Page{},
+		func(s scanner) error {
+			t, err := scanTemplate(s)
+			if err == nil {
+				result = append(result, *t)
+			}
+			return err
+		})
+	if err == nil && total == 0 {
+		err = model.ErrNotFound
+	}
+	return result, err
+}
+
+/*
+UpdateTemplateState changes the state of a Template, without storing a new revision.
+
+error != nil on error;
+error is model.ErrNotFound if the Template does not exist
+*/
+func (r SQLRepository) UpdateTemplateState(id string, newState model.State) (*model.Template, error) {
+	err := r.update("templates", id, []string{"state"}, string(newState))
+	if err != nil {
+		return nil, err
+	}
+	return r.GetTemplate(id)
+}
+
+// templateValues returns the values of the columns of a template, in the order of
+// templateTableColumns
+func templateValues(t *model.Template) ([]interface{}, error) {
+	details, err := toJSON(t.Details)
+	if err != nil {
+		return nil, err
+	}
+	parameters, err := toJSON(t.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		t.Id, t.Name, string(t.State), t.Version, t.Details.Provider.Id, t.Details.Creation.UTC(), details,
+		parameters,
+	}, nil
+}
+
+var templateTableColumns = []string{
+	"id", "name", "state", "version", "provider_id", "creation", "details", "parameters",
+}
+
+func scanSubscription(s scanner) (*model.Subscription, error) {
+	var sub model.Subscription
+	var events string
+
+	err := s.Scan(&sub.Id, &sub.Url, &sub.AgreementId, &sub.ProviderId, &sub.ClientId, &events, &sub.Secret,
+		&sub.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal([]byte(events), &sub.Events)
+	return &sub, err
+}
+
+// subscriptionValues returns the values of the columns of a subscription, in the order of
+// subscriptionTableColumns
+func subscriptionValues(s *model.Subscription) ([]interface{}, error) {
+	events, err := toJSON(s.Events)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{s.Id, s.Url, s.AgreementId, s.ProviderId, s.ClientId, events, s.Secret}, nil
+}
+
+var subscriptionTableColumns = []string{"id", "url", "agreement_id", "provider_id", "client_id", "events", "secret"}
+
+/*
+GetAllSubscriptions returns the list of subscriptions.
+
+The list is empty when there are no subscriptions;
+error != nil on error
+*/
+func (r SQLRepository) GetAllSubscriptions() (model.Subscriptions, error) {
+	result := make(model.Subscriptions, 0)
+
+	_, err := r.list("subscriptions", subscriptionColumns, r.where(), " ORDER BY id", model.Page{},
+		func(s scanner) error {
+			sub, err := scanSubscription(s)
+			if err == nil {
+				result = append(result, *sub)
+			}
+			return err
+		})
+	return result, err
+}
+
+/*
+GetSubscription returns the Subscription identified by id.
+
+error != nil on error;
+error is model.ErrNotFound if the Subscription is not found
+*/
+func (r SQLRepository) GetSubscription(id string) (*model.Subscription, error) {
+	row := r.db.QueryRow("SELECT "+subscriptionColumns+" FROM subscriptions WHERE tenant = $1 AND id = $2", r.tenant, id)
+	sub, err := scanSubscription(row)
+	if sub == nil {
+		sub = new(model.Subscription)
+	}
+	return sub, notFound(err)
+}
+
+/*
+CreateSubscription stores a new Subscription.
+
+error != nil on error;
+error is model.ErrAlreadyExist if the Subscription already exists
+*/
+func (r SQLRepository) CreateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	s.Tenant = r.tenant
+	values, err := subscriptionValues(s)
+	if err != nil {
+		return s, err
+	}
+	err = r.insert("subscriptions", s.Id, subscriptionTableColumns, values...)
+	return s, err
+}
+
+/*
+UpdateSubscription updates an already saved Subscription.
+
+error != nil on error;
+error is model.ErrNotFound if the Subscription does not exist
+*/
+func (r SQLRepository) UpdateSubscription(s *model.Subscription) (*model.Subscription, error) {
+	s.Tenant = r.tenant
+	values, err := subscriptionValues(s)
+	if err != nil {
+		return s, err
+	}
+	err = r.update("subscriptions", s.Id, subscriptionTableColumns[1:], values[1:]...)
+	return s, err
+}
+
+/*
+DeleteSubscription deletes from the repository the Subscription whose id is s.Id.
+
+error != nil on error;
+error is model.ErrNotFound if the Subscription does not exist.
+*/
+func (r SQLRepository) DeleteSubscription(s *model.Subscription) error {
+	return r.delete("subscriptions", s.Id)
+}
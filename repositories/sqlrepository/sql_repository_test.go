@@ -0,0 +1,165 @@
+/*
+Copyright 2020 Atos
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+This tests the SQL repository, making use of the repository_testbase file.
+
+By default, the test uses a sqlite database in a temporary directory. To test
+against a PostgreSQL database, set the env vars SLA_DRIVER=postgres and
+SLA_DATASOURCE=<connection string>.
+*/
+
+package sqlrepository
+
+import (
+	"SLALite/model"
+	"SLALite/repositories"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var repo model.IRepository
+
+func TestMain(m *testing.M) {
+	result := -1
+
+	dir, err := ioutil.TempDir("", "slalite-sql")
+	if err != nil {
+		log.Fatal("Error creating temporary directory: ", err.Error())
+	}
+
+	sqlRepo, err := createRepository(filepath.Join(dir, "test.sqlite"))
+	if err == nil {
+		repo = sqlRepo
+		result = m.Run()
+		sqlRepo.Close()
+	} else {
+		log.Error("Error creating repository: ", err.Error())
+	}
+
+	os.RemoveAll(dir)
+	os.Exit(result)
+}
+
+func createRepository(path string) (SQLRepository, error) {
+	config := viper.New()
+	config.SetEnvPrefix("sla")
+	config.AutomaticEnv()
+	if !config.IsSet("datasource") {
+		config.Set("datasource", path)
+	}
+	config.Set("clear_on_boot", true)
+	return New(config)
+}
+
+func TestMigrate(t *testing.T) {
+	sqlRepo := repo.(SQLRepository)
+
+	// migrating an up-to-date schema is a no-op
+	if err := migrate(sqlRepo.db, sqlRepo.dialect); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var version int
+	if err := sqlRepo.db.QueryRow("SELECT MAX(version) FROM schema_version").Scan(&version); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if version != len(migrations) {
+		t.Errorf("Unexpected schema version. Expected: %d; Actual: %d", len(migrations), version)
+	}
+}
+
+func TestRepository(t *testing.T) {
+	ctx := repositories.TestContext{Repo: repo}
+	/* Providers */
+	t.Run("CreateProvider", ctx.TestCreateProvider)
+	t.Run("CreateProviderExists", ctx.TestCreateProviderExists)
+	t.Run("GetAllProviders", ctx.TestGetAllProviders)
+	t.Run("GetProviders", ctx.TestGetProviders)
+	t.Run("GetProvider", ctx.TestGetProvider)
+	t.Run("GetProviderNotExists", ctx.TestGetProviderNotExists)
+	t.Run("DeleteProvider", ctx.TestDeleteProvider)
+	t.Run("DeleteProviderNotExists", ctx.TestDeleteProviderNotExists)
+
+	/* Agreements */
+	t.Run("CreateAgreement", ctx.TestCreateAgreement)
+	t.Run("CreateAgreementExists", ctx.TestCreateAgreementExists)
+	t.Run("GetAllAgreements", ctx.TestGetAllAgreements)
+	t.Run("GetAgreement", ctx.TestGetAgreement)
+	t.Run("GetAgreementNotExists", ctx.TestGetAgreementNotExists)
+	t.Run("UpdateAgreementState", ctx.TestUpdateAgreementState)
+	t.Run("UpdateAgreementStateNotExists", ctx.TestUpdateAgreementStateNotExists)
+	t.Run("GetAgreementsByState", ctx.TestGetAgreementsByState)
+	t.Run("GetAgreements", ctx.TestGetAgreements)
+	t.Run("UpdateAgreement", ctx.TestUpdateAgreement)
+	t.Run("UpdateAgreementNotExists", ctx.TestUpdateAgreementNotExists)
+	t.Run("DeleteAgreement", ctx.TestDeleteAgreement)
+	t.Run("DeleteAgreementNotExists", ctx.TestDeleteAgreementNotExists)
+
+	/* Violations */
+	t.Run("CreateViolation", ctx.TestCreateViolation)
+	t.Run("CreateViolationExists", ctx.TestCreateViolationExists)
+
+	t.Run("GetViolation", ctx.TestGetViolation)
+	t.Run("GetViolationNotExists", ctx.TestGetViolationNotExists)
+	t.Run("GetViolations", ctx.TestGetViolations)
+
+	/* Warnings */
+	t.Run("CreateWarning", ctx.TestCreateWarning)
+	t.Run("CreateWarningExists", ctx.TestCreateWarningExists)
+	t.Run("GetWarningNotExists", ctx.TestGetWarningNotExists)
+	t.Run("GetWarnings", ctx.TestGetWarnings)
+
+	/* Penalties */
+	t.Run("CreatePenalty", ctx.TestCreatePenalty)
+	t.Run("CreatePenaltyExists", ctx.TestCreatePenaltyExists)
+	t.Run("GetPenaltyNotExists", ctx.TestGetPenaltyNotExists)
+	t.Run("GetPenalties", ctx.TestGetPenalties)
+
+	/* Subscriptions */
+	t.Run("CreateSubscription", ctx.TestCreateSubscription)
+	t.Run("CreateSubscriptionExists", ctx.TestCreateSubscriptionExists)
+	t.Run("GetSubscriptionNotExists", ctx.TestGetSubscriptionNotExists)
+	t.Run("GetAllSubscriptions", ctx.TestGetAllSubscriptions)
+	t.Run("UpdateSubscription", ctx.TestUpdateSubscription)
+	t.Run("UpdateSubscriptionNotExists", ctx.TestUpdateSubscriptionNotExists)
+	t.Run("DeleteSubscription", ctx.TestDeleteSubscription)
+	t.Run("DeleteSubscriptionNotExists", ctx.TestDeleteSubscriptionNotExists)
+
+	/* Templates */
+	t.Run("CreateTemplate", ctx.TestCreateTemplate)
+	t.Run("CreateTemplateExists", ctx.TestCreateTemplateExists)
+	t.Run("GetAllTemplates", ctx.TestGetAllTemplates)
+	t.Run("GetTemplates", ctx.TestGetTemplates)
+	t.Run("GetTemplate", ctx.TestGetTemplate)
+	t.Run("GetTemplateNotExists", ctx.TestGetTemplateNotExists)
+	t.Run("UpdateTemplate", ctx.TestUpdateTemplate)
+	t.Run("UpdateTemplateNotExists", ctx.TestUpdateTemplateNotExists)
+	t.Run("GetTemplateVersions", ctx.TestGetTemplateVersions)
+	t.Run("GetTemplateVersionsNotExists", ctx.TestGetTemplateVersionsNotExists)
+	t.Run("UpdateTemplateState", ctx.TestUpdateTemplateState)
+	t.Run("UpdateTemplateStateNotExists", ctx.TestUpdateTemplateStateNotExists)
+	t.Run("DeleteTemplate", ctx.TestDeleteTemplate)
+	t.Run("DeletedTemplateVersions", ctx.TestDeletedTemplateVersions)
+	t.Run("DeleteTemplateNotExists", ctx.TestDeleteTemplateNotExists)
+
+	/* Tenants */
+	t.Run("Tenants", ctx.TestTenants)
+}
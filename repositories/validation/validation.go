@@ -27,6 +27,7 @@ limitations under the License.
 package validation
 
 import (
+	"SLALite/health"
 	"SLALite/model"
 	"bytes"
 	"fmt"
@@ -70,12 +71,39 @@ func New(backend model.IRepository, val model.Validator) (model.IRepository, err
 	}, nil
 }
 
+// Check checks the backend, if it implements health.Checker.
+func (r repository) Check() error {
+	if c, ok := r.backend.(health.Checker); ok {
+		return c.Check()
+	}
+	return nil
+}
+
+// ForTenant returns the validating repository of tenant in the backend (see model.ForTenant).
+func (r repository) ForTenant(tenant string) (model.IRepository, error) {
+	backend, err := model.ForTenant(r.backend, tenant)
+	if err != nil {
+		return nil, err
+	}
+	return New(backend, r.val)
+}
+
+// GetTenants returns the tenants of the backend (see model.GetTenants).
+func (r repository) GetTenants() ([]string, error) {
+	return model.GetTenants(r.backend)
+}
+
 // GetAllProviders gets all providers.
 func (r repository) GetAllProviders() (model.Providers, error) {
 	return r.backend.GetAllProviders()
 }
 
-// GetProviders get a provider.
+// GetProviders gets a page of providers.
+func (r repository) GetProviders(q model.ProviderQuery) (model.Providers, int, error) {
+	return r.backend.GetProviders(q)
+}
+
+// GetProvider get a provider.
 func (r repository) GetProvider(id string) (*model.Provider, error) {
 	return r.backend.GetProvider(id)
 }
@@ -101,6 +129,11 @@ func (r repository) GetAllAgreements() (model.Agreements, error) {
 	return r.backend.GetAllAgreements()
 }
 
+// GetAgreements gets the agreements that match the query.
+func (r repository) GetAgreements(q model.AgreementQuery) (model.Agreements, int, error) {
+	return r.backend.GetAgreements(q)
+}
+
 // GetAgreement gets an agreement by id
 func (r repository) GetAgreement(id string) (*model.Agreement, error) {
 	return r.backend.GetAgreement(id)
@@ -154,6 +187,86 @@ func (r repository) GetViolation(id string) (*model.Violation, error) {
 	return r.backend.GetViolation(id)
 }
 
+// GetViolations returns the violations that match the query.
+func (r repository) GetViolations(q model.ViolationQuery) (model.Violations, int, error) {
+	return r.backend.GetViolations(q)
+}
+
+// CreateWarning validates and persists a new Warning.
+func (r repository) CreateWarning(w *model.Warning) (*model.Warning, error) {
+
+	if errs := w.Validate(r.val, model.CREATE); len(errs) > 0 {
+		err := newValError(errs)
+		return w, err
+	}
+	return r.backend.CreateWarning(w)
+}
+
+// GetWarning returns the Warning identified by id.
+func (r repository) GetWarning(id string) (*model.Warning, error) {
+	return r.backend.GetWarning(id)
+}
+
+// GetWarnings returns the warnings that match the query.
+func (r repository) GetWarnings(q model.WarningQuery) (model.Warnings, int, error) {
+	return r.backend.GetWarnings(q)
+}
+
+// CreatePenalty validates and persists a new Penalty.
+func (r repository) CreatePenalty(p *model.Penalty) (*model.Penalty, error) {
+
+	if errs := p.Validate(r.val, model.CREATE); len(errs) > 0 {
+		err := newValError(errs)
+		return p, err
+	}
+	return r.backend.CreatePenalty(p)
+}
+
+// GetPenalty returns the Penalty identified by id.
+func (r repository) GetPenalty(id string) (*model.Penalty, error) {
+	return r.backend.GetPenalty(id)
+}
+
+// GetPenalties returns the penalties that match the query.
+func (r repository) GetPenalties(q model.PenaltyQuery) (model.Penalties, int, error) {
+	return r.backend.GetPenalties(q)
+}
+
+// GetAllSubscriptions returns the list of subscriptions.
+func (r repository) GetAllSubscriptions() (model.Subscriptions, error) {
+	return r.backend.GetAllSubscriptions()
+}
+
+// GetSubscription returns the Subscription identified by id.
+func (r repository) GetSubscription(id string) (*model.Subscription, error) {
+	return r.backend.GetSubscription(id)
+}
+
+// CreateSubscription validates and persists a new Subscription.
+func (r repository) CreateSubscription(s *model.Subscription) (*model.Subscription, error) {
+
+	if errs := s.Validate(r.val, model.CREATE); len(errs) > 0 {
+		err := newValError(errs)
+		return s, err
+	}
+	return r.backend.CreateSubscription(s)
+}
+
+// UpdateSubscription validates and updates a Subscription.
+func (r repository) UpdateSubscription(s *model.Subscription) (*model.Subscription, error) {
+
+	if errs := s.Validate(r.val, model.UPDATE); len(errs) > 0 {
+		err := newValError(errs)
+		return s, err
+	}
+	return r.backend.UpdateSubscription(s)
+}
+
+// DeleteSubscription deletes a Subscription.
+func (r repository) DeleteSubscription(s *model.Subscription) error {
+	return r.backend.DeleteSubscription(s)
+}
+
 // UpdateAgreement changes the state of an Agreement.
 func (r repository) UpdateAgreementState(id string, newState model.State) (*model.Agreement, error) {
 	var err error
@@ -177,6 +290,11 @@ func (r repository) GetAllTemplates() (model.Templates, error) {
 	return r.backend.GetAllTemplates()
 }
 
+// GetTemplates gets the templates that match the query.
+func (r repository) GetTemplates(q model.TemplateQuery) (model.Templates, int, error) {
+	return r.backend.GetTemplates(q)
+}
+
 // GetTemplate gets an template by id
 func (r repository) GetTemplate(id string) (*model.Template, error) {
 	return r.backend.GetTemplate(id)
@@ -190,3 +308,31 @@ func (r repository) CreateTemplate(template *model.Template) (*model.Template, e
 	}
 	return r.backend.CreateTemplate(template)
 }
+
+// UpdateTemplate validates and updates a template.
+func (r repository) UpdateTemplate(template *model.Template) (*model.Template, error) {
+	if errs := template.Validate(r.val, model.UPDATE); len(errs) > 0 {
+		err := newValError(errs)
+		return template, err
+	}
+	return r.backend.UpdateTemplate(template)
+}
+
+// DeleteTemplate deletes a template from repository.
+func (r repository) DeleteTemplate(template *model.Template) error {
+	return r.backend.DeleteTemplate(template)
+}
+
+// GetTemplateVersions gets the revisions of a template.
+func (r repository) GetTemplateVersions(id string) (model.Templates, error) {
+	return r.backend.GetTemplateVersions(id)
+}
+
+// UpdateTemplateState changes the state of a Template, that must be started or stopped.
+func (r repository) UpdateTemplateState(id string, newState model.State) (*model.Template, error) {
+	newState = newState.Normalize()
+	if newState != model.STARTED && newState != model.STOPPED {
+		return nil, &valError{msg: fmt.Sprintf("Not valid state %s for template %s", newState, id)}
+	}
+	return r.backend.UpdateTemplateState(id, newState)
+}
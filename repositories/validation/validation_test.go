@@ -43,15 +43,28 @@ func TestCallThroughMethods(t *testing.T) {
 
 	v.GetProvider("id")
 	v.GetAllProviders()
+	v.GetProviders(model.ProviderQuery{})
 	v.GetAgreement("id")
 	v.GetAllAgreements()
+	v.GetAgreements(model.AgreementQuery{})
 	v.GetAgreementsByState()
 	v.GetViolation("id")
+	v.GetViolations(model.ViolationQuery{})
+	v.GetWarning("id")
+	v.GetWarnings(model.WarningQuery{})
+	v.GetPenalty("id")
+	v.GetPenalties(model.PenaltyQuery{})
+	v.GetSubscription("id")
+	v.GetAllSubscriptions()
+	v.CreateSubscription(&model.Subscription{Id: "s01", Url: "http://localhost"})
+	v.UpdateSubscription(&model.Subscription{Id: "s01", Url: "wrong"})
+	v.DeleteSubscription(&model.Subscription{Id: "s01"})
 	v.CreateAgreement(a)
 	v.UpdateAgreement(a)
 	v.UpdateAgreementState(a.Id, model.TERMINATED)
 	v.UpdateAgreementState(a.Id, model.STARTED)
 	v.GetAllTemplates()
+	v.GetTemplates(model.TemplateQuery{})
 	v.GetTemplate("id")
 	v.CreateTemplate(tpl)
 }
@@ -31,12 +31,43 @@ const (
 	// DefaultExternalIDs is the default value of externalIDs
 	DefaultExternalIDs bool = true
 
+	// DefaultNotifiers is the default value of notifiers
+	DefaultNotifiers string = "log"
+
+	// DefaultEventsBuffer is the default number of events kept to resume the event streams
+	DefaultEventsBuffer int = 1000
+
 	// CheckPeriodPropertyName is the name of the property CheckPeriod
 	CheckPeriodPropertyName = "checkPeriod"
 
 	// RepositoryTypePropertyName is the name of the property repository type
 	RepositoryTypePropertyName = "repository"
 
+	// AdapterPropertyName is the name of the property monitoring adapter type.
+	// If not set, the cimi adapter is used with the cimi repository, and the
+	// agreements are not assessed otherwise.
+	AdapterPropertyName = "adapter"
+
+	// RetrieverPropertyName is the name of the property that sets where the generic
+	// adapter retrieves the metric values from (prometheus or file)
+	RetrieverPropertyName = "retriever"
+
+	// PrometheusURLPropertyName is the name of the property that contains the base URL
+	// of the Prometheus server of the prometheus retriever
+	PrometheusURLPropertyName = "prometheusUrl"
+
+	// MetricsFilePropertyName is the name of the property that contains the path of
+	// the file of the file retriever
+	MetricsFilePropertyName = "metricsFile"
+
+	// NotifiersPropertyName is the name of the property that contains the list
+	// (or comma separated string) of violation notifiers
+	NotifiersPropertyName = "notifiers"
+
+	// EventsBufferPropertyName is the name of the property that contains the number of
+	// assessment events kept to resume the event streams (see Last-Event-ID)
+	EventsBufferPropertyName = "eventsBuffer"
+
 	// ExternalIDsPropertyName is a boolean value that indicates if the used repository
 	// auto assigns the ID of entities when they are stored on repository
 	ExternalIDsPropertyName = "externalIDs"
@@ -21,9 +21,11 @@ package utils
 
 import (
 	"SLALite/model"
+	"SLALite/repositories/bolt"
 	"SLALite/repositories/cimi"
 	"SLALite/repositories/memrepository"
 	"SLALite/repositories/mongodb"
+	"SLALite/repositories/sqlrepository"
 	"SLALite/repositories/validation"
 	"os"
 	"strings"
@@ -59,6 +61,28 @@ func createRepository(repoType string) model.IRepository {
 			log.Fatal("Error creating mongo repository: ", errMongo.Error())
 		}
 		repo = mongoRepo
+	case bolt.Name:
+		config := viper.New()
+		config.Set("database", "test.db")
+		config.Set("clear_on_boot", true)
+		boltRepo, errBolt := bolt.New(config)
+		if errBolt != nil {
+			log.Fatal("Error creating bolt repository: ", errBolt.Error())
+		}
+		repo = boltRepo
+	case sqlrepository.Name:
+		config := viper.New()
+		config.SetEnvPrefix(ConfigPrefix) // Env vars start with 'SLA_'
+		config.AutomaticEnv()
+		if !config.IsSet("datasource") {
+			config.Set("datasource", "test.sqlite")
+		}
+		config.Set("clear_on_boot", true)
+		sqlRepo, errSQL := sqlrepository.New(config)
+		if errSQL != nil {
+			log.Fatal("Error creating SQL repository: ", errSQL.Error())
+		}
+		repo = sqlRepo
 	case cimi.Name:
 		config := viper.New()
 		config.SetEnvPrefix(ConfigPrefix) // Env vars start with 'SLA_'